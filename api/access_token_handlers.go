@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pdkovacs/igo-repo/domain"
+	"github.com/pdkovacs/igo-repo/security/authr"
+	"github.com/pdkovacs/igo-repo/services"
+	log "github.com/sirupsen/logrus"
+)
+
+type CreateAccessTokenRequestData struct {
+	Label       string               `json:"label"`
+	Permissions []authr.PermissionID `json:"permissions"`
+	ExpiresAt   time.Time            `json:"expiresAt"`
+}
+
+// CreateAccessTokenResponse carries the secret of the new token, which is not retrievable later
+type CreateAccessTokenResponse struct {
+	domain.AccessToken
+	Token string `json:"token"`
+}
+
+func createAccessTokenHandler(accessTokenService *services.AccessTokenService) func(c *gin.Context) {
+	logger := log.WithField("prefix", "createAccessTokenHandler")
+	return func(c *gin.Context) {
+		session := MustGetUserSession(c)
+
+		// A leaked token shouldn't be usable to mint further tokens
+		if isAuthenticatedWithAccessToken(c) {
+			logger.Infof("%v tried to create an access token using an access token", session.UserInfo.UserId)
+			c.AbortWithStatus(403)
+			return
+		}
+
+		jsonData, readBodyErr := io.ReadAll(c.Request.Body)
+		if readBodyErr != nil {
+			logger.Errorf("failed to read body: %v", readBodyErr)
+			c.AbortWithStatus(400)
+			return
+		}
+		requestData := CreateAccessTokenRequestData{}
+		unmarshalErr := json.Unmarshal(jsonData, &requestData)
+		if unmarshalErr != nil {
+			logger.Infof("failed to parse request body: %v", unmarshalErr)
+			c.AbortWithStatus(400)
+			return
+		}
+
+		token, secret, serviceError := accessTokenService.CreateAccessToken(requestData.Label, requestData.Permissions, requestData.ExpiresAt, session.UserInfo)
+		if serviceError != nil {
+			if errors.Is(serviceError, services.ErrInvalidAccessTokenRequest) {
+				logger.Infof("invalid access token request: %v", serviceError)
+				c.AbortWithStatus(400)
+				return
+			}
+			if errors.Is(serviceError, authr.ErrPermission) {
+				logger.Infof("access token with permissions beyond those of the user requested: %v", serviceError)
+				c.AbortWithStatus(403)
+				return
+			}
+			logger.Errorf("failed to create access token: %v", serviceError)
+			c.AbortWithStatus(500)
+			return
+		}
+		c.JSON(201, CreateAccessTokenResponse{AccessToken: token, Token: secret})
+	}
+}
+
+func listAccessTokensHandler(accessTokenService *services.AccessTokenService) func(c *gin.Context) {
+	logger := log.WithField("prefix", "listAccessTokensHandler")
+	return func(c *gin.Context) {
+		session := MustGetUserSession(c)
+		tokens, serviceError := accessTokenService.ListAccessTokens(session.UserInfo)
+		if serviceError != nil {
+			logger.Errorf("failed to list access tokens: %v", serviceError)
+			c.AbortWithStatus(500)
+			return
+		}
+		c.JSON(200, tokens)
+	}
+}
+
+func revokeAccessTokenHandler(accessTokenService *services.AccessTokenService) func(c *gin.Context) {
+	logger := log.WithField("prefix", "revokeAccessTokenHandler")
+	return func(c *gin.Context) {
+		session := MustGetUserSession(c)
+		id, parseErr := strconv.ParseInt(c.Param("id"), 10, 64)
+		if parseErr != nil {
+			logger.Infof("invalid access token id %s: %v", c.Param("id"), parseErr)
+			c.AbortWithStatus(400)
+			return
+		}
+		serviceError := accessTokenService.RevokeAccessToken(id, session.UserInfo)
+		if serviceError != nil {
+			if errors.Is(serviceError, domain.ErrAccessTokenNotFound) {
+				logger.Infof("access token %d not found: %v", id, serviceError)
+				c.AbortWithStatus(404)
+				return
+			}
+			logger.Errorf("failed to revoke access token %d: %v", id, serviceError)
+			c.AbortWithStatus(500)
+			return
+		}
+		c.Status(204)
+	}
+}
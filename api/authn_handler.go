@@ -9,30 +9,33 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-// OIDCConfig holds the configuration for the OIDCConfig authentication scheme
-type OIDCConfig struct{}
-
 const UserKey = "igo-user"
 
 type SessionData struct {
 	UserInfo services.UserInfo
 }
 
-func MustGetUserSession(c *gin.Context) SessionData {
+// getUserSession returns the data of the authenticated user either from the request context
+// (where stateless authentication schemes put it) or from the session
+func getUserSession(c *gin.Context) (SessionData, interface{}, bool) {
+	if user, authenticatedByRequest := c.Get(UserKey); authenticatedByRequest {
+		userSession, ok := user.(SessionData)
+		return userSession, user, ok
+	}
 	session := sessions.Default(c)
 	user := session.Get(UserKey)
-	if userSession, ok := user.(SessionData); ok {
+	userSession, ok := user.(SessionData)
+	return userSession, user, ok
+}
+
+func MustGetUserSession(c *gin.Context) SessionData {
+	userSession, user, ok := getUserSession(c)
+	if ok {
 		return userSession
 	}
 	panic(fmt.Errorf("unexpected user session type: %T", user))
 }
 
-func oidcScheme(c *gin.Context) {
-	session := sessions.Default(c)
-	fmt.Printf("OIDC authentication: session: %v\n", session)
-	c.AbortWithStatus(500)
-}
-
 // Authentication handles authentication
 func Authentication(authnConfig interface{}, userService *services.UserService) gin.HandlerFunc {
 	logger := log.WithField("prefix", "Authentication")
@@ -41,7 +44,30 @@ func Authentication(authnConfig interface{}, userService *services.UserService)
 	case BasicConfig:
 		return basicScheme(authnConfig, userService)
 	case OIDCConfig:
-		return oidcScheme
+		return oidcScheme(authnConfig, userService)
 	}
 	return nil
 }
+
+// LogoutHandler ends the user's session. When a logout URL is specified (the identity provider's,
+// for example), the client is redirected there.
+func LogoutHandler(logoutURL string) gin.HandlerFunc {
+	logger := log.WithField("prefix", "LogoutHandler")
+	return func(c *gin.Context) {
+		session := sessions.Default(c)
+		logger.Infof("%v logging out", session.Get(UserKey))
+		session.Clear()
+		session.Options(sessions.Options{Path: "/", MaxAge: -1})
+		saveErr := session.Save()
+		if saveErr != nil {
+			logger.Errorf("failed to save cleared session: %v", saveErr)
+			c.AbortWithStatus(500)
+			return
+		}
+		if logoutURL != "" {
+			c.Redirect(303, logoutURL)
+			return
+		}
+		c.Status(204)
+	}
+}
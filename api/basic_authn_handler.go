@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/base64"
+	"fmt"
 	"strings"
 
 	"github.com/gin-contrib/sessions"
@@ -9,6 +10,7 @@ import (
 	"github.com/pdkovacs/igo-repo/config"
 	"github.com/pdkovacs/igo-repo/security/authn"
 	"github.com/pdkovacs/igo-repo/services"
+	log "github.com/sirupsen/logrus"
 )
 
 // BasicConfig holds the configuration for the Basic authentication scheme
@@ -16,6 +18,43 @@ type BasicConfig struct {
 	PasswordCredentialsList []config.PasswordCredentials
 }
 
+// NewBasicConfig creates the Basic authentication configuration from the password credentials
+// and the htpasswd file specified in the application options
+func NewBasicConfig(options config.Options) (BasicConfig, error) {
+	logger := log.WithField("prefix", "NewBasicConfig")
+	credentialsList := []config.PasswordCredentials{}
+	for _, pc := range options.PasswordCredentials {
+		if pc.PasswordHash != "" {
+			validationErr := authn.ValidatePasswordHash(pc.PasswordHash)
+			if validationErr != nil {
+				return BasicConfig{}, fmt.Errorf("invalid password hash for %s: %w", pc.Username, validationErr)
+			}
+		} else {
+			logger.Warnf("plaintext password configured for %s", pc.Username)
+		}
+		credentialsList = append(credentialsList, pc)
+	}
+
+	if options.HtpasswdFile != "" {
+		hashes, readErr := authn.ReadHtpasswdFile(options.HtpasswdFile)
+		if readErr != nil {
+			return BasicConfig{}, readErr
+		}
+		for username, hash := range hashes {
+			credentialsList = append(credentialsList, config.PasswordCredentials{Username: username, PasswordHash: hash})
+		}
+	}
+
+	return BasicConfig{PasswordCredentialsList: credentialsList}, nil
+}
+
+func passwordMatches(pc config.PasswordCredentials, password string) bool {
+	if pc.PasswordHash != "" {
+		return authn.VerifyPassword(pc.PasswordHash, password)
+	}
+	return authn.VerifyPlaintextPassword(pc.Password, password)
+}
+
 func decodeBasicAuthnHeaderValue(headerValue string) (userid string, password string, decodeOK bool) {
 	s := strings.SplitN(headerValue, " ", 2)
 	if len(s) != 2 {
@@ -36,6 +75,7 @@ func decodeBasicAuthnHeaderValue(headerValue string) (userid string, password st
 }
 
 func basicScheme(config BasicConfig, userService *services.UserService) gin.HandlerFunc {
+	logger := log.WithField("prefix", "basicScheme")
 	return func(c *gin.Context) {
 		authorized := false
 
@@ -49,11 +89,24 @@ func basicScheme(config BasicConfig, userService *services.UserService) gin.Hand
 				username, password, decodeOK := decodeBasicAuthnHeaderValue(authnHeaderValue[0])
 				if decodeOK {
 					for _, pc := range config.PasswordCredentialsList {
-						if pc.Username == username && pc.Password == password {
+						if pc.Username == username && passwordMatches(pc, password) {
 							userId := authn.LocalDomain.CreateUserID(username)
 							userInfo := userService.GetUserInfo(userId)
+							renewErr := renewSessionID(c)
+							if renewErr != nil {
+								logger.Errorf("%v", renewErr)
+								c.AbortWithStatus(500)
+								return
+							}
 							session.Set(UserKey, SessionData{userInfo})
-							session.Save()
+							// Only sessions just authenticated are saved: saving on every request would write back
+							// sessions revoked in the meantime
+							saveErr := session.Save()
+							if saveErr != nil {
+								logger.Errorf("failed to save session: %v", saveErr)
+								c.AbortWithStatus(500)
+								return
+							}
 							authorized = true
 							break
 						}
@@ -61,7 +114,6 @@ func basicScheme(config BasicConfig, userService *services.UserService) gin.Hand
 				}
 			}
 		}
-		session.Save()
 
 		if authorized {
 			c.Next()
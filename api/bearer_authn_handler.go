@@ -0,0 +1,197 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pdkovacs/igo-repo/config"
+	"github.com/pdkovacs/igo-repo/security/authn"
+	"github.com/pdkovacs/igo-repo/security/authr"
+	"github.com/pdkovacs/igo-repo/services"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultGroupsClaim = "groups"
+
+// BearerConfig holds the configuration for the (stateless) JWT bearer-token authentication scheme
+type BearerConfig struct {
+	Keys        authn.KeySet
+	Issuer      string
+	Audience    string
+	GroupsClaim string
+}
+
+// JWTBearerConfigured tells whether JWT bearer-token authentication has been configured
+func JWTBearerConfigured(options config.Options) bool {
+	return options.JWTBearerPublicKeyPemBase64 != "" || options.JWTBearerJWKSFile != ""
+}
+
+// NewBearerConfig creates the JWT bearer-token authentication configuration from the application options
+func NewBearerConfig(options config.Options) (BearerConfig, error) {
+	keys := authn.KeySet{}
+
+	if options.JWTBearerJWKSFile != "" {
+		var readErr error
+		keys, readErr = authn.ReadJWKSFile(options.JWTBearerJWKSFile)
+		if readErr != nil {
+			return BearerConfig{}, fmt.Errorf("failed to read JWT bearer-token verification keys: %w", readErr)
+		}
+	}
+
+	if options.JWTBearerPublicKeyPemBase64 != "" {
+		pemBytes, decodeErr := base64.StdEncoding.DecodeString(options.JWTBearerPublicKeyPemBase64)
+		if decodeErr != nil {
+			return BearerConfig{}, fmt.Errorf("failed to decode JWT bearer-token verification key: %w", decodeErr)
+		}
+		key, parseErr := authn.ParsePublicKeyPEM(pemBytes)
+		if parseErr != nil {
+			return BearerConfig{}, fmt.Errorf("failed to parse JWT bearer-token verification key: %w", parseErr)
+		}
+		keys[""] = key
+	}
+
+	groupsClaim := options.JWTBearerGroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = defaultGroupsClaim
+	}
+
+	return BearerConfig{
+		Keys:        keys,
+		Issuer:      options.JWTBearerIssuer,
+		Audience:    options.JWTBearerAudience,
+		GroupsClaim: groupsClaim,
+	}, nil
+}
+
+func getBearerToken(c *gin.Context) (string, bool) {
+	authnHeaderValue := c.GetHeader("Authorization")
+	s := strings.SplitN(authnHeaderValue, " ", 2)
+	if len(s) != 2 || !strings.EqualFold(s[0], "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(s[1]), true
+}
+
+func getGroupsFromClaim(claimValue interface{}) []string {
+	groups := []string{}
+	switch value := claimValue.(type) {
+	case string:
+		groups = append(groups, strings.Fields(value)...)
+	case []interface{}:
+		for _, item := range value {
+			if group, ok := item.(string); ok {
+				groups = append(groups, group)
+			}
+		}
+	}
+	return groups
+}
+
+func (bearerConfig BearerConfig) verifyToken(rawToken string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}))
+	_, parseErr := parser.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return bearerConfig.Keys.Lookup(kid)
+	})
+	if parseErr != nil {
+		return claims, fmt.Errorf("invalid bearer token: %w", parseErr)
+	}
+	if _, hasExpiration := claims["exp"]; !hasExpiration {
+		return claims, fmt.Errorf("bearer token without expiration")
+	}
+	if bearerConfig.Issuer != "" && !claims.VerifyIssuer(bearerConfig.Issuer, true) {
+		return claims, fmt.Errorf("unexpected bearer token issuer: %v", claims["iss"])
+	}
+	if bearerConfig.Audience != "" && !claims.VerifyAudience(bearerConfig.Audience, true) {
+		return claims, fmt.Errorf("bearer token not issued for %s, but for %v", bearerConfig.Audience, claims["aud"])
+	}
+	return claims, nil
+}
+
+func (bearerConfig BearerConfig) authenticator(userService *services.UserService) bearerTokenAuthenticator {
+	return func(rawToken string) (services.UserInfo, error) {
+		return bearerConfig.authenticate(rawToken, userService)
+	}
+}
+
+func (bearerConfig BearerConfig) authenticate(rawToken string, userService *services.UserService) (services.UserInfo, error) {
+	claims, verifyErr := bearerConfig.verifyToken(rawToken)
+	if verifyErr != nil {
+		return services.UserInfo{}, verifyErr
+	}
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return services.UserInfo{}, fmt.Errorf("bearer token without subject")
+	}
+	issuer, _ := claims["iss"].(string)
+	userId := authn.IssuerDomain(issuer).CreateUserID(subject)
+	return userService.GetUserInfoWithGroups(userId, getGroupsFromClaim(claims[bearerConfig.GroupsClaim])), nil
+}
+
+// bearerTokenAuthenticator tells who the user presenting the specified bearer token is
+type bearerTokenAuthenticator func(rawToken string) (services.UserInfo, error)
+
+// accessTokenAuthnKey marks requests authenticated with a personal access token
+const accessTokenAuthnKey = "igo-access-token-authn"
+
+func accessTokenAuthenticator(accessTokenService *services.AccessTokenService, userService *services.UserService) bearerTokenAuthenticator {
+	return func(rawToken string) (services.UserInfo, error) {
+		owner, scope, authnErr := accessTokenService.AuthenticateAccessToken(rawToken)
+		if authnErr != nil {
+			return services.UserInfo{}, authnErr
+		}
+		userInfo := userService.GetUserInfo(owner)
+		userInfo.Permissions = authr.RestrictPermissions(userInfo.Permissions, scope)
+		return userInfo, nil
+	}
+}
+
+func isAuthenticatedWithAccessToken(c *gin.Context) bool {
+	return c.GetBool(accessTokenAuthnKey)
+}
+
+// bearerScheme authenticates requests with a bearer token: either a personal access token or, if configured,
+// a JWT. The user information is built for each request and is kept in the request context rather than
+// in the session. Requests without a bearer token are passed over to the session-based authentication scheme, if any,
+// and are rejected otherwise.
+func bearerScheme(jwtAuthenticator bearerTokenAuthenticator, patAuthenticator bearerTokenAuthenticator, sessionScheme gin.HandlerFunc) gin.HandlerFunc {
+	logger := log.WithField("prefix", "bearerScheme")
+	return func(c *gin.Context) {
+		rawToken, hasBearerToken := getBearerToken(c)
+		if !hasBearerToken {
+			if sessionScheme != nil {
+				sessionScheme(c)
+				return
+			}
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatus(401)
+			return
+		}
+
+		var userInfo services.UserInfo
+		var authnErr error
+		isAccessToken := services.IsAccessToken(rawToken)
+		switch {
+		case isAccessToken:
+			userInfo, authnErr = patAuthenticator(rawToken)
+		case jwtAuthenticator != nil:
+			userInfo, authnErr = jwtAuthenticator(rawToken)
+		default:
+			authnErr = fmt.Errorf("no authentication scheme configured for the bearer token")
+		}
+		if authnErr != nil {
+			logger.Infof("bearer token rejected: %v", authnErr)
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.AbortWithStatus(401)
+			return
+		}
+
+		c.Set(UserKey, SessionData{userInfo})
+		c.Set(accessTokenAuthnKey, isAccessToken)
+		c.Next()
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var contentTypesByFormat = map[string]string{
+	"svg":  "image/svg+xml",
+	"png":  "image/png",
+	"jpeg": "image/jpeg",
+	"jpg":  "image/jpeg",
+	"gif":  "image/gif",
+	"webp": "image/webp",
+	"ico":  "image/x-icon",
+}
+
+// iconfileContentType tells the media type of an iconfile, sniffing the content for unusual formats
+func iconfileContentType(format string, content []byte) string {
+	if contentType, ok := contentTypesByFormat[strings.ToLower(format)]; ok {
+		return contentType
+	}
+	return http.DetectContentType(content)
+}
+
+// acceptQuality returns the quality value the Accept header assigns to the specified media type;
+// the most specific matching media range counts
+func acceptQuality(acceptHeader string, mediaType string) float64 {
+	if strings.TrimSpace(acceptHeader) == "" {
+		return 1
+	}
+	mediaMainType := strings.SplitN(mediaType, "/", 2)[0]
+	quality := 0.0
+	specificity := -1
+	for _, mediaRange := range strings.Split(acceptHeader, ",") {
+		rangeType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+		rangeSpecificity := -1
+		switch {
+		case rangeType == mediaType:
+			rangeSpecificity = 2
+		case rangeType == mediaMainType+"/*":
+			rangeSpecificity = 1
+		case rangeType == "*/*":
+			rangeSpecificity = 0
+		}
+		if rangeSpecificity <= specificity {
+			continue
+		}
+		specificity = rangeSpecificity
+		quality = 1
+		if q, ok := params["q"]; ok {
+			parsedQ, parseErr := strconv.ParseFloat(q, 64)
+			if parseErr == nil {
+				quality = parsedQ
+			}
+		}
+	}
+	return quality
+}
+
+// prefersJSON tells whether the client asks rather for JSON than for the content of the specified type
+func prefersJSON(acceptHeader string, contentType string) bool {
+	return acceptQuality(acceptHeader, "application/json") > acceptQuality(acceptHeader, contentType)
+}
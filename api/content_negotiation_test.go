@@ -0,0 +1,33 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type contentNegotiationTestSuite struct {
+	suite.Suite
+}
+
+func TestContentNegotiationTestSuite(t *testing.T) {
+	suite.Run(t, &contentNegotiationTestSuite{})
+}
+
+func (s *contentNegotiationTestSuite) TestServeContentByDefault() {
+	s.False(prefersJSON("", "image/svg+xml"))
+	s.False(prefersJSON("*/*", "image/svg+xml"))
+	s.False(prefersJSON("image/avif,image/webp,*/*;q=0.8", "image/png"))
+}
+
+func (s *contentNegotiationTestSuite) TestServeJSONWhenPreferred() {
+	s.True(prefersJSON("application/json", "image/svg+xml"))
+	s.True(prefersJSON("application/json, */*;q=0.5", "image/png"))
+	s.False(prefersJSON("application/json;q=0.5, image/*", "image/png"))
+}
+
+func (s *contentNegotiationTestSuite) TestMostSpecificRangeCounts() {
+	s.Equal(0.0, acceptQuality("image/png;q=0, image/*", "image/png"))
+	s.Equal(0.3, acceptQuality("text/html, image/*;q=0.3", "image/png"))
+	s.Equal(0.0, acceptQuality("text/html", "image/png"))
+}
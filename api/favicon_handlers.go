@@ -0,0 +1,48 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pdkovacs/igo-repo/domain"
+	"github.com/pdkovacs/igo-repo/services"
+	log "github.com/sirupsen/logrus"
+)
+
+func getFaviconBundleHandler(iconService *services.IconService) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		logger := log.WithField("prefix", "getFaviconBundleHandler")
+		iconName := c.Param("name")
+		options := services.FaviconOptions{
+			Name:            c.Query("appName"),
+			ThemeColor:      c.Query("themeColor"),
+			BackgroundColor: c.Query("backgroundColor"),
+		}
+		bundle, err := iconService.GetFaviconBundle(iconName, options)
+		if err != nil {
+			if errors.Is(err, domain.ErrIconNotFound) || errors.Is(err, domain.ErrIconfileNotFound) {
+				logger.Infof("%v", err)
+				c.AbortWithStatus(404)
+				return
+			}
+			if errors.Is(err, services.ErrInvalidFaviconOptions) {
+				logger.Infof("%v", err)
+				c.AbortWithStatus(400)
+				return
+			}
+			logger.Errorf("failed to build favicon bundle of %s: %v", iconName, err)
+			c.AbortWithStatus(500)
+			return
+		}
+
+		c.Header("Cache-Control", revalidateCacheControl)
+		if isNotModified(c, bundle.Key, time.Time{}) {
+			c.Status(304)
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", bundle.FileName))
+		c.Data(200, "application/zip", bundle.Content)
+	}
+}
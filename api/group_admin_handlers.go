@@ -0,0 +1,126 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pdkovacs/igo-repo/domain"
+	"github.com/pdkovacs/igo-repo/security/authr"
+	"github.com/pdkovacs/igo-repo/services"
+	log "github.com/sirupsen/logrus"
+)
+
+// memberQuery tells whose membership or user information is concerned. User IDs qualified with the issuer of
+// the identity provider may contain slashes, so they cannot be path parameters.
+func memberQuery(c *gin.Context) (string, bool) {
+	userID := c.Query("user")
+	if userID == "" {
+		log.WithField("prefix", "memberQuery").Info("user not specified")
+		c.AbortWithStatus(400)
+		return "", false
+	}
+	return userID, true
+}
+
+func listGroupsHandler(groupAdminService *services.GroupAdminService) func(c *gin.Context) {
+	logger := log.WithField("prefix", "listGroupsHandler")
+	return func(c *gin.Context) {
+		session := MustGetUserSession(c)
+		groups, serviceError := groupAdminService.ListGroups(session.UserInfo)
+		if serviceError != nil {
+			if errors.Is(serviceError, authr.ErrPermission) {
+				logger.Infof("%v", serviceError)
+				c.AbortWithStatus(403)
+				return
+			}
+			logger.Errorf("%v", serviceError)
+			c.AbortWithStatus(500)
+			return
+		}
+		c.JSON(200, groups)
+	}
+}
+
+func addGroupMemberHandler(groupAdminService *services.GroupAdminService) func(c *gin.Context) {
+	logger := log.WithField("prefix", "addGroupMemberHandler")
+	return func(c *gin.Context) {
+		session := MustGetUserSession(c)
+		userID, ok := memberQuery(c)
+		if !ok {
+			return
+		}
+		serviceError := groupAdminService.AddMember(authr.GroupID(c.Param("group")), userID, session.UserInfo)
+		if serviceError != nil {
+			if errors.Is(serviceError, authr.ErrPermission) {
+				logger.Infof("%v", serviceError)
+				c.AbortWithStatus(403)
+				return
+			}
+			if errors.Is(serviceError, domain.ErrGroupNotFound) {
+				logger.Infof("%v", serviceError)
+				c.AbortWithStatus(404)
+				return
+			}
+			logger.Errorf("%v", serviceError)
+			c.AbortWithStatus(500)
+			return
+		}
+		c.Status(204)
+	}
+}
+
+func removeGroupMemberHandler(groupAdminService *services.GroupAdminService) func(c *gin.Context) {
+	logger := log.WithField("prefix", "removeGroupMemberHandler")
+	return func(c *gin.Context) {
+		session := MustGetUserSession(c)
+		userID, ok := memberQuery(c)
+		if !ok {
+			return
+		}
+		serviceError := groupAdminService.RemoveMember(authr.GroupID(c.Param("group")), userID, session.UserInfo)
+		if serviceError != nil {
+			if errors.Is(serviceError, authr.ErrPermission) {
+				logger.Infof("%v", serviceError)
+				c.AbortWithStatus(403)
+				return
+			}
+			if errors.Is(serviceError, domain.ErrGroupNotFound) || errors.Is(serviceError, domain.ErrMembershipNotFound) {
+				logger.Infof("%v", serviceError)
+				c.AbortWithStatus(404)
+				return
+			}
+			if errors.Is(serviceError, services.ErrConfiguredMembership) {
+				logger.Infof("%v", serviceError)
+				c.AbortWithStatus(409)
+				return
+			}
+			logger.Errorf("%v", serviceError)
+			c.AbortWithStatus(500)
+			return
+		}
+		c.Status(204)
+	}
+}
+
+func getEffectiveUserInfoHandler(groupAdminService *services.GroupAdminService) func(c *gin.Context) {
+	logger := log.WithField("prefix", "getEffectiveUserInfoHandler")
+	return func(c *gin.Context) {
+		session := MustGetUserSession(c)
+		userID, ok := memberQuery(c)
+		if !ok {
+			return
+		}
+		userInfo, serviceError := groupAdminService.GetEffectiveUserInfo(userID, session.UserInfo)
+		if serviceError != nil {
+			if errors.Is(serviceError, authr.ErrPermission) {
+				logger.Infof("%v", serviceError)
+				c.AbortWithStatus(403)
+				return
+			}
+			logger.Errorf("%v", serviceError)
+			c.AbortWithStatus(500)
+			return
+		}
+		c.JSON(200, userInfo)
+	}
+}
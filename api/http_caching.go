@@ -0,0 +1,52 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// immutableCacheControl is for responses whose URL changes whenever their content does
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// revalidateCacheControl lets clients cache responses, but have them check with the server before reusing them
+const revalidateCacheControl = "no-cache"
+
+func entityTag(contentHash string) string {
+	return fmt.Sprintf("\"%s\"", contentHash)
+}
+
+// etagMatches tells whether the If-None-Match header lists the entity tag (weak comparison)
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// isNotModified sets the validators of the response and tells whether the conditional request headers
+// allow for responding with 304 Not Modified. If-Modified-Since is considered only in the absence of If-None-Match.
+func isNotModified(c *gin.Context, contentHash string, lastModified time.Time) bool {
+	etag := entityTag(contentHash)
+	c.Header("ETag", etag)
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" {
+		return etagMatches(ifNoneMatch, etag)
+	}
+	if ifModifiedSince := c.GetHeader("If-Modified-Since"); ifModifiedSince != "" && !lastModified.IsZero() {
+		since, parseErr := http.ParseTime(ifModifiedSince)
+		if parseErr == nil {
+			return !lastModified.Truncate(time.Second).After(since)
+		}
+	}
+	return false
+}
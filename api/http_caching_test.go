@@ -0,0 +1,30 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type httpCachingTestSuite struct {
+	suite.Suite
+}
+
+func TestHTTPCachingTestSuite(t *testing.T) {
+	suite.Run(t, &httpCachingTestSuite{})
+}
+
+func (s *httpCachingTestSuite) TestETagMatching() {
+	etag := entityTag("0123abcd")
+	s.True(etagMatches(`"0123abcd"`, etag))
+	s.True(etagMatches(`"other", W/"0123abcd"`, etag))
+	s.True(etagMatches("*", etag))
+	s.False(etagMatches(`"0123abce"`, etag))
+	s.False(etagMatches("", etag))
+}
+
+func (s *httpCachingTestSuite) TestContentHashValidation() {
+	s.True(isContentHash("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"))
+	s.False(isContentHash("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b85"))
+	s.False(isContentHash("../../../../etc/passwd"))
+}
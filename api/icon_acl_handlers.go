@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pdkovacs/igo-repo/domain"
+	"github.com/pdkovacs/igo-repo/security/authr"
+	"github.com/pdkovacs/igo-repo/services"
+	log "github.com/sirupsen/logrus"
+)
+
+func getIconACLHandler(iconService *services.IconService) func(c *gin.Context) {
+	logger := log.WithField("prefix", "getIconACLHandler")
+	return func(c *gin.Context) {
+		iconName := c.Param("name")
+		acl, serviceError := iconService.GetIconACL(iconName)
+		if serviceError != nil {
+			if errors.Is(serviceError, domain.ErrIconNotFound) {
+				logger.Infof("%v", serviceError)
+				c.AbortWithStatus(404)
+				return
+			}
+			logger.Errorf("%v", serviceError)
+			c.AbortWithStatus(500)
+			return
+		}
+		c.JSON(200, acl)
+	}
+}
+
+func setIconACLHandler(iconService *services.IconService) func(c *gin.Context) {
+	logger := log.WithField("prefix", "setIconACLHandler")
+	return func(c *gin.Context) {
+		session := MustGetUserSession(c)
+		iconName := c.Param("name")
+		jsonData, readBodyErr := io.ReadAll(c.Request.Body)
+		if readBodyErr != nil {
+			logger.Errorf("failed to read body: %v", readBodyErr)
+			c.AbortWithStatus(400)
+			return
+		}
+		acl := domain.IconACL{}
+		unmarshalErr := json.Unmarshal(jsonData, &acl)
+		if unmarshalErr != nil {
+			logger.Infof("failed to parse ACL for icon %s: %v", iconName, unmarshalErr)
+			c.AbortWithStatus(400)
+			return
+		}
+		serviceError := iconService.SetIconACL(iconName, acl, session.UserInfo)
+		if serviceError != nil {
+			if errors.Is(serviceError, services.ErrInvalidIconACL) {
+				logger.Infof("%v", serviceError)
+				c.AbortWithStatus(400)
+				return
+			}
+			if errors.Is(serviceError, authr.ErrPermission) {
+				logger.Infof("%v", serviceError)
+				c.AbortWithStatus(403)
+				return
+			}
+			if errors.Is(serviceError, domain.ErrIconNotFound) {
+				logger.Infof("%v", serviceError)
+				c.AbortWithStatus(404)
+				return
+			}
+			logger.Errorf("%v", serviceError)
+			c.AbortWithStatus(500)
+			return
+		}
+		c.Status(204)
+	}
+}
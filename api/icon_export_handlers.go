@@ -0,0 +1,42 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pdkovacs/igo-repo/services"
+	log "github.com/sirupsen/logrus"
+)
+
+func exportIconsHandler(iconService *services.IconService) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		logger := log.WithField("prefix", "exportIconsHandler")
+		filter := services.ExportFilter{
+			Tags:     queryList(c, "tag"),
+			TagMatch: c.Query("tagMatch"),
+			Formats:  queryList(c, "format"),
+			Sizes:    queryList(c, "size"),
+		}
+		icons, err := iconService.SelectIconsToExport(filter)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidIconQuery) {
+				logger.Infof("%v", err)
+				c.AbortWithStatus(400)
+				return
+			}
+			logger.Errorf("%v", err)
+			c.AbortWithStatus(500)
+			return
+		}
+
+		c.Header("Content-Type", "application/zip")
+		c.Header("Content-Disposition", "attachment; filename=\"icons.zip\"")
+		c.Status(200)
+		err = iconService.WriteExport(icons, c.Writer)
+		if err != nil {
+			// The status has been sent already, the client is left with a truncated archive
+			logger.Errorf("failed to export icons: %v", err)
+			c.Abort()
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pdkovacs/igo-repo/services"
+	log "github.com/sirupsen/logrus"
+)
+
+func getIconFontHandler(iconService *services.IconService) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		logger := log.WithField("prefix", "getIconFontHandler")
+		filter := services.FontFilter{
+			Tags:     append(queryList(c, "tags"), queryList(c, "tag")...),
+			TagMatch: c.Query("tagMatch"),
+			Size:     c.Query("size"),
+			Family:   c.Query("family"),
+		}
+		font, err := iconService.GetIconFont(filter)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidIconQuery) || errors.Is(err, services.ErrInvalidFontFamily) {
+				logger.Infof("%v", err)
+				c.AbortWithStatus(400)
+				return
+			}
+			logger.Errorf("failed to build icon font: %v", err)
+			c.AbortWithStatus(500)
+			return
+		}
+
+		c.Header("Cache-Control", revalidateCacheControl)
+		if isNotModified(c, font.Key, time.Time{}) {
+			c.Status(304)
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", font.FileName))
+		c.Data(200, "application/zip", font.Content)
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"image"
 	"io"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -23,10 +26,41 @@ type IconPath struct {
 }
 
 type ResponseIcon struct {
-	Name       string     `json:"name"`
-	ModifiedBy string     `json:"modifiedBy"`
-	Paths      []IconPath `json:"paths"`
-	Tags       []string   `json:"tags"`
+	Name        string     `json:"name"`
+	ModifiedBy  string     `json:"modifiedBy"`
+	Paths       []IconPath `json:"paths"`
+	Tags        []string   `json:"tags"`
+	Description string     `json:"description"`
+}
+
+// CreatedIcon is the response to icon creation, with the report on what has been removed from an SVG iconfile
+type CreatedIcon struct {
+	ResponseIcon
+	Sanitization *services.SanitizationReport `json:"sanitization,omitempty"`
+}
+
+// AddedIconfile is the response to adding an iconfile, with the report on what has been removed from an SVG iconfile
+type AddedIconfile struct {
+	IconPath
+	Sanitization *services.SanitizationReport `json:"sanitization,omitempty"`
+}
+
+// UnsafeIconfileResponse tells why an SVG iconfile has been rejected in strict sanitization mode
+type UnsafeIconfileResponse struct {
+	Error        string                      `json:"error"`
+	Sanitization services.SanitizationReport `json:"sanitization"`
+}
+
+// InvalidIconfileResponse tells why an iconfile has been rejected as being of an unknown format or malformed
+type InvalidIconfileResponse struct {
+	Error string `json:"error"`
+}
+
+func sanitizationReport(report services.SanitizationReport) *services.SanitizationReport {
+	if report.Clean() {
+		return nil
+	}
+	return &report
 }
 
 func createIconfilePath(baseUrl string, iconName string, iconfileDescriptor domain.IconfileDescriptor) string {
@@ -53,10 +87,11 @@ func CreateIconfilePaths(baseUrl string, iconDesc domain.IconDescriptor) []IconP
 
 func CreateResponseIcon(iconPathRoot string, iconDesc domain.IconDescriptor) ResponseIcon {
 	return ResponseIcon{
-		Name:       iconDesc.Name,
-		ModifiedBy: iconDesc.ModifiedBy,
-		Paths:      CreateIconfilePaths(iconPathRoot, iconDesc),
-		Tags:       iconDesc.Tags,
+		Name:        iconDesc.Name,
+		ModifiedBy:  iconDesc.ModifiedBy,
+		Paths:       CreateIconfilePaths(iconPathRoot, iconDesc),
+		Tags:        iconDesc.Tags,
+		Description: iconDesc.Description,
 	}
 }
 
@@ -78,19 +113,81 @@ func iconToResponseIcon(icon domain.Icon) ResponseIcon {
 	)
 }
 
+// queryList returns the values of a query parameter either repeated or specified as a comma separated list
+func queryList(c *gin.Context, name string) []string {
+	values := []string{}
+	for _, param := range c.QueryArray(name) {
+		for _, value := range strings.Split(param, ",") {
+			if value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// parseIconQuery reads the icon query from the query parameters of the request. Tags can be specified
+// either by repeating the "tag" parameter or as a comma separated list.
+func parseIconQuery(c *gin.Context) (domain.IconQuery, error) {
+	query := domain.IconQuery{
+		NameContains: c.Query("name"),
+		NamePrefix:   c.Query("prefix"),
+		TagMatch:     c.Query("tagMatch"),
+		Format:       c.Query("format"),
+		Size:         c.Query("size"),
+		Cursor:       c.Query("cursor"),
+	}
+	query.Tags = queryList(c, "tag")
+	sortParam := c.Query("sort")
+	if strings.HasPrefix(sortParam, "-") {
+		query.Descending = true
+		sortParam = sortParam[1:]
+	}
+	query.SortBy = sortParam
+	if limitParam := c.Query("limit"); limitParam != "" {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit < 1 {
+			return query, fmt.Errorf("invalid limit %s: %w", limitParam, services.ErrInvalidIconQuery)
+		}
+		query.Limit = limit
+	}
+	return query, nil
+}
+
+func nextPageURL(requestURL *url.URL, nextCursor string) string {
+	nextURL := *requestURL
+	params := nextURL.Query()
+	params.Set("cursor", nextCursor)
+	nextURL.RawQuery = params.Encode()
+	return nextURL.RequestURI()
+}
+
 func describeAllIconsHanler(iconService *services.IconService) func(c *gin.Context) {
 	return func(c *gin.Context) {
-		logger := log.WithField("prefix", "createIconHandler")
-		icons, err := iconService.DescribeAllIcons()
-		if err != nil {
-			logger.Errorf("%v", err)
-			c.AbortWithStatus(500)
+		logger := log.WithField("prefix", "describeAllIconsHandler")
+		query, err := parseIconQuery(c)
+		if err == nil {
+			var page domain.IconPage
+			page, err = iconService.QueryIcons(query)
+			if err == nil {
+				responseIcon := []ResponseIcon{}
+				for _, icon := range page.Icons {
+					responseIcon = append(responseIcon, CreateResponseIcon(iconRootPath, icon))
+				}
+				if page.NextCursor != "" {
+					c.Header("Link", fmt.Sprintf("<%s>; rel=\"next\"", nextPageURL(c.Request.URL, page.NextCursor)))
+				}
+				c.JSON(200, responseIcon)
+				return
+			}
 		}
-		responseIcon := []ResponseIcon{}
-		for _, icon := range icons {
-			responseIcon = append(responseIcon, CreateResponseIcon(iconRootPath, icon))
+		if errors.Is(err, services.ErrInvalidIconQuery) {
+			logger.Infof("%v", err)
+			c.AbortWithStatus(400)
+			return
 		}
-		c.JSON(200, responseIcon)
+		logger.Errorf("%v", err)
+		c.AbortWithStatus(500)
 	}
 }
 
@@ -148,36 +245,177 @@ func createIconHandler(iconService *services.IconService) func(c *gin.Context) {
 		logger.Infof("received %d bytes for icon %s", buf.Len(), iconName)
 
 		// do something with the contents...
-		icon, errCreate := iconService.CreateIcon(iconName, buf.Bytes(), MustGetUserSession(c).UserInfo)
+		icon, report, errCreate := iconService.CreateIcon(iconName, buf.Bytes(), r.FormValue("owningGroup"), MustGetUserSession(c).UserInfo)
 		if errCreate != nil {
 			logger.Errorf("failed to create icon %v", errCreate)
 			if errors.Is(errCreate, authr.ErrPermission) {
 				c.AbortWithStatus(403)
 				return
+			} else if errors.Is(errCreate, services.ErrUnsafeSVG) {
+				c.AbortWithStatusJSON(400, UnsafeIconfileResponse{Error: errCreate.Error(), Sanitization: report})
+				return
+			} else if isInvalidIconfileError(errCreate) || errors.Is(errCreate, services.ErrInvalidIconACL) {
+				c.AbortWithStatusJSON(400, InvalidIconfileResponse{Error: errCreate.Error()})
+				return
 			} else {
 				c.AbortWithStatus(500)
 				return
 			}
 		}
-		c.JSON(201, iconToResponseIcon(icon))
+		c.JSON(201, CreatedIcon{ResponseIcon: iconToResponseIcon(icon), Sanitization: sanitizationReport(report)})
+	}
+}
+
+const iconfileByHashRootPath = "/iconfile"
+
+// IconfileMetadata describes an iconfile for clients asking for JSON rather than the image itself
+type IconfileMetadata struct {
+	IconPath
+	ContentType   string `json:"contentType"`
+	ContentLength int    `json:"contentLength"`
+	ContentHash   string `json:"contentHash"`
+	// ImmutablePath is the content-addressed path of the iconfile which can be cached forever. Content derived on the
+	// fly is not stored, so it has no such path: the path it has been derived at is returned for it.
+	ImmutablePath string `json:"immutablePath"`
+}
+
+func createImmutableIconfilePath(baseUrl string, contentHash string) string {
+	return fmt.Sprintf("%s/%s", baseUrl, contentHash)
+}
+
+func writeIconfileContent(c *gin.Context, contentType string, content []byte) {
+	c.Header("X-Content-Type-Options", "nosniff")
+	if contentType == "image/svg+xml" {
+		// Keep scripts possibly embedded in SVGs from running when the iconfile is opened directly
+		c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
 	}
+	c.Data(200, contentType, content)
+}
+
+// isInvalidIconfileError tells whether the error is due to the uploaded iconfile being of an unknown format or malformed
+func isInvalidIconfileError(err error) bool {
+	return errors.Is(err, image.ErrFormat) || errors.Is(err, services.ErrInvalidSVG)
 }
 
 func getIconfileHandler(iconService *services.IconService) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		logger := log.WithField("prefix", "getIconfileHandler")
 		iconName := c.Param("name")
-		format := c.Param("format")
-		size := c.Param("size")
-		iconFile, err := iconService.GetIconfile(iconName, domain.IconfileDescriptor{
-			Format: format,
-			Size:   size,
-		})
+		iconfileDescriptor := domain.IconfileDescriptor{
+			Format: c.Param("format"),
+			Size:   c.Param("size"),
+		}
+		session := MustGetUserSession(c)
+		// Missing PNGs are rendered from the SVG iconfile of the icon
+		iconfile, err := iconService.GetIconfileVariant(iconName, iconfileDescriptor, c.Query("variant"), session.UserInfo)
+		if err != nil {
+			if errors.Is(err, domain.ErrIconfileNotFound) {
+				logger.Infof("iconfile %v of icon %s not found", iconfileDescriptor, iconName)
+				c.AbortWithStatus(404)
+				return
+			}
+			if errors.Is(err, services.ErrInvalidRasterSize) || errors.Is(err, services.ErrInvalidIconfileVariant) {
+				logger.Infof("%v", err)
+				c.AbortWithStatus(400)
+				return
+			}
+			logger.Errorf("failed to retrieve iconfile %v of icon %s: %v", iconfileDescriptor, iconName, err)
+			c.AbortWithStatus(500)
+			return
+		}
+
+		// Monochrome SVGs are recolored on the fly
+		recoloring := services.Recoloring{Fill: c.Query("fill"), Stroke: c.Query("stroke"), Theme: c.Query("theme")}
+		if recoloring.Requested() {
+			iconfile, err = iconService.RecolorIconfile(iconfile, recoloring)
+			if err != nil {
+				if errors.Is(err, services.ErrInvalidRecoloring) {
+					logger.Infof("%v", err)
+					c.AbortWithStatus(400)
+					return
+				}
+				if errors.Is(err, services.ErrNotRecolorable) || errors.Is(err, services.ErrInvalidSVG) {
+					logger.Infof("failed to recolor iconfile %v of icon %s: %v", iconfileDescriptor, iconName, err)
+					c.AbortWithStatus(422)
+					return
+				}
+				logger.Errorf("failed to recolor iconfile %v of icon %s: %v", iconfileDescriptor, iconName, err)
+				c.AbortWithStatus(500)
+				return
+			}
+		}
+
+		contentType := iconfileContentType(iconfile.Format, iconfile.Content)
+		c.Header("Vary", "Accept")
+		if prefersJSON(c.GetHeader("Accept"), contentType) {
+			immutablePath := c.Request.URL.RequestURI()
+			if !iconfile.Derived {
+				immutablePath = createImmutableIconfilePath(iconfileByHashRootPath, iconfile.ContentHash)
+			}
+			c.JSON(200, IconfileMetadata{
+				IconPath:      CreateIconPath(iconRootPath, iconName, iconfileDescriptor),
+				ContentType:   contentType,
+				ContentLength: len(iconfile.Content),
+				ContentHash:   iconfile.ContentHash,
+				ImmutablePath: immutablePath,
+			})
+			return
+		}
+
+		// The iconfile may be replaced under the same name, so caches are to revalidate
+		c.Header("Cache-Control", revalidateCacheControl)
+		if isNotModified(c, iconfile.ContentHash, iconfile.CreatedAt) {
+			c.Status(304)
+			return
+		}
+		writeIconfileContent(c, contentType, iconfile.Content)
+	}
+}
+
+func isContentHash(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
+
+func getIconfileByHashHandler(iconService *services.IconService) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		logger := log.WithField("prefix", "getIconfileByHashHandler")
+		contentHash := strings.ToLower(c.Param("hash"))
+		if !isContentHash(contentHash) {
+			logger.Infof("invalid content hash: %s", c.Param("hash"))
+			c.AbortWithStatus(404)
+			return
+		}
+
+		// The content behind the URL never changes
+		c.Header("Cache-Control", immutableCacheControl)
+		if etagMatches(c.GetHeader("If-None-Match"), entityTag(contentHash)) {
+			c.Header("ETag", entityTag(contentHash))
+			c.Status(304)
+			return
+		}
+
+		iconfile, err := iconService.GetIconfileByHash(contentHash)
 		if err != nil {
-			logger.Errorf("failed to retrieve %s:%scontents for icon %s: %v", iconName, size, format, err)
+			c.Header("Cache-Control", revalidateCacheControl)
+			if errors.Is(err, domain.ErrIconfileNotFound) {
+				logger.Infof("iconfile with content hash %s not found", contentHash)
+				c.AbortWithStatus(404)
+				return
+			}
+			logger.Errorf("failed to retrieve iconfile with content hash %s: %v", contentHash, err)
 			c.AbortWithStatus(500)
+			return
 		}
-		c.JSON(200, iconFile)
+		c.Header("ETag", entityTag(contentHash))
+		writeIconfileContent(c, iconfileContentType(iconfile.Format, iconfile.Content), iconfile.Content)
 	}
 }
 
@@ -228,7 +466,7 @@ func addIconfileHandler(iconService *services.IconService) func(c *gin.Context)
 		logger.Infof("received %d bytes as iconfile content for icon %s", buf.Len(), iconName)
 
 		// do something with the contents...
-		iconfileDescriptor, errCreate := iconService.AddIconfile(iconName, buf.Bytes(), MustGetUserSession(c).UserInfo)
+		iconfileDescriptor, report, errCreate := iconService.AddIconfile(iconName, buf.Bytes(), MustGetUserSession(c).UserInfo)
 		if errCreate != nil {
 			logger.Errorf("failed to add iconfile %v", errCreate)
 			if errors.Is(errCreate, authr.ErrPermission) {
@@ -237,11 +475,18 @@ func addIconfileHandler(iconService *services.IconService) func(c *gin.Context)
 			} else if errors.Is(errCreate, domain.ErrIconfileAlreadyExists) {
 				c.AbortWithStatus(409)
 				return
+			} else if errors.Is(errCreate, services.ErrUnsafeSVG) {
+				c.AbortWithStatusJSON(400, UnsafeIconfileResponse{Error: errCreate.Error(), Sanitization: report})
+				return
+			} else if isInvalidIconfileError(errCreate) {
+				c.AbortWithStatusJSON(400, InvalidIconfileResponse{Error: errCreate.Error()})
+				return
 			} else {
 				c.AbortWithStatus(500)
+				return
 			}
 		}
-		c.JSON(200, CreateIconPath(iconRootPath, iconName, iconfileDescriptor))
+		c.JSON(200, AddedIconfile{IconPath: CreateIconPath(iconRootPath, iconName, iconfileDescriptor), Sanitization: sanitizationReport(report)})
 
 		buf.Reset()
 	}
@@ -376,3 +621,47 @@ func removeTagHandler(iconService *services.IconService) func(c *gin.Context) {
 		c.Status(204)
 	}
 }
+
+type UpdateDescriptionRequestData struct {
+	Description string `json:"description"`
+}
+
+func updateDescriptionHandler(iconService *services.IconService) func(c *gin.Context) {
+	logger := log.WithField("prefix", "updateDescriptionHandler")
+	return func(c *gin.Context) {
+		session := MustGetUserSession(c)
+		iconName := c.Param("name")
+
+		jsonData, readBodyErr := io.ReadAll(c.Request.Body)
+		if readBodyErr != nil {
+			logger.Errorf("failed to read body: %v", readBodyErr)
+			c.AbortWithStatus(400)
+			return
+		}
+		requestData := UpdateDescriptionRequestData{}
+		unmarshalErr := json.Unmarshal(jsonData, &requestData)
+		if unmarshalErr != nil {
+			logger.Infof("failed to parse request body: %v", unmarshalErr)
+			c.AbortWithStatus(400)
+			return
+		}
+
+		serviceError := iconService.UpdateDescription(iconName, requestData.Description, session.UserInfo)
+		if serviceError != nil {
+			if errors.Is(serviceError, authr.ErrPermission) {
+				logger.Infof("Not allowed to update description of icon %s: %v", iconName, serviceError)
+				c.AbortWithStatus(403)
+				return
+			}
+			if errors.Is(serviceError, domain.ErrIconNotFound) {
+				logger.Infof("Icon %s not found to update description of: %v", iconName, serviceError)
+				c.AbortWithStatus(404)
+				return
+			}
+			logger.Errorf("Failed to update description of %s: %v", iconName, serviceError)
+			c.AbortWithStatus(500)
+			return
+		}
+		c.Status(204)
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pdkovacs/igo-repo/security/authr"
+	"github.com/pdkovacs/igo-repo/services"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxImportArchiveSize is the largest ZIP archive accepted for import
+const maxImportArchiveSize = 512 << 20
+
+// readImportArchive reads the archive either from the "archive" field of a multipart form or from the body itself
+func readImportArchive(c *gin.Context) ([]byte, error) {
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		file, _, err := c.Request.FormFile("archive")
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return io.ReadAll(io.LimitReader(file, maxImportArchiveSize))
+	}
+	return io.ReadAll(io.LimitReader(c.Request.Body, maxImportArchiveSize))
+}
+
+func importIconsHandler(iconService *services.IconService) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		logger := log.WithField("prefix", "importIconsHandler")
+		session := MustGetUserSession(c)
+		dryRun := c.Query("dryRun") == "true"
+
+		archive, readErr := readImportArchive(c)
+		if readErr != nil {
+			logger.Infof("failed to read import archive: %v", readErr)
+			c.AbortWithStatus(400)
+			return
+		}
+
+		report, err := iconService.ImportIcons(archive, dryRun, session.UserInfo)
+		if err != nil {
+			if errors.Is(err, authr.ErrPermission) {
+				logger.Infof("%v", err)
+				c.AbortWithStatus(403)
+				return
+			}
+			if errors.Is(err, services.ErrInvalidImportArchive) {
+				logger.Infof("%v", err)
+				c.AbortWithStatus(400)
+				return
+			}
+			logger.Errorf("%v", err)
+			c.AbortWithStatus(500)
+			return
+		}
+		c.JSON(200, report)
+	}
+}
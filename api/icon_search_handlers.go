@@ -0,0 +1,52 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pdkovacs/igo-repo/services"
+	log "github.com/sirupsen/logrus"
+)
+
+// SearchHit is an icon found along with the relevance score it is ranked by
+type SearchHit struct {
+	ResponseIcon
+	Score float64 `json:"score"`
+}
+
+func searchIconsHandler(iconService *services.IconService) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		logger := log.WithField("prefix", "searchIconsHandler")
+		text := c.Query("q")
+		limit := 0
+		var err error
+		if limitParam := c.Query("limit"); limitParam != "" {
+			limit, err = strconv.Atoi(limitParam)
+			if err != nil || limit < 1 {
+				logger.Infof("invalid limit: %s", limitParam)
+				c.AbortWithStatus(400)
+				return
+			}
+		}
+		hits, err := iconService.SearchIcons(text, limit)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidIconQuery) {
+				logger.Infof("%v", err)
+				c.AbortWithStatus(400)
+				return
+			}
+			logger.Errorf("failed to search icons for %s: %v", text, err)
+			c.AbortWithStatus(500)
+			return
+		}
+		responseHits := []SearchHit{}
+		for _, hit := range hits {
+			responseHits = append(responseHits, SearchHit{
+				ResponseIcon: CreateResponseIcon(iconRootPath, hit.IconDescriptor),
+				Score:        hit.Score,
+			})
+		}
+		c.JSON(200, responseHits)
+	}
+}
@@ -0,0 +1,311 @@
+package api
+
+import (
+	"crypto"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pdkovacs/igo-repo/config"
+	"github.com/pdkovacs/igo-repo/security/authn"
+	"github.com/pdkovacs/igo-repo/services"
+	log "github.com/sirupsen/logrus"
+)
+
+// OIDCConfig holds the configuration for the OIDC authentication scheme
+type OIDCConfig struct {
+	ClientID         string
+	ClientSecret     string
+	AuthorizationURL string
+	TokenURL         string
+	// RedirectBackURL is the URL the identity provider sends the user back to after authentication.
+	// When empty, it is derived from the incoming request and CallbackPath.
+	RedirectBackURL      string
+	CallbackPath         string
+	AppRootPath          string
+	TokenIssuer          string
+	TokenVerificationKey crypto.PublicKey
+	LogoutURL            string
+	GroupsClaim          string
+}
+
+const oidcStateKey = "igo-oidc-state"
+const oidcNonceKey = "igo-oidc-nonce"
+const oidcRequestedURIKey = "igo-oidc-requested-uri"
+
+var errOIDCAuthentication = errors.New("OIDC authentication failed")
+
+// NewOIDCConfig creates the OIDC authentication configuration from the application options
+func NewOIDCConfig(options config.Options) (OIDCConfig, error) {
+	if options.OIDCClientID == "" || options.OIDCUserAuthorizationURL == "" || options.OIDCAccessTokenURL == "" {
+		return OIDCConfig{}, fmt.Errorf("OIDC client id, user authorization url and access token url must all be configured")
+	}
+	if options.OIDCTokenIssuer == "" {
+		return OIDCConfig{}, fmt.Errorf("OIDC token issuer must be configured")
+	}
+
+	pemBytes, decodeErr := base64.StdEncoding.DecodeString(options.OIDCIpJwtPublicKeyPemBase64)
+	if decodeErr != nil {
+		return OIDCConfig{}, fmt.Errorf("failed to decode OIDC ID-token verification key: %w", decodeErr)
+	}
+	key, parseErr := authn.ParsePublicKeyPEM(pemBytes)
+	if parseErr != nil {
+		return OIDCConfig{}, fmt.Errorf("failed to parse OIDC ID-token verification key: %w", parseErr)
+	}
+
+	callbackPath := "/login"
+	if options.OIDCClientRedirectBackURL != "" {
+		redirectBackURL, urlParseErr := url.Parse(options.OIDCClientRedirectBackURL)
+		if urlParseErr != nil {
+			return OIDCConfig{}, fmt.Errorf("failed to parse OIDC client redirect back url: %w", urlParseErr)
+		}
+		callbackPath = redirectBackURL.Path
+	}
+
+	groupsClaim := options.OIDCGroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = defaultGroupsClaim
+	}
+
+	return OIDCConfig{
+		ClientID:             options.OIDCClientID,
+		ClientSecret:         options.OIDCClientSecret,
+		AuthorizationURL:     options.OIDCUserAuthorizationURL,
+		TokenURL:             options.OIDCAccessTokenURL,
+		RedirectBackURL:      options.OIDCClientRedirectBackURL,
+		CallbackPath:         callbackPath,
+		AppRootPath:          "/",
+		TokenIssuer:          options.OIDCTokenIssuer,
+		TokenVerificationKey: key,
+		LogoutURL:            options.OIDCIpLogoutURL,
+		GroupsClaim:          groupsClaim,
+	}, nil
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce string `json:"nonce"`
+	// Groups holds the values of the configured groups claim
+	Groups []string `json:"-"`
+}
+
+type tokenEndpointResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (oidcConfig OIDCConfig) getRedirectBackURL(c *gin.Context) string {
+	if oidcConfig.RedirectBackURL != "" {
+		return oidcConfig.RedirectBackURL
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, c.Request.Host, oidcConfig.CallbackPath)
+}
+
+func (oidcConfig OIDCConfig) createAuthorizationRequestURL(redirectBackURL string, state string, nonce string) string {
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", oidcConfig.ClientID)
+	query.Set("redirect_uri", redirectBackURL)
+	query.Set("scope", "openid profile email")
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+
+	separator := "?"
+	if strings.Contains(oidcConfig.AuthorizationURL, "?") {
+		separator = "&"
+	}
+	return oidcConfig.AuthorizationURL + separator + query.Encode()
+}
+
+func (oidcConfig OIDCConfig) exchangeCode(httpClient *http.Client, code string, redirectBackURL string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectBackURL)
+	form.Set("client_id", oidcConfig.ClientID)
+	form.Set("client_secret", oidcConfig.ClientSecret)
+
+	resp, postErr := httpClient.PostForm(oidcConfig.TokenURL, form)
+	if postErr != nil {
+		return "", fmt.Errorf("failed to call token endpoint: %w", postErr)
+	}
+	defer resp.Body.Close()
+
+	tokenResponse := tokenEndpointResponse{}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&tokenResponse)
+	if decodeErr != nil {
+		return "", fmt.Errorf("failed to decode token endpoint response with status %d: %w", resp.StatusCode, decodeErr)
+	}
+	if resp.StatusCode != 200 || tokenResponse.Error != "" {
+		return "", fmt.Errorf("token endpoint responded with status %d: %s %s", resp.StatusCode, tokenResponse.Error, tokenResponse.ErrorDescription)
+	}
+	if tokenResponse.IDToken == "" {
+		return "", fmt.Errorf("no ID token in token endpoint response")
+	}
+	return tokenResponse.IDToken, nil
+}
+
+func (oidcConfig OIDCConfig) verifyIDToken(rawIDToken string, expectedNonce string) (idTokenClaims, error) {
+	claims := idTokenClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}))
+	_, parseErr := parser.ParseWithClaims(rawIDToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		return oidcConfig.TokenVerificationKey, nil
+	})
+	if parseErr != nil {
+		return claims, fmt.Errorf("invalid ID token: %v: %w", parseErr, errOIDCAuthentication)
+	}
+	if !claims.VerifyIssuer(oidcConfig.TokenIssuer, true) {
+		return claims, fmt.Errorf("unexpected ID token issuer %s: %w", claims.Issuer, errOIDCAuthentication)
+	}
+	if !claims.VerifyAudience(oidcConfig.ClientID, true) {
+		return claims, fmt.Errorf("ID token not issued for %s, but for %v: %w", oidcConfig.ClientID, claims.Audience, errOIDCAuthentication)
+	}
+	if claims.ExpiresAt == nil {
+		return claims, fmt.Errorf("ID token without expiration: %w", errOIDCAuthentication)
+	}
+	if claims.Nonce != expectedNonce {
+		return claims, fmt.Errorf("ID token nonce mismatch: %w", errOIDCAuthentication)
+	}
+	if claims.Subject == "" {
+		return claims, fmt.Errorf("ID token without subject: %w", errOIDCAuthentication)
+	}
+	// The name of the groups claim is configurable; the signature has been verified above
+	allClaims := jwt.MapClaims{}
+	_, _, parseErr = parser.ParseUnverified(rawIDToken, allClaims)
+	if parseErr != nil {
+		return claims, fmt.Errorf("failed to parse ID token claims: %v: %w", parseErr, errOIDCAuthentication)
+	}
+	claims.Groups = getGroupsFromClaim(allClaims[oidcConfig.GroupsClaim])
+	return claims, nil
+}
+
+func getSessionString(session sessions.Session, key string) string {
+	if value, ok := session.Get(key).(string); ok {
+		return value
+	}
+	return ""
+}
+
+func (oidcConfig OIDCConfig) handleCallback(c *gin.Context, httpClient *http.Client, userService *services.UserService) error {
+	session := sessions.Default(c)
+
+	if idpError := c.Query("error"); idpError != "" {
+		return fmt.Errorf("identity provider returned error %s: %s: %w", idpError, c.Query("error_description"), errOIDCAuthentication)
+	}
+
+	expectedState := getSessionString(session, oidcStateKey)
+	if expectedState == "" || c.Query("state") != expectedState {
+		return fmt.Errorf("state mismatch: %w", errOIDCAuthentication)
+	}
+
+	rawIDToken, exchangeErr := oidcConfig.exchangeCode(httpClient, c.Query("code"), oidcConfig.getRedirectBackURL(c))
+	if exchangeErr != nil {
+		return fmt.Errorf("failed to exchange authorization code: %v: %w", exchangeErr, errOIDCAuthentication)
+	}
+
+	claims, verifyErr := oidcConfig.verifyIDToken(rawIDToken, getSessionString(session, oidcNonceKey))
+	if verifyErr != nil {
+		return verifyErr
+	}
+
+	userId := authn.IssuerDomain(claims.Issuer).CreateUserID(claims.Subject)
+	userInfo := userService.GetUserInfoWithGroups(userId, claims.Groups)
+
+	requestedURI := getSessionString(session, oidcRequestedURIKey)
+	session.Delete(oidcStateKey)
+	session.Delete(oidcNonceKey)
+	session.Delete(oidcRequestedURIKey)
+	renewErr := renewSessionID(c)
+	if renewErr != nil {
+		return renewErr
+	}
+	session.Set(UserKey, SessionData{userInfo})
+	saveErr := session.Save()
+	if saveErr != nil {
+		return fmt.Errorf("failed to save session for %v: %w", userId, saveErr)
+	}
+
+	if requestedURI == "" {
+		requestedURI = oidcConfig.AppRootPath
+	}
+	c.Redirect(302, requestedURI)
+	return nil
+}
+
+func (oidcConfig OIDCConfig) redirectToIdentityProvider(c *gin.Context) {
+	logger := log.WithField("prefix", "redirectToIdentityProvider")
+	session := sessions.Default(c)
+
+	state := randomString()
+	nonce := randomString()
+	session.Set(oidcStateKey, state)
+	session.Set(oidcNonceKey, nonce)
+	session.Set(oidcRequestedURIKey, c.Request.URL.RequestURI())
+	saveErr := session.Save()
+	if saveErr != nil {
+		logger.Errorf("failed to save session: %v", saveErr)
+		c.AbortWithStatus(500)
+		return
+	}
+
+	c.Redirect(302, oidcConfig.createAuthorizationRequestURL(oidcConfig.getRedirectBackURL(c), state, nonce))
+	c.Abort()
+}
+
+func oidcScheme(oidcConfig OIDCConfig, userService *services.UserService) gin.HandlerFunc {
+	logger := log.WithField("prefix", "oidcScheme")
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+
+	return func(c *gin.Context) {
+		session := sessions.Default(c)
+		if session.Get(UserKey) != nil {
+			c.Next()
+			return
+		}
+
+		if c.Request.URL.Path == oidcConfig.CallbackPath && c.Request.Method == http.MethodGet {
+			callbackErr := oidcConfig.handleCallback(c, httpClient, userService)
+			if callbackErr != nil {
+				logger.Infof("failed to complete OIDC login: %v", callbackErr)
+				if errors.Is(callbackErr, errOIDCAuthentication) {
+					c.AbortWithStatus(401)
+					return
+				}
+				c.AbortWithStatus(500)
+				return
+			}
+			c.Abort()
+			return
+		}
+
+		if c.Request.Method != http.MethodGet || c.GetHeader("X-Requested-With") == "XMLHttpRequest" {
+			c.AbortWithStatus(401)
+			return
+		}
+
+		oidcConfig.redirectToIdentityProvider(c)
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"strconv"
 
 	"github.com/gin-contrib/sessions"
-	"github.com/gin-contrib/sessions/memstore"
 	"github.com/gin-gonic/gin"
 	"github.com/pdkovacs/igo-repo/config"
 	"github.com/pdkovacs/igo-repo/repositories"
@@ -72,21 +71,85 @@ func (s *Server) SetupAndStart(options config.Options, ready func(port int)) {
 	s.Start(options.ServerPort, r, ready)
 }
 
+// authenticationType returns the authentication type configured or, if none is, the one the credentials configured
+// are for
+func authenticationType(options config.Options) string {
+	if options.AuthenticationType != "" {
+		return options.AuthenticationType
+	}
+	if options.OIDCClientID != "" {
+		return config.OIDCAuthentication
+	}
+	return config.BasicAuthentication
+}
+
 func (s *Server) initEndpoints(options config.Options) *gin.Engine {
 	logger := log.WithField("prefix", "server:initEndpoints")
-	authorizationService := services.NewAuthorizationService(options)
+	var membershipRepo services.GroupMembershipRepository
+	if s.Repositories.DB != nil {
+		membershipRepo = s.Repositories.DB
+	}
+	authorizationService, err := services.NewAuthorizationService(options, membershipRepo)
+	if err != nil {
+		panic(fmt.Errorf("failed to configure authorization: %w", err))
+	}
 	userService := services.NewUserService(&authorizationService)
 
 	gob.Register(SessionData{})
 	r := gin.Default()
-	store := memstore.NewStore([]byte("secret"))
-	store.Options(sessions.Options{MaxAge: 60 * 60 * 24})
-	r.Use(sessions.Sessions("mysession", store))
-	logger.Debugf("options.PasswordCredentials size: %d", len(options.PasswordCredentials))
-	if options.PasswordCredentials != nil && len(options.PasswordCredentials) > 0 {
-		r.Use(Authentication(BasicConfig{PasswordCredentialsList: options.PasswordCredentials}, &userService))
+	var sessionRepo sessionRepository
+	if s.Repositories.DB != nil {
+		sessionRepo = s.Repositories.DB
+	}
+	store, err := NewSessionStore(options, sessionRepo)
+	if err != nil {
+		panic(fmt.Errorf("failed to configure session store: %w", err))
+	}
+	r.Use(sessions.Sessions(SessionCookieName(options), store))
+
+	logoutURL := ""
+	var authnConfig interface{}
+	switch authenticationType(options) {
+	case config.OIDCAuthentication:
+		oidcConfig, err := NewOIDCConfig(options)
+		if err != nil {
+			panic(fmt.Errorf("failed to configure OIDC authentication: %w", err))
+		}
+		authnConfig = oidcConfig
+		logoutURL = oidcConfig.LogoutURL
+	default:
+		logger.Debugf("options.PasswordCredentials size: %d", len(options.PasswordCredentials))
+		if len(options.PasswordCredentials) > 0 || options.HtpasswdFile != "" {
+			basicConfig, err := NewBasicConfig(options)
+			if err != nil {
+				panic(fmt.Errorf("failed to configure basic authentication: %w", err))
+			}
+			authnConfig = basicConfig
+		}
 	}
 
+	// Logging out must be possible without being authenticated
+	r.POST("/logout", LogoutHandler(logoutURL))
+
+	var authnHandler gin.HandlerFunc
+	if authnConfig != nil {
+		authnHandler = Authentication(authnConfig, &userService)
+	}
+	var jwtAuthenticator bearerTokenAuthenticator
+	if JWTBearerConfigured(options) {
+		bearerConfig, err := NewBearerConfig(options)
+		if err != nil {
+			panic(fmt.Errorf("failed to configure JWT bearer-token authentication: %w", err))
+		}
+		jwtAuthenticator = bearerConfig.authenticator(&userService)
+	}
+	// Personal access tokens are issued to users authenticated otherwise, so they do not count here
+	if authnHandler == nil && jwtAuthenticator == nil {
+		panic(fmt.Errorf("failed to configure authentication: neither password credentials nor JWT bearer tokens are configured"))
+	}
+	accessTokenService := services.AccessTokenService{Repositories: s.Repositories}
+	r.Use(bearerScheme(jwtAuthenticator, accessTokenAuthenticator(&accessTokenService, &userService), authnHandler))
+
 	r.POST("/login", func(c *gin.Context) {
 		session := MustGetUserSession(c)
 		logger.Infof("%v logged in", session.UserInfo)
@@ -99,25 +162,65 @@ func (s *Server) initEndpoints(options config.Options) *gin.Engine {
 
 	r.GET("/user", UserInfoHandler(userService))
 
+	r.POST("/user/tokens", createAccessTokenHandler(&accessTokenService))
+	r.GET("/user/tokens", listAccessTokensHandler(&accessTokenService))
+	r.DELETE("/user/tokens/:id", revokeAccessTokenHandler(&accessTokenService))
+
+	groupAdminService := services.NewGroupAdminService(&authorizationService, &userService)
+	r.GET("/admin/groups", listGroupsHandler(&groupAdminService))
+	r.PUT("/admin/groups/:group/members", addGroupMemberHandler(&groupAdminService))
+	r.DELETE("/admin/groups/:group/members", removeGroupMemberHandler(&groupAdminService))
+	r.GET("/admin/users", getEffectiveUserInfoHandler(&groupAdminService))
+
 	if options.EnableBackdoors {
 		r.PUT("/backdoor/authentication", HandlePutIntoBackdoorRequest)
 		r.GET("/backdoor/authentication", HandleGetIntoBackdoorRequest)
 	}
 
-	iconService := services.IconService{Repositories: s.Repositories}
+	if err := services.ValidateThemePalettes(options.SVGThemes); err != nil {
+		panic(fmt.Errorf("failed to configure SVG themes: %w", err))
+	}
+	iconService := services.IconService{
+		Repositories:               s.Repositories,
+		PersistRasterizedIconfiles: options.PersistRasterizedIconfiles,
+		StrictSVGSanitization:      options.StrictSVGSanitization,
+		OptimizeSVGIconfiles:       options.OptimizeSVGIconfiles,
+		SVGThemes:                  options.SVGThemes,
+		GenerateRasterSizeLadder:   options.GenerateRasterSizeLadder,
+	}
 
 	r.GET("/icon", describeAllIconsHanler(&iconService))
 	r.GET("/icon/:name", describeIconHandler(&iconService))
 	r.POST("/icon", createIconHandler(&iconService))
+	r.POST("/icon/import", importIconsHandler(&iconService))
 	r.DELETE("/icon/:name", deleteIconHandler(&iconService))
 
+	r.GET("/icon/:name/acl", getIconACLHandler(&iconService))
+	r.PUT("/icon/:name/acl", setIconACLHandler(&iconService))
+
 	r.POST("/icon/:name", addIconfileHandler(&iconService))
 	r.GET("/icon/:name/format/:format/size/:size", getIconfileHandler(&iconService))
 	r.DELETE("/icon/:name/format/:format/size/:size", deleteIconfileHandler(&iconService))
+	r.GET("/icon/:name/favicon", getFaviconBundleHandler(&iconService))
+	r.GET("/iconfile/:hash", getIconfileByHashHandler(&iconService))
 
 	r.GET("/tag", getTagsHandler(&iconService))
 	r.POST("/icon/:name/tag", addTagHandler(&iconService))
 	r.DELETE("/icon/:name/tag/:tag", removeTagHandler(&iconService))
+	r.PUT("/icon/:name/description", updateDescriptionHandler(&iconService))
+
+	r.GET("/search", searchIconsHandler(&iconService))
+	r.GET("/export", exportIconsHandler(&iconService))
+	r.GET("/sprite.svg", getSpriteHandler(&iconService))
+	r.GET("/font", getIconFontHandler(&iconService))
+
+	// Sessions can be enumerated and revoked only when they are kept in the database
+	if options.SessionStore == config.DatabaseSessionStore {
+		sessionService := services.SessionService{Repositories: s.Repositories}
+		r.GET("/admin/sessions", listSessionsHandler(&sessionService))
+		r.DELETE("/admin/sessions", revokeSessionsOfUserHandler(&sessionService))
+		r.DELETE("/admin/sessions/:handle", revokeSessionHandler(&sessionService))
+	}
 
 	assetHandler := web.AssetHandler("/", "dist")
 	r.NoRoute(gin.WrapH(assetHandler))
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/pdkovacs/igo-repo/config"
+	"github.com/stretchr/testify/suite"
+)
+
+type serverTestSuite struct {
+	suite.Suite
+}
+
+func TestServerTestSuite(t *testing.T) {
+	suite.Run(t, &serverTestSuite{})
+}
+
+func (s *serverTestSuite) TestAuthenticationTypeFollowsCredentialsConfigured() {
+	options := config.GetDefaultConfiguration()
+	options.PasswordCredentials = []config.PasswordCredentials{{Username: "ux", Password: "ux"}}
+	s.Equal(config.BasicAuthentication, authenticationType(options))
+
+	options.OIDCClientID = "igo-repo"
+	s.Equal(config.OIDCAuthentication, authenticationType(options))
+
+	options.AuthenticationType = config.BasicAuthentication
+	s.Equal(config.BasicAuthentication, authenticationType(options))
+}
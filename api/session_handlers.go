@@ -0,0 +1,90 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pdkovacs/igo-repo/domain"
+	"github.com/pdkovacs/igo-repo/security/authr"
+	"github.com/pdkovacs/igo-repo/services"
+	log "github.com/sirupsen/logrus"
+)
+
+type RevokedSessionsResponse struct {
+	Revoked int64 `json:"revoked"`
+}
+
+func listSessionsHandler(sessionService *services.SessionService) func(c *gin.Context) {
+	logger := log.WithField("prefix", "listSessionsHandler")
+	return func(c *gin.Context) {
+		session := MustGetUserSession(c)
+		sessions, serviceError := sessionService.ListSessions(c.Query("user"), session.UserInfo)
+		if serviceError != nil {
+			if errors.Is(serviceError, authr.ErrPermission) {
+				logger.Infof("%v", serviceError)
+				c.AbortWithStatus(403)
+				return
+			}
+			logger.Errorf("%v", serviceError)
+			c.AbortWithStatus(500)
+			return
+		}
+		c.JSON(200, sessions)
+	}
+}
+
+func revokeSessionHandler(sessionService *services.SessionService) func(c *gin.Context) {
+	logger := log.WithField("prefix", "revokeSessionHandler")
+	return func(c *gin.Context) {
+		session := MustGetUserSession(c)
+		handle, parseErr := strconv.ParseInt(c.Param("handle"), 10, 64)
+		if parseErr != nil {
+			logger.Infof("invalid session handle %s: %v", c.Param("handle"), parseErr)
+			c.AbortWithStatus(400)
+			return
+		}
+		serviceError := sessionService.RevokeSession(handle, session.UserInfo)
+		if serviceError != nil {
+			if errors.Is(serviceError, authr.ErrPermission) {
+				logger.Infof("%v", serviceError)
+				c.AbortWithStatus(403)
+				return
+			}
+			if errors.Is(serviceError, domain.ErrSessionNotFound) {
+				logger.Infof("%v", serviceError)
+				c.AbortWithStatus(404)
+				return
+			}
+			logger.Errorf("%v", serviceError)
+			c.AbortWithStatus(500)
+			return
+		}
+		c.Status(204)
+	}
+}
+
+func revokeSessionsOfUserHandler(sessionService *services.SessionService) func(c *gin.Context) {
+	logger := log.WithField("prefix", "revokeSessionsOfUserHandler")
+	return func(c *gin.Context) {
+		session := MustGetUserSession(c)
+		owner := c.Query("user")
+		if owner == "" {
+			logger.Info("user whose sessions are to be revoked not specified")
+			c.AbortWithStatus(400)
+			return
+		}
+		count, serviceError := sessionService.RevokeSessionsOfUser(owner, session.UserInfo)
+		if serviceError != nil {
+			if errors.Is(serviceError, authr.ErrPermission) {
+				logger.Infof("%v", serviceError)
+				c.AbortWithStatus(403)
+				return
+			}
+			logger.Errorf("%v", serviceError)
+			c.AbortWithStatus(500)
+			return
+		}
+		c.JSON(200, RevokedSessionsResponse{Revoked: count})
+	}
+}
@@ -0,0 +1,335 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/gob"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/memstore"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/securecookie"
+	gsessions "github.com/gorilla/sessions"
+	"github.com/pdkovacs/igo-repo/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultSessionCookieName = "igo-session"
+const defaultSessionMaxAge = 60 * 60 * 24
+
+// sessionCleanupInterval is the minimum time between two purges of expired sessions from a server-side store
+const sessionCleanupInterval = time.Hour
+
+// parseSessionKeys turns the configured session keys into the authentication and encryption key pairs
+// expected by the session stores. A random key is generated for the memory store when none is configured.
+func parseSessionKeys(options config.Options, storeType string) ([][]byte, error) {
+	keyPairs := [][]byte{}
+	for index, key := range options.SessionKeys {
+		pair := strings.SplitN(key, ":", 2)
+		authnKey, authnKeyErr := base64.StdEncoding.DecodeString(pair[0])
+		if authnKeyErr != nil || len(authnKey) < 32 {
+			return nil, fmt.Errorf("session authentication key #%d must be a base64 encoded key of at least 32 bytes", index+1)
+		}
+		var encryptionKey []byte
+		if len(pair) == 2 {
+			var encryptionKeyErr error
+			encryptionKey, encryptionKeyErr = base64.StdEncoding.DecodeString(pair[1])
+			if encryptionKeyErr != nil || (len(encryptionKey) != 16 && len(encryptionKey) != 24 && len(encryptionKey) != 32) {
+				return nil, fmt.Errorf("session encryption key #%d must be a base64 encoded key of 16, 24 or 32 bytes", index+1)
+			}
+		}
+		keyPairs = append(keyPairs, authnKey, encryptionKey)
+	}
+
+	if len(keyPairs) == 0 {
+		if storeType != config.MemorySessionStore {
+			return nil, fmt.Errorf("session keys must be configured for the %s session store", storeType)
+		}
+		keyPairs = append(keyPairs, securecookie.GenerateRandomKey(32), nil)
+	}
+	return keyPairs, nil
+}
+
+func parseSameSite(sameSite string) (http.SameSite, error) {
+	switch strings.ToLower(sameSite) {
+	case "", "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	}
+	return http.SameSiteDefaultMode, fmt.Errorf("unexpected SameSite value: %s", sameSite)
+}
+
+// SessionCookieName returns the name of the session cookie
+func SessionCookieName(options config.Options) string {
+	if options.SessionCookieName == "" {
+		return defaultSessionCookieName
+	}
+	return options.SessionCookieName
+}
+
+func sessionOptions(options config.Options) (sessions.Options, error) {
+	sameSite, sameSiteErr := parseSameSite(options.SessionCookieSameSite)
+	if sameSiteErr != nil {
+		return sessions.Options{}, sameSiteErr
+	}
+	if sameSite == http.SameSiteNoneMode && !options.SessionCookieSecure {
+		return sessions.Options{}, fmt.Errorf("SameSite=None session cookies must be secure")
+	}
+	maxAge := options.SessionMaxAge
+	if maxAge <= 0 {
+		maxAge = defaultSessionMaxAge
+	}
+	return sessions.Options{
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   options.SessionCookieSecure,
+		HttpOnly: true,
+		SameSite: sameSite,
+	}, nil
+}
+
+// NewSessionStore creates the session store configured in the application options
+func NewSessionStore(options config.Options, sessionRepo sessionRepository) (sessions.Store, error) {
+	storeType := options.SessionStore
+	if storeType == "" {
+		storeType = config.MemorySessionStore
+	}
+
+	keyPairs, keysErr := parseSessionKeys(options, storeType)
+	if keysErr != nil {
+		return nil, keysErr
+	}
+	storeOptions, optionsErr := sessionOptions(options)
+	if optionsErr != nil {
+		return nil, optionsErr
+	}
+
+	var store sessions.Store
+	switch storeType {
+	case config.MemorySessionStore:
+		store = memstore.NewStore(keyPairs...)
+	case config.CookieSessionStore:
+		store = &cookieStore{gsessions.NewCookieStore(keyPairs...)}
+	case config.DatabaseSessionStore:
+		if sessionRepo == nil {
+			return nil, fmt.Errorf("no database available for the database session store")
+		}
+		store = newDBSessionStore(sessionRepo, keyPairs...)
+	default:
+		return nil, fmt.Errorf("unknown session store: %s", storeType)
+	}
+	store.Options(storeOptions)
+	return store, nil
+}
+
+// cookieStore keeps the whole session in the cookie. Unlike the cookie store of gin-contrib/sessions,
+// it makes sure the max age of the session is also enforced when the cookie is decoded.
+type cookieStore struct {
+	*gsessions.CookieStore
+}
+
+func (store *cookieStore) Options(options sessions.Options) {
+	store.CookieStore.Options = options.ToGorillaOptions()
+	store.CookieStore.MaxAge(options.MaxAge)
+}
+
+type sessionRepository interface {
+	LoadSession(id string) ([]byte, bool, error)
+	CreateSession(id string, owner string, data []byte, expiresAt time.Time) error
+	UpdateSession(id string, owner string, data []byte, expiresAt time.Time) (bool, error)
+	DeleteSession(id string) error
+	DeleteExpiredSessions() error
+}
+
+// dbSessionStore keeps the session data in the database; the cookie holds only the signed session id.
+// This allows the sessions to outlive server restarts and to be shared between replicas.
+type dbSessionStore struct {
+	repo        sessionRepository
+	codecs      []securecookie.Codec
+	options     *gsessions.Options
+	mutex       sync.Mutex
+	lastCleanup time.Time
+}
+
+func newDBSessionStore(repo sessionRepository, keyPairs ...[]byte) *dbSessionStore {
+	return &dbSessionStore{
+		repo:    repo,
+		codecs:  securecookie.CodecsFromPairs(keyPairs...),
+		options: &gsessions.Options{Path: "/", MaxAge: defaultSessionMaxAge},
+	}
+}
+
+func (store *dbSessionStore) Options(options sessions.Options) {
+	store.options = options.ToGorillaOptions()
+	for _, codec := range store.codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(options.MaxAge)
+		}
+	}
+}
+
+func (store *dbSessionStore) Get(r *http.Request, name string) (*gsessions.Session, error) {
+	return gsessions.GetRegistry(r).Get(store, name)
+}
+
+func (store *dbSessionStore) New(r *http.Request, name string) (*gsessions.Session, error) {
+	session := gsessions.NewSession(store, name)
+	opts := *store.options
+	session.Options = &opts
+	session.IsNew = true
+
+	cookie, cookieErr := r.Cookie(name)
+	if cookieErr != nil {
+		return session, nil
+	}
+	decodeErr := securecookie.DecodeMulti(name, cookie.Value, &session.ID, store.codecs...)
+	if decodeErr != nil {
+		return session, decodeErr
+	}
+
+	data, found, loadErr := store.repo.LoadSession(session.ID)
+	if loadErr != nil {
+		return session, loadErr
+	}
+	if !found {
+		session.ID = ""
+		return session, nil
+	}
+	decodeErr = gob.NewDecoder(bytes.NewReader(data)).Decode(&session.Values)
+	if decodeErr != nil {
+		return session, fmt.Errorf("failed to decode session data: %w", decodeErr)
+	}
+	session.IsNew = false
+	return session, nil
+}
+
+// sessionOwner tells whom the session belongs to, if anyone has authenticated yet
+func sessionOwner(session *gsessions.Session) string {
+	if userSession, ok := session.Values[UserKey].(SessionData); ok {
+		return userSession.UserInfo.UserId.String()
+	}
+	return ""
+}
+
+func (store *dbSessionStore) Save(r *http.Request, w http.ResponseWriter, session *gsessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			deleteErr := store.repo.DeleteSession(session.ID)
+			if deleteErr != nil {
+				return deleteErr
+			}
+		}
+		http.SetCookie(w, gsessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	created := session.ID == ""
+	if created {
+		if len(session.Values) == 0 {
+			// Nothing worth persisting for, say, a failed authentication attempt
+			return nil
+		}
+		idBytes := make([]byte, 32)
+		_, randErr := rand.Read(idBytes)
+		if randErr != nil {
+			return fmt.Errorf("failed to generate session id: %w", randErr)
+		}
+		session.ID = strings.TrimRight(base32.StdEncoding.EncodeToString(idBytes), "=")
+		store.cleanupMaybe()
+	}
+
+	var data bytes.Buffer
+	encodeErr := gob.NewEncoder(&data).Encode(session.Values)
+	if encodeErr != nil {
+		return fmt.Errorf("failed to encode session data: %w", encodeErr)
+	}
+	maxAge := session.Options.MaxAge
+	if maxAge == 0 {
+		maxAge = defaultSessionMaxAge
+	}
+	expiresAt := time.Now().Add(time.Duration(maxAge) * time.Second)
+	if created {
+		storeErr := store.repo.CreateSession(session.ID, sessionOwner(session), data.Bytes(), expiresAt)
+		if storeErr != nil {
+			return storeErr
+		}
+	} else {
+		found, storeErr := store.repo.UpdateSession(session.ID, sessionOwner(session), data.Bytes(), expiresAt)
+		if storeErr != nil {
+			return storeErr
+		}
+		if !found {
+			// The session has been revoked since the request started: it is to stay revoked
+			http.SetCookie(w, gsessions.NewCookie(session.Name(), "", &gsessions.Options{Path: session.Options.Path, MaxAge: -1}))
+			return nil
+		}
+	}
+
+	encodedID, encodeIDErr := securecookie.EncodeMulti(session.Name(), session.ID, store.codecs...)
+	if encodeIDErr != nil {
+		return fmt.Errorf("failed to encode session id: %w", encodeIDErr)
+	}
+	http.SetCookie(w, gsessions.NewCookie(session.Name(), encodedID, session.Options))
+	return nil
+}
+
+func (store *dbSessionStore) cleanupMaybe() {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if time.Since(store.lastCleanup) < sessionCleanupInterval {
+		return
+	}
+	store.lastCleanup = time.Now()
+	cleanupErr := store.repo.DeleteExpiredSessions()
+	if cleanupErr != nil {
+		log.WithField("prefix", "dbSessionStore").Errorf("%v", cleanupErr)
+	}
+}
+
+// discardedResponse swallows the cookie a session store sets when it deletes a stale session
+type discardedResponse struct {
+	header http.Header
+}
+
+func (response *discardedResponse) Header() http.Header {
+	return response.header
+}
+
+func (response *discardedResponse) Write(content []byte) (int, error) {
+	return len(content), nil
+}
+
+func (response *discardedResponse) WriteHeader(statusCode int) {}
+
+// renewSessionID discards the session known by the ID the client has presented so far. The session data is stored
+// under a new ID on the next save, so an ID planted in the browser before logging in is of no use afterwards.
+// Stores keeping the whole session in the cookie have no IDs to renew.
+func renewSessionID(c *gin.Context) error {
+	withSession, ok := sessions.Default(c).(interface{ Session() *gsessions.Session })
+	if !ok {
+		return nil
+	}
+	session := withSession.Session()
+	if session == nil || session.ID == "" {
+		return nil
+	}
+	stale := gsessions.NewSession(session.Store(), session.Name())
+	stale.ID = session.ID
+	stale.Options = &gsessions.Options{Path: session.Options.Path, MaxAge: -1}
+	deleteErr := session.Store().Save(c.Request, &discardedResponse{header: http.Header{}}, stale)
+	if deleteErr != nil {
+		return fmt.Errorf("failed to discard session before renewing its id: %w", deleteErr)
+	}
+	session.ID = ""
+	return nil
+}
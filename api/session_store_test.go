@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gsessions "github.com/gorilla/sessions"
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeSessionRepository keeps the session data in memory
+type fakeSessionRepository struct {
+	sessions map[string][]byte
+}
+
+func (repo *fakeSessionRepository) LoadSession(id string) ([]byte, bool, error) {
+	data, found := repo.sessions[id]
+	return data, found, nil
+}
+
+func (repo *fakeSessionRepository) CreateSession(id string, owner string, data []byte, expiresAt time.Time) error {
+	repo.sessions[id] = data
+	return nil
+}
+
+func (repo *fakeSessionRepository) UpdateSession(id string, owner string, data []byte, expiresAt time.Time) (bool, error) {
+	if _, found := repo.sessions[id]; !found {
+		return false, nil
+	}
+	repo.sessions[id] = data
+	return true, nil
+}
+
+func (repo *fakeSessionRepository) DeleteSession(id string) error {
+	delete(repo.sessions, id)
+	return nil
+}
+
+func (repo *fakeSessionRepository) DeleteExpiredSessions() error {
+	return nil
+}
+
+type sessionStoreTestSuite struct {
+	suite.Suite
+}
+
+func TestSessionStoreTestSuite(t *testing.T) {
+	suite.Run(t, &sessionStoreTestSuite{})
+}
+
+func (s *sessionStoreTestSuite) TestShouldNotBringRevokedSessionBack() {
+	repo := &fakeSessionRepository{sessions: map[string][]byte{}}
+	store := newDBSessionStore(repo, []byte("0123456789abcdef0123456789abcdef"))
+
+	session := gsessions.NewSession(store, "igo-session")
+	session.Options = &gsessions.Options{Path: "/", MaxAge: defaultSessionMaxAge}
+	session.Values["key"] = "value"
+	s.Require().NoError(store.Save(httptest.NewRequest("GET", "/", nil), httptest.NewRecorder(), session))
+	s.Require().Contains(repo.sessions, session.ID)
+
+	// Revoked while a request using it is in progress
+	s.Require().NoError(repo.DeleteSession(session.ID))
+
+	response := httptest.NewRecorder()
+	session.Values["key"] = "other value"
+	s.NoError(store.Save(httptest.NewRequest("GET", "/", nil), response, session))
+	s.Empty(repo.sessions)
+	cookies := (&http.Response{Header: response.Header()}).Cookies()
+	s.Equal(1, len(cookies))
+	s.Equal(-1, cookies[0].MaxAge)
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pdkovacs/igo-repo/services"
+	log "github.com/sirupsen/logrus"
+)
+
+func getSpriteHandler(iconService *services.IconService) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		logger := log.WithField("prefix", "getSpriteHandler")
+		filter := services.SpriteFilter{
+			Tags:     append(queryList(c, "tags"), queryList(c, "tag")...),
+			TagMatch: c.Query("tagMatch"),
+			Size:     c.Query("size"),
+		}
+		sprite, err := iconService.GetSprite(filter)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidIconQuery) {
+				logger.Infof("%v", err)
+				c.AbortWithStatus(400)
+				return
+			}
+			logger.Errorf("failed to assemble sprite: %v", err)
+			c.AbortWithStatus(500)
+			return
+		}
+
+		// The selection of icons may change any time, but the ETag changes along with it
+		c.Header("Cache-Control", revalidateCacheControl)
+		if isNotModified(c, sprite.Key, time.Time{}) {
+			c.Status(304)
+			return
+		}
+		writeIconfileContent(c, "image/svg+xml", sprite.Content)
+	}
+}
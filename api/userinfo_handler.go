@@ -1,7 +1,6 @@
 package api
 
 import (
-	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"github.com/pdkovacs/igo-repo/security/authn"
 	"github.com/pdkovacs/igo-repo/services"
@@ -12,10 +11,7 @@ func UserInfoHandler(userService services.UserService) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		userId := c.Query("userId")
 		logger := log.WithField("prefix", "UserInfoHandler")
-		session := sessions.Default(c)
-		user := session.Get(UserKey)
-
-		usession, ok := user.(SessionData)
+		usession, user, ok := getUserSession(c)
 		if !ok {
 			logger.Errorf("failed to cast user session of type %T", user)
 		}
@@ -24,7 +20,7 @@ func UserInfoHandler(userService services.UserService) func(c *gin.Context) {
 		if userId == "" {
 			userInfo = usession.UserInfo
 		} else {
-			userInfo = userService.GetUserInfo(authn.UserID{IDInDomain: userId})
+			userInfo = userService.GetUserInfo(authn.ParseUserID(userId))
 		}
 
 		logger.Debugf("User info: %v", userInfo)
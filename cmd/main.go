@@ -1,14 +1,17 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
 	_ "image/jpeg"
 	_ "image/png"
 	"os"
+	"strings"
 
 	_ "github.com/jackc/pgx/v4/stdlib"
 	"github.com/pdkovacs/igo-repo/api"
 	"github.com/pdkovacs/igo-repo/config"
+	"github.com/pdkovacs/igo-repo/security/authn"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -23,6 +26,36 @@ func setLogLevel(levelArg string) {
 	log.SetLevel(level)
 }
 
+const hashPasswordCommand = "hash-password"
+
+// hashPassword reads a password from the standard input and prints its hash to be used
+// as "passwordHash" in the configuration or in an htpasswd file
+func hashPassword(args []string) {
+	algorithm := authn.BcryptHashAlgorithm
+	if len(args) > 0 {
+		algorithm = args[0]
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, readErr := bufio.NewReader(os.Stdin).ReadString('\n')
+	if readErr != nil && password == "" {
+		fmt.Fprintf(os.Stderr, "failed to read password: %v\n", readErr)
+		os.Exit(1)
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		fmt.Fprintln(os.Stderr, "empty password")
+		os.Exit(1)
+	}
+
+	hash, hashErr := authn.HashPassword(password, algorithm)
+	if hashErr != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", hashErr)
+		os.Exit(1)
+	}
+	fmt.Println(hash)
+}
+
 func main() {
 	log.SetFormatter(&log.TextFormatter{
 		DisableColors:   true,
@@ -30,6 +63,11 @@ func main() {
 		TimestampFormat: "2006-01-02 15:04:05.000",
 	})
 
+	if len(os.Args) > 1 && os.Args[1] == hashPasswordCommand {
+		hashPassword(os.Args[2:])
+		return
+	}
+
 	var serverWanted bool = true
 
 	for _, value := range os.Args {
@@ -14,17 +14,38 @@ import (
 const BasicAuthentication = "basic"
 const OIDCAuthentication = "oidc"
 
-// PasswordCredentials holds password-credentials
+const MemorySessionStore = "memory"
+const CookieSessionStore = "cookie"
+const DatabaseSessionStore = "database"
+
+// PasswordCredentials holds password-credentials. The password is preferably specified
+// as a bcrypt or argon2id hash (see the "hash-password" command) rather than in plaintext.
 type PasswordCredentials struct {
-	Username string
-	Password string
+	Username     string
+	Password     string
+	PasswordHash string
 }
 
 // UsersByRoles maps roles to lists of user holding the role
 type UsersByRoles map[string][]string
 
+// PermsByRoles maps roles to the lists of permissions they grant
+type PermsByRoles map[string][]string
+
+// ClaimsByRoles maps roles to the lists of group claim values (as asserted by the identity provider) granting the role
+type ClaimsByRoles map[string][]string
+
 type basicAuthnData []PasswordCredentials
 
+// ThemePalette is the colors monochrome SVG icons are recolored with in a theme
+type ThemePalette struct {
+	Fill   string `json:"fill"`
+	Stroke string `json:"stroke"`
+}
+
+// ThemePalettes maps the names of themes to their palettes
+type ThemePalettes map[string]ThemePalette
+
 // Options holds the available command-line options
 type Options struct {
 	ServerHostname              string         `json:"serverHostname" env:"SERVER_HOSTNAME" long:"server-hostname" short:"h" default:"localhost" description:"Server hostname"`
@@ -34,8 +55,9 @@ type Options struct {
 	PathToStaticFiles           string         `json:"pathToStaticFiles" env:"PATH_TO_STATIC_FILES" long:"path-to-static-files" short:"f" default:"" description:"Path to static files"`
 	IconDataLocationGit         string         `json:"iconDataLocationGit" env:"ICON_DATA_LOCATION_GIT" long:"icon-data-location-git" short:"g" default:"" description:"Icon data location git"`
 	IconDataCreateNew           string         `json:"iconDataCreateNew" env:"ICON_DATA_CREATE_NEW" long:"icon-data-create-new" short:"n" default:"never" description:"Icon data create new"`
-	AuthenticationType          string         `json:"authenticationType" env:"AUTHENTICATION_TYPE" long:"authentication-type" short:"a" default:"oidc" description:"Authentication type"`
+	AuthenticationType          string         `json:"authenticationType" env:"AUTHENTICATION_TYPE" long:"authentication-type" short:"a" default:"" description:"Authentication type (basic or oidc); if not specified, oidc when an OIDC client is configured, basic otherwise"`
 	PasswordCredentials         basicAuthnData `json:"passwordCredentials" env:"PASSWORD_CREDENTIALS" long:"password-credentials"`
+	HtpasswdFile                string         `json:"htpasswdFile" env:"HTPASSWD_FILE" long:"htpasswd-file" short:"" default:"" description:"htpasswd file with bcrypt or argon2id password hashes"`
 	OIDCClientID                string         `json:"oidcClientId" env:"OIDC_CLIENT_ID" long:"oidc-client-id" short:"" default:"" description:"OIDC client id"`
 	OIDCClientSecret            string         `json:"oidcClientSecret" env:"OIDC_CLIENT_SECRET" long:"oidc-client-secret" short:"" default:"" description:"OIDC client secret"`
 	OIDCAccessTokenURL          string         `json:"oidcAccessTokenUrl" env:"OIDC_ACCESS_TOKEN_URL" long:"oidc-access-token-url" short:"" default:"" description:"OIDC access token url"`
@@ -45,13 +67,32 @@ type Options struct {
 	OIDCIpJwtPublicKeyURL       string         `json:"oidcIpJwtPublicKeyUrl" env:"OIDC_IP_JWT_PUBLIC_KEY_URL" long:"oidc-ip-jwt-public-key-url" short:"" default:"" description:"OIDC ip jwt public key url"`
 	OIDCIpJwtPublicKeyPemBase64 string         `json:"oidcIpJwtPublicKeyPemBase64" env:"OIDC_IP_JWT_PUBLIC_KEY_PEM_BASE64" long:"oidc-ip-jwt-public-key-pem-base64" short:"" default:"" description:"OIDC ip jwt public key pem base64"`
 	OIDCIpLogoutURL             string         `json:"oidcIpLogoutUrl" env:"OIDC_IP_LOGOUT_URL" long:"oidc-ip-logout-url" short:"" default:"" description:"OIDC ip logout url"`
+	OIDCGroupsClaim             string         `json:"oidcGroupsClaim" env:"OIDC_GROUPS_CLAIM" long:"oidc-groups-claim" short:"" default:"" description:"OIDC ID token claim listing the groups of the user (\"groups\" if not specified)"`
+	JWTBearerPublicKeyPemBase64 string         `json:"jwtBearerPublicKeyPemBase64" env:"JWT_BEARER_PUBLIC_KEY_PEM_BASE64" long:"jwt-bearer-public-key-pem-base64" short:"" default:"" description:"JWT bearer token verification public key pem base64"`
+	JWTBearerJWKSFile           string         `json:"jwtBearerJwksFile" env:"JWT_BEARER_JWKS_FILE" long:"jwt-bearer-jwks-file" short:"" default:"" description:"JWT bearer token verification keys JWKS file"`
+	JWTBearerIssuer             string         `json:"jwtBearerIssuer" env:"JWT_BEARER_ISSUER" long:"jwt-bearer-issuer" short:"" default:"" description:"JWT bearer token issuer"`
+	JWTBearerAudience           string         `json:"jwtBearerAudience" env:"JWT_BEARER_AUDIENCE" long:"jwt-bearer-audience" short:"" default:"" description:"JWT bearer token audience"`
+	JWTBearerGroupsClaim        string         `json:"jwtBearerGroupsClaim" env:"JWT_BEARER_GROUPS_CLAIM" long:"jwt-bearer-groups-claim" short:"" default:"" description:"JWT bearer token claim listing the groups of the user (\"groups\" if not specified)"`
+	SessionStore                string         `json:"sessionStore" env:"SESSION_STORE" long:"session-store" short:"" default:"" description:"Session store (memory, cookie or database; memory if not specified)"`
+	SessionKeys                 []string       `json:"sessionKeys" env:"SESSION_KEYS" env-delim:"," long:"session-keys" short:"" description:"Base64 session authentication keys optionally followed by a colon and an encryption key; the first one is used for new sessions"`
+	SessionCookieName           string         `json:"sessionCookieName" env:"SESSION_COOKIE_NAME" long:"session-cookie-name" short:"" default:"" description:"Session cookie name (\"igo-session\" if not specified)"`
+	SessionMaxAge               int            `json:"sessionMaxAge" env:"SESSION_MAX_AGE" long:"session-max-age" short:"" description:"Session max age in seconds (one day if not specified)"`
+	SessionCookieSecure         bool           `json:"sessionCookieSecure" env:"SESSION_COOKIE_SECURE" long:"session-cookie-secure" short:"" description:"Send the session cookie over HTTPS only"`
+	SessionCookieSameSite       string         `json:"sessionCookieSameSite" env:"SESSION_COOKIE_SAME_SITE" long:"session-cookie-same-site" short:"" default:"" description:"SameSite attribute of the session cookie (lax, strict or none; lax if not specified)"`
 	UsersByRoles                UsersByRoles   `json:"usersByRoles" env:"USERS_BY_ROLES" long:"users-by-roles" short:"" default:"" description:"Users by roles"`
+	PermissionsByRoles          PermsByRoles   `json:"permissionsByRoles" env:"PERMISSIONS_BY_ROLES" long:"permissions-by-roles" short:"" description:"Permissions by roles in addition to (or overriding) the built-in ICON_EDITOR and ADMINISTRATOR roles"`
+	GroupClaimsByRoles          ClaimsByRoles  `json:"groupClaimsByRoles" env:"GROUP_CLAIMS_BY_ROLES" long:"group-claims-by-roles" short:"" description:"Group claim values of OIDC ID tokens and JWT bearer tokens by the roles they grant; claim values not listed grant no role"`
 	DBHost                      string         `json:"dbHost" env:"DB_HOST" long:"db-host" short:"" default:"localhost" description:"DB host"`
 	DBPort                      int            `json:"dbPort" env:"DB_PORT" long:"db-port" short:"" default:"5432" description:"DB port"`
 	DBUser                      string         `json:"dbUser" env:"DB_USER" long:"db-user" short:"" default:"iconrepo" description:"DB user"`
 	DBPassword                  string         `json:"dbPassword" env:"DB_PASSWORD" long:"db-password" short:"" default:"iconrepo" description:"DB password"`
 	DBName                      string         `json:"dbName" env:"DB_NAME" long:"db-name" short:"" default:"iconrepo" description:"Name of the database"`
 	DBSchemaName                string         `json:"dbSchemaName" env:"DB_SCHEMA_NAME" long:"db-schema-name" short:"" default:"icon_repo" description:"Name of the database schemma"`
+	PersistRasterizedIconfiles  bool           `json:"persistRasterizedIconfiles" env:"PERSIST_RASTERIZED_ICONFILES" long:"persist-rasterized-iconfiles" short:"" description:"Add the PNG iconfiles rendered from SVG and the PNG and JPEG iconfiles downscaled on demand to their icons"`
+	StrictSVGSanitization       bool           `json:"strictSvgSanitization" env:"STRICT_SVG_SANITIZATION" long:"strict-svg-sanitization" short:"" description:"Reject uploaded SVG iconfiles with scripts, event handlers, external references and the like rather than removing those"`
+	OptimizeSVGIconfiles        bool           `json:"optimizeSvgIconfiles" env:"OPTIMIZE_SVG_ICONFILES" long:"optimize-svg-iconfiles" short:"" description:"Store uploaded SVG iconfiles optimized, keeping their original content available, too"`
+	SVGThemes                   ThemePalettes  `json:"svgThemes" env:"SVG_THEMES" long:"svg-themes" short:"" description:"Named palettes with the fill and stroke colors monochrome SVG icons can be recolored with"`
+	GenerateRasterSizeLadder    bool           `json:"generateRasterSizeLadder" env:"GENERATE_RASTER_SIZE_LADDER" long:"generate-raster-size-ladder" short:"" description:"Add the 16, 24, 32, 48 and 64px iconfiles smaller than uploaded PNG and JPEG iconfiles to their icons"`
 	EnableBackdoors             bool           `json:"enableBackdoors" env:"ENABLE_BACKDOORS" long:"enable-backdoors" short:"" description:"Enable backdoors"`
 	PackageRootDir              string         `json:"packageRootDir" env:"PACKAGE_ROOT_DIR" long:"package-root-dir" short:"" default:"" description:"Package root dir"`
 	LogLevel                    string         `json:"logLevel" env:"IGOREPO_LOG_LEVEL" long:"log-level" short:"l" default:"info"`
@@ -0,0 +1,18 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/pdkovacs/igo-repo/security/authr"
+)
+
+// AccessToken describes a personal access token without its secret
+type AccessToken struct {
+	ID          int64                `json:"id"`
+	Owner       string               `json:"owner"`
+	Label       string               `json:"label"`
+	Permissions []authr.PermissionID `json:"permissions"`
+	CreatedAt   time.Time            `json:"createdAt"`
+	ExpiresAt   time.Time            `json:"expiresAt"`
+	LastUsedAt  *time.Time           `json:"lastUsedAt"`
+}
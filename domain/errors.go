@@ -7,4 +7,9 @@ var (
 	ErrIconfileNotFound      = errors.New("iconfile not found")
 	ErrTooManyIconsFound     = errors.New("too many icons found")
 	ErrIconfileAlreadyExists = errors.New("iconfile already exists")
+	ErrAccessTokenNotFound   = errors.New("access token not found")
+	ErrAccessTokenExpired    = errors.New("access token expired")
+	ErrSessionNotFound       = errors.New("session not found")
+	ErrGroupNotFound         = errors.New("group not found")
+	ErrMembershipNotFound    = errors.New("group membership not found")
 )
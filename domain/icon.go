@@ -1,6 +1,11 @@
 package domain
 
-import "fmt"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
 
 type IconfileDescriptor struct {
 	Format string `json:"format"`
@@ -11,16 +16,36 @@ type IconfileDescriptor struct {
 type Iconfile struct {
 	IconfileDescriptor
 	Content []byte
+	// OriginalContent is the content as uploaded if Content is an optimized version of it
+	OriginalContent []byte
 }
 
 func (i Iconfile) String() string {
 	return fmt.Sprintf("Format: %s, Size: %s, Content: [%d bytes long]", i.Format, i.Size, len(i.Content))
 }
 
+// ContentHash is the hex encoded SHA-256 hash of the iconfile content identifying the content
+func ContentHash(content []byte) string {
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+// StoredIconfile is an iconfile along with the data the repository keeps about it
+type StoredIconfile struct {
+	Iconfile
+	ContentHash string
+	CreatedAt   time.Time
+	// Derived tells that the content has been derived on the fly rather than retrieved from the repository, so it
+	// cannot be looked up by its content hash
+	Derived bool
+}
+
 type IconAttributes struct {
 	Name       string
 	ModifiedBy string
 	Tags       []string
+	// Description is free text, keywords and the like, for the icon to be found by
+	Description string
 }
 
 type IconDescriptor struct {
@@ -0,0 +1,21 @@
+package domain
+
+const (
+	UserPrincipal  = "user"
+	GroupPrincipal = "group"
+)
+
+// IconACLEntry grants a permission on an icon to a user or to the members of a group
+type IconACLEntry struct {
+	PrincipalType string `json:"principalType"`
+	Principal     string `json:"principal"`
+	Permission    string `json:"permission"`
+}
+
+// IconACL tells who may change an icon. Icons without an owner (like those created
+// before ownership was recorded) are not restricted.
+type IconACL struct {
+	Owner       string         `json:"owner"`
+	OwningGroup string         `json:"owningGroup"`
+	Entries     []IconACLEntry `json:"entries"`
+}
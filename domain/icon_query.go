@@ -0,0 +1,42 @@
+package domain
+
+const (
+	// TagMatchAny selects icons having any of the tags specified
+	TagMatchAny = "any"
+	// TagMatchAll selects icons having all of the tags specified
+	TagMatchAll = "all"
+)
+
+// Sort keys of icon listings
+const (
+	SortByName       = "name"
+	SortByModifiedAt = "modifiedAt"
+)
+
+// IconQuery selects, orders and pages icons. Zero values mean no filtering, ordering by name and no paging.
+type IconQuery struct {
+	NameContains string
+	NamePrefix   string
+	Tags         []string
+	TagMatch     string
+	Format       string
+	Size         string
+	SortBy       string
+	Descending   bool
+	// Limit is the maximum number of icons to return; zero means no limit
+	Limit int
+	// Cursor is the opaque position after which to continue the listing, as returned with the previous page
+	Cursor string
+}
+
+// IconSearchHit is an icon found by a search along with its relevance to the search
+type IconSearchHit struct {
+	IconDescriptor
+	Score float64
+}
+
+// IconPage is a page of icons along with the cursor to the next page, which is empty on the last page
+type IconPage struct {
+	Icons      []IconDescriptor
+	NextCursor string
+}
@@ -0,0 +1,13 @@
+package domain
+
+import "time"
+
+// SessionInfo describes an active user session. The handle identifies the session
+// for management purposes; it cannot be used to take over the session.
+type SessionInfo struct {
+	Handle     int64     `json:"handle"`
+	Owner      string    `json:"owner"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pdkovacs/igo-repo/domain"
+	"github.com/pdkovacs/igo-repo/security/authr"
+)
+
+func permissionsToText(permissions []authr.PermissionID) string {
+	strs := []string{}
+	for _, permission := range permissions {
+		strs = append(strs, string(permission))
+	}
+	return strings.Join(strs, ",")
+}
+
+func textToPermissions(text string) []authr.PermissionID {
+	permissions := []authr.PermissionID{}
+	if text == "" {
+		return permissions
+	}
+	for _, str := range strings.Split(text, ",") {
+		permissions = append(permissions, authr.PermissionID(str))
+	}
+	return permissions
+}
+
+type accessTokenRowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+const accessTokenColumns = "id, owner, label, permissions, created_at, expires_at, last_used_at"
+
+func scanAccessToken(row accessTokenRowScanner) (domain.AccessToken, error) {
+	var token domain.AccessToken
+	var permissions string
+	var lastUsedAt sql.NullTime
+	err := row.Scan(&token.ID, &token.Owner, &token.Label, &permissions, &token.CreatedAt, &token.ExpiresAt, &lastUsedAt)
+	if err != nil {
+		return domain.AccessToken{}, err
+	}
+	token.Permissions = textToPermissions(permissions)
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	return token, nil
+}
+
+// CreateAccessToken stores a new personal access token identified by the hash of its secret
+func (repo DatabaseRepository) CreateAccessToken(owner string, label string, tokenHash string, permissions []authr.PermissionID, expiresAt time.Time) (domain.AccessToken, error) {
+	const insertSQL = "INSERT INTO access_token(owner, label, token_hash, permissions, expires_at) " +
+		"VALUES($1, $2, $3, $4, $5) RETURNING " + accessTokenColumns
+	token, err := scanAccessToken(repo.ConnectionPool.QueryRow(insertSQL, owner, label, tokenHash, permissionsToText(permissions), expiresAt))
+	if err != nil {
+		return domain.AccessToken{}, fmt.Errorf("failed to create access token \"%s\" for %s: %w", label, owner, err)
+	}
+	return token, nil
+}
+
+// ListAccessTokens lists the personal access tokens of the specified owner
+func (repo DatabaseRepository) ListAccessTokens(owner string) ([]domain.AccessToken, error) {
+	rows, err := repo.ConnectionPool.Query("SELECT "+accessTokenColumns+" FROM access_token WHERE owner = $1 ORDER BY id", owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list access tokens of %s: %w", owner, err)
+	}
+	defer rows.Close()
+
+	tokens := []domain.AccessToken{}
+	for rows.Next() {
+		token, scanErr := scanAccessToken(rows)
+		if scanErr != nil {
+			return nil, fmt.Errorf("failed to list access tokens of %s: %w", owner, scanErr)
+		}
+		tokens = append(tokens, token)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list access tokens of %s: %w", owner, err)
+	}
+	return tokens, nil
+}
+
+// DeleteAccessToken deletes the specified personal access token of the specified owner
+func (repo DatabaseRepository) DeleteAccessToken(owner string, id int64) error {
+	result, err := repo.ConnectionPool.Exec("DELETE FROM access_token WHERE owner = $1 AND id = $2", owner, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete access token %d of %s: %w", id, owner, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve rows affected by deleting access token %d of %s: %w", id, owner, err)
+	}
+	if rowsAffected < 1 {
+		return fmt.Errorf("access token %d of %s: %w", id, owner, domain.ErrAccessTokenNotFound)
+	}
+	return nil
+}
+
+// UseAccessToken looks up the personal access token with the specified hash and records the time of its use
+func (repo DatabaseRepository) UseAccessToken(tokenHash string) (domain.AccessToken, error) {
+	const updateSQL = "UPDATE access_token SET last_used_at = now() WHERE token_hash = $1 RETURNING " + accessTokenColumns
+	token, err := scanAccessToken(repo.ConnectionPool.QueryRow(updateSQL, tokenHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.AccessToken{}, domain.ErrAccessTokenNotFound
+		}
+		return domain.AccessToken{}, fmt.Errorf("failed to look up access token: %w", err)
+	}
+	return token, nil
+}
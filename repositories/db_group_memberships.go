@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"fmt"
+
+	"github.com/pdkovacs/igo-repo/domain"
+)
+
+// GetGroupsOfUser lists the groups the user has been added to via the API
+func (repo DatabaseRepository) GetGroupsOfUser(userID string) ([]string, error) {
+	rows, err := repo.ConnectionPool.Query("SELECT group_name FROM group_membership WHERE user_id = $1 ORDER BY group_name", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list groups of %s: %w", userID, err)
+	}
+	defer rows.Close()
+
+	groups := []string{}
+	for rows.Next() {
+		var group string
+		err = rows.Scan(&group)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list groups of %s: %w", userID, err)
+		}
+		groups = append(groups, group)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list groups of %s: %w", userID, err)
+	}
+	return groups, nil
+}
+
+// GetGroupMemberships lists the members added via the API by group
+func (repo DatabaseRepository) GetGroupMemberships() (map[string][]string, error) {
+	rows, err := repo.ConnectionPool.Query("SELECT group_name, user_id FROM group_membership ORDER BY group_name, user_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list group memberships: %w", err)
+	}
+	defer rows.Close()
+
+	membersByGroup := map[string][]string{}
+	for rows.Next() {
+		var group, userID string
+		err = rows.Scan(&group, &userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list group memberships: %w", err)
+		}
+		membersByGroup[group] = append(membersByGroup[group], userID)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list group memberships: %w", err)
+	}
+	return membersByGroup, nil
+}
+
+// AddGroupMember adds the user to the group; adding an existing member is not an error
+func (repo DatabaseRepository) AddGroupMember(group string, userID string, addedBy string) error {
+	const insertSQL = "INSERT INTO group_membership(group_name, user_id, added_by) VALUES($1, $2, $3) " +
+		"ON CONFLICT (group_name, user_id) DO NOTHING"
+	_, err := repo.ConnectionPool.Exec(insertSQL, group, userID, addedBy)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to group %s: %w", userID, group, err)
+	}
+	return nil
+}
+
+// RemoveGroupMember removes the user from the group
+func (repo DatabaseRepository) RemoveGroupMember(group string, userID string) error {
+	result, err := repo.ConnectionPool.Exec("DELETE FROM group_membership WHERE group_name = $1 AND user_id = $2", group, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove %s from group %s: %w", userID, group, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve rows affected by removing %s from group %s: %w", userID, group, err)
+	}
+	if rowsAffected < 1 {
+		return fmt.Errorf("%s in group %s: %w", userID, group, domain.ErrMembershipNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pdkovacs/igo-repo/domain"
+)
+
+// IconACLCheck tells whether the access control list of the icon permits the change at hand. Changes subject to
+// the ACL run it in their transaction with the icon locked, so that the ACL cannot change in the meantime.
+type IconACLCheck func(acl domain.IconACL) error
+
+// aclQueryer is what the ACL is read through: either the connection pool or a transaction
+type aclQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// GetIconACL returns the owner and the access control entries of the icon
+func (repo DatabaseRepository) GetIconACL(iconName string) (domain.IconACL, error) {
+	return getIconACL(repo.ConnectionPool, iconName, false)
+}
+
+// checkIconACLInTx locks the icon for the rest of the transaction and runs the check on its ACL
+func checkIconACLInTx(tx *sql.Tx, iconName string, check IconACLCheck) error {
+	if check == nil {
+		return nil
+	}
+	acl, err := getIconACL(tx, iconName, true)
+	if err != nil {
+		return err
+	}
+	return check(acl)
+}
+
+func getIconACL(db aclQueryer, iconName string, forUpdate bool) (domain.IconACL, error) {
+	iconSQL := "SELECT id, owner, owning_group FROM icon WHERE name = $1"
+	if forUpdate {
+		iconSQL += " FOR UPDATE"
+	}
+	var iconID int
+	var owner, owningGroup sql.NullString
+	err := db.QueryRow(iconSQL, iconName).Scan(&iconID, &owner, &owningGroup)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.IconACL{}, fmt.Errorf("icon %s not found: %w", iconName, domain.ErrIconNotFound)
+		}
+		return domain.IconACL{}, fmt.Errorf("failed to retrieve the ACL of icon %s: %w", iconName, err)
+	}
+
+	rows, err := db.Query(
+		"SELECT principal_type, principal, permission FROM icon_acl WHERE icon_id = $1 ORDER BY principal_type, principal, permission",
+		iconID,
+	)
+	if err != nil {
+		return domain.IconACL{}, fmt.Errorf("failed to retrieve the ACL of icon %s: %w", iconName, err)
+	}
+	defer rows.Close()
+
+	entries := []domain.IconACLEntry{}
+	for rows.Next() {
+		var entry domain.IconACLEntry
+		err = rows.Scan(&entry.PrincipalType, &entry.Principal, &entry.Permission)
+		if err != nil {
+			return domain.IconACL{}, fmt.Errorf("failed to retrieve the ACL of icon %s: %w", iconName, err)
+		}
+		entries = append(entries, entry)
+	}
+	err = rows.Err()
+	if err != nil {
+		return domain.IconACL{}, fmt.Errorf("failed to retrieve the ACL of icon %s: %w", iconName, err)
+	}
+
+	return domain.IconACL{
+		Owner:       owner.String,
+		OwningGroup: owningGroup.String,
+		Entries:     entries,
+	}, nil
+}
+
+// SetIconACL replaces the owner and the access control entries of the icon provided the current ACL passes the check
+func (repo DatabaseRepository) SetIconACL(iconName string, acl domain.IconACL, checkACL IconACLCheck) error {
+	tx, err := repo.ConnectionPool.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction when setting the ACL of icon %s: %w", iconName, err)
+	}
+	defer tx.Rollback()
+
+	err = checkIconACLInTx(tx, iconName, checkACL)
+	if err != nil {
+		return fmt.Errorf("failed to set the ACL of icon %s: %w", iconName, err)
+	}
+
+	var iconID int
+	err = tx.QueryRow(
+		"UPDATE icon SET owner = $2, owning_group = NULLIF($3, '') WHERE name = $1 RETURNING id",
+		iconName, acl.Owner, acl.OwningGroup,
+	).Scan(&iconID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("icon %s not found: %w", iconName, domain.ErrIconNotFound)
+		}
+		return fmt.Errorf("failed to set the owner of icon %s: %w", iconName, err)
+	}
+
+	_, err = tx.Exec("DELETE FROM icon_acl WHERE icon_id = $1", iconID)
+	if err != nil {
+		return fmt.Errorf("failed to clear the ACL of icon %s: %w", iconName, err)
+	}
+	for _, entry := range acl.Entries {
+		_, err = tx.Exec(
+			"INSERT INTO icon_acl(icon_id, principal_type, principal, permission) VALUES($1, $2, $3, $4) ON CONFLICT DO NOTHING",
+			iconID, entry.PrincipalType, entry.Principal, entry.Permission,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to add ACL entry %v to icon %s: %w", entry, iconName, err)
+		}
+	}
+
+	return tx.Commit()
+}
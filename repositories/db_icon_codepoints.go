@@ -0,0 +1,127 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+const (
+	// firstCodepoint is the start of the Private Use Area of the Basic Multilingual Plane
+	firstCodepoint = 0xE000
+	// lastBMPCodepoint is the end of the Private Use Area of the Basic Multilingual Plane
+	lastBMPCodepoint = 0xF8FF
+	// firstSupplementaryCodepoint is the start of the Supplementary Private Use Area-A
+	firstSupplementaryCodepoint = 0xF0000
+)
+
+// readCodepoints returns the codepoints assigned to the icons along with the next codepoint free to assign
+func readCodepoints(query func(query string, args ...interface{}) (*sql.Rows, error), iconNames []string) (map[string]int, int, error) {
+	codepoints := map[string]int{}
+	requested := map[string]bool{}
+	for _, iconName := range iconNames {
+		requested[iconName] = true
+	}
+	next := firstCodepoint
+	rows, err := query("SELECT icon_name, codepoint FROM icon_codepoint")
+	if err != nil {
+		return codepoints, next, fmt.Errorf("failed to query icon codepoints: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var iconName string
+		var codepoint int
+		err = rows.Scan(&iconName, &codepoint)
+		if err != nil {
+			return codepoints, next, fmt.Errorf("failed to read icon codepoint: %w", err)
+		}
+		if requested[iconName] {
+			codepoints[iconName] = codepoint
+		}
+		if codepoint >= next {
+			next = codepoint + 1
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return codepoints, next, fmt.Errorf("failed to query icon codepoints: %w", err)
+	}
+	return codepoints, next, nil
+}
+
+func allAssigned(codepoints map[string]int, iconNames []string) bool {
+	for _, iconName := range iconNames {
+		if _, assigned := codepoints[iconName]; !assigned {
+			return false
+		}
+	}
+	return true
+}
+
+// GetCodepoints returns the icon font codepoints assigned to the icons
+func (repo DatabaseRepository) GetCodepoints(iconNames []string) (map[string]int, error) {
+	codepoints, _, err := readCodepoints(repo.ConnectionPool.Query, iconNames)
+	return codepoints, err
+}
+
+// assignCodepointsInTx assigns icon font codepoints to those of the icons without one. Icons are assigned codepoints
+// as they are created, so that building a font only reads them. Codepoints once assigned to an icon name are never
+// reassigned so that font users can rely on them.
+func assignCodepointsInTx(tx *sql.Tx, iconNames []string) error {
+	// Assigned codepoints never change, so icons created again with their earlier names need no lock
+	codepoints, _, err := readCodepoints(tx.Query, iconNames)
+	if err != nil || allAssigned(codepoints, iconNames) {
+		return err
+	}
+
+	// Concurrent assignments would try to hand out the same next codepoint
+	_, err = tx.Exec("LOCK TABLE icon_codepoint IN EXCLUSIVE MODE")
+	if err != nil {
+		return fmt.Errorf("failed to lock icon codepoints: %w", err)
+	}
+
+	// Others may have assigned codepoints since they were read without the lock
+	codepoints, next, err := readCodepoints(tx.Query, iconNames)
+	if err != nil {
+		return err
+	}
+
+	for _, iconName := range iconNames {
+		if _, assigned := codepoints[iconName]; assigned {
+			continue
+		}
+		if next > lastBMPCodepoint && next < firstSupplementaryCodepoint {
+			next = firstSupplementaryCodepoint
+		}
+		_, err = tx.Exec("INSERT INTO icon_codepoint(icon_name, codepoint) VALUES($1, $2)", iconName, next)
+		if err != nil {
+			return fmt.Errorf("failed to assign codepoint %x to icon %s: %w", next, iconName, err)
+		}
+		codepoints[iconName] = next
+		next++
+	}
+	return nil
+}
+
+// assignCodepointsToExistingIcons assigns codepoints to the icons created before codepoints were assigned on creation
+func assignCodepointsToExistingIcons(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT name FROM icon ORDER BY name")
+	if err != nil {
+		return fmt.Errorf("failed to query icon names: %w", err)
+	}
+	iconNames := []string{}
+	for rows.Next() {
+		var iconName string
+		err = rows.Scan(&iconName)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read icon name: %w", err)
+		}
+		iconNames = append(iconNames, iconName)
+	}
+	rows.Close()
+	err = rows.Err()
+	if err != nil {
+		return fmt.Errorf("failed to query icon names: %w", err)
+	}
+	return assignCodepointsInTx(tx, iconNames)
+}
@@ -0,0 +1,216 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pdkovacs/igo-repo/domain"
+)
+
+// ErrInvalidCursor is returned for cursors not issued by QueryIcons
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// iconCursor is the position of the last icon on a page in the sort order
+type iconCursor struct {
+	Name       string    `json:"n"`
+	ModifiedAt time.Time `json:"m"`
+}
+
+func encodeCursor(cursor iconCursor) string {
+	cursorJSON, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(cursorJSON)
+}
+
+func decodeCursor(encoded string) (iconCursor, error) {
+	cursor := iconCursor{}
+	cursorJSON, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor, fmt.Errorf("%s: %w", encoded, ErrInvalidCursor)
+	}
+	err = json.Unmarshal(cursorJSON, &cursor)
+	if err != nil {
+		return cursor, fmt.Errorf("%s: %w", encoded, ErrInvalidCursor)
+	}
+	return cursor, nil
+}
+
+func escapeLikePattern(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// iconQueryBuilder collects the conditions and the arguments of the icon query
+type iconQueryBuilder struct {
+	conditions []string
+	args       []interface{}
+}
+
+func (builder *iconQueryBuilder) arg(value interface{}) string {
+	builder.args = append(builder.args, value)
+	return fmt.Sprintf("$%d", len(builder.args))
+}
+
+func (builder *iconQueryBuilder) where(condition string) {
+	builder.conditions = append(builder.conditions, condition)
+}
+
+func (builder *iconQueryBuilder) argList(values []string) string {
+	placeholders := []string{}
+	for _, value := range values {
+		placeholders = append(placeholders, builder.arg(value))
+	}
+	return strings.Join(placeholders, ", ")
+}
+
+// iconColumnsSQL selects an icon along with its iconfiles and tags aggregated into JSON arrays as read by scanIconRow
+const iconColumnsSQL = "icon.name, icon.modified_by, icon.modified_at, icon.description, " +
+	"COALESCE((SELECT json_agg(json_build_object('format', file_format, 'size', icon_size) ORDER BY file_format, icon_size) " +
+	"FROM icon_file WHERE icon_file.icon_id = icon.id), '[]'), " +
+	"COALESCE((SELECT json_agg(tag.text ORDER BY tag.text) FROM tag, icon_to_tags " +
+	"WHERE icon_to_tags.icon_id = icon.id AND icon_to_tags.tag_id = tag.id), '[]')"
+
+// QueryIcons selects the icons matching the query in a single statement, iconfiles and tags included
+func (repo DatabaseRepository) QueryIcons(query domain.IconQuery) (domain.IconPage, error) {
+	builder := iconQueryBuilder{}
+
+	if query.NameContains != "" {
+		builder.where("icon.name ILIKE '%' || " + builder.arg(escapeLikePattern(query.NameContains)) + " || '%'")
+	}
+	if query.NamePrefix != "" {
+		builder.where("icon.name ILIKE " + builder.arg(escapeLikePattern(query.NamePrefix)) + " || '%'")
+	}
+
+	if len(query.Tags) > 0 {
+		tagsWithIcon := "SELECT count(DISTINCT tag.text) FROM tag, icon_to_tags " +
+			"WHERE icon_to_tags.icon_id = icon.id AND icon_to_tags.tag_id = tag.id " +
+			"AND tag.text IN (" + builder.argList(query.Tags) + ")"
+		if query.TagMatch == domain.TagMatchAll {
+			builder.where("(" + tagsWithIcon + ") = " + builder.arg(len(uniqueStrings(query.Tags))))
+		} else {
+			builder.where("(" + tagsWithIcon + ") > 0")
+		}
+	}
+
+	if query.Format != "" || query.Size != "" {
+		iconfileCondition := "EXISTS (SELECT 1 FROM icon_file WHERE icon_file.icon_id = icon.id"
+		if query.Format != "" {
+			iconfileCondition += " AND icon_file.file_format = " + builder.arg(query.Format)
+		}
+		if query.Size != "" {
+			iconfileCondition += " AND icon_file.icon_size = " + builder.arg(query.Size)
+		}
+		builder.where(iconfileCondition + ")")
+	}
+
+	comparison := ">"
+	direction := "ASC"
+	if query.Descending {
+		comparison = "<"
+		direction = "DESC"
+	}
+	orderBy := "icon.name " + direction
+	if query.SortBy == domain.SortByModifiedAt {
+		orderBy = fmt.Sprintf("icon.modified_at %s, icon.name %s", direction, direction)
+	}
+
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return domain.IconPage{}, err
+		}
+		if query.SortBy == domain.SortByModifiedAt {
+			builder.where(fmt.Sprintf(
+				"(icon.modified_at, icon.name) %s (%s::timestamp, %s)",
+				comparison, builder.arg(cursor.ModifiedAt.Format("2006-01-02 15:04:05.999999")), builder.arg(cursor.Name),
+			))
+		} else {
+			builder.where(fmt.Sprintf("icon.name %s %s", comparison, builder.arg(cursor.Name)))
+		}
+	}
+
+	querySQL := "SELECT " + iconColumnsSQL + " FROM icon"
+	if len(builder.conditions) > 0 {
+		querySQL += " WHERE " + strings.Join(builder.conditions, " AND ")
+	}
+	querySQL += " ORDER BY " + orderBy
+	if query.Limit > 0 {
+		// One more to tell whether there is a next page
+		querySQL += " LIMIT " + builder.arg(query.Limit+1)
+	}
+
+	rows, err := repo.ConnectionPool.Query(querySQL, builder.args...)
+	if err != nil {
+		return domain.IconPage{}, fmt.Errorf("failed to query icons: %w", err)
+	}
+	defer rows.Close()
+
+	icons := []domain.IconDescriptor{}
+	modifiedAts := []time.Time{}
+	for rows.Next() {
+		icon, modifiedAt, scanErr := scanIconRow(rows)
+		if scanErr != nil {
+			return domain.IconPage{}, scanErr
+		}
+		icons = append(icons, icon)
+		modifiedAts = append(modifiedAts, modifiedAt)
+	}
+	err = rows.Err()
+	if err != nil {
+		return domain.IconPage{}, fmt.Errorf("failed to query icons: %w", err)
+	}
+
+	page := domain.IconPage{Icons: icons}
+	if query.Limit > 0 && len(icons) > query.Limit {
+		page.Icons = icons[:query.Limit]
+		last := query.Limit - 1
+		page.NextCursor = encodeCursor(iconCursor{Name: icons[last].Name, ModifiedAt: modifiedAts[last]})
+	}
+	return page, nil
+}
+
+// scanIconRow reads the columns of iconColumnsSQL followed by the columns to read into the extra destinations
+func scanIconRow(rows *sql.Rows, extraDest ...interface{}) (domain.IconDescriptor, time.Time, error) {
+	var name, modifiedBy, description string
+	var modifiedAt time.Time
+	var iconfilesJSON, tagsJSON []byte
+	dest := append([]interface{}{&name, &modifiedBy, &modifiedAt, &description, &iconfilesJSON, &tagsJSON}, extraDest...)
+	err := rows.Scan(dest...)
+	if err != nil {
+		return domain.IconDescriptor{}, modifiedAt, fmt.Errorf("failed to read icon: %w", err)
+	}
+	iconfiles := []domain.IconfileDescriptor{}
+	err = json.Unmarshal(iconfilesJSON, &iconfiles)
+	if err != nil {
+		return domain.IconDescriptor{}, modifiedAt, fmt.Errorf("failed to read iconfiles of icon %s: %w", name, err)
+	}
+	tags := []string{}
+	err = json.Unmarshal(tagsJSON, &tags)
+	if err != nil {
+		return domain.IconDescriptor{}, modifiedAt, fmt.Errorf("failed to read tags of icon %s: %w", name, err)
+	}
+	return domain.IconDescriptor{
+		IconAttributes: domain.IconAttributes{
+			Name:        name,
+			ModifiedBy:  modifiedBy,
+			Tags:        tags,
+			Description: description,
+		},
+		Iconfiles: iconfiles,
+	}, modifiedAt, nil
+}
+
+func uniqueStrings(strs []string) []string {
+	unique := []string{}
+	seen := map[string]bool{}
+	for _, s := range strs {
+		if !seen[s] {
+			seen[s] = true
+			unique = append(unique, s)
+		}
+	}
+	return unique
+}
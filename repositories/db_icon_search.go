@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/pdkovacs/igo-repo/domain"
+)
+
+// searchTerms splits the search text into words
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// fullTextQuery builds a tsquery matching documents with any of the words of the search text, also as prefixes
+func fullTextQuery(text string) string {
+	terms := []string{}
+	for _, term := range searchTerms(text) {
+		terms = append(terms, term+":*")
+	}
+	return strings.Join(terms, " | ")
+}
+
+// SearchIcons returns the icons most relevant to the search text ordered by relevance. Icons are found by the words in their names,
+// tags and descriptions as well as by names similar to the search text so as to tolerate typos.
+func (repo DatabaseRepository) SearchIcons(text string, limit int) ([]domain.IconSearchHit, error) {
+	hits := []domain.IconSearchHit{}
+
+	tsQuery := fullTextQuery(text)
+	if tsQuery == "" {
+		return hits, nil
+	}
+	fuzzyText := strings.Join(searchTerms(text), " ")
+
+	searchSQL := "SELECT " + iconColumnsSQL + ", " +
+		"ts_rank(icon.search_document, query) + word_similarity($2, icon.name) AS score " +
+		"FROM icon, to_tsquery('english', $1) query " +
+		"WHERE icon.search_document @@ query OR $2 <% icon.name " +
+		"ORDER BY score DESC, icon.name " +
+		"LIMIT $3"
+
+	rows, err := repo.ConnectionPool.Query(searchSQL, tsQuery, fuzzyText, limit)
+	if err != nil {
+		return hits, fmt.Errorf("failed to search icons for %s: %w", text, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var score float64
+		icon, _, scanErr := scanIconRow(rows, &score)
+		if scanErr != nil {
+			return hits, scanErr
+		}
+		hits = append(hits, domain.IconSearchHit{IconDescriptor: icon, Score: score})
+	}
+	err = rows.Err()
+	if err != nil {
+		return hits, fmt.Errorf("failed to search icons for %s: %w", text, err)
+	}
+	return hits, nil
+}
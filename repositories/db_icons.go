@@ -17,7 +17,7 @@ func describeIconInTx(tx *sql.Tx, iconName string, forUpdate bool) (domain.IconD
 	if forUpdate {
 		forUpdateClause = " FOR UPDATE"
 	}
-	var iconSQL = "SELECT id, modified_by FROM icon WHERE name = $1" + forUpdateClause
+	var iconSQL = "SELECT id, modified_by, description FROM icon WHERE name = $1" + forUpdateClause
 	var iconfilesSQL = "SELECT file_format, icon_size FROM icon_file " +
 		"WHERE icon_id = $1 " +
 		"ORDER BY file_format, icon_size" + forUpdateClause
@@ -27,7 +27,8 @@ func describeIconInTx(tx *sql.Tx, iconName string, forUpdate bool) (domain.IconD
 
 	var iconId int
 	var modifiedBy string
-	err = tx.QueryRow(iconSQL, iconName).Scan(&iconId, &modifiedBy)
+	var description string
+	err = tx.QueryRow(iconSQL, iconName).Scan(&iconId, &modifiedBy, &description)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return domain.IconDescriptor{}, fmt.Errorf("icon %s not found: %w", iconName, domain.ErrIconNotFound)
@@ -85,9 +86,10 @@ func describeIconInTx(tx *sql.Tx, iconName string, forUpdate bool) (domain.IconD
 
 	return domain.IconDescriptor{
 		IconAttributes: domain.IconAttributes{
-			Name:       iconName,
-			ModifiedBy: modifiedBy,
-			Tags:       tags,
+			Name:        iconName,
+			ModifiedBy:  modifiedBy,
+			Tags:        tags,
+			Description: description,
 		},
 		Iconfiles: iconfiles,
 	}, nil
@@ -104,44 +106,16 @@ func (repo DatabaseRepository) DescribeIcon(iconName string) (domain.IconDescrip
 }
 
 func (repo DatabaseRepository) DescribeAllIcons() ([]domain.IconDescriptor, error) {
-	tx, err := repo.ConnectionPool.Begin()
+	page, err := repo.QueryIcons(domain.IconQuery{})
 	if err != nil {
-		return []domain.IconDescriptor{}, err
-	}
-	defer tx.Rollback()
-
-	rows, errQuery := tx.Query("SELECT name FROM icon")
-	if errQuery != nil {
-		return []domain.IconDescriptor{}, fmt.Errorf("failed to retrieve all icon names: %w", errQuery)
+		return []domain.IconDescriptor{}, fmt.Errorf("failed to describe all icons: %w", err)
 	}
-	defer rows.Close()
-
-	iconNames := []string{}
-	for rows.Next() {
-		var name string
-		rows.Scan(&name)
-		iconNames = append(iconNames, name)
-	}
-	errProcessRows := rows.Err()
-	if errProcessRows != nil {
-		return []domain.IconDescriptor{}, fmt.Errorf("error while processing rows: %w", errProcessRows)
-	}
-
-	result := []domain.IconDescriptor{}
-	for _, iconName := range iconNames {
-		icon, errIconDesc := describeIconInTx(tx, iconName, false)
-		if errIconDesc != nil {
-			return []domain.IconDescriptor{}, fmt.Errorf("failed to retrieve icon %s: %w", iconName, errIconDesc)
-		}
-		result = append(result, icon)
-	}
-
-	return result, nil
+	return page.Icons, nil
 }
 
 type CreateSideEffect func() error
 
-func (repo DatabaseRepository) CreateIcon(iconName string, iconfile domain.Iconfile, modifiedBy string, createSideEffect CreateSideEffect) error {
+func (repo DatabaseRepository) CreateIcon(iconName string, iconfile domain.Iconfile, modifiedBy string, owningGroup string, createSideEffect CreateSideEffect) error {
 	var tx *sql.Tx
 	var err error
 	tx, err = repo.ConnectionPool.Begin()
@@ -150,8 +124,9 @@ func (repo DatabaseRepository) CreateIcon(iconName string, iconfile domain.Iconf
 	}
 	defer tx.Rollback()
 
-	const insertIconSQL string = "INSERT INTO icon(name, modified_by) VALUES($1, $2) RETURNING id"
-	_, err = tx.Exec(insertIconSQL, iconName, modifiedBy)
+	// The creator owns the icon along with the group, if any, they have handed it over to
+	const insertIconSQL string = "INSERT INTO icon(name, modified_by, owner, owning_group) VALUES($1, $2, $2, NULLIF($3, '')) RETURNING id"
+	_, err = tx.Exec(insertIconSQL, iconName, modifiedBy, owningGroup)
 	if err != nil {
 		return fmt.Errorf("failed to create icon %v: %w", iconName, err)
 	}
@@ -161,6 +136,11 @@ func (repo DatabaseRepository) CreateIcon(iconName string, iconfile domain.Iconf
 		return fmt.Errorf("failed to create iconfile for %v: %w", iconName, err)
 	}
 
+	err = assignCodepointsInTx(tx, []string{iconName})
+	if err != nil {
+		return fmt.Errorf("failed to create icon %v: %w", iconName, err)
+	}
+
 	if createSideEffect != nil {
 		err = createSideEffect()
 		if err != nil {
@@ -173,6 +153,57 @@ func (repo DatabaseRepository) CreateIcon(iconName string, iconfile domain.Iconf
 	return nil
 }
 
+// ImportIcons adds the iconfiles to the icons creating those of the icons which don't exist yet, all in a single transaction.
+// Each icon is locked and its ACL checked with the check of checkACLs keyed by its name before iconfiles are added to it.
+func (repo DatabaseRepository) ImportIcons(icons []domain.Icon, modifiedBy string, checkACLs map[string]IconACLCheck, createSideEffect CreateSideEffect) error {
+	tx, err := repo.ConnectionPool.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction when importing icons: %w", err)
+	}
+	defer tx.Rollback()
+
+	const insertIconSQL string = "INSERT INTO icon(name, modified_by, owner) VALUES($1, $2, $2) ON CONFLICT (name) DO NOTHING"
+	for _, icon := range icons {
+		_, err = tx.Exec(insertIconSQL, icon.Name, modifiedBy)
+		if err != nil {
+			return fmt.Errorf("failed to create icon %v: %w", icon.Name, err)
+		}
+		err = checkIconACLInTx(tx, icon.Name, checkACLs[icon.Name])
+		if err != nil {
+			return fmt.Errorf("failed to import iconfiles to icon %v: %w", icon.Name, err)
+		}
+		for _, iconfile := range icon.Iconfiles {
+			err = insertIconfile(tx, icon.Name, iconfile, modifiedBy)
+			if err != nil {
+				return fmt.Errorf("failed to import iconfile %v for %v: %w", iconfile.IconfileDescriptor, icon.Name, err)
+			}
+		}
+		err = updateModifier(tx, icon.Name, modifiedBy)
+		if err != nil {
+			return fmt.Errorf("failed to import icon %v: %w", icon.Name, err)
+		}
+	}
+
+	iconNames := make([]string, 0, len(icons))
+	for _, icon := range icons {
+		iconNames = append(iconNames, icon.Name)
+	}
+	err = assignCodepointsInTx(tx, iconNames)
+	if err != nil {
+		return fmt.Errorf("failed to import icons: %w", err)
+	}
+
+	if createSideEffect != nil {
+		err = createSideEffect()
+		if err != nil {
+			return fmt.Errorf("failed to import icons due to error while creating side-effect: %w", err)
+		}
+	}
+
+	tx.Commit()
+	return nil
+}
+
 func updateModifier(tx *sql.Tx, iconName string, modifiedBy string) error {
 	_, err := tx.Exec("UPDATE icon SET modified_by = $1 WHERE name = $2", modifiedBy, iconName)
 	if err != nil {
@@ -181,7 +212,7 @@ func updateModifier(tx *sql.Tx, iconName string, modifiedBy string) error {
 	return nil
 }
 
-func (repo DatabaseRepository) AddIconfileToIcon(iconName string, iconfile domain.Iconfile, modifiedBy string, createSideEffect CreateSideEffect) error {
+func (repo DatabaseRepository) AddIconfileToIcon(iconName string, iconfile domain.Iconfile, modifiedBy string, checkACL IconACLCheck, createSideEffect CreateSideEffect) error {
 	var tx *sql.Tx
 	var err error
 
@@ -191,6 +222,11 @@ func (repo DatabaseRepository) AddIconfileToIcon(iconName string, iconfile domai
 	}
 	defer tx.Rollback()
 
+	err = checkIconACLInTx(tx, iconName, checkACL)
+	if err != nil {
+		return fmt.Errorf("failed to add iconfile %v to icon %s: %w", iconfile.IconfileDescriptor, iconName, err)
+	}
+
 	err = insertIconfile(tx, iconName, iconfile, modifiedBy)
 	if err != nil {
 		return fmt.Errorf("failed to create iconfile %v: %w", iconName, err)
@@ -213,9 +249,14 @@ func (repo DatabaseRepository) AddIconfileToIcon(iconName string, iconfile domai
 }
 
 func insertIconfile(tx *sql.Tx, iconName string, iconfile domain.Iconfile, modifiedBy string) error {
-	const insertIconfileSQL = "INSERT INTO icon_file(icon_id, file_format, icon_size, content) " +
-		"SELECT id, $2, $3, $4 FROM icon WHERE name = $1 RETURNING id"
-	_, err := tx.Exec(insertIconfileSQL, iconName, iconfile.Format, iconfile.Size, iconfile.Content)
+	const insertIconfileSQL = "INSERT INTO icon_file(icon_id, file_format, icon_size, content, content_hash, original_content, original_content_hash) " +
+		"SELECT id, $2, $3, $4, $5, $6, $7 FROM icon WHERE name = $1 RETURNING id"
+	var originalContentHash interface{}
+	if iconfile.OriginalContent != nil {
+		originalContentHash = domain.ContentHash(iconfile.OriginalContent)
+	}
+	_, err := tx.Exec(insertIconfileSQL, iconName, iconfile.Format, iconfile.Size, iconfile.Content, domain.ContentHash(iconfile.Content),
+		iconfile.OriginalContent, originalContentHash)
 	if err != nil {
 		if pgErr, ok := err.(*pgx.PgError); !ok || pgErr.Code != "23505" {
 			return domain.ErrIconfileAlreadyExists
@@ -248,6 +289,75 @@ func (repo DatabaseRepository) GetIconFile(iconName, format, iconSize string) ([
 	return content, nil
 }
 
+// GetStoredIconfile returns the iconfile along with its content hash and creation time
+func (repo DatabaseRepository) GetStoredIconfile(iconName, format, iconSize string) (domain.StoredIconfile, error) {
+	const getIconfileSQL = "SELECT content, content_hash, created_at FROM icon, icon_file " +
+		"WHERE icon_id = icon.id AND " +
+		"file_format = $2 AND " +
+		"icon_size = $3 AND " +
+		"icon.name = $1"
+
+	iconfile := domain.StoredIconfile{
+		Iconfile: domain.Iconfile{
+			IconfileDescriptor: domain.IconfileDescriptor{Format: format, Size: iconSize},
+		},
+	}
+	err := repo.ConnectionPool.QueryRow(getIconfileSQL, iconName, format, iconSize).Scan(&iconfile.Content, &iconfile.ContentHash, &iconfile.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.StoredIconfile{}, fmt.Errorf("iconfile %v for icon %s not found %w", iconfile.IconfileDescriptor, iconName, domain.ErrIconfileNotFound)
+		}
+		return domain.StoredIconfile{}, fmt.Errorf("failed to get iconfile %v: %w", iconName, err)
+	}
+	return iconfile, nil
+}
+
+// GetOriginalIconfile returns the iconfile as uploaded, which is the iconfile itself unless it has been optimized
+func (repo DatabaseRepository) GetOriginalIconfile(iconName, format, iconSize string) (domain.StoredIconfile, error) {
+	const getIconfileSQL = "SELECT coalesce(original_content, content), coalesce(original_content_hash, content_hash), created_at " +
+		"FROM icon, icon_file " +
+		"WHERE icon_id = icon.id AND " +
+		"file_format = $2 AND " +
+		"icon_size = $3 AND " +
+		"icon.name = $1"
+
+	iconfile := domain.StoredIconfile{
+		Iconfile: domain.Iconfile{
+			IconfileDescriptor: domain.IconfileDescriptor{Format: format, Size: iconSize},
+		},
+	}
+	err := repo.ConnectionPool.QueryRow(getIconfileSQL, iconName, format, iconSize).Scan(&iconfile.Content, &iconfile.ContentHash, &iconfile.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.StoredIconfile{}, fmt.Errorf("iconfile %v for icon %s not found %w", iconfile.IconfileDescriptor, iconName, domain.ErrIconfileNotFound)
+		}
+		return domain.StoredIconfile{}, fmt.Errorf("failed to get original iconfile %v: %w", iconName, err)
+	}
+	return iconfile, nil
+}
+
+// GetIconfileByHash returns an iconfile having the specified content hash, the original content of optimized
+// iconfiles included
+func (repo DatabaseRepository) GetIconfileByHash(contentHash string) (domain.StoredIconfile, error) {
+	const getIconfileSQL = "SELECT file_format, icon_size, " +
+		"CASE WHEN content_hash = $1 THEN content ELSE original_content END, " +
+		"CASE WHEN content_hash = $1 THEN content_hash ELSE original_content_hash END, created_at FROM icon_file " +
+		"WHERE content_hash = $1 OR original_content_hash = $1 " +
+		"ORDER BY created_at LIMIT 1"
+
+	iconfile := domain.StoredIconfile{}
+	err := repo.ConnectionPool.QueryRow(getIconfileSQL, contentHash).Scan(
+		&iconfile.Format, &iconfile.Size, &iconfile.Content, &iconfile.ContentHash, &iconfile.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.StoredIconfile{}, fmt.Errorf("iconfile with content hash %s not found %w", contentHash, domain.ErrIconfileNotFound)
+		}
+		return domain.StoredIconfile{}, fmt.Errorf("failed to get iconfile with content hash %s: %w", contentHash, err)
+	}
+	return iconfile, nil
+}
+
 func (repo DatabaseRepository) GetExistingTags() ([]string, error) {
 	rows, err := repo.ConnectionPool.Query("SELECT text FROM tag")
 	if err != nil {
@@ -339,6 +449,25 @@ func (repo DatabaseRepository) AddTag(iconName string, tag string, modifiedBy st
 	return nil
 }
 
+// UpdateIconDescription replaces the description of the icon
+func (repo DatabaseRepository) UpdateIconDescription(iconName string, description string, modifiedBy string) error {
+	result, err := repo.ConnectionPool.Exec(
+		"UPDATE icon SET description = $1, modified_by = $2, modified_at = now() WHERE name = $3",
+		description, modifiedBy, iconName,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update description of icon '%s': %w", iconName, err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update description of icon '%s': %w", iconName, err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("icon %s not found: %w", iconName, domain.ErrIconNotFound)
+	}
+	return nil
+}
+
 func (repo DatabaseRepository) RemoveTag(iconName string, tag string, modifiedBy string) error {
 	tx, trError := repo.ConnectionPool.Begin()
 	if trError != nil {
@@ -403,7 +532,7 @@ func deleteIconfileBare(tx *sql.Tx, iconName string, iconfile domain.IconfileDes
 	return sqlResult, nil
 }
 
-func (repo DatabaseRepository) DeleteIcon(iconName string, modifiedBy string, createSideEffect CreateSideEffect) error {
+func (repo DatabaseRepository) DeleteIcon(iconName string, modifiedBy string, checkACL IconACLCheck, createSideEffect CreateSideEffect) error {
 	var tx *sql.Tx
 	var err error
 
@@ -413,6 +542,11 @@ func (repo DatabaseRepository) DeleteIcon(iconName string, modifiedBy string, cr
 	}
 	defer tx.Rollback()
 
+	err = checkIconACLInTx(tx, iconName, checkACL)
+	if err != nil {
+		return fmt.Errorf("failed to delete icon %s: %w", iconName, err)
+	}
+
 	var iconDesc domain.IconDescriptor
 	iconDesc, err = describeIconInTx(tx, iconName, true)
 	if err != nil {
@@ -437,7 +571,7 @@ func (repo DatabaseRepository) DeleteIcon(iconName string, modifiedBy string, cr
 	return nil
 }
 
-func (repo DatabaseRepository) DeleteIconfile(iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect CreateSideEffect) error {
+func (repo DatabaseRepository) DeleteIconfile(iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, checkACL IconACLCheck, createSideEffect CreateSideEffect) error {
 	var err error
 	var tx *sql.Tx
 	var sqlResult sql.Result
@@ -449,6 +583,11 @@ func (repo DatabaseRepository) DeleteIconfile(iconName string, iconfile domain.I
 	}
 	defer tx.Rollback()
 
+	err = checkIconACLInTx(tx, iconName, checkACL)
+	if err != nil {
+		return fmt.Errorf("failed to delete iconfile %v from %s: %w", iconfile, iconName, err)
+	}
+
 	sqlResult, err = deleteIconfileBare(tx, iconName, iconfile)
 	if err != nil {
 		return fmt.Errorf("failed to delete iconfile %v from %s: %w", iconfile, iconName, err)
@@ -4,14 +4,20 @@ import (
 	"database/sql"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/pdkovacs/igo-repo/domain"
 	log "github.com/sirupsen/logrus"
 )
 
 type upgradeStep struct {
 	version string
-	sqls    []string
+	// prerequisite, when set, is checked before the SQL statements are executed
+	prerequisite func(tx *sql.Tx) error
+	sqls         []string
+	// migrate, when set, is executed after the SQL statements for data migrations not expressible in SQL
+	migrate func(tx *sql.Tx) error
 }
 
 var upgradeSteps = []upgradeStep{
@@ -45,10 +51,237 @@ var upgradeSteps = []upgradeStep{
 				")",
 		},
 	},
+	{
+		version: "2026-10-18/2 - personal access tokens",
+		sqls: []string{
+			`CREATE TABLE access_token(
+				id           serial primary key,
+				owner        text NOT NULL,
+				label        text,
+				token_hash   text NOT NULL,
+				permissions  text,
+				created_at   timestamptz DEFAULT now(),
+				expires_at   timestamptz NOT NULL,
+				last_used_at timestamptz,
+				UNIQUE(token_hash)
+			)`,
+			"CREATE INDEX access_token_owner_idx ON access_token(owner)",
+		},
+	},
+	{
+		version: "2026-10-18/3 - http sessions",
+		sqls: []string{
+			`CREATE TABLE http_session(
+				id         text primary key,
+				data       bytea NOT NULL,
+				expires_at timestamptz NOT NULL
+			)`,
+			"CREATE INDEX http_session_expires_at_idx ON http_session(expires_at)",
+		},
+	},
+	{
+		version: "2026-10-18/4 - http session owners",
+		sqls: []string{
+			"ALTER TABLE http_session ADD COLUMN handle serial",
+			"ALTER TABLE http_session ADD COLUMN owner text",
+			"ALTER TABLE http_session ADD COLUMN created_at timestamptz DEFAULT now()",
+			"ALTER TABLE http_session ADD COLUMN last_seen_at timestamptz DEFAULT now()",
+			"CREATE INDEX http_session_owner_idx ON http_session(owner)",
+		},
+	},
+	{
+		version: "2026-10-18/5 - group memberships",
+		sqls: []string{
+			`CREATE TABLE group_membership(
+				group_name text NOT NULL,
+				user_id    text NOT NULL,
+				added_by   text,
+				added_at   timestamptz DEFAULT now(),
+				PRIMARY KEY(group_name, user_id)
+			)`,
+			"CREATE INDEX group_membership_user_idx ON group_membership(user_id)",
+		},
+	},
+	{
+		version: "2026-10-18/6 - icon access control lists",
+		sqls: []string{
+			"ALTER TABLE icon ADD COLUMN owner text",
+			"ALTER TABLE icon ADD COLUMN owning_group text",
+			`CREATE TABLE icon_acl(
+				icon_id        int REFERENCES icon(id) ON DELETE CASCADE,
+				principal_type text NOT NULL,
+				principal      text NOT NULL,
+				permission     text NOT NULL,
+				PRIMARY KEY(icon_id, principal_type, principal, permission)
+			)`,
+		},
+	},
+	{
+		version: "2026-10-18/7 - iconfile content hashes",
+		sqls: []string{
+			"ALTER TABLE icon_file ADD COLUMN content_hash text",
+			"ALTER TABLE icon_file ADD COLUMN created_at timestamptz DEFAULT now()",
+			"CREATE INDEX icon_file_content_hash_idx ON icon_file(content_hash)",
+		},
+		migrate: backfillContentHashes,
+	},
+	{
+		version:      "2026-10-18/8 - icon full-text search",
+		prerequisite: requireExtension("pg_trgm"),
+		sqls: []string{
+			"ALTER TABLE icon ADD COLUMN description text NOT NULL DEFAULT ''",
+			"ALTER TABLE icon ADD COLUMN search_document tsvector",
+			// Names are weighted over tags, tags over the description
+			`CREATE FUNCTION icon_search_document(icon_id int, icon_name text, icon_description text) RETURNS tsvector
+				LANGUAGE sql STABLE AS $$
+					SELECT setweight(to_tsvector('english', translate(icon_name, '_-', '  ')), 'A') ||
+						setweight(to_tsvector('english', coalesce((
+							SELECT string_agg(tag.text, ' ') FROM tag, icon_to_tags
+							WHERE icon_to_tags.icon_id = $1 AND icon_to_tags.tag_id = tag.id
+						), '')), 'B') ||
+						setweight(to_tsvector('english', icon_description), 'C')
+				$$`,
+			`CREATE FUNCTION icon_search_document_trigger() RETURNS trigger
+				LANGUAGE plpgsql AS $$
+					BEGIN
+						NEW.search_document := icon_search_document(NEW.id, NEW.name, NEW.description);
+						RETURN NEW;
+					END
+				$$`,
+			`CREATE TRIGGER icon_search_document_update BEFORE INSERT OR UPDATE OF name, description ON icon
+				FOR EACH ROW EXECUTE PROCEDURE icon_search_document_trigger()`,
+			`CREATE FUNCTION icon_tags_search_document_trigger() RETURNS trigger
+				LANGUAGE plpgsql AS $$
+					DECLARE
+						tagged_icon_id int;
+					BEGIN
+						IF TG_OP = 'DELETE' THEN
+							tagged_icon_id := OLD.icon_id;
+						ELSE
+							tagged_icon_id := NEW.icon_id;
+						END IF;
+						UPDATE icon SET search_document = icon_search_document(id, name, description) WHERE id = tagged_icon_id;
+						RETURN NULL;
+					END
+				$$`,
+			`CREATE TRIGGER icon_tags_search_document_update AFTER INSERT OR DELETE ON icon_to_tags
+				FOR EACH ROW EXECUTE PROCEDURE icon_tags_search_document_trigger()`,
+			"UPDATE icon SET search_document = icon_search_document(id, name, description)",
+			"CREATE INDEX icon_search_document_idx ON icon USING gin(search_document)",
+			"CREATE INDEX icon_name_trgm_idx ON icon USING gin(name gin_trgm_ops)",
+		},
+	},
+	{
+		version: "2026-10-18/9 - icon font codepoints",
+		sqls: []string{
+			// Keyed by name rather than by icon id so that an icon deleted and added again gets its codepoint back
+			`CREATE TABLE icon_codepoint(
+				icon_name   text PRIMARY KEY,
+				codepoint   int NOT NULL UNIQUE,
+				assigned_at timestamptz DEFAULT now()
+			)`,
+		},
+	},
+	{
+		version: "2026-10-18/10 - original content of optimized iconfiles",
+		sqls: []string{
+			"ALTER TABLE icon_file ADD COLUMN original_content bytea",
+			"ALTER TABLE icon_file ADD COLUMN original_content_hash text",
+			"CREATE INDEX icon_file_original_content_hash_idx ON icon_file(original_content_hash)",
+		},
+	},
+	{
+		version: "2026-10-18/11 - codepoints assigned on icon creation",
+		migrate: assignCodepointsToExistingIcons,
+	},
+}
+
+// backfillContentHashes computes the content hashes of the existing iconfiles (sha256() is missing from PostgreSQL 10)
+func backfillContentHashes(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT id, content FROM icon_file WHERE content_hash IS NULL")
+	if err != nil {
+		return fmt.Errorf("failed to query iconfiles without content hash: %w", err)
+	}
+	contentHashes := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var content []byte
+		err = rows.Scan(&id, &content)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read iconfile without content hash: %w", err)
+		}
+		contentHashes[id] = domain.ContentHash(content)
+	}
+	rows.Close()
+	err = rows.Err()
+	if err != nil {
+		return fmt.Errorf("failed to query iconfiles without content hash: %w", err)
+	}
+	for id, contentHash := range contentHashes {
+		_, err = tx.Exec("UPDATE icon_file SET content_hash = $1 WHERE id = $2", contentHash, id)
+		if err != nil {
+			return fmt.Errorf("failed to set content hash of iconfile %d: %w", id, err)
+		}
+	}
+	return nil
 }
 
+// requireExtension makes sure the extension is installed in the database. Creating most extensions takes privileges
+// the database owner the application connects as is unlikely to have, so the extension is expected to have been created
+// by a superuser in advance. Creating it is attempted all the same, without failing the transaction, for development
+// setups where the application connects as a superuser.
+func requireExtension(extension string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		var installed bool
+		err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM pg_extension WHERE extname = $1)", extension).Scan(&installed)
+		if err != nil {
+			return fmt.Errorf("failed to check whether the %s extension is installed: %w", extension, err)
+		}
+		if installed {
+			return nil
+		}
+
+		_, err = tx.Exec("SAVEPOINT create_extension")
+		if err != nil {
+			return fmt.Errorf("failed to create savepoint for creating the %s extension: %w", extension, err)
+		}
+		_, errCreate := tx.Exec(fmt.Sprintf("CREATE EXTENSION %s", extension))
+		if errCreate == nil {
+			_, err = tx.Exec("RELEASE SAVEPOINT create_extension")
+			return err
+		}
+		_, err = tx.Exec("ROLLBACK TO SAVEPOINT create_extension")
+		if err != nil {
+			return fmt.Errorf("failed to roll back the attempt to create the %s extension: %w", extension, err)
+		}
+		return fmt.Errorf(
+			"the %s extension is missing from the database and cannot be created by the application (%v): "+
+				"have a superuser execute 'CREATE EXTENSION %s;' in the database",
+			extension, errCreate, extension,
+		)
+	}
+}
+
+// parseVersion splits the "<date>/<sequence number> - <description>" version of an upgrade step
+func parseVersion(version string) (string, int) {
+	parts := strings.SplitN(version, "/", 2)
+	if len(parts) < 2 {
+		return version, 0
+	}
+	sequenceNumber, _ := strconv.Atoi(strings.SplitN(parts[1], " ", 2)[0])
+	return parts[0], sequenceNumber
+}
+
+// compareVersions orders upgrade steps by date, then by sequence number. The sequence numbers are compared as numbers
+// since they aren't zero-padded; nor can they be padded now as the versions applied are recorded as they are.
 func compareVersions(upgrStep1 upgradeStep, upgrStep2 upgradeStep) int {
-	return strings.Compare(upgrStep1.version, upgrStep2.version)
+	date1, sequenceNumber1 := parseVersion(upgrStep1.version)
+	date2, sequenceNumber2 := parseVersion(upgrStep2.version)
+	if date1 != date2 {
+		return strings.Compare(date1, date2)
+	}
+	return sequenceNumber1 - sequenceNumber2
 }
 
 func makeSureMetaExists(tx *sql.Tx) error {
@@ -85,12 +318,24 @@ func createMetaRecord(tx *sql.Tx, version string) error {
 
 func applyUpgrade(tx *sql.Tx, upgrStep upgradeStep) error {
 	var err error
+	if upgrStep.prerequisite != nil {
+		err = upgrStep.prerequisite(tx)
+		if err != nil {
+			return fmt.Errorf("prerequisite of schema upgrade to %s not met: %w", upgrStep.version, err)
+		}
+	}
 	for _, uStatement := range upgrStep.sqls {
 		_, err = tx.Exec(uStatement)
 		if err != nil {
 			return fmt.Errorf("failed to execute schema upgrade step %s for version %s: %w", uStatement, upgrStep.version, err)
 		}
 	}
+	if upgrStep.migrate != nil {
+		err = upgrStep.migrate(tx)
+		if err != nil {
+			return fmt.Errorf("failed to migrate data in schema upgrade step for version %s: %w", upgrStep.version, err)
+		}
+	}
 	err = createMetaRecord(tx, upgrStep.version)
 	if err != nil {
 		return fmt.Errorf("failed to apply schema upgrade to %s: %w", upgrStep.version, err)
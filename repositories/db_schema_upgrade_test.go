@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type schemaUpgradeTestSuite struct {
+	suite.Suite
+}
+
+func TestSchemaUpgradeTestSuite(t *testing.T) {
+	suite.Run(t, &schemaUpgradeTestSuite{})
+}
+
+func (s *schemaUpgradeTestSuite) TestUpgradeStepsAreOrderedBySequenceNumber() {
+	steps := []upgradeStep{
+		{version: "2026-10-18/10 - tenth"},
+		{version: "2026-10-18/9 - ninth"},
+		{version: "2018-12-30/1 - first"},
+		{version: "2026-10-18/2 - second"},
+	}
+	sort.Slice(steps, func(i int, j int) bool { return compareVersions(steps[i], steps[j]) < 0 })
+
+	versions := []string{}
+	for _, step := range steps {
+		versions = append(versions, step.version)
+	}
+	s.Equal([]string{
+		"2018-12-30/1 - first",
+		"2026-10-18/2 - second",
+		"2026-10-18/9 - ninth",
+		"2026-10-18/10 - tenth",
+	}, versions)
+}
+
+func (s *schemaUpgradeTestSuite) TestUpgradeStepsAreDefinedInOrder() {
+	for i := 1; i < len(upgradeSteps); i++ {
+		s.Less(compareVersions(upgradeSteps[i-1], upgradeSteps[i]), 0, upgradeSteps[i].version)
+	}
+}
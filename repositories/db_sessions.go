@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/pdkovacs/igo-repo/domain"
+)
+
+// LoadSession retrieves the data of the unexpired session with the specified id
+func (repo DatabaseRepository) LoadSession(id string) ([]byte, bool, error) {
+	var data []byte
+	err := repo.ConnectionPool.QueryRow("SELECT data FROM http_session WHERE id = $1 AND expires_at > now()", id).Scan(&data)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to load session: %w", err)
+	}
+	return data, true, nil
+}
+
+// CreateSession creates the session with the specified id
+func (repo DatabaseRepository) CreateSession(id string, owner string, data []byte, expiresAt time.Time) error {
+	const insertSQL = "INSERT INTO http_session(id, owner, data, expires_at) VALUES($1, NULLIF($2, ''), $3, $4)"
+	_, err := repo.ConnectionPool.Exec(insertSQL, id, owner, data, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+// UpdateSession updates the session with the specified id telling whether it still exists. Sessions deleted, when
+// revoked for example, are not brought back.
+func (repo DatabaseRepository) UpdateSession(id string, owner string, data []byte, expiresAt time.Time) (bool, error) {
+	const updateSQL = "UPDATE http_session SET owner = NULLIF($2, ''), data = $3, expires_at = $4, last_seen_at = now() WHERE id = $1"
+	result, err := repo.ConnectionPool.Exec(updateSQL, id, owner, data, expiresAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to update session: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to retrieve rows affected by updating session: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// DeleteSession deletes the session with the specified id
+func (repo DatabaseRepository) DeleteSession(id string) error {
+	_, err := repo.ConnectionPool.Exec("DELETE FROM http_session WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredSessions deletes the sessions which have expired
+func (repo DatabaseRepository) DeleteExpiredSessions() error {
+	_, err := repo.ConnectionPool.Exec("DELETE FROM http_session WHERE expires_at <= now()")
+	if err != nil {
+		return fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+	return nil
+}
+
+// ListSessions lists the unexpired sessions of authenticated users, or those of the specified owner only
+func (repo DatabaseRepository) ListSessions(owner string) ([]domain.SessionInfo, error) {
+	const listSQL = "SELECT handle, owner, created_at, last_seen_at, expires_at FROM http_session " +
+		"WHERE owner IS NOT NULL AND ($1 = '' OR owner = $1) AND expires_at > now() ORDER BY owner, handle"
+	rows, err := repo.ConnectionPool.Query(listSQL, owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []domain.SessionInfo{}
+	for rows.Next() {
+		var session domain.SessionInfo
+		err = rows.Scan(&session.Handle, &session.Owner, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list sessions: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// DeleteSessionByHandle deletes the session with the specified handle
+func (repo DatabaseRepository) DeleteSessionByHandle(handle int64) error {
+	result, err := repo.ConnectionPool.Exec("DELETE FROM http_session WHERE handle = $1", handle)
+	if err != nil {
+		return fmt.Errorf("failed to delete session %d: %w", handle, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve rows affected by deleting session %d: %w", handle, err)
+	}
+	if rowsAffected < 1 {
+		return fmt.Errorf("session %d: %w", handle, domain.ErrSessionNotFound)
+	}
+	return nil
+}
+
+// DeleteSessionsOfOwner deletes all sessions of the specified owner and returns their count
+func (repo DatabaseRepository) DeleteSessionsOfOwner(owner string) (int64, error) {
+	result, err := repo.ConnectionPool.Exec("DELETE FROM http_session WHERE owner = $1", owner)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete sessions of %s: %w", owner, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve rows affected by deleting sessions of %s: %w", owner, err)
+	}
+	return rowsAffected, nil
+}
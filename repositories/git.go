@@ -192,6 +192,40 @@ func (g GitRepository) AddIconfile(iconName string, iconfile domain.Iconfile, mo
 	return nil
 }
 
+// AddIconfiles adds the iconfiles of all the icons in a single commit
+func (g GitRepository) AddIconfiles(icons []domain.Icon, modifiedBy string) error {
+	iconfileOperation := func() ([]string, error) {
+		pathsInRepo := []string{}
+		for _, icon := range icons {
+			for _, iconfile := range icon.Iconfiles {
+				pathToIconfileInRepo, err := g.createIconfile(icon.Name, iconfile, modifiedBy)
+				if err != nil {
+					return nil, fmt.Errorf("failed to create iconfile %v for %s: %w", iconfile, icon.Name, err)
+				}
+				pathsInRepo = append(pathsInRepo, pathToIconfileInRepo)
+			}
+		}
+		return pathsInRepo, nil
+	}
+
+	jobTextProvider := gitJobTextProvider{
+		"import icon files",
+		func(fileList []string) string {
+			return fmt.Sprintf("%d icon file(s) imported:\n\n%s", len(fileList), fileListAsText(fileList))
+		},
+	}
+
+	var err error
+	config.Enqueue(func() {
+		err = g.createIconfileJob(iconfileOperation, jobTextProvider, modifiedBy)
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to import iconfiles to git repository: %w", err)
+	}
+	return nil
+}
+
 func (s *GitRepository) deleteIconfileFile(iconName string, iconfileDesc domain.IconfileDescriptor) (string, error) {
 	pathCompos := s.getPathComponents1(iconName, iconfileDesc)
 	removeFileErr := os.Remove(pathCompos.pathToIconfile)
@@ -15,7 +15,34 @@ func (ld localDomain) GetDomainID() string {
 func (ld localDomain) CreateUserID(idInDomain string) UserID {
 	return UserID{
 		IDInDomain: idInDomain,
+		DomainID:   ld.GetDomainID(),
 	}
 }
 
 var LocalDomain = localDomain{}
+
+// fallbackIssuerDomainID is the domain of users authenticated with tokens lacking an issuer
+const fallbackIssuerDomainID = "urn:igo-repo:unknown-issuer"
+
+type issuerDomain struct {
+	issuer string
+}
+
+func (id issuerDomain) GetDomainID() string {
+	return id.issuer
+}
+
+func (id issuerDomain) CreateUserID(idInDomain string) UserID {
+	return UserID{
+		IDInDomain: idInDomain,
+		DomainID:   id.issuer,
+	}
+}
+
+// IssuerDomain is the domain of the users authenticated by the specified token issuer (identity provider)
+func IssuerDomain(issuer string) Domain {
+	if issuer == "" {
+		issuer = fallbackIssuerDomainID
+	}
+	return issuerDomain{issuer}
+}
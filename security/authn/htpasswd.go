@@ -0,0 +1,43 @@
+package authn
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReadHtpasswdFile reads the password hashes by user names from an htpasswd file.
+// Only bcrypt (htpasswd -B) and argon2id hashes are accepted.
+func ReadHtpasswdFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open htpasswd file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	hashes := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entry := strings.SplitN(line, ":", 2)
+		if len(entry) != 2 || entry[0] == "" {
+			return nil, fmt.Errorf("malformed entry in line %d of htpasswd file %s", lineNumber, path)
+		}
+		validationErr := ValidatePasswordHash(entry[1])
+		if validationErr != nil {
+			return nil, fmt.Errorf("invalid hash for %s in line %d of htpasswd file %s: %w", entry[0], lineNumber, path, validationErr)
+		}
+		hashes[entry[0]] = entry[1]
+	}
+	err = scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read htpasswd file %s: %w", path, err)
+	}
+	return hashes, nil
+}
@@ -0,0 +1,138 @@
+package authn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// ErrKeyNotFound is returned when no key in a key set matches the one a token was signed with
+var ErrKeyNotFound = errors.New("verification key not found")
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// KeySet holds public keys used for verifying JWT signatures indexed by their key ID
+type KeySet map[string]crypto.PublicKey
+
+// Lookup returns the key with the specified key ID. When the key ID is empty, the key set is expected to hold a single key.
+func (ks KeySet) Lookup(kid string) (crypto.PublicKey, error) {
+	if kid == "" {
+		if len(ks) == 1 {
+			for _, key := range ks {
+				return key, nil
+			}
+		}
+		return nil, fmt.Errorf("no key id specified for a key set of %d keys: %w", len(ks), ErrKeyNotFound)
+	}
+	if key, found := ks[kid]; found {
+		return key, nil
+	}
+	// a single key configured without an ID (from a PEM file, for example) verifies every token
+	if key, found := ks[""]; found && len(ks) == 1 {
+		return key, nil
+	}
+	return nil, fmt.Errorf("key %s: %w", kid, ErrKeyNotFound)
+}
+
+func decodeBase64URLInt(value string) (*big.Int, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(bytes), nil
+}
+
+func (jwk jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, nErr := decodeBase64URLInt(jwk.N)
+		if nErr != nil {
+			return nil, fmt.Errorf("failed to decode modulus: %w", nErr)
+		}
+		e, eErr := decodeBase64URLInt(jwk.E)
+		if eErr != nil {
+			return nil, fmt.Errorf("failed to decode exponent: %w", eErr)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", jwk.Crv)
+		}
+		x, xErr := decodeBase64URLInt(jwk.X)
+		if xErr != nil {
+			return nil, fmt.Errorf("failed to decode x coordinate: %w", xErr)
+		}
+		y, yErr := decodeBase64URLInt(jwk.Y)
+		if yErr != nil {
+			return nil, fmt.Errorf("failed to decode y coordinate: %w", yErr)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", jwk.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type: %s", jwk.Kty)
+}
+
+// ParseJWKS parses a JSON Web Key Set. Only RSA and EC public keys meant for signature verification are taken into account.
+func ParseJWKS(content []byte) (KeySet, error) {
+	jwks := jsonWebKeySet{}
+	unmarshalErr := json.Unmarshal(content, &jwks)
+	if unmarshalErr != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", unmarshalErr)
+	}
+
+	keySet := KeySet{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, keyErr := jwk.publicKey()
+		if keyErr != nil {
+			return nil, fmt.Errorf("failed to parse key %s in JWKS: %w", jwk.Kid, keyErr)
+		}
+		keySet[jwk.Kid] = key
+	}
+
+	if len(keySet) == 0 {
+		return nil, fmt.Errorf("no signature verification key found in JWKS")
+	}
+	return keySet, nil
+}
+
+// ReadJWKSFile reads a JSON Web Key Set from the specified file
+func ReadJWKSFile(filePath string) (KeySet, error) {
+	content, readErr := os.ReadFile(filePath)
+	if readErr != nil {
+		return nil, fmt.Errorf("failed to read JWKS file %s: %w", filePath, readErr)
+	}
+	return ParseJWKS(content)
+}
@@ -0,0 +1,21 @@
+package authn
+
+import (
+	"crypto"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// ParsePublicKeyPEM parses a PEM encoded RSA or ECDSA public key used for verifying JWT signatures
+func ParsePublicKeyPEM(pemBytes []byte) (crypto.PublicKey, error) {
+	rsaKey, rsaErr := jwt.ParseRSAPublicKeyFromPEM(pemBytes)
+	if rsaErr == nil {
+		return rsaKey, nil
+	}
+	ecKey, ecErr := jwt.ParseECPublicKeyFromPEM(pemBytes)
+	if ecErr == nil {
+		return ecKey, nil
+	}
+	return nil, fmt.Errorf("failed to parse public key PEM as RSA (%v) or as EC (%v) key", rsaErr, ecErr)
+}
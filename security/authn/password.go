@@ -0,0 +1,132 @@
+package authn
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const BcryptHashAlgorithm = "bcrypt"
+const Argon2idHashAlgorithm = "argon2id"
+
+// ErrUnsupportedPasswordHash is returned for password hashes in a format the application cannot verify
+var ErrUnsupportedPasswordHash = errors.New("unsupported password hash")
+
+// Argon2id parameters as recommended by RFC 9106 for memory-constrained environments
+const argon2idTime = 3
+const argon2idMemory = 64 * 1024
+const argon2idThreads = 4
+const argon2idKeyLength = 32
+const argon2idSaltLength = 16
+
+// HashPassword creates a hash of the password with the specified algorithm in the format
+// it is to be stored in the configuration
+func HashPassword(password string, algorithm string) (string, error) {
+	switch algorithm {
+	case BcryptHashAlgorithm, "":
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return "", fmt.Errorf("failed to create bcrypt hash: %w", err)
+		}
+		return string(hash), nil
+	case Argon2idHashAlgorithm:
+		salt := make([]byte, argon2idSaltLength)
+		_, err := rand.Read(salt)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate salt: %w", err)
+		}
+		key := argon2.IDKey([]byte(password), salt, argon2idTime, argon2idMemory, argon2idThreads, argon2idKeyLength)
+		return fmt.Sprintf(
+			"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, argon2idMemory, argon2idTime, argon2idThreads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key),
+		), nil
+	}
+	return "", fmt.Errorf("unknown password hash algorithm %s", algorithm)
+}
+
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+type argon2idHash struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+func parseArgon2idHash(hash string) (argon2idHash, error) {
+	parsed := argon2idHash{}
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != Argon2idHashAlgorithm {
+		return parsed, fmt.Errorf("malformed argon2id hash: %w", ErrUnsupportedPasswordHash)
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return parsed, fmt.Errorf("argon2id version %s: %w", parts[2], ErrUnsupportedPasswordHash)
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &parsed.memory, &parsed.time, &parsed.threads)
+	if err != nil {
+		return parsed, fmt.Errorf("malformed argon2id parameters %s: %w", parts[3], ErrUnsupportedPasswordHash)
+	}
+	// argon2.IDKey panics on these
+	if parsed.memory == 0 || parsed.time == 0 || parsed.threads == 0 {
+		return parsed, fmt.Errorf("argon2id parameters %s out of range: %w", parts[3], ErrUnsupportedPasswordHash)
+	}
+
+	parsed.salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return parsed, fmt.Errorf("malformed argon2id salt: %w", ErrUnsupportedPasswordHash)
+	}
+	parsed.key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(parsed.key) == 0 {
+		return parsed, fmt.Errorf("malformed argon2id key: %w", ErrUnsupportedPasswordHash)
+	}
+	return parsed, nil
+}
+
+// ValidatePasswordHash checks whether the hash is in one of the formats VerifyPassword understands
+func ValidatePasswordHash(hash string) error {
+	if isBcryptHash(hash) {
+		_, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return fmt.Errorf("malformed bcrypt hash: %v: %w", err, ErrUnsupportedPasswordHash)
+		}
+		return nil
+	}
+	if strings.HasPrefix(hash, "$"+Argon2idHashAlgorithm+"$") {
+		_, err := parseArgon2idHash(hash)
+		return err
+	}
+	return fmt.Errorf("neither bcrypt, nor argon2id hash: %w", ErrUnsupportedPasswordHash)
+}
+
+// VerifyPassword tells whether the password matches the hash
+func VerifyPassword(hash string, password string) bool {
+	if isBcryptHash(hash) {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	}
+	parsed, err := parseArgon2idHash(hash)
+	if err != nil {
+		return false
+	}
+	key := argon2.IDKey([]byte(password), parsed.salt, parsed.time, parsed.memory, parsed.threads, uint32(len(parsed.key)))
+	return subtle.ConstantTimeCompare(key, parsed.key) == 1
+}
+
+// VerifyPlaintextPassword compares plaintext passwords in constant time
+func VerifyPlaintextPassword(expected string, password string) bool {
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(password)) == 1
+}
@@ -1,16 +1,45 @@
 package authn
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
+// UserID identifies a user within the domain (like the local user base or an identity provider) the user
+// has been authenticated in
 type UserID struct {
 	IDInDomain string
+	DomainID   string
+}
+
+func (userId *UserID) isLocal() bool {
+	return userId.DomainID == "" || userId.DomainID == LocalDomain.GetDomainID()
 }
 
 func (userId *UserID) Equal(idInDomain, domainID string) bool {
-	uid := *userId
-	return idInDomain == uid.IDInDomain
+	other := UserID{IDInDomain: idInDomain, DomainID: domainID}
+	if userId.isLocal() || other.isLocal() {
+		return userId.isLocal() && other.isLocal() && idInDomain == userId.IDInDomain
+	}
+	return idInDomain == userId.IDInDomain && domainID == userId.DomainID
 }
 
+// String renders local users by their plain user name and others qualified with their domain,
+// like "jdoe@https://idp.example.com". This is the form users are referred to in the configuration
+// and in the database.
 func (userId *UserID) String() string {
-	return fmt.Sprintf("%s", userId.IDInDomain)
+	if userId.isLocal() {
+		return userId.IDInDomain
+	}
+	return fmt.Sprintf("%s@%s", userId.IDInDomain, userId.DomainID)
+}
+
+// ParseUserID is the inverse of UserID.String. Domains are told apart from the host part of e-mail
+// addresses (which may well be local user names) by the colon their identifiers contain.
+func ParseUserID(s string) UserID {
+	separatorIndex := strings.LastIndex(s, "@")
+	if separatorIndex > 0 && strings.Contains(s[separatorIndex+1:], ":") {
+		return UserID{IDInDomain: s[:separatorIndex], DomainID: s[separatorIndex+1:]}
+	}
+	return LocalDomain.CreateUserID(s)
 }
@@ -17,8 +17,36 @@ const (
 	REMOVE_ICON     PermissionID = "REMOVE_ICON"
 	ADD_TAG         PermissionID = "ADD_TAG"
 	REMOVE_TAG      PermissionID = "REMOVE_TAG"
+	MANAGE_SESSIONS PermissionID = "MANAGE_SESSIONS"
+	MANAGE_USERS    PermissionID = "MANAGE_USERS"
+
+	// MANAGE_ICON_ACLS allows changing the access control list of any icon and overrides the restrictions therein
+	MANAGE_ICON_ACLS PermissionID = "MANAGE_ICON_ACLS"
 )
 
+var knownPermissions = []PermissionID{
+	CREATE_ICON,
+	UPDATE_ICON,
+	ADD_ICONFILE,
+	REMOVE_ICONFILE,
+	REMOVE_ICON,
+	ADD_TAG,
+	REMOVE_TAG,
+	MANAGE_SESSIONS,
+	MANAGE_USERS,
+	MANAGE_ICON_ACLS,
+}
+
+// IsKnownPermission tells whether the specified permission is one the application knows about
+func IsKnownPermission(permission PermissionID) bool {
+	for _, known := range knownPermissions {
+		if known == permission {
+			return true
+		}
+	}
+	return false
+}
+
 func GetPrivilegeString(id PermissionID) string {
 	return string(id)
 }
@@ -26,7 +54,8 @@ func GetPrivilegeString(id PermissionID) string {
 type GroupID string
 
 const (
-	ICON_EDITOR GroupID = "ICON_EDITOR"
+	ICON_EDITOR   GroupID = "ICON_EDITOR"
+	ADMINISTRATOR GroupID = "ADMINISTRATOR"
 )
 
 var permissionsByGroup = map[GroupID][]PermissionID{
@@ -39,12 +68,42 @@ var permissionsByGroup = map[GroupID][]PermissionID{
 		ADD_TAG,
 		REMOVE_TAG,
 	},
+	ADMINISTRATOR: {
+		MANAGE_SESSIONS,
+		MANAGE_USERS,
+		MANAGE_ICON_ACLS,
+	},
 }
 
+// GetPermissionsForGroup returns the permissions of the built-in group
 func GetPermissionsForGroup(group GroupID) []PermissionID {
 	return permissionsByGroup[group]
 }
 
+// DefaultPermissionsByGroup returns a copy of the built-in group definitions
+func DefaultPermissionsByGroup() map[GroupID][]PermissionID {
+	groups := map[GroupID][]PermissionID{}
+	for group, permissions := range permissionsByGroup {
+		groups[group] = append([]PermissionID{}, permissions...)
+	}
+	return groups
+}
+
+var ErrUnknownPermission = errors.New("unknown permission")
+
+// ParsePermissions converts the permission names into PermissionIDs checking that each is known
+func ParsePermissions(names []string) ([]PermissionID, error) {
+	permissions := []PermissionID{}
+	for _, name := range names {
+		permission := PermissionID(name)
+		if !IsKnownPermission(permission) {
+			return nil, fmt.Errorf("%s: %w", name, ErrUnknownPermission)
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, nil
+}
+
 var ErrPermission = errors.New("permission error")
 
 func HasRequiredPermissions(userId authn.UserID, userPermissions []PermissionID, requiredPermissions []PermissionID) error {
@@ -62,3 +121,17 @@ func HasRequiredPermissions(userId authn.UserID, userPermissions []PermissionID,
 	}
 	return nil
 }
+
+// RestrictPermissions returns those of the granted permissions which are also included in the specified scope
+func RestrictPermissions(granted []PermissionID, scope []PermissionID) []PermissionID {
+	restricted := []PermissionID{}
+	for _, grantedPerm := range granted {
+		for _, scopePerm := range scope {
+			if grantedPerm == scopePerm {
+				restricted = append(restricted, grantedPerm)
+				break
+			}
+		}
+	}
+	return restricted
+}
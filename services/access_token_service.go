@@ -0,0 +1,93 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pdkovacs/igo-repo/domain"
+	"github.com/pdkovacs/igo-repo/repositories"
+	"github.com/pdkovacs/igo-repo/security/authn"
+	"github.com/pdkovacs/igo-repo/security/authr"
+)
+
+// AccessTokenPrefix helps telling personal access tokens apart from other kinds of bearer tokens (like JWTs)
+const AccessTokenPrefix = "igo_"
+
+var ErrInvalidAccessTokenRequest = errors.New("invalid access token request")
+
+type AccessTokenService struct {
+	Repositories *repositories.Repositories
+}
+
+// IsAccessToken tells whether the specified bearer token is (meant to be) a personal access token
+func IsAccessToken(rawToken string) bool {
+	return strings.HasPrefix(rawToken, AccessTokenPrefix)
+}
+
+func hashAccessToken(rawToken string) string {
+	hash := sha256.Sum256([]byte(rawToken))
+	return hex.EncodeToString(hash[:])
+}
+
+func generateAccessToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return AccessTokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CreateAccessToken mints a new personal access token for the user carrying a subset of the user's permissions.
+// The secret is returned only here, only its hash is stored.
+func (service *AccessTokenService) CreateAccessToken(label string, permissions []authr.PermissionID, expiresAt time.Time, owner UserInfo) (domain.AccessToken, string, error) {
+	for _, permission := range permissions {
+		if !authr.IsKnownPermission(permission) {
+			return domain.AccessToken{}, "", fmt.Errorf("unknown permission %s: %w", permission, ErrInvalidAccessTokenRequest)
+		}
+	}
+	if !expiresAt.After(time.Now()) {
+		return domain.AccessToken{}, "", fmt.Errorf("expiry %v is not in the future: %w", expiresAt, ErrInvalidAccessTokenRequest)
+	}
+	err := authr.HasRequiredPermissions(owner.UserId, owner.Permissions, permissions)
+	if err != nil {
+		return domain.AccessToken{}, "", fmt.Errorf("failed to create access token \"%s\": %w", label, err)
+	}
+
+	rawToken, generateErr := generateAccessToken()
+	if generateErr != nil {
+		return domain.AccessToken{}, "", fmt.Errorf("failed to generate access token: %w", generateErr)
+	}
+
+	token, createErr := service.Repositories.DB.CreateAccessToken(owner.UserId.String(), label, hashAccessToken(rawToken), permissions, expiresAt)
+	if createErr != nil {
+		return domain.AccessToken{}, "", createErr
+	}
+	return token, rawToken, nil
+}
+
+func (service *AccessTokenService) ListAccessTokens(owner UserInfo) ([]domain.AccessToken, error) {
+	return service.Repositories.DB.ListAccessTokens(owner.UserId.String())
+}
+
+func (service *AccessTokenService) RevokeAccessToken(id int64, owner UserInfo) error {
+	return service.Repositories.DB.DeleteAccessToken(owner.UserId.String(), id)
+}
+
+// AuthenticateAccessToken looks up a personal access token by its secret and returns its owner and its scope
+func (service *AccessTokenService) AuthenticateAccessToken(rawToken string) (authn.UserID, []authr.PermissionID, error) {
+	token, err := service.Repositories.DB.UseAccessToken(hashAccessToken(rawToken))
+	if err != nil {
+		return authn.UserID{}, nil, fmt.Errorf("failed to authenticate access token: %w", err)
+	}
+	if !token.ExpiresAt.After(time.Now()) {
+		return authn.UserID{}, nil, fmt.Errorf("access token %d of %s: %w", token.ID, token.Owner, domain.ErrAccessTokenExpired)
+	}
+	return authn.ParseUserID(token.Owner), token.Permissions, nil
+}
@@ -1,6 +1,9 @@
 package services
 
 import (
+	"fmt"
+	"sort"
+
 	"github.com/pdkovacs/igo-repo/config"
 	"github.com/pdkovacs/igo-repo/security/authn"
 	"github.com/pdkovacs/igo-repo/security/authr"
@@ -12,24 +15,127 @@ type UsersByGroups map[authr.GroupID][]authn.UserID
 type AuthorizationService interface {
 	GetGroupsForUser(userID authn.UserID) []authr.GroupID
 	GetPermissionsForGroup(group authr.GroupID) []authr.PermissionID
+	MapClaimedGroups(claimValues []string) []authr.GroupID
 }
 
-func NewAuthorizationService(config config.Options) authRService {
-	return authRService{config.UsersByRoles}
+// GroupMembershipRepository keeps the group memberships managed via the API
+type GroupMembershipRepository interface {
+	GetGroupsOfUser(userID string) ([]string, error)
+	GetGroupMemberships() (map[string][]string, error)
+	AddGroupMember(group string, userID string, addedBy string) error
+	RemoveGroupMember(group string, userID string) error
+}
+
+// NewAuthorizationService creates the authorization service. The groups defined in the configuration
+// are added to (or replace) the built-in ones; their permissions as well as the groups users are assigned to
+// are validated. The memberships kept in membershipRepo, if any, are merged with those in the configuration.
+func NewAuthorizationService(config config.Options, membershipRepo GroupMembershipRepository) (authRService, error) {
+	permissionsByGroup := authr.DefaultPermissionsByGroup()
+	for groupName, permissionNames := range config.PermissionsByRoles {
+		if groupName == "" {
+			return authRService{}, fmt.Errorf("group with empty name defined")
+		}
+		permissions, err := authr.ParsePermissions(permissionNames)
+		if err != nil {
+			return authRService{}, fmt.Errorf("invalid permissions for group %s: %w", groupName, err)
+		}
+		permissionsByGroup[str2GroupID(groupName)] = permissions
+	}
+
+	for groupName := range config.UsersByRoles {
+		if groupName == "" {
+			// go-flags' rendering of the empty default
+			continue
+		}
+		if _, defined := permissionsByGroup[str2GroupID(groupName)]; !defined {
+			return authRService{}, fmt.Errorf("users assigned to undefined group %s", groupName)
+		}
+	}
+
+	for groupName := range config.GroupClaimsByRoles {
+		if _, defined := permissionsByGroup[str2GroupID(groupName)]; !defined {
+			return authRService{}, fmt.Errorf("group claims mapped to undefined group %s", groupName)
+		}
+	}
+
+	return authRService{config.UsersByRoles, permissionsByGroup, config.GroupClaimsByRoles, membershipRepo}, nil
 }
 
 type authRService struct {
-	// TODO: if this data structure is to serve both the local and the OIDC domain,
-	// "usersByGroups" should be more abstract/indirect, like let it be at least a function or something
-	usersByGroups config.UsersByRoles
+	// Users of domains other than the local one are referred to in the qualified form rendered by authn.UserID.String
+	usersByGroups      config.UsersByRoles
+	permissionsByGroup map[authr.GroupID][]authr.PermissionID
+	claimsByGroup      config.ClaimsByRoles
+	membershipRepo     GroupMembershipRepository
 }
 
 func (as *authRService) GetGroupsForUser(userID authn.UserID) []authr.GroupID {
-	return getLocalGroupsFor(userID, as.usersByGroups)
+	groups := getLocalGroupsFor(userID, as.usersByGroups)
+	if as.membershipRepo == nil {
+		return groups
+	}
+	storedGroupNames, err := as.membershipRepo.GetGroupsOfUser(userID.String())
+	if err != nil {
+		// Better to carry on with the configured groups than to lock everybody out
+		log.WithField("prefix", "GetGroupsForUser").Errorf("%v", err)
+		return groups
+	}
+	for _, storedGroup := range groupNames2GroupIDs(storedGroupNames) {
+		if _, defined := as.permissionsByGroup[storedGroup]; !defined {
+			// The group may have been removed from the configuration since the member was added
+			continue
+		}
+		if !containsGroup(groups, storedGroup) {
+			groups = append(groups, storedGroup)
+		}
+	}
+	return groups
+}
+
+// MapClaimedGroups translates the values of the group claim in a token of an identity provider to the groups
+// they grant. Only the claim values mapped in the configuration grant any group: the identity provider is not
+// trusted to name groups, let alone ADMINISTRATOR, by itself.
+func (as *authRService) MapClaimedGroups(claimValues []string) []authr.GroupID {
+	groups := []authr.GroupID{}
+	for groupName, mappedClaimValues := range as.claimsByGroup {
+		for _, value := range claimValues {
+			if containsString(mappedClaimValues, value) {
+				groups = append(groups, str2GroupID(groupName))
+				break
+			}
+		}
+	}
+	return groups
+}
+
+// GetGroups lists the groups defined, whether built-in or configured
+func (as *authRService) GetGroups() []authr.GroupID {
+	groups := []authr.GroupID{}
+	for group := range as.permissionsByGroup {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i] < groups[j] })
+	return groups
+}
+
+func (as *authRService) isGroupDefined(group authr.GroupID) bool {
+	_, defined := as.permissionsByGroup[group]
+	return defined
+}
+
+// getConfiguredMembers lists the members assigned to the group in the configuration
+func (as *authRService) getConfiguredMembers(group authr.GroupID) []string {
+	members := []string{}
+	for _, member := range as.usersByGroups[string(group)] {
+		if member != "" {
+			members = append(members, member)
+		}
+	}
+	return members
 }
 
 func (as *authRService) GetPermissionsForGroup(group authr.GroupID) []authr.PermissionID {
-	return authr.GetPermissionsForGroup(group)
+	return as.permissionsByGroup[group]
 }
 
 func str2GroupID(s string) authr.GroupID {
@@ -40,7 +146,8 @@ func getLocalGroupsFor(userID authn.UserID, usersByGroups map[string][]string) [
 	groupNames := []string{}
 	for groupName, members := range usersByGroups {
 		for _, member := range members {
-			if userID.IDInDomain == member {
+			memberID := authn.ParseUserID(member)
+			if userID.Equal(memberID.IDInDomain, memberID.DomainID) {
 				groupNames = append(groupNames, groupName)
 				break
 			}
@@ -50,6 +157,15 @@ func getLocalGroupsFor(userID authn.UserID, usersByGroups map[string][]string) [
 	return groupNames2GroupIDs(groupNames)
 }
 
+func containsGroup(groups []authr.GroupID, group authr.GroupID) bool {
+	for _, g := range groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
 func groupNames2GroupIDs(strs []string) []authr.GroupID {
 	groupIDs := []authr.GroupID{}
 	for _, s := range strs {
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"container/list"
+	"sync"
+)
+
+// contentCache keeps the most recently used generated contents up to a total size.
+// Keys are expected to be derived from content hashes so that entries never go stale.
+type contentCache struct {
+	mutex    sync.Mutex
+	maxBytes int
+	bytes    int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type contentCacheEntry struct {
+	key     string
+	content []byte
+}
+
+func newContentCache(maxBytes int) *contentCache {
+	return &contentCache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+func (cache *contentCache) get(key string) ([]byte, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	element, ok := cache.entries[key]
+	if !ok {
+		return nil, false
+	}
+	cache.order.MoveToFront(element)
+	return element.Value.(*contentCacheEntry).content, true
+}
+
+func (cache *contentCache) put(key string, content []byte) {
+	if len(content) > cache.maxBytes {
+		return
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if element, ok := cache.entries[key]; ok {
+		cache.order.MoveToFront(element)
+		return
+	}
+	cache.entries[key] = cache.order.PushFront(&contentCacheEntry{key: key, content: content})
+	cache.bytes += len(content)
+	for cache.bytes > cache.maxBytes {
+		oldest := cache.order.Back()
+		entry := oldest.Value.(*contentCacheEntry)
+		cache.order.Remove(oldest)
+		delete(cache.entries, entry.key)
+		cache.bytes -= len(entry.content)
+	}
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+
+	"github.com/pdkovacs/igo-repo/domain"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// FaviconManifestName is the name of the web app manifest in favicon bundles
+	FaviconManifestName = "site.webmanifest"
+	faviconICOName      = "favicon.ico"
+)
+
+// faviconICOSizes are the sizes in pixels of the images in the multi-resolution favicon.ico
+var faviconICOSizes = []int{16, 32, 48}
+
+// appleTouchIconSizes are the sizes in pixels of the apple-touch-icon PNGs, the first of them being the default
+var appleTouchIconSizes = []int{180, 167, 152, 120}
+
+// manifestIconSizes are the sizes in pixels of the PNGs referred to by the web app manifest
+var manifestIconSizes = []int{192, 512}
+
+// ErrInvalidFaviconOptions is returned for favicon bundles requested with malformed colors
+var ErrInvalidFaviconOptions = errors.New("invalid favicon options")
+
+// FaviconOptions customize the web app manifest of favicon bundles. The name defaults to that of the icon, the
+// background color, which apple-touch-icons are also flattened onto, to white.
+type FaviconOptions struct {
+	Name            string
+	ThemeColor      string
+	BackgroundColor string
+}
+
+// FaviconBundle is a ZIP archive with a multi-resolution favicon.ico, apple-touch-icon PNGs and a web app manifest
+// along with the PNGs it refers to
+type FaviconBundle struct {
+	Content  []byte
+	Key      string
+	FileName string
+}
+
+// WebManifestIcon is an entry of the icons of web app manifests
+type WebManifestIcon struct {
+	Src   string `json:"src"`
+	Sizes string `json:"sizes"`
+	Type  string `json:"type"`
+}
+
+// WebManifest is the snippet of a web app manifest describing the icons of the favicon bundle
+type WebManifest struct {
+	Name            string            `json:"name"`
+	ShortName       string            `json:"short_name"`
+	Icons           []WebManifestIcon `json:"icons"`
+	ThemeColor      string            `json:"theme_color,omitempty"`
+	BackgroundColor string            `json:"background_color"`
+	Display         string            `json:"display"`
+}
+
+func appleTouchIconName(size int) string {
+	if size == appleTouchIconSizes[0] {
+		return "apple-touch-icon.png"
+	}
+	return fmt.Sprintf("apple-touch-icon-%dx%d.png", size, size)
+}
+
+func manifestIconName(size int) string {
+	return fmt.Sprintf("android-chrome-%dx%d.png", size, size)
+}
+
+// fitSquare scales the image to fit a transparent square of the size keeping its aspect ratio and centers it
+func fitSquare(img image.Image, size int) *image.NRGBA {
+	bounds := img.Bounds()
+	scale := float64(size) / math.Max(float64(bounds.Dx()), float64(bounds.Dy()))
+	width := int(math.Max(1, math.Round(float64(bounds.Dx())*scale)))
+	height := int(math.Max(1, math.Round(float64(bounds.Dy())*scale)))
+	resampled := resampleImage(img, width, height)
+	if width == size && height == size {
+		return resampled
+	}
+	square := image.NewNRGBA(image.Rect(0, 0, size, size))
+	offset := image.Pt((size-width)/2, (size-height)/2)
+	draw.Draw(square, resampled.Bounds().Add(offset), resampled, image.Point{}, draw.Src)
+	return square
+}
+
+// writeICO packs the images into an ICO file. The images are stored as PNGs which every browser supports.
+func writeICO(images []*image.NRGBA) ([]byte, error) {
+	const headerLength, entryLength = 6, 16
+	encoded := make([][]byte, len(images))
+	for i, img := range images {
+		var out bytes.Buffer
+		if err := png.Encode(&out, img); err != nil {
+			return nil, err
+		}
+		encoded[i] = out.Bytes()
+	}
+
+	var ico bytes.Buffer
+	binary.Write(&ico, binary.LittleEndian, []uint16{0, 1, uint16(len(images))})
+	offset := headerLength + entryLength*len(images)
+	for i, img := range images {
+		// Sizes of 256 pixels are stored as 0
+		width, height := uint8(img.Bounds().Dx()), uint8(img.Bounds().Dy())
+		ico.Write([]byte{width, height, 0, 0})
+		binary.Write(&ico, binary.LittleEndian, []uint16{1, 32})
+		binary.Write(&ico, binary.LittleEndian, []uint32{uint32(len(encoded[i])), uint32(offset)})
+		offset += len(encoded[i])
+	}
+	for _, content := range encoded {
+		ico.Write(content)
+	}
+	return ico.Bytes(), nil
+}
+
+// faviconImage returns the square PNG of the icon of the size: the stored one if any, otherwise one rendered from
+// SVG or downscaled from a larger raster iconfile. Sizes which cannot be derived are reported as not found.
+func (service *IconService) faviconImage(iconName string, size int) (*image.NRGBA, bool, error) {
+	descriptor := domain.IconfileDescriptor{Format: "png", Size: fmt.Sprintf("%dpx", size)}
+	iconfile, err := service.GetIconfile(iconName, descriptor)
+	if errors.Is(err, domain.ErrIconfileNotFound) {
+		iconfile, err = service.deriveIconfile(iconName, descriptor)
+		if errors.Is(err, domain.ErrIconfileNotFound) || errors.Is(err, ErrInvalidSVG) || errors.Is(err, ErrInvalidRasterSize) {
+			return nil, false, nil
+		}
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(iconfile.Content))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decode %v of %s: %w", descriptor, iconName, err)
+	}
+	return fitSquare(img, size), true, nil
+}
+
+func writeFaviconArchive(fileNames []string, files map[string][]byte) ([]byte, error) {
+	var out bytes.Buffer
+	archive := zip.NewWriter(&out)
+	for _, fileName := range fileNames {
+		fileWriter, err := archive.Create(fileName)
+		if err == nil {
+			_, err = fileWriter.Write(files[fileName])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s to favicon bundle: %w", fileName, err)
+		}
+	}
+	err := archive.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to close favicon bundle: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// GetFaviconBundle builds the favicon bundle of the icon from its iconfiles rasterizing or downscaling them as
+// needed. Sizes the icon has no large enough iconfile for are left out, but the favicon.ico needs at least one.
+func (service *IconService) GetFaviconBundle(iconName string, options FaviconOptions) (FaviconBundle, error) {
+	logger := log.WithField("prefix", "GetFaviconBundle")
+	manifest := WebManifest{Name: options.Name, Icons: []WebManifestIcon{}, BackgroundColor: "#ffffff", Display: "standalone"}
+	if manifest.Name == "" {
+		manifest.Name = iconName
+	}
+	manifest.ShortName = manifest.Name
+	var err error
+	if options.ThemeColor != "" {
+		if manifest.ThemeColor, err = normalizeHexColor(options.ThemeColor); err != nil {
+			return FaviconBundle{}, fmt.Errorf("theme color: %v: %w", err, ErrInvalidFaviconOptions)
+		}
+	}
+	if options.BackgroundColor != "" {
+		if manifest.BackgroundColor, err = normalizeHexColor(options.BackgroundColor); err != nil {
+			return FaviconBundle{}, fmt.Errorf("background color: %v: %w", err, ErrInvalidFaviconOptions)
+		}
+	}
+	background, _ := parseColor(manifest.BackgroundColor)
+
+	_, err = service.Repositories.DB.DescribeIcon(iconName)
+	if err != nil {
+		return FaviconBundle{}, fmt.Errorf("failed to describe icon %s for favicon bundle: %w", iconName, err)
+	}
+
+	fileNames := []string{}
+	files := map[string][]byte{}
+	addPNG := func(fileName string, img *image.NRGBA) error {
+		var out bytes.Buffer
+		if encodeErr := png.Encode(&out, img); encodeErr != nil {
+			return fmt.Errorf("failed to encode %s: %w", fileName, encodeErr)
+		}
+		fileNames = append(fileNames, fileName)
+		files[fileName] = out.Bytes()
+		return nil
+	}
+
+	icoImages := []*image.NRGBA{}
+	for _, size := range faviconICOSizes {
+		img, found, imageErr := service.faviconImage(iconName, size)
+		if imageErr != nil {
+			return FaviconBundle{}, imageErr
+		}
+		if !found {
+			logger.Infof("no iconfile of %s to derive the %dpx favicon from", iconName, size)
+			continue
+		}
+		icoImages = append(icoImages, img)
+	}
+	if len(icoImages) == 0 {
+		return FaviconBundle{}, fmt.Errorf("no iconfile of %s to build favicon of: %w", iconName, domain.ErrIconfileNotFound)
+	}
+	ico, err := writeICO(icoImages)
+	if err != nil {
+		return FaviconBundle{}, fmt.Errorf("failed to build %s of %s: %w", faviconICOName, iconName, err)
+	}
+	fileNames = append(fileNames, faviconICOName)
+	files[faviconICOName] = ico
+
+	// iOS fills transparent areas with black
+	opaqueBackground := color.NRGBA{
+		R: uint8(math.Round(background.r * 0xff)),
+		G: uint8(math.Round(background.g * 0xff)),
+		B: uint8(math.Round(background.b * 0xff)),
+		A: 0xff,
+	}
+	for _, size := range appleTouchIconSizes {
+		img, found, imageErr := service.faviconImage(iconName, size)
+		if imageErr != nil {
+			return FaviconBundle{}, imageErr
+		}
+		if !found {
+			logger.Infof("no iconfile of %s to derive the %dpx apple-touch-icon from", iconName, size)
+			continue
+		}
+		if err = addPNG(appleTouchIconName(size), flattenOnto(img, opaqueBackground)); err != nil {
+			return FaviconBundle{}, err
+		}
+	}
+
+	for _, size := range manifestIconSizes {
+		img, found, imageErr := service.faviconImage(iconName, size)
+		if imageErr != nil {
+			return FaviconBundle{}, imageErr
+		}
+		if !found {
+			logger.Infof("no iconfile of %s to derive the %dpx manifest icon from", iconName, size)
+			continue
+		}
+		if err = addPNG(manifestIconName(size), img); err != nil {
+			return FaviconBundle{}, err
+		}
+		manifest.Icons = append(manifest.Icons, WebManifestIcon{
+			Src:   "/" + manifestIconName(size),
+			Sizes: fmt.Sprintf("%dx%d", size, size),
+			Type:  "image/png",
+		})
+	}
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return FaviconBundle{}, fmt.Errorf("failed to build %s: %w", FaviconManifestName, err)
+	}
+	fileNames = append(fileNames, FaviconManifestName)
+	files[FaviconManifestName] = manifestJSON
+
+	content, err := writeFaviconArchive(fileNames, files)
+	if err != nil {
+		return FaviconBundle{}, err
+	}
+	return FaviconBundle{Content: content, Key: domain.ContentHash(content), FileName: iconName + "-favicon.zip"}, nil
+}
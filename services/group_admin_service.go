@@ -0,0 +1,149 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pdkovacs/igo-repo/domain"
+	"github.com/pdkovacs/igo-repo/security/authn"
+	"github.com/pdkovacs/igo-repo/security/authr"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ConfigMembership marks memberships defined in the configuration; these cannot be changed via the API
+	ConfigMembership = "config"
+	// DatabaseMembership marks memberships added via the API
+	DatabaseMembership = "database"
+)
+
+// ErrConfiguredMembership is returned on attempts to remove a membership defined in the configuration
+var ErrConfiguredMembership = errors.New("group membership is defined in the configuration")
+
+var errNoMembershipRepository = errors.New("no repository available for group memberships")
+
+type GroupMember struct {
+	UserID string `json:"userID"`
+	Source string `json:"source"`
+}
+
+type GroupInfo struct {
+	Name        authr.GroupID        `json:"name"`
+	Permissions []authr.PermissionID `json:"permissions"`
+	Members     []GroupMember        `json:"members"`
+}
+
+// GroupAdminService lets administrators manage the members of the groups
+type GroupAdminService struct {
+	authorizationService *authRService
+	userService          *UserService
+}
+
+func NewGroupAdminService(authorizationService *authRService, userService *UserService) GroupAdminService {
+	return GroupAdminService{
+		authorizationService: authorizationService,
+		userService:          userService,
+	}
+}
+
+func checkManageUsersPermission(requestedBy UserInfo) error {
+	return authr.HasRequiredPermissions(requestedBy.UserId, requestedBy.Permissions, []authr.PermissionID{
+		authr.MANAGE_USERS,
+	})
+}
+
+func (service *GroupAdminService) ListGroups(requestedBy UserInfo) ([]GroupInfo, error) {
+	err := checkManageUsersPermission(requestedBy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list groups: %w", err)
+	}
+
+	storedMembers := map[string][]string{}
+	if service.authorizationService.membershipRepo != nil {
+		storedMembers, err = service.authorizationService.membershipRepo.GetGroupMemberships()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	groups := []GroupInfo{}
+	for _, group := range service.authorizationService.GetGroups() {
+		members := []GroupMember{}
+		configuredMembers := service.authorizationService.getConfiguredMembers(group)
+		for _, member := range configuredMembers {
+			members = append(members, GroupMember{UserID: member, Source: ConfigMembership})
+		}
+		for _, member := range storedMembers[string(group)] {
+			if !containsString(configuredMembers, member) {
+				members = append(members, GroupMember{UserID: member, Source: DatabaseMembership})
+			}
+		}
+		groups = append(groups, GroupInfo{
+			Name:        group,
+			Permissions: service.authorizationService.GetPermissionsForGroup(group),
+			Members:     members,
+		})
+	}
+	return groups, nil
+}
+
+func (service *GroupAdminService) AddMember(group authr.GroupID, userID string, requestedBy UserInfo) error {
+	logger := log.WithField("prefix", "AddMember")
+	err := checkManageUsersPermission(requestedBy)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to group %s: %w", userID, group, err)
+	}
+	if !service.authorizationService.isGroupDefined(group) {
+		return fmt.Errorf("failed to add %s to group %s: %w", userID, group, domain.ErrGroupNotFound)
+	}
+	if service.authorizationService.membershipRepo == nil {
+		return fmt.Errorf("failed to add %s to group %s: %w", userID, group, errNoMembershipRepository)
+	}
+	err = service.authorizationService.membershipRepo.AddGroupMember(string(group), userID, requestedBy.UserId.String())
+	if err != nil {
+		return err
+	}
+	logger.Infof("%s added to group %s by %s", userID, group, requestedBy.UserId.String())
+	return nil
+}
+
+func (service *GroupAdminService) RemoveMember(group authr.GroupID, userID string, requestedBy UserInfo) error {
+	logger := log.WithField("prefix", "RemoveMember")
+	err := checkManageUsersPermission(requestedBy)
+	if err != nil {
+		return fmt.Errorf("failed to remove %s from group %s: %w", userID, group, err)
+	}
+	if !service.authorizationService.isGroupDefined(group) {
+		return fmt.Errorf("failed to remove %s from group %s: %w", userID, group, domain.ErrGroupNotFound)
+	}
+	if containsString(service.authorizationService.getConfiguredMembers(group), userID) {
+		return fmt.Errorf("failed to remove %s from group %s: %w", userID, group, ErrConfiguredMembership)
+	}
+	if service.authorizationService.membershipRepo == nil {
+		return fmt.Errorf("failed to remove %s from group %s: %w", userID, group, errNoMembershipRepository)
+	}
+	err = service.authorizationService.membershipRepo.RemoveGroupMember(string(group), userID)
+	if err != nil {
+		return err
+	}
+	logger.Infof("%s removed from group %s by %s", userID, group, requestedBy.UserId.String())
+	return nil
+}
+
+// GetEffectiveUserInfo tells the groups and permissions the user gets on their next login
+func (service *GroupAdminService) GetEffectiveUserInfo(userID string, requestedBy UserInfo) (UserInfo, error) {
+	err := checkManageUsersPermission(requestedBy)
+	if err != nil {
+		return UserInfo{}, fmt.Errorf("failed to get the permissions of %s: %w", userID, err)
+	}
+	return service.userService.GetUserInfo(authn.ParseUserID(userID)), nil
+}
+
+func containsString(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pdkovacs/igo-repo/domain"
+	"github.com/pdkovacs/igo-repo/repositories"
+	"github.com/pdkovacs/igo-repo/security/authr"
+)
+
+// ErrInvalidIconACL is returned when an access control list to be set on an icon is malformed
+var ErrInvalidIconACL = errors.New("invalid icon ACL")
+
+// aclRestrictablePermissions are the permissions which can be restricted per icon
+var aclRestrictablePermissions = []authr.PermissionID{
+	authr.REMOVE_ICON,
+	authr.ADD_ICONFILE,
+	authr.REMOVE_ICONFILE,
+}
+
+func isOwnerOf(acl domain.IconACL, user UserInfo) bool {
+	if acl.Owner == user.UserId.String() {
+		return true
+	}
+	return acl.OwningGroup != "" && containsGroup(user.Groups, authr.GroupID(acl.OwningGroup))
+}
+
+func isGrantedBy(acl domain.IconACL, permission authr.PermissionID, user UserInfo) bool {
+	for _, entry := range acl.Entries {
+		if entry.Permission != string(permission) {
+			continue
+		}
+		switch entry.PrincipalType {
+		case domain.UserPrincipal:
+			if entry.Principal == user.UserId.String() {
+				return true
+			}
+		case domain.GroupPrincipal:
+			if containsGroup(user.Groups, authr.GroupID(entry.Principal)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// iconACLCheck checks whether the icon's access control list lets the user exercise the permission on it.
+// The user is expected to hold the permission itself already.
+func iconACLCheck(iconName string, permission authr.PermissionID, user UserInfo) repositories.IconACLCheck {
+	return func(acl domain.IconACL) error {
+		if acl.Owner == "" ||
+			containsPermission(user.Permissions, authr.MANAGE_ICON_ACLS) ||
+			isOwnerOf(acl, user) ||
+			isGrantedBy(acl, permission, user) {
+			return nil
+		}
+		return fmt.Errorf("%s is not allowed to %s on icon %s: %w", user.UserId.String(), permission, iconName, authr.ErrPermission)
+	}
+}
+
+// checkIconACL checks the icon's access control list up front. Changes check it again in their transaction.
+func (service *IconService) checkIconACL(iconName string, permission authr.PermissionID, user UserInfo) error {
+	acl, err := service.Repositories.DB.GetIconACL(iconName)
+	if err != nil {
+		return err
+	}
+	return iconACLCheck(iconName, permission, user)(acl)
+}
+
+// validateOwningGroup checks that the creator of an icon can only hand it over to a group they belong to
+func validateOwningGroup(owningGroup string, creator UserInfo) error {
+	if owningGroup == "" || containsGroup(creator.Groups, authr.GroupID(owningGroup)) {
+		return nil
+	}
+	return fmt.Errorf("%s is not a member of the owning group %s: %w", creator.UserId.String(), owningGroup, ErrInvalidIconACL)
+}
+
+func (service *IconService) GetIconACL(iconName string) (domain.IconACL, error) {
+	acl, err := service.Repositories.DB.GetIconACL(iconName)
+	if err != nil {
+		return domain.IconACL{}, fmt.Errorf("failed to retrieve the ACL of icon \"%s\": %w", iconName, err)
+	}
+	return acl, nil
+}
+
+func validateIconACL(acl domain.IconACL) error {
+	if acl.Owner == "" {
+		return fmt.Errorf("owner missing: %w", ErrInvalidIconACL)
+	}
+	for _, entry := range acl.Entries {
+		if entry.PrincipalType != domain.UserPrincipal && entry.PrincipalType != domain.GroupPrincipal {
+			return fmt.Errorf("unexpected principal type %s: %w", entry.PrincipalType, ErrInvalidIconACL)
+		}
+		if entry.Principal == "" {
+			return fmt.Errorf("principal missing: %w", ErrInvalidIconACL)
+		}
+		if !containsPermission(aclRestrictablePermissions, authr.PermissionID(entry.Permission)) {
+			return fmt.Errorf("permission %s cannot be granted per icon: %w", entry.Permission, ErrInvalidIconACL)
+		}
+	}
+	return nil
+}
+
+// SetIconACL replaces the owner and the access control entries of the icon. Only the current owners
+// and those with the MANAGE_ICON_ACLS permission can do this.
+func (service *IconService) SetIconACL(iconName string, acl domain.IconACL, modifiedBy UserInfo) error {
+	err := validateIconACL(acl)
+	if err != nil {
+		return err
+	}
+	if acl.Entries == nil {
+		acl.Entries = []domain.IconACLEntry{}
+	}
+
+	return service.Repositories.DB.SetIconACL(iconName, acl, func(currentACL domain.IconACL) error {
+		if !containsPermission(modifiedBy.Permissions, authr.MANAGE_ICON_ACLS) && !isOwnerOf(currentACL, modifiedBy) {
+			return fmt.Errorf("%s is not allowed to set the ACL of icon %s: %w", modifiedBy.UserId.String(), iconName, authr.ErrPermission)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/pdkovacs/igo-repo/domain"
+)
+
+// ExportManifestName is the name of the manifest in export archives
+const ExportManifestName = "manifest.json"
+
+// ExportFilter selects the icons and iconfiles to export. Empty formats and sizes select all of them.
+type ExportFilter struct {
+	Tags     []string
+	TagMatch string
+	Formats  []string
+	Sizes    []string
+}
+
+// ExportedIconfile describes an iconfile in the export archive
+type ExportedIconfile struct {
+	Format      string `json:"format"`
+	Size        string `json:"size"`
+	Path        string `json:"path"`
+	ContentHash string `json:"contentHash"`
+}
+
+// ExportedIcon describes an icon in the export archive
+type ExportedIcon struct {
+	Name        string             `json:"name"`
+	Tags        []string           `json:"tags"`
+	Description string             `json:"description,omitempty"`
+	Iconfiles   []ExportedIconfile `json:"iconfiles"`
+}
+
+// ExportManifest lists the contents of the export archive
+type ExportManifest struct {
+	ExportedAt time.Time      `json:"exportedAt"`
+	Icons      []ExportedIcon `json:"icons"`
+}
+
+func selects(values []string, value string) bool {
+	return len(values) == 0 || containsString(values, value)
+}
+
+// SelectIconsToExport returns the icons matching the filter with their iconfiles restricted to those matching it as well
+func (service *IconService) SelectIconsToExport(filter ExportFilter) ([]domain.IconDescriptor, error) {
+	page, err := service.QueryIcons(domain.IconQuery{Tags: filter.Tags, TagMatch: filter.TagMatch})
+	if err != nil {
+		return nil, err
+	}
+	selected := []domain.IconDescriptor{}
+	for _, icon := range page.Icons {
+		iconfiles := []domain.IconfileDescriptor{}
+		for _, iconfile := range icon.Iconfiles {
+			if selects(filter.Formats, iconfile.Format) && selects(filter.Sizes, iconfile.Size) {
+				iconfiles = append(iconfiles, iconfile)
+			}
+		}
+		if len(iconfiles) > 0 {
+			icon.Iconfiles = iconfiles
+			selected = append(selected, icon)
+		}
+	}
+	return selected, nil
+}
+
+// WriteExport writes the iconfiles of the icons into a ZIP archive laid out the same way as the git repository
+// and closes the archive with the manifest. Iconfiles are read one by one so as not to hold the export in memory.
+func (service *IconService) WriteExport(icons []domain.IconDescriptor, out io.Writer) error {
+	archive := zip.NewWriter(out)
+	manifest := ExportManifest{ExportedAt: time.Now().UTC(), Icons: []ExportedIcon{}}
+
+	for _, icon := range icons {
+		exportedIcon := ExportedIcon{
+			Name:        icon.Name,
+			Tags:        icon.Tags,
+			Description: icon.Description,
+			Iconfiles:   []ExportedIconfile{},
+		}
+		for _, descriptor := range icon.Iconfiles {
+			iconfile, err := service.GetIconfile(icon.Name, descriptor)
+			if err != nil {
+				return fmt.Errorf("failed to export iconfile %v of %s: %w", descriptor, icon.Name, err)
+			}
+			pathInArchive := filepath.ToSlash(service.Repositories.Git.GetPathToIconfileInRepos(icon.Name, descriptor))
+			fileWriter, err := archive.Create(pathInArchive)
+			if err != nil {
+				return fmt.Errorf("failed to add %s to export archive: %w", pathInArchive, err)
+			}
+			_, err = fileWriter.Write(iconfile.Content)
+			if err != nil {
+				return fmt.Errorf("failed to write %s to export archive: %w", pathInArchive, err)
+			}
+			exportedIcon.Iconfiles = append(exportedIcon.Iconfiles, ExportedIconfile{
+				Format:      descriptor.Format,
+				Size:        descriptor.Size,
+				Path:        pathInArchive,
+				ContentHash: iconfile.ContentHash,
+			})
+		}
+		manifest.Icons = append(manifest.Icons, exportedIcon)
+	}
+
+	manifestWriter, err := archive.Create(ExportManifestName)
+	if err != nil {
+		return fmt.Errorf("failed to add manifest to export archive: %w", err)
+	}
+	encoder := json.NewEncoder(manifestWriter)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to write manifest to export archive: %w", err)
+	}
+	return archive.Close()
+}
@@ -0,0 +1,392 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pdkovacs/igo-repo/domain"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultFontFamily is the family name of icon fonts unless requested otherwise
+	DefaultFontFamily = "icons"
+	fontUnitsPerEm    = 1000
+	fontAscent        = 850
+	fontDescent       = 150
+	// fontCurveTolerance is how far in font units the quadratic approximations of cubic curves may deviate
+	fontCurveTolerance = 1.0
+	// IconFontClassPrefix prefixes the CSS classes of the icons in the font
+	IconFontClassPrefix = "icon-"
+)
+
+// ErrInvalidFontFamily is returned for font family names which are not safe to use in CSS and file names
+var ErrInvalidFontFamily = errors.New("invalid font family")
+
+var fontFamilyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 _-]{0,62}$`)
+
+var cssClassInvalidCharacters = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// fontCache keeps the recently generated fonts keyed by the content hashes of their iconfiles and their codepoints
+var fontCache = newContentCache(32 << 20)
+
+// FontFilter selects the icons to build a font of. Without a size the largest SVG iconfile of each icon is used.
+type FontFilter struct {
+	Tags     []string
+	TagMatch string
+	Size     string
+	Family   string
+}
+
+// IconFont is a ZIP archive with an icon font in TrueType and WOFF2 formats, a stylesheet with a CSS class
+// for each icon and the map of the icons' codepoints
+type IconFont struct {
+	Content  []byte
+	Key      string
+	FileName string
+}
+
+// FontCodepoint is an entry of the codepoint map of icon fonts
+type FontCodepoint struct {
+	Codepoint int    `json:"codepoint"`
+	Unicode   string `json:"unicode"`
+	ClassName string `json:"className"`
+}
+
+// FontCodepointMap lists the icons of an icon font
+type FontCodepointMap struct {
+	Family string                   `json:"family"`
+	Icons  map[string]FontCodepoint `json:"icons"`
+}
+
+func fontFileBaseName(family string) string {
+	return strings.ToLower(strings.ReplaceAll(family, " ", "-"))
+}
+
+func iconFontClassName(iconName string) string {
+	return IconFontClassPrefix + cssClassInvalidCharacters.ReplaceAllString(iconName, "-")
+}
+
+// cubicToQuadratics approximates the cubic Bézier curve with quadratic ones returning their control and end points
+func cubicToQuadratics(p0, p1, p2, p3 point, depth int) []point {
+	control := p1.add(p2).scale(3).sub(p0).sub(p3).scale(0.25)
+	// The distance between the cubic and its single quadratic approximation is bounded by √3/36 |p3 - 3p2 + 3p1 - p0|
+	deviation := math.Sqrt(3) / 36 * p3.sub(p2.scale(3)).add(p1.scale(3)).sub(p0).length()
+	if deviation <= fontCurveTolerance || depth >= 6 {
+		return []point{control, p3}
+	}
+	p01, p12, p23 := lerp(p0, p1, 0.5), lerp(p1, p2, 0.5), lerp(p2, p3, 0.5)
+	p012, p123 := lerp(p01, p12, 0.5), lerp(p12, p23, 0.5)
+	middle := lerp(p012, p123, 0.5)
+	return append(cubicToQuadratics(p0, p01, p012, middle, depth+1), cubicToQuadratics(middle, p123, p23, p3, depth+1)...)
+}
+
+// glyphContour converts the subpath into a closed TrueType contour, filling implicitly closes open subpaths
+func glyphContour(sp subpath, toFont func(point) point) ttContour {
+	current := toFont(sp.start)
+	points := []point{current}
+	onCurve := []bool{true}
+	for _, segment := range sp.segments {
+		segmentPoints := make([]point, len(segment.points))
+		for i, p := range segment.points {
+			segmentPoints[i] = toFont(p)
+		}
+		switch segment.kind {
+		case lineTo:
+			points, onCurve = append(points, segmentPoints[0]), append(onCurve, true)
+		case quadTo:
+			points, onCurve = append(points, segmentPoints...), append(onCurve, false, true)
+		case cubicTo:
+			quadratics := cubicToQuadratics(current, segmentPoints[0], segmentPoints[1], segmentPoints[2], 0)
+			for i := range quadratics {
+				onCurve = append(onCurve, i%2 == 1)
+			}
+			points = append(points, quadratics...)
+		default:
+			continue
+		}
+		current = segmentPoints[len(segmentPoints)-1]
+	}
+
+	contour := ttContour{}
+	for i, p := range points {
+		ttp := ttPoint{x: int(math.Round(p.x)), y: int(math.Round(p.y)), onCurve: onCurve[i]}
+		if n := len(contour); n > 0 && contour[n-1].x == ttp.x && contour[n-1].y == ttp.y && ttp.onCurve {
+			contour[n-1].onCurve = true
+			continue
+		}
+		contour = append(contour, ttp)
+	}
+	if n := len(contour); n > 1 && contour[n-1].onCurve && contour[n-1].x == contour[0].x && contour[n-1].y == contour[0].y {
+		contour = contour[:n-1]
+	}
+	if len(contour) < 3 || contour.signedArea() == 0 {
+		return nil
+	}
+	return contour
+}
+
+// signedArea is positive for counter-clockwise contours, computed on the polygon of all points
+func (contour ttContour) signedArea() float64 {
+	area := 0.0
+	for i, p := range contour {
+		q := contour[(i+1)%len(contour)]
+		area += float64(p.x*q.y - q.x*p.y)
+	}
+	return area / 2
+}
+
+func (contour ttContour) contains(p ttPoint) bool {
+	inside := false
+	for i, a := range contour {
+		b := contour[(i+1)%len(contour)]
+		if (a.y > p.y) != (b.y > p.y) && float64(p.x) < float64(b.x-a.x)*float64(p.y-a.y)/float64(b.y-a.y)+float64(a.x) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func (contour ttContour) reversed() ttContour {
+	result := make(ttContour, len(contour))
+	for i, p := range contour {
+		result[len(contour)-1-i] = p
+	}
+	return result
+}
+
+// orientContours directs the contours of a shape so that TrueType's non-zero winding rule fills what SVG fills:
+// outer contours go clockwise. Even-odd filled shapes are reoriented by how deep contours are nested, while
+// non-zero filled ones are reversed as a whole if need be so as to preserve their relative directions.
+func orientContours(contours []ttContour, fillRule string) []ttContour {
+	depths := make([]int, len(contours))
+	for i, contour := range contours {
+		for j, other := range contours {
+			if i != j && other.contains(contour[0]) {
+				depths[i]++
+			}
+		}
+	}
+	if fillRule == "evenodd" {
+		oriented := make([]ttContour, len(contours))
+		for i, contour := range contours {
+			if (depths[i]%2 == 0) != (contour.signedArea() < 0) {
+				contour = contour.reversed()
+			}
+			oriented[i] = contour
+		}
+		return oriented
+	}
+	outermost, largestArea := -1, 0.0
+	for i, contour := range contours {
+		if area := math.Abs(contour.signedArea()); depths[i] == 0 && area > largestArea {
+			outermost, largestArea = i, area
+		}
+	}
+	if outermost < 0 || contours[outermost].signedArea() < 0 {
+		return contours
+	}
+	reversed := make([]ttContour, len(contours))
+	for i, contour := range contours {
+		reversed[i] = contour.reversed()
+	}
+	return reversed
+}
+
+// iconGlyph converts the filled shapes of the SVG into a glyph scaling its viewBox to the height of the em box.
+// Fonts have no notion of strokes, so stroked only shapes are left out.
+func iconGlyph(svg parsedSVG) (ttGlyph, int, error) {
+	origin, width, height, err := svg.viewBoxRect()
+	if err != nil {
+		return ttGlyph{}, 0, err
+	}
+	shapes, err := svg.shapes()
+	if err != nil {
+		return ttGlyph{}, 0, err
+	}
+	scale := fontUnitsPerEm / height
+	toFont := func(p point) point {
+		return point{(p.x - origin.x) * scale, fontAscent - (p.y-origin.y)*scale}
+	}
+
+	glyph := ttGlyph{advance: int(math.Round(width * scale))}
+	strokesLeftOut := 0
+	for _, shape := range shapes {
+		if shape.paint.fill == "none" || shape.paint.fill == "transparent" || shape.paint.fillOpacity*shape.paint.opacity == 0 {
+			if shape.paint.stroke != "none" {
+				strokesLeftOut++
+			}
+			continue
+		}
+		contours := []ttContour{}
+		for _, sp := range shape.subpaths {
+			if contour := glyphContour(sp, toFont); contour != nil {
+				contours = append(contours, contour)
+			}
+		}
+		glyph.contours = append(glyph.contours, orientContours(contours, shape.paint.fillRule)...)
+	}
+	return glyph, strokesLeftOut, nil
+}
+
+func iconFontStylesheet(family string, baseName string, iconNames []string, codepoints map[string]int) []byte {
+	var css bytes.Buffer
+	fmt.Fprintf(&css, "@font-face {\n"+
+		"  font-family: \"%s\";\n"+
+		"  src: url(\"%s.woff2\") format(\"woff2\"), url(\"%s.ttf\") format(\"truetype\");\n"+
+		"  font-weight: normal;\n"+
+		"  font-style: normal;\n"+
+		"  font-display: block;\n"+
+		"}\n\n", family, baseName, baseName)
+	fmt.Fprintf(&css, "[class^=\"%s\"], [class*=\" %s\"] {\n"+
+		"  font-family: \"%s\" !important;\n"+
+		"  font-style: normal;\n"+
+		"  font-weight: normal;\n"+
+		"  font-variant: normal;\n"+
+		"  text-transform: none;\n"+
+		"  line-height: 1;\n"+
+		"  -webkit-font-smoothing: antialiased;\n"+
+		"  -moz-osx-font-smoothing: grayscale;\n"+
+		"}\n", IconFontClassPrefix, IconFontClassPrefix, family)
+	for _, iconName := range iconNames {
+		fmt.Fprintf(&css, "\n.%s::before {\n  content: \"\\%x\";\n}\n", iconFontClassName(iconName), codepoints[iconName])
+	}
+	return css.Bytes()
+}
+
+func writeIconFontArchive(baseName string, files map[string][]byte) ([]byte, error) {
+	var out bytes.Buffer
+	archive := zip.NewWriter(&out)
+	extensions := []string{"ttf", "woff2", "css", "json"}
+	for _, extension := range extensions {
+		fileWriter, err := archive.Create(baseName + "." + extension)
+		if err == nil {
+			_, err = fileWriter.Write(files[extension])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s.%s to font archive: %w", baseName, extension, err)
+		}
+	}
+	err := archive.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to close font archive: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// GetIconFont builds an icon font of the SVG iconfiles of the selected icons. Icons are assigned codepoints
+// in the Private Use Area as they are created and keep them ever after.
+func (service *IconService) GetIconFont(filter FontFilter) (IconFont, error) {
+	logger := log.WithField("prefix", "GetIconFont")
+	family := filter.Family
+	if family == "" {
+		family = DefaultFontFamily
+	}
+	if !fontFamilyPattern.MatchString(family) {
+		return IconFont{}, fmt.Errorf("%q: %w", family, ErrInvalidFontFamily)
+	}
+	baseName := fontFileBaseName(family)
+
+	page, err := service.QueryIcons(domain.IconQuery{Tags: filter.Tags, TagMatch: filter.TagMatch, Format: "svg", Size: filter.Size})
+	if err != nil {
+		return IconFont{}, err
+	}
+	iconNames := []string{}
+	iconfiles := map[string]domain.StoredIconfile{}
+	for _, icon := range page.Icons {
+		descriptor, found := selectSpriteIconfile(icon, filter.Size)
+		if !found {
+			continue
+		}
+		iconfile, getErr := service.GetIconfile(icon.Name, descriptor)
+		if getErr != nil {
+			return IconFont{}, fmt.Errorf("failed to build icon font: %w", getErr)
+		}
+		iconNames = append(iconNames, icon.Name)
+		iconfiles[icon.Name] = iconfile
+	}
+	sort.Strings(iconNames)
+
+	codepoints, err := service.Repositories.DB.GetCodepoints(iconNames)
+	if err != nil {
+		return IconFont{}, fmt.Errorf("failed to build icon font: %w", err)
+	}
+
+	var keyBase strings.Builder
+	fmt.Fprintf(&keyBase, "%s\n", family)
+	for _, iconName := range iconNames {
+		fmt.Fprintf(&keyBase, "%s:%s:%x\n", iconName, iconfiles[iconName].ContentHash, codepoints[iconName])
+	}
+	key := domain.ContentHash([]byte(keyBase.String()))
+	if content, cached := fontCache.get(key); cached {
+		return IconFont{Content: content, Key: key, FileName: baseName + ".zip"}, nil
+	}
+
+	font := ttFont{
+		familyName:        family,
+		unitsPerEm:        fontUnitsPerEm,
+		ascent:            fontAscent,
+		descent:           fontDescent,
+		glyphs:            []ttGlyph{{advance: fontUnitsPerEm / 2}},
+		glyphsByCodepoint: map[int]int{},
+	}
+	codepointMap := FontCodepointMap{Family: family, Icons: map[string]FontCodepoint{}}
+	includedNames := []string{}
+	for _, iconName := range iconNames {
+		if _, assigned := codepoints[iconName]; !assigned {
+			logger.Warnf("leaving %s out of the font: no codepoint assigned", iconName)
+			continue
+		}
+		svg, parseErr := parseSVG(iconfiles[iconName].Content)
+		if parseErr != nil {
+			logger.Warnf("leaving %s out of the font: %v", iconName, parseErr)
+			continue
+		}
+		glyph, strokesLeftOut, glyphErr := iconGlyph(svg)
+		if glyphErr != nil {
+			logger.Warnf("leaving %s out of the font: %v", iconName, glyphErr)
+			continue
+		}
+		if strokesLeftOut > 0 {
+			logger.Warnf("%d stroked shape(s) of %s cannot be represented in the font", strokesLeftOut, iconName)
+		}
+		font.glyphsByCodepoint[codepoints[iconName]] = len(font.glyphs)
+		font.glyphs = append(font.glyphs, glyph)
+		includedNames = append(includedNames, iconName)
+		codepointMap.Icons[iconName] = FontCodepoint{
+			Codepoint: codepoints[iconName],
+			Unicode:   fmt.Sprintf("%x", codepoints[iconName]),
+			ClassName: iconFontClassName(iconName),
+		}
+	}
+
+	tables := font.tables()
+	ttf := sfnt(tables)
+	woff2Font, err := woff2(tables)
+	if err != nil {
+		return IconFont{}, fmt.Errorf("failed to build icon font: %w", err)
+	}
+	codepointJSON, err := json.MarshalIndent(codepointMap, "", "  ")
+	if err != nil {
+		return IconFont{}, fmt.Errorf("failed to build codepoint map: %w", err)
+	}
+	content, err := writeIconFontArchive(baseName, map[string][]byte{
+		"ttf":   ttf,
+		"woff2": woff2Font,
+		"css":   iconFontStylesheet(family, baseName, includedNames, codepoints),
+		"json":  codepointJSON,
+	})
+	if err != nil {
+		return IconFont{}, err
+	}
+
+	fontCache.put(key, content)
+	return IconFont{Content: content, Key: key, FileName: baseName + ".zip"}, nil
+}
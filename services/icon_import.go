@@ -0,0 +1,295 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pdkovacs/igo-repo/domain"
+	"github.com/pdkovacs/igo-repo/repositories"
+	"github.com/pdkovacs/igo-repo/security/authr"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrInvalidImportArchive is returned when the archive to import icons from cannot be read
+var ErrInvalidImportArchive = errors.New("invalid import archive")
+
+// maxImportedIconfileSize is the largest iconfile accepted in an import archive
+const maxImportedIconfileSize = 32 << 20
+
+// maxImportedContentSize is the most the iconfiles of an import archive may take in total once decompressed
+const maxImportedContentSize = 128 << 20
+
+// Statuses of the files in the import archive
+const (
+	ImportCreated = "created"
+	ImportSkipped = "skipped"
+	ImportFailed  = "failed"
+)
+
+// ImportEntry reports what happened to a file of the import archive
+type ImportEntry struct {
+	Path     string `json:"path"`
+	IconName string `json:"iconName,omitempty"`
+	Format   string `json:"format,omitempty"`
+	Size     string `json:"size,omitempty"`
+	Status   string `json:"status"`
+	Reason   string `json:"reason,omitempty"`
+	// Sanitization lists what has been removed from SVG iconfiles
+	Sanitization []SanitizationRemoval `json:"sanitization,omitempty"`
+}
+
+// ImportReport reports the outcome of an import file by file. In dry-run mode the report tells what would happen.
+type ImportReport struct {
+	DryRun  bool          `json:"dryRun"`
+	Created int           `json:"created"`
+	Skipped int           `json:"skipped"`
+	Failed  int           `json:"failed"`
+	Entries []ImportEntry `json:"entries"`
+}
+
+func (report *ImportReport) add(entry ImportEntry) {
+	switch entry.Status {
+	case ImportCreated:
+		report.Created++
+	case ImportSkipped:
+		report.Skipped++
+	case ImportFailed:
+		report.Failed++
+	}
+	report.Entries = append(report.Entries, entry)
+}
+
+// parseImportPath tells the icon name and the iconfile descriptor from the path of a file in the import archive.
+// Both the layout of the git repository (<format>/<size>/<name>@<size>.<format>) and that of the demo data
+// (<format>/<size>/<name>.<format>) are understood. The size in the path is only a hint: the demo data, for one, keeps
+// PNGs in directories named after density-independent sizes. The iconfile is imported at the size and in the format
+// of its content.
+func parseImportPath(filePath string) (string, domain.IconfileDescriptor, error) {
+	dir, fileName := path.Split(filePath)
+	ext := path.Ext(fileName)
+	if ext == "" {
+		return "", domain.IconfileDescriptor{}, errors.New("missing file name extension")
+	}
+	format := strings.ToLower(ext[1:])
+	baseName := strings.TrimSuffix(fileName, ext)
+
+	if at := strings.LastIndex(baseName, "@"); at > 0 && at < len(baseName)-1 {
+		return baseName[:at], domain.IconfileDescriptor{Format: format, Size: baseName[at+1:]}, nil
+	}
+
+	dirs := strings.Split(strings.Trim(dir, "/"), "/")
+	if len(dirs) < 2 || baseName == "" {
+		return "", domain.IconfileDescriptor{}, errors.New("expected <format>/<size>/<name>.<format> or <name>@<size>.<format>")
+	}
+	if strings.ToLower(dirs[len(dirs)-2]) != format {
+		return "", domain.IconfileDescriptor{}, fmt.Errorf("file name extension doesn't match format directory %s", dirs[len(dirs)-2])
+	}
+	return baseName, domain.IconfileDescriptor{Format: format, Size: dirs[len(dirs)-1]}, nil
+}
+
+// readImportedFile reads at most maxImportedIconfileSize bytes of the file, but no more than what remains of the
+// content allowed in total. The sizes declared in the archive are not trusted.
+func readImportedFile(file *zip.File, remaining int64) ([]byte, error) {
+	if file.UncompressedSize64 > maxImportedIconfileSize {
+		return nil, fmt.Errorf("larger than %d bytes", maxImportedIconfileSize)
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	limit := int64(maxImportedIconfileSize)
+	if remaining < limit {
+		limit = remaining
+	}
+	content, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > limit {
+		return content, fmt.Errorf("larger than %d bytes", limit)
+	}
+	return content, nil
+}
+
+// isImportFormat tells whether the format told by the file name extension is that of the content
+func isImportFormat(decodedFormat string, format string) bool {
+	return decodedFormat == format || (decodedFormat == "jpeg" && format == "jpg")
+}
+
+// importableIcon collects the iconfiles to import for an icon along with the report entries about them
+type importableIcon struct {
+	icon    domain.Icon
+	entries []ImportEntry
+}
+
+func (service *IconService) checkImportToExistingIcon(iconName string, user UserInfo) error {
+	err := authr.HasRequiredPermissions(user.UserId, user.Permissions, []authr.PermissionID{
+		authr.UPDATE_ICON,
+		authr.ADD_ICONFILE,
+	})
+	if err != nil {
+		return err
+	}
+	return service.checkIconACL(iconName, authr.ADD_ICONFILE, user)
+}
+
+// ImportIcons creates the icons and iconfiles found in the ZIP archive. Iconfiles already in the repository are skipped,
+// those which cannot be imported are reported as failed. All of the rest are committed to the git repository at once.
+func (service *IconService) ImportIcons(archive []byte, dryRun bool, modifiedBy UserInfo) (ImportReport, error) {
+	logger := log.WithField("prefix", "ImportIcons")
+	report := ImportReport{DryRun: dryRun, Entries: []ImportEntry{}}
+
+	err := authr.HasRequiredPermissions(modifiedBy.UserId, modifiedBy.Permissions, []authr.PermissionID{
+		authr.CREATE_ICON,
+	})
+	if err != nil {
+		return report, fmt.Errorf("failed to import icons: %w", err)
+	}
+
+	zipReader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return report, fmt.Errorf("%v: %w", err, ErrInvalidImportArchive)
+	}
+
+	importables := map[string]*importableIcon{}
+	iconNames := []string{}
+	seen := map[string]bool{}
+	var contentSize int64
+	for _, file := range zipReader.File {
+		if file.FileInfo().IsDir() || strings.HasPrefix(file.Name, "__MACOSX/") || strings.HasPrefix(path.Base(file.Name), ".") {
+			continue
+		}
+		entry := ImportEntry{Path: file.Name}
+		iconName, descriptor, parseErr := parseImportPath(file.Name)
+		if parseErr != nil {
+			entry.Status, entry.Reason = ImportFailed, parseErr.Error()
+			report.add(entry)
+			continue
+		}
+		entry.IconName = iconName
+
+		content, readErr := readImportedFile(file, maxImportedContentSize-contentSize)
+		contentSize += int64(len(content))
+		if contentSize > maxImportedContentSize {
+			return report, fmt.Errorf("content larger than %d bytes in total: %w", maxImportedContentSize, ErrInvalidImportArchive)
+		}
+		if readErr != nil {
+			entry.Status, entry.Reason = ImportFailed, fmt.Sprintf("failed to read: %v", readErr)
+			report.add(entry)
+			continue
+		}
+		config, decodedFormat, decodeErr := image.DecodeConfig(bytes.NewReader(content))
+		if decodeErr != nil {
+			entry.Status, entry.Reason = ImportFailed, fmt.Sprintf("failed to decode: %v", decodeErr)
+			report.add(entry)
+			continue
+		}
+		if !isImportFormat(decodedFormat, descriptor.Format) {
+			entry.Status, entry.Reason = ImportFailed, fmt.Sprintf("content is %s", decodedFormat)
+			report.add(entry)
+			continue
+		}
+		// Iconfiles are described the same way as uploaded ones are, whatever the path says
+		descriptor = domain.IconfileDescriptor{Format: decodedFormat, Size: fmt.Sprintf("%dpx", config.Height)}
+		entry.Format, entry.Size = descriptor.Format, descriptor.Size
+
+		key := fmt.Sprintf("%s/%s/%s", iconName, descriptor.Format, descriptor.Size)
+		if seen[key] {
+			entry.Status, entry.Reason = ImportSkipped, "duplicate in archive"
+			report.add(entry)
+			continue
+		}
+		seen[key] = true
+
+		content, sanitization, sanitizeErr := service.sanitizeIconfile(decodedFormat, content)
+		entry.Sanitization = sanitization.Removed
+		if sanitizeErr != nil {
+			entry.Status, entry.Reason = ImportFailed, sanitizeErr.Error()
+			report.add(entry)
+			continue
+		}
+
+		importable, ok := importables[iconName]
+		if !ok {
+			importable = &importableIcon{icon: domain.Icon{IconAttributes: domain.IconAttributes{Name: iconName}}}
+			importables[iconName] = importable
+			iconNames = append(iconNames, iconName)
+		}
+		content, originalContent := service.optimizeIconfile(decodedFormat, content)
+		importable.icon.Iconfiles = append(importable.icon.Iconfiles, domain.Iconfile{IconfileDescriptor: descriptor, Content: content, OriginalContent: originalContent})
+		importable.entries = append(importable.entries, entry)
+	}
+	sort.Strings(iconNames)
+
+	toImport := []domain.Icon{}
+	checkACLs := map[string]repositories.IconACLCheck{}
+	for _, iconName := range iconNames {
+		importable := importables[iconName]
+		existing, describeErr := service.Repositories.DB.DescribeIcon(iconName)
+		if describeErr != nil && !errors.Is(describeErr, domain.ErrIconNotFound) {
+			return report, fmt.Errorf("failed to import icons: %w", describeErr)
+		}
+		if describeErr == nil {
+			if checkErr := service.checkImportToExistingIcon(iconName, modifiedBy); checkErr != nil {
+				if !errors.Is(checkErr, authr.ErrPermission) {
+					return report, fmt.Errorf("failed to import icons: %w", checkErr)
+				}
+				for _, entry := range importable.entries {
+					entry.Status, entry.Reason = ImportFailed, "not allowed to add iconfiles to the existing icon"
+					report.add(entry)
+				}
+				continue
+			}
+		}
+
+		icon := domain.Icon{IconAttributes: importable.icon.IconAttributes}
+		for i, iconfile := range importable.icon.Iconfiles {
+			entry := importable.entries[i]
+			if containsIconfile(existing.Iconfiles, iconfile.IconfileDescriptor) {
+				entry.Status, entry.Reason = ImportSkipped, "iconfile exists"
+				report.add(entry)
+				continue
+			}
+			entry.Status = ImportCreated
+			report.add(entry)
+			icon.Iconfiles = append(icon.Iconfiles, iconfile)
+		}
+		if len(icon.Iconfiles) > 0 {
+			toImport = append(toImport, icon)
+			// The icon might have been created by someone else since it has been described
+			checkACLs[iconName] = iconACLCheck(iconName, authr.ADD_ICONFILE, modifiedBy)
+		}
+	}
+
+	sort.Slice(report.Entries, func(i, j int) bool { return report.Entries[i].Path < report.Entries[j].Path })
+
+	logger.Infof("%d iconfile(s) to import, %d skipped, %d failed, dry run: %v", report.Created, report.Skipped, report.Failed, dryRun)
+	if dryRun || len(toImport) == 0 {
+		return report, nil
+	}
+
+	err = service.Repositories.DB.ImportIcons(toImport, modifiedBy.UserId.String(), checkACLs, func() error {
+		return service.Repositories.Git.AddIconfiles(toImport, modifiedBy.UserId.String())
+	})
+	if err != nil {
+		return report, fmt.Errorf("failed to import icons: %w", err)
+	}
+	return report, nil
+}
+
+func containsIconfile(iconfiles []domain.IconfileDescriptor, iconfile domain.IconfileDescriptor) bool {
+	for _, candidate := range iconfiles {
+		if candidate == iconfile {
+			return true
+		}
+	}
+	return false
+}
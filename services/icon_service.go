@@ -2,9 +2,12 @@ package services
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"image"
+	"strings"
 
+	"github.com/pdkovacs/igo-repo/config"
 	"github.com/pdkovacs/igo-repo/domain"
 	"github.com/pdkovacs/igo-repo/repositories"
 	"github.com/pdkovacs/igo-repo/security/authr"
@@ -13,6 +16,16 @@ import (
 
 type IconService struct {
 	Repositories *repositories.Repositories
+	// PersistRasterizedIconfiles makes iconfiles rendered from SVG or downscaled on demand get added to their icons
+	PersistRasterizedIconfiles bool
+	// StrictSVGSanitization makes SVG iconfiles with unsafe content get rejected rather than cleaned
+	StrictSVGSanitization bool
+	// OptimizeSVGIconfiles makes SVG iconfiles get stored optimized with their original content kept, too
+	OptimizeSVGIconfiles bool
+	// SVGThemes are the named palettes monochrome SVG iconfiles can be recolored with
+	SVGThemes config.ThemePalettes
+	// GenerateRasterSizeLadder makes the standard sizes smaller than uploaded PNG and JPEG iconfiles get generated
+	GenerateRasterSizeLadder bool
 }
 
 func (server *IconService) DescribeAllIcons() ([]domain.IconDescriptor, error) {
@@ -23,6 +36,62 @@ func (server *IconService) DescribeAllIcons() ([]domain.IconDescriptor, error) {
 	return icons, err
 }
 
+// maxIconPageSize is the largest page of icons to be requested
+const maxIconPageSize = 1000
+
+// ErrInvalidIconQuery is returned for malformed icon queries
+var ErrInvalidIconQuery = errors.New("invalid icon query")
+
+func validateIconQuery(query domain.IconQuery) error {
+	if query.TagMatch != "" && query.TagMatch != domain.TagMatchAny && query.TagMatch != domain.TagMatchAll {
+		return fmt.Errorf("unexpected tag match mode %s: %w", query.TagMatch, ErrInvalidIconQuery)
+	}
+	if query.SortBy != "" && query.SortBy != domain.SortByName && query.SortBy != domain.SortByModifiedAt {
+		return fmt.Errorf("unexpected sort key %s: %w", query.SortBy, ErrInvalidIconQuery)
+	}
+	if query.Limit < 0 || query.Limit > maxIconPageSize {
+		return fmt.Errorf("page size must be between 1 and %d: %w", maxIconPageSize, ErrInvalidIconQuery)
+	}
+	return nil
+}
+
+// QueryIcons returns the icons matching the query
+func (server *IconService) QueryIcons(query domain.IconQuery) (domain.IconPage, error) {
+	err := validateIconQuery(query)
+	if err != nil {
+		return domain.IconPage{}, err
+	}
+	page, err := server.Repositories.DB.QueryIcons(query)
+	if err != nil {
+		if errors.Is(err, repositories.ErrInvalidCursor) {
+			return domain.IconPage{}, fmt.Errorf("%v: %w", err, ErrInvalidIconQuery)
+		}
+		return domain.IconPage{}, fmt.Errorf("failed to query icons: %w", err)
+	}
+	return page, nil
+}
+
+// defaultSearchHitCount is the number of the most relevant icons returned by searches unless specified otherwise
+const defaultSearchHitCount = 50
+
+// SearchIcons returns the icons most relevant to the search text in the order of relevance
+func (server *IconService) SearchIcons(text string, limit int) ([]domain.IconSearchHit, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("empty search text: %w", ErrInvalidIconQuery)
+	}
+	if limit == 0 {
+		limit = defaultSearchHitCount
+	}
+	if limit < 0 || limit > maxIconPageSize {
+		return nil, fmt.Errorf("hit count must be between 1 and %d: %w", maxIconPageSize, ErrInvalidIconQuery)
+	}
+	hits, err := server.Repositories.DB.SearchIcons(text, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search icons: %w", err)
+	}
+	return hits, nil
+}
+
 func (server *IconService) DescribeIcon(iconName string) (domain.IconDescriptor, error) {
 	icon, err := server.Repositories.DB.DescribeIcon(iconName)
 	if err != nil {
@@ -31,36 +100,53 @@ func (server *IconService) DescribeIcon(iconName string) (domain.IconDescriptor,
 	return icon, err
 }
 
-func (service *IconService) CreateIcon(iconName string, initialIconfileContent []byte, modifiedBy UserInfo) (domain.Icon, error) {
+// CreateIcon creates an icon with the iconfile. SVG iconfiles are sanitized first, the report tells what has been
+// removed from them.
+func (service *IconService) CreateIcon(iconName string, initialIconfileContent []byte, owningGroup string, modifiedBy UserInfo) (domain.Icon, SanitizationReport, error) {
 	logger := log.WithField("prefix", "CreateIcon")
 	err := authr.HasRequiredPermissions(modifiedBy.UserId, modifiedBy.Permissions, []authr.PermissionID{
 		authr.CREATE_ICON,
 	})
 	if err != nil {
-		return domain.Icon{}, fmt.Errorf("failed to create icon %v: %w", iconName, err)
+		return domain.Icon{}, SanitizationReport{}, fmt.Errorf("failed to create icon %v: %w", iconName, err)
+	}
+	err = validateOwningGroup(owningGroup, modifiedBy)
+	if err != nil {
+		return domain.Icon{}, SanitizationReport{}, fmt.Errorf("failed to create icon %v: %w", iconName, err)
 	}
 	logger.Infof("iconName: %s, initialIconfileContent: %v encoded bytes, modifiedBy: %s", iconName, len(initialIconfileContent), modifiedBy)
 	config, format, err := image.DecodeConfig(bytes.NewReader(initialIconfileContent))
 	if err != nil {
-		return domain.Icon{}, fmt.Errorf("failed to decode iconfile: %w", err)
+		return domain.Icon{}, SanitizationReport{}, fmt.Errorf("failed to decode iconfile: %w", err)
+	}
+	initialIconfileContent, report, err := service.sanitizeIconfile(format, initialIconfileContent)
+	if err != nil {
+		return domain.Icon{}, report, fmt.Errorf("failed to create icon %v: %w", iconName, err)
 	}
+	content, originalContent := service.optimizeIconfile(format, initialIconfileContent)
 	iconfile := domain.Iconfile{
 		IconfileDescriptor: domain.IconfileDescriptor{
 			Format: format,
 			Size:   fmt.Sprintf("%dpx", config.Height),
 		},
-		Content: initialIconfileContent,
+		Content:         content,
+		OriginalContent: originalContent,
 	}
 	logger.Infof(
 		"iconName: %s, iconfile: %v, initialIconfileContent size: %d, modifiedBy: %s",
 		iconName, iconfile, len(initialIconfileContent), modifiedBy,
 	)
 
-	errCreate := service.Repositories.DB.CreateIcon(iconName, iconfile, modifiedBy.UserId.String(), func() error {
+	errCreate := service.Repositories.DB.CreateIcon(iconName, iconfile, modifiedBy.UserId.String(), owningGroup, func() error {
 		return service.Repositories.Git.AddIconfile(iconName, iconfile, modifiedBy.UserId.String())
 	})
 	if errCreate != nil {
-		return domain.Icon{}, errCreate
+		return domain.Icon{}, report, errCreate
+	}
+
+	iconfiles := []domain.Iconfile{iconfile}
+	for _, descriptor := range service.addRasterSizeLadder(iconName, iconfile, config.Height, modifiedBy) {
+		iconfiles = append(iconfiles, domain.Iconfile{IconfileDescriptor: descriptor})
 	}
 
 	return domain.Icon{
@@ -69,57 +155,72 @@ func (service *IconService) CreateIcon(iconName string, initialIconfileContent [
 			ModifiedBy: modifiedBy.UserId.String(),
 			Tags:       []string{},
 		},
-		Iconfiles: []domain.Iconfile{
-			iconfile,
-		},
-	}, nil
+		Iconfiles: iconfiles,
+	}, report, nil
+}
+
+func (service *IconService) GetIconfile(iconName string, iconfile domain.IconfileDescriptor) (domain.StoredIconfile, error) {
+	storedIconfile, err := service.Repositories.DB.GetStoredIconfile(iconName, iconfile.Format, iconfile.Size)
+	if err != nil {
+		return domain.StoredIconfile{}, fmt.Errorf("failed to retrieve iconfile %v: %w", iconfile, err)
+	}
+	return storedIconfile, nil
 }
 
-func (service *IconService) GetIconfile(iconName string, iconfile domain.IconfileDescriptor) (domain.Iconfile, error) {
-	content, err := service.Repositories.DB.GetIconFile(iconName, iconfile.Format, iconfile.Size)
+// GetIconfileByHash returns the iconfile with the specified content hash, whichever icon it belongs to. Only iconfiles
+// stored in the repository can be looked up: content derived on the fly is kept by the replica deriving it only.
+func (service *IconService) GetIconfileByHash(contentHash string) (domain.StoredIconfile, error) {
+	storedIconfile, err := service.Repositories.DB.GetIconfileByHash(contentHash)
 	if err != nil {
-		return domain.Iconfile{}, fmt.Errorf("failed to retrieve iconfile %v: %w", iconfile, err)
+		return domain.StoredIconfile{}, fmt.Errorf("failed to retrieve iconfile by content hash: %w", err)
 	}
-	return domain.Iconfile{
-		IconfileDescriptor: iconfile,
-		Content:            content,
-	}, nil
+	return storedIconfile, nil
 }
 
-func (service *IconService) AddIconfile(iconName string, initialIconfileContent []byte, modifiedBy UserInfo) (domain.IconfileDescriptor, error) {
+// AddIconfile adds the iconfile to the icon. SVG iconfiles are sanitized first, the report tells what has been
+// removed from them.
+func (service *IconService) AddIconfile(iconName string, initialIconfileContent []byte, modifiedBy UserInfo) (domain.IconfileDescriptor, SanitizationReport, error) {
 	logger := log.WithField("prefix", "AddIconfile")
 	err := authr.HasRequiredPermissions(modifiedBy.UserId, modifiedBy.Permissions, []authr.PermissionID{
 		authr.UPDATE_ICON,
 		authr.ADD_ICONFILE,
 	})
 	if err != nil {
-		return domain.IconfileDescriptor{}, fmt.Errorf("failed to add iconfile %v: %w", iconName, err)
+		return domain.IconfileDescriptor{}, SanitizationReport{}, fmt.Errorf("failed to add iconfile %v: %w", iconName, err)
 	}
 	reader := bytes.NewReader(initialIconfileContent)
 	config, format, err := image.DecodeConfig(reader)
 	if err != nil {
 		logger.Errorf("failed to decode image configuration of iconfile for %s: %v", iconName, err)
-		return domain.IconfileDescriptor{}, fmt.Errorf("failed to decode image configuration of iconfile for %s: %w", iconName, err)
+		return domain.IconfileDescriptor{}, SanitizationReport{}, fmt.Errorf("failed to decode image configuration of iconfile for %s: %w", iconName, err)
 	}
+	initialIconfileContent, report, err := service.sanitizeIconfile(format, initialIconfileContent)
+	if err != nil {
+		return domain.IconfileDescriptor{}, report, fmt.Errorf("failed to add iconfile to %v: %w", iconName, err)
+	}
+	content, originalContent := service.optimizeIconfile(format, initialIconfileContent)
 	iconfile := domain.Iconfile{
 		IconfileDescriptor: domain.IconfileDescriptor{
 			Format: format,
 			Size:   fmt.Sprintf("%dpx", config.Height),
 		},
-		Content: initialIconfileContent,
+		Content:         content,
+		OriginalContent: originalContent,
 	}
 	logger.Infof(
 		"iconName: %s, iconfile: %v, content of iconfile to add size: %d, modifiedBy: %s",
 		iconName, iconfile, len(initialIconfileContent), modifiedBy,
 	)
-	errAddIconfile := service.Repositories.DB.AddIconfileToIcon(iconName, iconfile, modifiedBy.UserId.String(), func() error {
+	checkACL := iconACLCheck(iconName, authr.ADD_ICONFILE, modifiedBy)
+	errAddIconfile := service.Repositories.DB.AddIconfileToIcon(iconName, iconfile, modifiedBy.UserId.String(), checkACL, func() error {
 		return service.Repositories.Git.AddIconfile(iconName, iconfile, modifiedBy.UserId.String())
 	})
 	if errAddIconfile != nil {
-		return domain.IconfileDescriptor{}, errAddIconfile
+		return domain.IconfileDescriptor{}, report, errAddIconfile
 	}
+	service.addRasterSizeLadder(iconName, iconfile, config.Height, modifiedBy)
 
-	return iconfile.IconfileDescriptor, nil
+	return iconfile.IconfileDescriptor, report, nil
 }
 
 func (service *IconService) DeleteIcon(iconName string, modifiedBy UserInfo) error {
@@ -133,7 +234,8 @@ func (service *IconService) DeleteIcon(iconName string, modifiedBy UserInfo) err
 	if describeErr != nil {
 		return fmt.Errorf("failed to have to-be-deleted icon \"%s\" described: %w", iconName, describeErr)
 	}
-	errDeleteIcon := service.Repositories.DB.DeleteIcon(iconName, modifiedBy.UserId.String(), func() error {
+	checkACL := iconACLCheck(iconName, authr.REMOVE_ICON, modifiedBy)
+	errDeleteIcon := service.Repositories.DB.DeleteIcon(iconName, modifiedBy.UserId.String(), checkACL, func() error {
 		return service.Repositories.Git.DeleteIcon(iconDesc, modifiedBy.UserId)
 	})
 	return errDeleteIcon
@@ -146,7 +248,8 @@ func (service *IconService) DeleteIconfile(iconName string, iconfileDescriptor d
 	if err != nil {
 		return fmt.Errorf("not enough permissions to delete icon \"%v\" to : %w", iconName, err)
 	}
-	errDeleteIcon := service.Repositories.DB.DeleteIconfile(iconName, iconfileDescriptor, modifiedBy.UserId.String(), func() error {
+	checkACL := iconACLCheck(iconName, authr.REMOVE_ICONFILE, modifiedBy)
+	errDeleteIcon := service.Repositories.DB.DeleteIconfile(iconName, iconfileDescriptor, modifiedBy.UserId.String(), checkACL, func() error {
 		return service.Repositories.Git.DeleteIconfile(iconName, iconfileDescriptor, modifiedBy.UserId)
 	})
 	return errDeleteIcon
@@ -168,6 +271,19 @@ func (service *IconService) AddTag(iconName string, tag string, userInfo UserInf
 	return nil
 }
 
+// UpdateDescription replaces the description of the icon, the free text it can be searched for by
+func (service *IconService) UpdateDescription(iconName string, description string, userInfo UserInfo) error {
+	permErr := authr.HasRequiredPermissions(userInfo.UserId, userInfo.Permissions, []authr.PermissionID{authr.UPDATE_ICON})
+	if permErr != nil {
+		return authr.ErrPermission
+	}
+	dbErr := service.Repositories.DB.UpdateIconDescription(iconName, description, userInfo.UserId.String())
+	if dbErr != nil {
+		return fmt.Errorf("failed to update description of \"%s\": %w", iconName, dbErr)
+	}
+	return nil
+}
+
 func (service *IconService) RemoveTag(iconName string, tag string, userInfo UserInfo) error {
 	permErr := authr.HasRequiredPermissions(userInfo.UserId, userInfo.Permissions, []authr.PermissionID{authr.REMOVE_TAG})
 	if permErr != nil {
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"strconv"
+
+	"github.com/pdkovacs/igo-repo/domain"
+	"github.com/pdkovacs/igo-repo/security/authr"
+	log "github.com/sirupsen/logrus"
+)
+
+// rasterSizeLadder are the heights in pixels raster iconfiles are generated at when a larger one is uploaded if the
+// service is configured to do so
+var rasterSizeLadder = []int{16, 24, 32, 48, 64}
+
+// downscaledJPEGQuality is the quality downscaled JPEG iconfiles are encoded with
+const downscaledJPEGQuality = 90
+
+func isDownscalableFormat(format string) bool {
+	return format == "png" || format == "jpeg"
+}
+
+// rasterSource is a raster iconfile along with its height in pixels
+type rasterSource struct {
+	iconfile domain.StoredIconfile
+	height   int
+}
+
+// selectDownscalingSource picks the smallest raster iconfile of the icon at least as tall as the height preferring
+// the format at the same height. The iconfiles are selected by their sizes, which are their heights in pixels, so that
+// only the content of the one selected gets loaded.
+func (service *IconService) selectDownscalingSource(iconName string, format string, height int) (rasterSource, error) {
+	icon, err := service.Repositories.DB.DescribeIcon(iconName)
+	if err != nil {
+		return rasterSource{}, fmt.Errorf("failed to describe icon %s to downscale: %w", iconName, err)
+	}
+	var selected domain.IconfileDescriptor
+	selectedHeight := 0
+	for _, descriptor := range icon.Iconfiles {
+		if !isDownscalableFormat(descriptor.Format) {
+			continue
+		}
+		match := rasterSizePattern.FindStringSubmatch(descriptor.Size)
+		if match == nil {
+			continue
+		}
+		candidateHeight, _ := strconv.Atoi(match[1])
+		if candidateHeight < height {
+			continue
+		}
+		if selectedHeight == 0 || candidateHeight < selectedHeight || candidateHeight == selectedHeight && descriptor.Format == format {
+			selected, selectedHeight = descriptor, candidateHeight
+		}
+	}
+	if selectedHeight == 0 {
+		return rasterSource{}, fmt.Errorf("no raster iconfile of %s to downscale to %dpx: %w", iconName, height, domain.ErrIconfileNotFound)
+	}
+	iconfile, err := service.GetIconfile(iconName, selected)
+	if err != nil {
+		return rasterSource{}, err
+	}
+	return rasterSource{iconfile: iconfile, height: selectedHeight}, nil
+}
+
+// flattenOnto composes the image on an opaque background for formats or platforms without transparency
+func flattenOnto(img *image.NRGBA, background color.NRGBA) *image.NRGBA {
+	flattened := image.NewNRGBA(img.Bounds())
+	backgroundComponents := [3]uint8{background.R, background.G, background.B}
+	for i := 0; i < len(img.Pix); i += 4 {
+		alpha := float64(img.Pix[i+3]) / 0xff
+		for c := 0; c < 3; c++ {
+			flattened.Pix[i+c] = uint8(math.Round(float64(img.Pix[i+c])*alpha + float64(backgroundComponents[c])*(1-alpha)))
+		}
+		flattened.Pix[i+3] = 0xff
+	}
+	return flattened
+}
+
+// encodeRasterIconfile encodes the image in the format
+func encodeRasterIconfile(img *image.NRGBA, format string) ([]byte, error) {
+	var out bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&out, img)
+	case "jpeg":
+		err = jpeg.Encode(&out, flattenOnto(img, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}), &jpeg.Options{Quality: downscaledJPEGQuality})
+	default:
+		return nil, fmt.Errorf("unsupported raster format %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// downscale scales the raster iconfile down to the height keeping its aspect ratio
+func downscale(source []byte, height int, format string) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(source))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode iconfile to downscale: %w", err)
+	}
+	bounds := img.Bounds()
+	width, err := rasterWidth(float64(bounds.Dx())/float64(bounds.Dy()), height)
+	if err != nil {
+		return nil, err
+	}
+	return encodeRasterIconfile(resampleImage(img, width, height), format)
+}
+
+// downscaleIconfile derives the PNG or JPEG iconfile of the size from the smallest larger raster iconfile of the icon
+func (service *IconService) downscaleIconfile(iconName string, descriptor domain.IconfileDescriptor) (domain.StoredIconfile, error) {
+	match := rasterSizePattern.FindStringSubmatch(descriptor.Size)
+	if match == nil {
+		return domain.StoredIconfile{}, fmt.Errorf("size %s is not in pixels: %w", descriptor.Size, domain.ErrIconfileNotFound)
+	}
+	height, _ := strconv.Atoi(match[1])
+	if height > maxRasterizedSize {
+		return domain.StoredIconfile{}, fmt.Errorf("%s is larger than %dpx: %w", descriptor.Size, maxRasterizedSize, ErrInvalidRasterSize)
+	}
+
+	source, err := service.selectDownscalingSource(iconName, descriptor.Format, height)
+	if err != nil {
+		return domain.StoredIconfile{}, err
+	}
+
+	downscaled := domain.StoredIconfile{Iconfile: domain.Iconfile{IconfileDescriptor: descriptor}, Derived: true}
+	key := rasterCacheKey(source.iconfile.ContentHash, descriptor)
+	if content, cached := rasterCache.get(key); cached {
+		downscaled.Content = content
+		downscaled.ContentHash = domain.ContentHash(content)
+		return downscaled, nil
+	}
+
+	downscaled.Content, err = downscale(source.iconfile.Content, height, descriptor.Format)
+	if err != nil {
+		return domain.StoredIconfile{}, fmt.Errorf("failed to downscale %v of %s to %v: %w", source.iconfile.IconfileDescriptor, iconName, descriptor, err)
+	}
+	downscaled.ContentHash = domain.ContentHash(downscaled.Content)
+	rasterCache.put(key, downscaled.Content)
+	return downscaled, nil
+}
+
+// addRasterSizeLadder adds the iconfiles of the standard sizes smaller than the uploaded raster iconfile to the icon
+// unless they exist. Failures are logged only: the upload itself has succeeded.
+func (service *IconService) addRasterSizeLadder(iconName string, uploaded domain.Iconfile, height int, modifiedBy UserInfo) []domain.IconfileDescriptor {
+	logger := log.WithField("prefix", "addRasterSizeLadder")
+	added := []domain.IconfileDescriptor{}
+	if !service.GenerateRasterSizeLadder || !isDownscalableFormat(uploaded.Format) {
+		return added
+	}
+	icon, err := service.Repositories.DB.DescribeIcon(iconName)
+	if err != nil {
+		logger.Errorf("failed to describe icon %s: %v", iconName, err)
+		return added
+	}
+	checkACL := iconACLCheck(iconName, authr.ADD_ICONFILE, modifiedBy)
+	for _, size := range rasterSizeLadder {
+		if size >= height {
+			break
+		}
+		descriptor := domain.IconfileDescriptor{Format: uploaded.Format, Size: fmt.Sprintf("%dpx", size)}
+		if containsIconfile(icon.Iconfiles, descriptor) {
+			continue
+		}
+		content, err := downscale(uploaded.Content, size, uploaded.Format)
+		if err != nil {
+			logger.Errorf("failed to downscale %v of %s to %v: %v", uploaded.IconfileDescriptor, iconName, descriptor, err)
+			return added
+		}
+		iconfile := domain.Iconfile{IconfileDescriptor: descriptor, Content: content}
+		err = service.Repositories.DB.AddIconfileToIcon(iconName, iconfile, modifiedBy.UserId.String(), checkACL, func() error {
+			return service.Repositories.Git.AddIconfile(iconName, iconfile, modifiedBy.UserId.String())
+		})
+		if errors.Is(err, domain.ErrIconfileAlreadyExists) {
+			continue
+		}
+		if err != nil {
+			logger.Errorf("failed to add %v to %s: %v", descriptor, iconName, err)
+			return added
+		}
+		added = append(added, descriptor)
+	}
+	return added
+}
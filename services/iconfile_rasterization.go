@@ -0,0 +1,161 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/png"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/pdkovacs/igo-repo/domain"
+	"github.com/pdkovacs/igo-repo/security/authr"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxRasterizedSize is the largest height and width in pixels iconfiles are rasterized and downscaled to
+const maxRasterizedSize = 2048
+
+// ErrInvalidRasterSize is returned for sizes SVG iconfiles cannot be rasterized at
+var ErrInvalidRasterSize = errors.New("invalid size to rasterize at")
+
+var rasterSizePattern = regexp.MustCompile(`^([1-9][0-9]*)px$`)
+
+// rasterCache keeps the recently rasterized and downscaled iconfiles keyed by the content hash of their source along
+// with their format and size
+var rasterCache = newContentCache(32 << 20)
+
+// rasterWidth tells how wide an image of the aspect ratio is at the height. Images wider than maxRasterizedSize are
+// not derived, however narrow their height is.
+func rasterWidth(aspectRatio float64, height int) (int, error) {
+	width := math.Max(1, math.Round(aspectRatio*float64(height)))
+	if !(width <= maxRasterizedSize) {
+		return 0, fmt.Errorf("%dpx high would be wider than %dpx: %w", height, maxRasterizedSize, ErrInvalidRasterSize)
+	}
+	return int(width), nil
+}
+
+func rasterCacheKey(sourceContentHash string, descriptor domain.IconfileDescriptor) string {
+	return sourceContentHash + "@" + descriptor.Format + "/" + descriptor.Size
+}
+
+// rasterizeIconfile renders the largest SVG iconfile of the icon as a PNG of the size
+func (service *IconService) rasterizeIconfile(iconName string, size string) (domain.StoredIconfile, error) {
+	match := rasterSizePattern.FindStringSubmatch(size)
+	if match == nil {
+		return domain.StoredIconfile{}, fmt.Errorf("size %s is not in pixels: %w", size, domain.ErrIconfileNotFound)
+	}
+	height, _ := strconv.Atoi(match[1])
+	if height > maxRasterizedSize {
+		return domain.StoredIconfile{}, fmt.Errorf("%s is larger than %dpx: %w", size, maxRasterizedSize, ErrInvalidRasterSize)
+	}
+
+	icon, err := service.Repositories.DB.DescribeIcon(iconName)
+	if err != nil {
+		return domain.StoredIconfile{}, fmt.Errorf("failed to describe icon %s to rasterize: %w", iconName, err)
+	}
+	descriptor, found := selectSpriteIconfile(icon, "")
+	if !found {
+		return domain.StoredIconfile{}, fmt.Errorf("no SVG iconfile of %s to rasterize: %w", iconName, domain.ErrIconfileNotFound)
+	}
+	source, err := service.GetIconfile(iconName, descriptor)
+	if err != nil {
+		return domain.StoredIconfile{}, err
+	}
+
+	rasterized := domain.StoredIconfile{Iconfile: domain.Iconfile{IconfileDescriptor: domain.IconfileDescriptor{Format: "png", Size: size}}, Derived: true}
+	key := rasterCacheKey(source.ContentHash, rasterized.IconfileDescriptor)
+	if content, cached := rasterCache.get(key); cached {
+		rasterized.Content = content
+		rasterized.ContentHash = domain.ContentHash(content)
+		return rasterized, nil
+	}
+
+	svg, err := parseSVG(source.Content)
+	if err != nil {
+		return domain.StoredIconfile{}, fmt.Errorf("failed to rasterize %v of %s: %w", descriptor, iconName, err)
+	}
+	img, err := svg.rasterize(height)
+	if err != nil {
+		return domain.StoredIconfile{}, fmt.Errorf("failed to rasterize %v of %s: %w", descriptor, iconName, err)
+	}
+	var out bytes.Buffer
+	err = png.Encode(&out, img)
+	if err != nil {
+		return domain.StoredIconfile{}, fmt.Errorf("failed to encode rasterized %v of %s: %w", descriptor, iconName, err)
+	}
+	rasterized.Content = out.Bytes()
+	rasterized.ContentHash = domain.ContentHash(rasterized.Content)
+	rasterCache.put(key, rasterized.Content)
+	return rasterized, nil
+}
+
+// GetIconfileOrRasterize returns the iconfile or, if it is a missing PNG or JPEG, derives one: PNGs are rendered from
+// the largest SVG iconfile of the icon if there is one, otherwise PNGs and JPEGs are downscaled from the smallest
+// larger raster iconfile. Derived iconfiles are added to the icon if the service is configured to persist them and
+// the requester could add them by uploading as well; otherwise they are served from the cache only.
+func (service *IconService) GetIconfileOrRasterize(iconName string, descriptor domain.IconfileDescriptor, requestedBy UserInfo) (domain.StoredIconfile, error) {
+	logger := log.WithField("prefix", "GetIconfileOrRasterize")
+	iconfile, err := service.GetIconfile(iconName, descriptor)
+	if err == nil || !isDownscalableFormat(descriptor.Format) || !errors.Is(err, domain.ErrIconfileNotFound) {
+		return iconfile, err
+	}
+
+	derived, deriveErr := service.deriveIconfile(iconName, descriptor)
+	if deriveErr != nil {
+		if errors.Is(deriveErr, domain.ErrIconfileNotFound) || errors.Is(deriveErr, domain.ErrIconNotFound) {
+			return domain.StoredIconfile{}, err
+		}
+		if errors.Is(deriveErr, ErrInvalidSVG) {
+			logger.Warnf("%v", deriveErr)
+			return domain.StoredIconfile{}, err
+		}
+		return domain.StoredIconfile{}, deriveErr
+	}
+
+	if service.PersistRasterizedIconfiles && mayAddIconfiles(requestedBy) {
+		modifiedBy := requestedBy.UserId.String()
+		checkACL := iconACLCheck(iconName, authr.ADD_ICONFILE, requestedBy)
+		persistErr := service.Repositories.DB.AddIconfileToIcon(iconName, derived.Iconfile, modifiedBy, checkACL, func() error {
+			return service.Repositories.Git.AddIconfile(iconName, derived.Iconfile, modifiedBy)
+		})
+		if errors.Is(persistErr, authr.ErrPermission) {
+			logger.Debugf("not persisting derived iconfile %v of %s: %v", descriptor, iconName, persistErr)
+		} else if persistErr != nil {
+			// Most likely a concurrent request has persisted the same iconfile
+			logger.Warnf("failed to persist derived iconfile %v of %s: %v", descriptor, iconName, persistErr)
+		} else {
+			derived.CreatedAt = time.Now()
+			derived.Derived = false
+		}
+	}
+	return derived, nil
+}
+
+// mayAddIconfiles tells whether the user holds the permissions to add iconfiles, a precondition of persisting the
+// iconfiles derived on their request
+func mayAddIconfiles(user UserInfo) bool {
+	return authr.HasRequiredPermissions(user.UserId, user.Permissions, []authr.PermissionID{
+		authr.UPDATE_ICON,
+		authr.ADD_ICONFILE,
+	}) == nil
+}
+
+// deriveIconfile renders the missing PNG from SVG or, failing that, downscales the PNG or JPEG from a larger one
+func (service *IconService) deriveIconfile(iconName string, descriptor domain.IconfileDescriptor) (domain.StoredIconfile, error) {
+	if descriptor.Format == "png" {
+		rasterized, err := service.rasterizeIconfile(iconName, descriptor.Size)
+		if err == nil || !errors.Is(err, domain.ErrIconfileNotFound) && !errors.Is(err, ErrInvalidSVG) {
+			return rasterized, err
+		}
+		downscaled, downscaleErr := service.downscaleIconfile(iconName, descriptor)
+		if errors.Is(downscaleErr, domain.ErrIconfileNotFound) {
+			// Rather report the SVG being invalid if it is
+			return domain.StoredIconfile{}, err
+		}
+		return downscaled, downscaleErr
+	}
+	return service.downscaleIconfile(iconName, descriptor)
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// lanczosLobes is the number of lobes of the Lanczos filter raster iconfiles are resampled with
+const lanczosLobes = 3
+
+func lanczos(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	if x <= -lanczosLobes || x >= lanczosLobes {
+		return 0
+	}
+	px := math.Pi * x
+	return lanczosLobes * math.Sin(px) * math.Sin(px/lanczosLobes) / (px * px)
+}
+
+// resampleTaps are the source pixels a destination pixel is computed from along with their weights
+type resampleTaps struct {
+	start   int
+	weights []float64
+}
+
+// resamplingTaps computes the taps for resampling a row or a column of pixels. The filter is stretched when
+// downscaling so that every source pixel contributes.
+func resamplingTaps(sourceLength int, targetLength int) []resampleTaps {
+	scale := float64(sourceLength) / float64(targetLength)
+	filterScale := math.Max(scale, 1)
+	support := lanczosLobes * filterScale
+	taps := make([]resampleTaps, targetLength)
+	for i := range taps {
+		center := (float64(i) + 0.5) * scale
+		start := int(math.Max(0, math.Floor(center-support)))
+		end := int(math.Min(float64(sourceLength), math.Ceil(center+support)))
+		weights := make([]float64, end-start)
+		sum := 0.0
+		for j := range weights {
+			weights[j] = lanczos((float64(start+j) + 0.5 - center) / filterScale)
+			sum += weights[j]
+		}
+		for j := range weights {
+			weights[j] /= sum
+		}
+		taps[i] = resampleTaps{start: start, weights: weights}
+	}
+	return taps
+}
+
+// premultipliedPixels are the color components of an image premultiplied by alpha, four per pixel
+type premultipliedPixels struct {
+	width  int
+	height int
+	values []float64
+}
+
+func newPremultipliedPixels(img image.Image) premultipliedPixels {
+	bounds := img.Bounds()
+	pixels := premultipliedPixels{width: bounds.Dx(), height: bounds.Dy(), values: make([]float64, 4*bounds.Dx()*bounds.Dy())}
+	i := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			pixels.values[i], pixels.values[i+1], pixels.values[i+2], pixels.values[i+3] = float64(r), float64(g), float64(b), float64(a)
+			i += 4
+		}
+	}
+	return pixels
+}
+
+// resampleAxis resamples the pixels along either the rows or the columns
+func (pixels premultipliedPixels) resampleAxis(taps []resampleTaps, horizontal bool) premultipliedPixels {
+	resampled := premultipliedPixels{width: pixels.width, height: pixels.height}
+	lines, stride, step := pixels.height, 4*pixels.width, 4
+	if horizontal {
+		resampled.width = len(taps)
+	} else {
+		resampled.height = len(taps)
+		lines, stride, step = pixels.width, 4, 4*pixels.width
+	}
+	resampled.values = make([]float64, 4*resampled.width*resampled.height)
+	targetStride, targetStep := 4*resampled.width, 4
+	if !horizontal {
+		targetStride, targetStep = 4, 4*resampled.width
+	}
+	for line := 0; line < lines; line++ {
+		for i, tap := range taps {
+			var sum [4]float64
+			for j, weight := range tap.weights {
+				offset := line*stride + (tap.start+j)*step
+				for c := 0; c < 4; c++ {
+					sum[c] += weight * pixels.values[offset+c]
+				}
+			}
+			copy(resampled.values[line*targetStride+i*targetStep:], sum[:])
+		}
+	}
+	return resampled
+}
+
+// resampleImage scales the image to the size with a Lanczos filter applied to its colors premultiplied by alpha
+func resampleImage(img image.Image, width int, height int) *image.NRGBA {
+	pixels := newPremultipliedPixels(img)
+	pixels = pixels.resampleAxis(resamplingTaps(pixels.width, width), true)
+	pixels = pixels.resampleAxis(resamplingTaps(pixels.height, height), false)
+
+	resampled := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := 4 * (y*width + x)
+			// The negative lobes of the filter may overshoot
+			alpha := math.Max(0, math.Min(0xffff, pixels.values[i+3]))
+			if alpha == 0 {
+				continue
+			}
+			component := func(value float64) uint8 {
+				return uint8(math.Round(math.Max(0, math.Min(alpha, value)) / alpha * 0xff))
+			}
+			resampled.SetNRGBA(x, y, color.NRGBA{
+				R: component(pixels.values[i]),
+				G: component(pixels.values[i+1]),
+				B: component(pixels.values[i+2]),
+				A: uint8(math.Round(alpha / 0xffff * 0xff)),
+			})
+		}
+	}
+	return resampled
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/pdkovacs/igo-repo/domain"
+	"github.com/pdkovacs/igo-repo/repositories"
+	"github.com/pdkovacs/igo-repo/security/authr"
+	log "github.com/sirupsen/logrus"
+)
+
+// SessionService lets administrators see and revoke the sessions kept in the database session store
+type SessionService struct {
+	Repositories *repositories.Repositories
+}
+
+func (service *SessionService) ListSessions(owner string, requestedBy UserInfo) ([]domain.SessionInfo, error) {
+	err := authr.HasRequiredPermissions(requestedBy.UserId, requestedBy.Permissions, []authr.PermissionID{
+		authr.MANAGE_SESSIONS,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return service.Repositories.DB.ListSessions(owner)
+}
+
+func (service *SessionService) RevokeSession(handle int64, requestedBy UserInfo) error {
+	logger := log.WithField("prefix", "RevokeSession")
+	err := authr.HasRequiredPermissions(requestedBy.UserId, requestedBy.Permissions, []authr.PermissionID{
+		authr.MANAGE_SESSIONS,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke session %d: %w", handle, err)
+	}
+	err = service.Repositories.DB.DeleteSessionByHandle(handle)
+	if err != nil {
+		return err
+	}
+	logger.Infof("session %d revoked by %s", handle, requestedBy.UserId.String())
+	return nil
+}
+
+func (service *SessionService) RevokeSessionsOfUser(owner string, requestedBy UserInfo) (int64, error) {
+	logger := log.WithField("prefix", "RevokeSessionsOfUser")
+	err := authr.HasRequiredPermissions(requestedBy.UserId, requestedBy.Permissions, []authr.PermissionID{
+		authr.MANAGE_SESSIONS,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke the sessions of %s: %w", owner, err)
+	}
+	count, err := service.Repositories.DB.DeleteSessionsOfOwner(owner)
+	if err != nil {
+		return 0, err
+	}
+	logger.Infof("%d session(s) of %s revoked by %s", count, owner, requestedBy.UserId.String())
+	return count, nil
+}
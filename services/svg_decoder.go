@@ -1,18 +1,23 @@
 package services
 
 import (
-	"encoding/xml"
 	"errors"
 	"fmt"
 	"image"
+	"image/color"
 	"io"
+	"math"
 	"strconv"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 )
 
 var name = "svg"
-var magicString = "<svg "
+
+// magicStrings are the ways SVG documents are seen to start: with the svg element itself, an XML declaration,
+// a doctype or a comment. Which of them is an SVG is decided by the root element.
+var magicStrings = []string{"<svg", "<?xml", "<!DOCTYPE svg", "<!--"}
 
 func decodeSVG(reader io.Reader) (image.Image, error) {
 	// logger := log.WithField("prefix", "SVG decoder::decodeSVG")
@@ -23,42 +28,156 @@ func decodeSVG(reader io.Reader) (image.Image, error) {
 	return nil, errors.New("Unsupported operation: decode")
 }
 
-type SVG struct {
-	XMLName xml.Name `xml:"svg"`
-	Width   string   `xml:"width,attr"`
-	Height  string   `xml:"height,attr"`
+// maxSVGAspectRatio is how many times wider than high or higher than wide SVG iconfiles may be
+const maxSVGAspectRatio = 16
+
+// svgLengthUnits are the sizes of the absolute and font relative CSS units in pixels with fonts taken to be 16px
+var svgLengthUnits = map[string]float64{
+	"": 1, "px": 1, "pt": 4.0 / 3, "pc": 16, "mm": 96 / 25.4, "cm": 96 / 2.54, "in": 96,
+	"em": 16, "rem": 16, "ex": 8,
+}
+
+// parseSVGLength parses a length returning it in pixels or, for percentages, as a fraction
+func parseSVGLength(value string) (float64, bool, error) {
+	value = strings.TrimSpace(value)
+	unitStart := len(value)
+	for unitStart > 0 && (value[unitStart-1] == '%' || value[unitStart-1] >= 'a' && value[unitStart-1] <= 'z') {
+		unitStart--
+	}
+	number, err := strconv.ParseFloat(value[:unitStart], 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("malformed length %q: %w", value, ErrInvalidSVG)
+	}
+	if number <= 0 || math.IsInf(number, 0) {
+		return 0, false, fmt.Errorf("length %q is not positive: %w", value, ErrInvalidSVG)
+	}
+	unit := value[unitStart:]
+	if unit == "%" {
+		return number / 100, true, nil
+	}
+	pixels, known := svgLengthUnits[unit]
+	if !known {
+		return 0, false, fmt.Errorf("unsupported unit in length %q: %w", value, ErrInvalidSVG)
+	}
+	return number * pixels, false, nil
+}
+
+func parseViewBox(viewBox string) (point, float64, float64, error) {
+	scanner := &pathDataScanner{data: viewBox}
+	values, err := scanner.numbers(4)
+	if err != nil || !scanner.done() {
+		return point{}, 0, 0, fmt.Errorf("malformed viewBox %q: %w", viewBox, ErrInvalidSVG)
+	}
+	if values[2] <= 0 || values[3] <= 0 {
+		return point{}, 0, 0, fmt.Errorf("viewBox %q is empty: %w", viewBox, ErrInvalidSVG)
+	}
+	return point{values[0], values[1]}, values[2], values[3], nil
+}
+
+// svgDimensions are the intrinsic dimensions of an SVG document in pixels
+type svgDimensions struct {
+	width  float64
+	height float64
+}
+
+// square tells whether the dimensions are the same to within a percent
+func (dimensions svgDimensions) square() bool {
+	return math.Abs(dimensions.width-dimensions.height) <= 0.01*math.Max(dimensions.width, dimensions.height)
+}
+
+// dimensions determines the size of the SVG from its width and height, falling back to its viewBox for those
+// missing or given as percentages
+func (svg parsedSVG) dimensions() (svgDimensions, error) {
+	var viewBoxWidth, viewBoxHeight float64
+	hasViewBox := false
+	if viewBox := svg.attribute("viewBox"); viewBox != "" {
+		var err error
+		_, viewBoxWidth, viewBoxHeight, err = parseViewBox(viewBox)
+		if err != nil {
+			return svgDimensions{}, err
+		}
+		hasViewBox = true
+	}
+
+	dimension := func(attribute string, viewBoxLength float64) (float64, error) {
+		value := svg.attribute(attribute)
+		if value == "" || value == "auto" {
+			return 0, nil
+		}
+		length, percentage, err := parseSVGLength(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s: %w", attribute, err)
+		}
+		if percentage {
+			if !hasViewBox {
+				return 0, fmt.Errorf("%s given as percentage without viewBox: %w", attribute, ErrInvalidSVG)
+			}
+			return viewBoxLength * length, nil
+		}
+		return length, nil
+	}
+	width, err := dimension("width", viewBoxWidth)
+	if err != nil {
+		return svgDimensions{}, err
+	}
+	height, err := dimension("height", viewBoxHeight)
+	if err != nil {
+		return svgDimensions{}, err
+	}
+
+	switch {
+	case width > 0 && height > 0:
+	case !hasViewBox:
+		return svgDimensions{}, fmt.Errorf("neither width and height nor viewBox specified: %w", ErrInvalidSVG)
+	case width > 0:
+		height = width * viewBoxHeight / viewBoxWidth
+	case height > 0:
+		width = height * viewBoxWidth / viewBoxHeight
+	default:
+		width, height = viewBoxWidth, viewBoxHeight
+	}
+	return svgDimensions{width: width, height: height}, nil
 }
 
 func decodeSVGConfig(reader io.Reader) (image.Config, error) {
 	logger := log.WithField("prefix", "SVG decoder::decodeSVGConfig")
 
-	byteValue, readError := io.ReadAll(reader)
+	content, readError := io.ReadAll(reader)
 	if readError != nil {
 		logger.Errorf("failed to read image content: %v", readError)
 		return image.Config{}, fmt.Errorf("failed to read image content: %w", readError)
 	}
-	svg := SVG{}
-	xml.Unmarshal(byteValue, &svg)
-
-	width, widthParseError := strconv.Atoi(svg.Width)
-	if widthParseError != nil {
-		logger.Errorf("failed to parse image width: %v", readError)
-		return image.Config{}, fmt.Errorf("failed to parse image width: %w", readError)
+	svg, parseError := parseSVG(content)
+	if parseError != nil {
+		logger.Infof("failed to parse SVG: %v", parseError)
+		return image.Config{}, fmt.Errorf("failed to parse SVG: %w", parseError)
+	}
+	dimensions, dimensionsError := svg.dimensions()
+	if dimensionsError != nil {
+		logger.Infof("failed to determine SVG dimensions: %v", dimensionsError)
+		return image.Config{}, fmt.Errorf("failed to determine SVG dimensions: %w", dimensionsError)
 	}
 
-	height, heightParseError := strconv.Atoi(svg.Height)
-	if heightParseError != nil {
-		logger.Errorf("failed to parse image height: %v", readError)
-		return image.Config{}, fmt.Errorf("failed to parse image height: %w", readError)
+	width, height := int(math.Round(dimensions.width)), int(math.Round(dimensions.height))
+	if width < 1 || height < 1 {
+		return image.Config{}, fmt.Errorf("SVG of %gx%g is smaller than a pixel: %w", dimensions.width, dimensions.height, ErrInvalidSVG)
+	}
+	if math.Max(dimensions.width, dimensions.height) > maxSVGAspectRatio*math.Min(dimensions.width, dimensions.height) {
+		return image.Config{}, fmt.Errorf("SVG of %dx%d is more than %d times as long as wide: %w", width, height, maxSVGAspectRatio, ErrInvalidSVG)
+	}
+	if !dimensions.square() {
+		logger.Infof("non-square SVG of %dx%d", width, height)
 	}
 
 	return image.Config{
-		ColorModel: nil,
+		ColorModel: color.NRGBAModel,
 		Width:      width,
 		Height:     height,
 	}, nil
 }
 
 func registerSVGDecoder() {
-	image.RegisterFormat(name, magicString, decodeSVG, decodeSVGConfig)
+	for _, magicString := range magicStrings {
+		image.RegisterFormat(name, magicString, decodeSVG, decodeSVGConfig)
+	}
 }
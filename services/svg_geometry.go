@@ -0,0 +1,705 @@
+package services
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// point is a point of the SVG user space
+type point struct {
+	x, y float64
+}
+
+func (p point) add(q point) point          { return point{p.x + q.x, p.y + q.y} }
+func (p point) sub(q point) point          { return point{p.x - q.x, p.y - q.y} }
+func (p point) scale(factor float64) point { return point{p.x * factor, p.y * factor} }
+func (p point) length() float64            { return math.Hypot(p.x, p.y) }
+
+func lerp(p, q point, t float64) point {
+	return point{p.x + (q.x-p.x)*t, p.y + (q.y-p.y)*t}
+}
+
+// affine is the transformation matrix [a c e; b d f] as specified for the SVG transform attribute
+type affine [6]float64
+
+var identity = affine{1, 0, 0, 1, 0, 0}
+
+func (m affine) apply(p point) point {
+	return point{m[0]*p.x + m[2]*p.y + m[4], m[1]*p.x + m[3]*p.y + m[5]}
+}
+
+// then returns the transformation applying m first and n next
+func (m affine) then(n affine) affine {
+	return affine{
+		n[0]*m[0] + n[2]*m[1], n[1]*m[0] + n[3]*m[1],
+		n[0]*m[2] + n[2]*m[3], n[1]*m[2] + n[3]*m[3],
+		n[0]*m[4] + n[2]*m[5] + n[4], n[1]*m[4] + n[3]*m[5] + n[5],
+	}
+}
+
+// scaleFactor is the factor lengths are scaled by on average
+func (m affine) scaleFactor() float64 {
+	return math.Sqrt(math.Abs(m[0]*m[3] - m[1]*m[2]))
+}
+
+type segmentKind int
+
+const (
+	moveTo segmentKind = iota
+	lineTo
+	quadTo
+	cubicTo
+	closePath
+)
+
+// pathSegment is a segment of a path in absolute coordinates; the last of the points is the end point
+type pathSegment struct {
+	kind   segmentKind
+	points []point
+}
+
+// subpath is a sequence of connected segments starting at a point
+type subpath struct {
+	start    point
+	segments []pathSegment
+	closed   bool
+}
+
+func (m affine) applyToSubpaths(subpaths []subpath) []subpath {
+	transformed := make([]subpath, 0, len(subpaths))
+	for _, sp := range subpaths {
+		tsp := subpath{start: m.apply(sp.start), closed: sp.closed}
+		for _, segment := range sp.segments {
+			points := make([]point, len(segment.points))
+			for i, p := range segment.points {
+				points[i] = m.apply(p)
+			}
+			tsp.segments = append(tsp.segments, pathSegment{kind: segment.kind, points: points})
+		}
+		transformed = append(transformed, tsp)
+	}
+	return transformed
+}
+
+// pathDataScanner tokenizes SVG path data
+type pathDataScanner struct {
+	data string
+	pos  int
+}
+
+func (s *pathDataScanner) skipSeparators() {
+	for s.pos < len(s.data) && strings.ContainsRune(" \t\r\n,", rune(s.data[s.pos])) {
+		s.pos++
+	}
+}
+
+func (s *pathDataScanner) done() bool {
+	s.skipSeparators()
+	return s.pos >= len(s.data)
+}
+
+func (s *pathDataScanner) command() (byte, bool) {
+	s.skipSeparators()
+	if s.pos < len(s.data) && strings.ContainsRune("MmLlHhVvCcSsQqTtAaZz", rune(s.data[s.pos])) {
+		s.pos++
+		return s.data[s.pos-1], true
+	}
+	return 0, false
+}
+
+func (s *pathDataScanner) nextIsNumber() bool {
+	s.skipSeparators()
+	return s.pos < len(s.data) && strings.ContainsRune("+-.0123456789", rune(s.data[s.pos]))
+}
+
+func (s *pathDataScanner) number() (float64, error) {
+	s.skipSeparators()
+	start := s.pos
+	if s.pos < len(s.data) && (s.data[s.pos] == '+' || s.data[s.pos] == '-') {
+		s.pos++
+	}
+	seenDot, seenDigit := false, false
+	for s.pos < len(s.data) {
+		c := s.data[s.pos]
+		if c >= '0' && c <= '9' {
+			seenDigit = true
+		} else if c == '.' && !seenDot {
+			seenDot = true
+		} else {
+			break
+		}
+		s.pos++
+	}
+	if seenDigit && s.pos < len(s.data) && (s.data[s.pos] == 'e' || s.data[s.pos] == 'E') {
+		exponentStart := s.pos
+		s.pos++
+		if s.pos < len(s.data) && (s.data[s.pos] == '+' || s.data[s.pos] == '-') {
+			s.pos++
+		}
+		digits := s.pos
+		for s.pos < len(s.data) && s.data[s.pos] >= '0' && s.data[s.pos] <= '9' {
+			s.pos++
+		}
+		if s.pos == digits {
+			s.pos = exponentStart
+		}
+	}
+	if !seenDigit {
+		return 0, fmt.Errorf("number expected at %d in path data: %w", start, ErrInvalidSVG)
+	}
+	return strconv.ParseFloat(s.data[start:s.pos], 64)
+}
+
+// flag reads an arc flag, which may be immediately followed by the next number without separator
+func (s *pathDataScanner) flag() (bool, error) {
+	s.skipSeparators()
+	if s.pos < len(s.data) && (s.data[s.pos] == '0' || s.data[s.pos] == '1') {
+		s.pos++
+		return s.data[s.pos-1] == '1', nil
+	}
+	return false, fmt.Errorf("flag expected at %d in path data: %w", s.pos, ErrInvalidSVG)
+}
+
+func (s *pathDataScanner) numbers(count int) ([]float64, error) {
+	values := make([]float64, count)
+	for i := range values {
+		value, err := s.number()
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// pathBuilder collects subpaths while keeping track of the state needed for relative and smooth commands
+type pathBuilder struct {
+	subpaths     []subpath
+	current      point
+	lastControl  point
+	lastKind     byte
+	subpathStart point
+}
+
+func (b *pathBuilder) moveTo(p point) {
+	b.subpaths = append(b.subpaths, subpath{start: p})
+	b.current, b.subpathStart = p, p
+}
+
+func (b *pathBuilder) add(kind segmentKind, points ...point) {
+	if len(b.subpaths) == 0 {
+		b.moveTo(b.current)
+	}
+	last := &b.subpaths[len(b.subpaths)-1]
+	if last.closed {
+		b.subpaths = append(b.subpaths, subpath{start: b.current})
+		last = &b.subpaths[len(b.subpaths)-1]
+	}
+	last.segments = append(last.segments, pathSegment{kind: kind, points: points})
+	b.current = points[len(points)-1]
+}
+
+func (b *pathBuilder) close() {
+	if len(b.subpaths) == 0 {
+		return
+	}
+	last := &b.subpaths[len(b.subpaths)-1]
+	if !last.closed {
+		last.segments = append(last.segments, pathSegment{kind: closePath, points: []point{b.subpathStart}})
+		last.closed = true
+	}
+	b.current = b.subpathStart
+}
+
+// arcTo approximates the elliptical arc with cubic Béziers following the SVG implementation notes
+func (b *pathBuilder) arcTo(rx, ry, xAxisRotation float64, largeArc, sweep bool, end point) {
+	start := b.current
+	if start == end {
+		return
+	}
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 {
+		b.add(lineTo, end)
+		return
+	}
+	phi := xAxisRotation * math.Pi / 180
+	cosPhi, sinPhi := math.Cos(phi), math.Sin(phi)
+	dx, dy := (start.x-end.x)/2, (start.y-end.y)/2
+	x1 := cosPhi*dx + sinPhi*dy
+	y1 := -sinPhi*dx + cosPhi*dy
+	lambda := (x1*x1)/(rx*rx) + (y1*y1)/(ry*ry)
+	if lambda > 1 {
+		rx, ry = rx*math.Sqrt(lambda), ry*math.Sqrt(lambda)
+	}
+	numerator := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	denominator := rx*rx*y1*y1 + ry*ry*x1*x1
+	coefficient := 0.0
+	if denominator != 0 && numerator > 0 {
+		coefficient = math.Sqrt(numerator / denominator)
+	}
+	if largeArc == sweep {
+		coefficient = -coefficient
+	}
+	cx1 := coefficient * rx * y1 / ry
+	cy1 := -coefficient * ry * x1 / rx
+	center := point{
+		cosPhi*cx1 - sinPhi*cy1 + (start.x+end.x)/2,
+		sinPhi*cx1 + cosPhi*cy1 + (start.y+end.y)/2,
+	}
+	angle := func(ux, uy, vx, vy float64) float64 {
+		return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	}
+	theta1 := angle(1, 0, (x1-cx1)/rx, (y1-cy1)/ry)
+	deltaTheta := angle((x1-cx1)/rx, (y1-cy1)/ry, (-x1-cx1)/rx, (-y1-cy1)/ry)
+	if !sweep && deltaTheta > 0 {
+		deltaTheta -= 2 * math.Pi
+	} else if sweep && deltaTheta < 0 {
+		deltaTheta += 2 * math.Pi
+	}
+
+	pieces := int(math.Ceil(math.Abs(deltaTheta) / (math.Pi / 2)))
+	step := deltaTheta / float64(pieces)
+	kappa := 4.0 / 3.0 * math.Tan(step/4)
+	ellipsePoint := func(theta float64) point {
+		x, y := rx*math.Cos(theta), ry*math.Sin(theta)
+		return point{cosPhi*x - sinPhi*y + center.x, sinPhi*x + cosPhi*y + center.y}
+	}
+	derivative := func(theta float64) point {
+		x, y := -rx*math.Sin(theta), ry*math.Cos(theta)
+		return point{cosPhi*x - sinPhi*y, sinPhi*x + cosPhi*y}
+	}
+	for i := 0; i < pieces; i++ {
+		t1 := theta1 + float64(i)*step
+		t2 := t1 + step
+		p1, p2 := ellipsePoint(t1), ellipsePoint(t2)
+		if i == pieces-1 {
+			p2 = end
+		}
+		b.add(cubicTo, p1.add(derivative(t1).scale(kappa)), p2.sub(derivative(t2).scale(kappa)), p2)
+	}
+}
+
+// parsePathData parses the d attribute of a path element
+func parsePathData(data string) ([]subpath, error) {
+	scanner := pathDataScanner{data: data}
+	builder := pathBuilder{}
+	var command byte
+	for !scanner.done() {
+		if next, ok := scanner.command(); ok {
+			command = next
+		} else if command == 0 || !scanner.nextIsNumber() {
+			return nil, fmt.Errorf("command expected at %d in path data: %w", scanner.pos, ErrInvalidSVG)
+		}
+		relative := command >= 'a' && command <= 'z'
+		origin := point{}
+		if relative {
+			origin = builder.current
+		}
+		at := func(x, y float64) point { return point{origin.x + x, origin.y + y} }
+
+		switch upper := command &^ 0x20; upper {
+		case 'Z':
+			builder.close()
+		case 'M', 'L':
+			values, err := scanner.numbers(2)
+			if err != nil {
+				return nil, err
+			}
+			if upper == 'M' {
+				builder.moveTo(at(values[0], values[1]))
+				// Coordinates following a moveto are implicit linetos
+				command = command - 'M' + 'L'
+			} else {
+				builder.add(lineTo, at(values[0], values[1]))
+			}
+		case 'H':
+			x, err := scanner.number()
+			if err != nil {
+				return nil, err
+			}
+			builder.add(lineTo, point{origin.x + x, builder.current.y})
+		case 'V':
+			y, err := scanner.number()
+			if err != nil {
+				return nil, err
+			}
+			builder.add(lineTo, point{builder.current.x, origin.y + y})
+		case 'C', 'S':
+			count := 6
+			if upper == 'S' {
+				count = 4
+			}
+			values, err := scanner.numbers(count)
+			if err != nil {
+				return nil, err
+			}
+			var control1 point
+			if upper == 'C' {
+				control1, values = at(values[0], values[1]), values[2:]
+			} else if builder.lastKind == 'C' || builder.lastKind == 'S' {
+				control1 = builder.current.scale(2).sub(builder.lastControl)
+			} else {
+				control1 = builder.current
+			}
+			control2 := at(values[0], values[1])
+			builder.add(cubicTo, control1, control2, at(values[2], values[3]))
+			builder.lastControl = control2
+		case 'Q', 'T':
+			count := 4
+			if upper == 'T' {
+				count = 2
+			}
+			values, err := scanner.numbers(count)
+			if err != nil {
+				return nil, err
+			}
+			var control point
+			if upper == 'Q' {
+				control, values = at(values[0], values[1]), values[2:]
+			} else if builder.lastKind == 'Q' || builder.lastKind == 'T' {
+				control = builder.current.scale(2).sub(builder.lastControl)
+			} else {
+				control = builder.current
+			}
+			builder.add(quadTo, control, at(values[0], values[1]))
+			builder.lastControl = control
+		case 'A':
+			radii, err := scanner.numbers(3)
+			if err != nil {
+				return nil, err
+			}
+			largeArc, err := scanner.flag()
+			if err != nil {
+				return nil, err
+			}
+			sweep, err := scanner.flag()
+			if err != nil {
+				return nil, err
+			}
+			end, err := scanner.numbers(2)
+			if err != nil {
+				return nil, err
+			}
+			builder.arcTo(radii[0], radii[1], radii[2], largeArc, sweep, at(end[0], end[1]))
+		}
+		builder.lastKind = command &^ 0x20
+		if command&^0x20 == 'Z' {
+			command = 0
+		}
+	}
+	return builder.subpaths, nil
+}
+
+// ellipseSubpath approximates an ellipse with four cubic Béziers
+func ellipseSubpath(cx, cy, rx, ry float64) subpath {
+	const kappa = 0.5522847498
+	ox, oy := rx*kappa, ry*kappa
+	return subpath{
+		start: point{cx + rx, cy},
+		segments: []pathSegment{
+			{cubicTo, []point{{cx + rx, cy + oy}, {cx + ox, cy + ry}, {cx, cy + ry}}},
+			{cubicTo, []point{{cx - ox, cy + ry}, {cx - rx, cy + oy}, {cx - rx, cy}}},
+			{cubicTo, []point{{cx - rx, cy - oy}, {cx - ox, cy - ry}, {cx, cy - ry}}},
+			{cubicTo, []point{{cx + ox, cy - ry}, {cx + rx, cy - oy}, {cx + rx, cy}}},
+			{closePath, []point{{cx + rx, cy}}},
+		},
+		closed: true,
+	}
+}
+
+func rectSubpaths(x, y, width, height, rx, ry float64) []subpath {
+	if rx == 0 && ry == 0 {
+		return []subpath{polygonSubpath([]point{{x, y}, {x + width, y}, {x + width, y + height}, {x, y + height}}, true)}
+	}
+	if rx == 0 {
+		rx = ry
+	}
+	if ry == 0 {
+		ry = rx
+	}
+	rx, ry = math.Min(rx, width/2), math.Min(ry, height/2)
+	builder := pathBuilder{}
+	builder.moveTo(point{x + rx, y})
+	builder.add(lineTo, point{x + width - rx, y})
+	builder.arcTo(rx, ry, 0, false, true, point{x + width, y + ry})
+	builder.add(lineTo, point{x + width, y + height - ry})
+	builder.arcTo(rx, ry, 0, false, true, point{x + width - rx, y + height})
+	builder.add(lineTo, point{x + rx, y + height})
+	builder.arcTo(rx, ry, 0, false, true, point{x, y + height - ry})
+	builder.add(lineTo, point{x, y + ry})
+	builder.arcTo(rx, ry, 0, false, true, point{x + rx, y})
+	builder.close()
+	return builder.subpaths
+}
+
+func polygonSubpath(points []point, closed bool) subpath {
+	sp := subpath{start: points[0], closed: closed}
+	for _, p := range points[1:] {
+		sp.segments = append(sp.segments, pathSegment{lineTo, []point{p}})
+	}
+	if closed {
+		sp.segments = append(sp.segments, pathSegment{closePath, []point{points[0]}})
+	}
+	return sp
+}
+
+func parsePoints(value string) ([]point, error) {
+	scanner := pathDataScanner{data: value}
+	points := []point{}
+	for !scanner.done() {
+		coordinates, err := scanner.numbers(2)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, point{coordinates[0], coordinates[1]})
+	}
+	return points, nil
+}
+
+// parseTransform parses the transform attribute
+func parseTransform(value string) (affine, error) {
+	result := identity
+	rest := strings.TrimSpace(value)
+	for rest != "" {
+		open := strings.Index(rest, "(")
+		closing := strings.Index(rest, ")")
+		if open < 0 || closing < open {
+			return identity, fmt.Errorf("malformed transform %q: %w", value, ErrInvalidSVG)
+		}
+		name := strings.TrimSpace(rest[:open])
+		scanner := pathDataScanner{data: rest[open+1 : closing]}
+		args := []float64{}
+		for !scanner.done() {
+			arg, err := scanner.number()
+			if err != nil {
+				return identity, fmt.Errorf("malformed transform %q: %w", value, ErrInvalidSVG)
+			}
+			args = append(args, arg)
+		}
+		arg := func(i int, defaultValue float64) float64 {
+			if i < len(args) {
+				return args[i]
+			}
+			return defaultValue
+		}
+		var m affine
+		switch name {
+		case "matrix":
+			if len(args) != 6 {
+				return identity, fmt.Errorf("malformed transform %q: %w", value, ErrInvalidSVG)
+			}
+			copy(m[:], args)
+		case "translate":
+			m = affine{1, 0, 0, 1, arg(0, 0), arg(1, 0)}
+		case "scale":
+			m = affine{arg(0, 1), 0, 0, arg(1, arg(0, 1)), 0, 0}
+		case "rotate":
+			angle := arg(0, 0) * math.Pi / 180
+			cx, cy := arg(1, 0), arg(2, 0)
+			m = affine{1, 0, 0, 1, -cx, -cy}.
+				then(affine{math.Cos(angle), math.Sin(angle), -math.Sin(angle), math.Cos(angle), 0, 0}).
+				then(affine{1, 0, 0, 1, cx, cy})
+		case "skewX":
+			m = affine{1, 0, math.Tan(arg(0, 0) * math.Pi / 180), 1, 0, 0}
+		case "skewY":
+			m = affine{1, math.Tan(arg(0, 0) * math.Pi / 180), 0, 1, 0, 0}
+		default:
+			return identity, fmt.Errorf("unknown transform %s: %w", name, ErrInvalidSVG)
+		}
+		// The rightmost transformation is applied first
+		result = m.then(result)
+		rest = strings.TrimLeft(rest[closing+1:], " \t\r\n,")
+	}
+	return result, nil
+}
+
+// svgPaint is the paint related presentation attributes in effect for an element
+type svgPaint struct {
+	fill          string
+	fillRule      string
+	fillOpacity   float64
+	stroke        string
+	strokeWidth   float64
+	strokeOpacity float64
+	lineCap       string
+	lineJoin      string
+	miterLimit    float64
+	// color is what the currentColor keyword refers to
+	color   string
+	opacity float64
+}
+
+var defaultPaint = svgPaint{
+	fill: "black", fillRule: "nonzero", fillOpacity: 1,
+	stroke: "none", strokeWidth: 1, strokeOpacity: 1, lineCap: "butt", lineJoin: "miter", miterLimit: 4,
+	color: "black", opacity: 1,
+}
+
+// svgShape is a shape of an SVG document in the coordinate system of the root element
+type svgShape struct {
+	subpaths []subpath
+	paint    svgPaint
+	// scale is the factor the transformation of the shape scales lengths by, stroke widths included
+	scale float64
+}
+
+// presentationAttributes returns the attributes of the element with the declarations of its style attribute applied on top
+func presentationAttributes(element xml.StartElement) map[string]string {
+	attributes := map[string]string{}
+	for _, attr := range element.Attr {
+		if attr.Name.Space == "" {
+			attributes[attr.Name.Local] = strings.TrimSpace(attr.Value)
+		}
+	}
+	for _, declaration := range strings.Split(attributes["style"], ";") {
+		if colon := strings.Index(declaration, ":"); colon > 0 {
+			attributes[strings.TrimSpace(declaration[:colon])] = strings.TrimSpace(strings.TrimSuffix(declaration[colon+1:], "!important"))
+		}
+	}
+	return attributes
+}
+
+func (paint svgPaint) inherit(attributes map[string]string) svgPaint {
+	number := func(name string, current float64) float64 {
+		if value, ok := attributes[name]; ok {
+			if parsed, numberOK := leadingNumber(value); numberOK {
+				if strings.HasSuffix(value, "%") {
+					return parsed / 100
+				}
+				return parsed
+			}
+		}
+		return current
+	}
+	if value, ok := attributes["fill"]; ok && value != "inherit" {
+		paint.fill = value
+	}
+	if value, ok := attributes["fill-rule"]; ok && value != "inherit" {
+		paint.fillRule = value
+	}
+	if value, ok := attributes["stroke"]; ok && value != "inherit" {
+		paint.stroke = value
+	}
+	if value, ok := attributes["stroke-linecap"]; ok && value != "inherit" {
+		paint.lineCap = value
+	}
+	if value, ok := attributes["stroke-linejoin"]; ok && value != "inherit" {
+		paint.lineJoin = value
+	}
+	if value, ok := attributes["color"]; ok && value != "inherit" {
+		paint.color = value
+	}
+	paint.miterLimit = number("stroke-miterlimit", paint.miterLimit)
+	paint.fillOpacity = number("fill-opacity", paint.fillOpacity)
+	paint.strokeOpacity = number("stroke-opacity", paint.strokeOpacity)
+	paint.strokeWidth = number("stroke-width", paint.strokeWidth)
+	// Opacity isn't inherited, but it multiplies along the ancestors in effect
+	paint.opacity *= number("opacity", 1)
+	return paint
+}
+
+// nonRenderedElements are those elements the contents of which are not rendered by themselves
+var nonRenderedElements = map[string]bool{
+	"defs": true, "clipPath": true, "mask": true, "symbol": true, "marker": true, "pattern": true,
+	"linearGradient": true, "radialGradient": true, "filter": true, "metadata": true,
+	"title": true, "desc": true, "style": true, "script": true, "text": true, "foreignObject": true,
+}
+
+// shapeSubpaths returns the geometry of the element if it is a basic shape or a path
+func shapeSubpaths(name string, attributes map[string]string) ([]subpath, error) {
+	number := func(attribute string) float64 {
+		value, _ := leadingNumber(attributes[attribute])
+		return value
+	}
+	switch name {
+	case "path":
+		return parsePathData(attributes["d"])
+	case "rect":
+		width, height := number("width"), number("height")
+		if width <= 0 || height <= 0 {
+			return nil, nil
+		}
+		return rectSubpaths(number("x"), number("y"), width, height, number("rx"), number("ry")), nil
+	case "circle":
+		if number("r") <= 0 {
+			return nil, nil
+		}
+		return []subpath{ellipseSubpath(number("cx"), number("cy"), number("r"), number("r"))}, nil
+	case "ellipse":
+		if number("rx") <= 0 || number("ry") <= 0 {
+			return nil, nil
+		}
+		return []subpath{ellipseSubpath(number("cx"), number("cy"), number("rx"), number("ry"))}, nil
+	case "line":
+		return []subpath{polygonSubpath([]point{{number("x1"), number("y1")}, {number("x2"), number("y2")}}, false)}, nil
+	case "polyline", "polygon":
+		points, err := parsePoints(attributes["points"])
+		if err != nil || len(points) < 2 {
+			return nil, err
+		}
+		return []subpath{polygonSubpath(points, name == "polygon")}, nil
+	}
+	return nil, nil
+}
+
+// shapes returns the shapes the SVG document renders with their transformations and inherited paint applied
+func (svg parsedSVG) shapes() ([]svgShape, error) {
+	type context struct {
+		transform affine
+		paint     svgPaint
+		skipped   bool
+	}
+	stack := []context{{transform: identity, paint: defaultPaint.inherit(presentationAttributes(svg.root))}}
+	shapes := []svgShape{}
+	for _, token := range svg.content {
+		switch element := token.(type) {
+		case xml.StartElement:
+			parent := stack[len(stack)-1]
+			current := parent
+			attributes := presentationAttributes(element)
+			if element.Name.Space != "" || nonRenderedElements[element.Name.Local] ||
+				attributes["display"] == "none" || attributes["visibility"] == "hidden" {
+				current.skipped = true
+			}
+			if !current.skipped {
+				if transformValue, ok := attributes["transform"]; ok {
+					transform, err := parseTransform(transformValue)
+					if err != nil {
+						return nil, err
+					}
+					current.transform = transform.then(parent.transform)
+				}
+				current.paint = parent.paint.inherit(attributes)
+				subpaths, err := shapeSubpaths(element.Name.Local, attributes)
+				if err != nil {
+					return nil, err
+				}
+				if len(subpaths) > 0 {
+					shapes = append(shapes, svgShape{
+						subpaths: current.transform.applyToSubpaths(subpaths),
+						paint:    current.paint,
+						scale:    current.transform.scaleFactor(),
+					})
+				}
+			}
+			stack = append(stack, current)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	return shapes, nil
+}
+
+// viewBoxRect returns the viewBox of the SVG as its origin, width and height
+func (svg parsedSVG) viewBoxRect() (point, float64, float64, error) {
+	viewBox, err := svg.viewBox()
+	if err != nil {
+		return point{}, 0, 0, err
+	}
+	return parseViewBox(viewBox)
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pdkovacs/igo-repo/domain"
+	log "github.com/sirupsen/logrus"
+)
+
+// optimizedPrecision is the number of decimal places the coordinates of optimized SVGs are rounded to
+const optimizedPrecision = 3
+
+const (
+	svgNamespace   = "http://www.w3.org/2000/svg"
+	xlinkNamespace = "http://www.w3.org/1999/xlink"
+)
+
+// optimizedNumericAttributes are the attributes holding plain numbers which are rounded
+var optimizedNumericAttributes = map[string]bool{
+	"x": true, "y": true, "width": true, "height": true, "cx": true, "cy": true, "r": true, "rx": true, "ry": true,
+	"x1": true, "y1": true, "x2": true, "y2": true, "fx": true, "fy": true, "stroke-width": true,
+}
+
+// whitespaceSensitiveElements are those whose whitespace-only text content is rendered
+var whitespaceSensitiveElements = map[string]bool{"text": true, "tspan": true, "textPath": true}
+
+// pathArgumentCounts are the number of arguments the path commands take
+var pathArgumentCounts = map[byte]int{'M': 2, 'L': 2, 'T': 2, 'H': 1, 'V': 1, 'C': 6, 'S': 4, 'Q': 4, 'A': 7, 'Z': 0}
+
+func formatOptimizedNumber(value float64) string {
+	scale := math.Pow(10, optimizedPrecision)
+	rounded := math.Round(value*scale) / scale
+	if rounded == 0 {
+		return "0"
+	}
+	formatted := strconv.FormatFloat(rounded, 'f', -1, 64)
+	if strings.HasPrefix(formatted, "0.") {
+		return formatted[1:]
+	}
+	if strings.HasPrefix(formatted, "-0.") {
+		return "-" + formatted[2:]
+	}
+	return formatted
+}
+
+// pathDataWriter writes path data with as few separators as possible
+type pathDataWriter struct {
+	out        strings.Builder
+	lastNumber string
+}
+
+func (w *pathDataWriter) command(command byte) {
+	w.out.WriteByte(command)
+	w.lastNumber = ""
+}
+
+func (w *pathDataWriter) number(formatted string) {
+	// A separator is needed unless the sign or a second decimal point ends the previous number
+	if w.lastNumber != "" && !strings.HasPrefix(formatted, "-") &&
+		!(strings.HasPrefix(formatted, ".") && strings.Contains(w.lastNumber, ".")) {
+		w.out.WriteByte(' ')
+	}
+	w.out.WriteString(formatted)
+	w.lastNumber = formatted
+}
+
+// optimizePathData rounds the coordinates of path data and drops the separators and repeated commands not needed
+func optimizePathData(data string) (string, error) {
+	scanner := pathDataScanner{data: data}
+	writer := pathDataWriter{}
+	var command, implicit byte
+	for !scanner.done() {
+		explicit := false
+		if next, ok := scanner.command(); ok {
+			command, explicit = next, true
+		} else if command == 0 || !scanner.nextIsNumber() {
+			return "", fmt.Errorf("command expected at %d in path data: %w", scanner.pos, ErrInvalidSVG)
+		}
+		upper := command &^ 0x20
+		if upper == 'Z' && !explicit {
+			return "", fmt.Errorf("command expected at %d in path data: %w", scanner.pos, ErrInvalidSVG)
+		}
+		if command != implicit || upper == 'Z' {
+			writer.command(command)
+		}
+		implicit = command
+		if upper == 'M' {
+			// Coordinates following a moveto are implicit linetos
+			command = command - 'M' + 'L'
+			implicit = command
+		}
+		for i := 0; i < pathArgumentCounts[upper]; i++ {
+			if upper == 'A' && (i == 3 || i == 4) {
+				flag, err := scanner.flag()
+				if err != nil {
+					return "", err
+				}
+				formatted := "0"
+				if flag {
+					formatted = "1"
+				}
+				writer.number(formatted)
+				continue
+			}
+			value, err := scanner.number()
+			if err != nil {
+				return "", err
+			}
+			writer.number(formatOptimizedNumber(value))
+		}
+	}
+	return writer.out.String(), nil
+}
+
+func optimizePoints(value string) (string, error) {
+	points, err := parsePoints(value)
+	if err != nil {
+		return "", err
+	}
+	formatted := make([]string, 0, len(points))
+	for _, p := range points {
+		formatted = append(formatted, formatOptimizedNumber(p.x)+","+formatOptimizedNumber(p.y))
+	}
+	return strings.Join(formatted, " "), nil
+}
+
+// svgOptimizer keeps track of the namespaces declared in the SVG so that the markup of editors can be told apart
+type svgOptimizer struct {
+	namespaces map[string]string
+}
+
+// isForeign tells whether the prefixed name belongs to a namespace other than SVG's, XLink's or XML's
+func (optimizer svgOptimizer) isForeign(name xml.Name) bool {
+	switch name.Space {
+	case "", "xml", "xlink":
+		return false
+	case "xmlns":
+		namespace := optimizer.namespaces[name.Local]
+		return namespace != svgNamespace && namespace != xlinkNamespace
+	}
+	namespace := optimizer.namespaces[name.Space]
+	return namespace != svgNamespace && namespace != xlinkNamespace
+}
+
+func (optimizer svgOptimizer) attributes(element xml.StartElement, root bool) ([]xml.Attr, error) {
+	attributes := []xml.Attr{}
+	for _, attr := range element.Attr {
+		if optimizer.isForeign(attr.Name) {
+			continue
+		}
+		value := attr.Value
+		if attr.Name.Space == "" && !root {
+			var err error
+			switch {
+			case attr.Name.Local == "d":
+				value, err = optimizePathData(value)
+			case attr.Name.Local == "points":
+				value, err = optimizePoints(value)
+			case optimizedNumericAttributes[attr.Name.Local]:
+				if number, parseErr := strconv.ParseFloat(strings.TrimSpace(value), 64); parseErr == nil {
+					value = formatOptimizedNumber(number)
+				}
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to optimize %s of %s: %w", attr.Name.Local, element.Name.Local, err)
+			}
+		}
+		attributes = append(attributes, xml.Attr{Name: attr.Name, Value: value})
+	}
+	return attributes, nil
+}
+
+// optimizeSVG removes the comments, metadata and the markup of drawing applications from the SVG, unwraps the
+// groups without attributes, rounds coordinates and drops the whitespace between elements. The attributes of the
+// root element are left as they are so that the dimensions of the SVG don't change.
+func optimizeSVG(content []byte) ([]byte, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	optimizer := svgOptimizer{namespaces: map[string]string{}}
+	var out bytes.Buffer
+	// unwrapped tells for each open element whether its tags are left out
+	unwrapped := []bool{}
+	openElements := []string{}
+	skipDepth := 0
+	startTagOpen := false
+	closeStartTag := func() {
+		if startTagOpen {
+			out.WriteString(">")
+			startTagOpen = false
+		}
+	}
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to optimize SVG: %v: %w", err, ErrInvalidSVG)
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			if skipDepth > 0 {
+				skipDepth++
+				continue
+			}
+			for _, attr := range element.Attr {
+				if attr.Name.Space == "xmlns" {
+					optimizer.namespaces[attr.Name.Local] = attr.Value
+				}
+			}
+			if optimizer.isForeign(element.Name) || element.Name.Local == "metadata" {
+				skipDepth++
+				continue
+			}
+			closeStartTag()
+			attributes, err := optimizer.attributes(element, len(openElements) == 0)
+			if err != nil {
+				return nil, err
+			}
+			openElements = append(openElements, element.Name.Local)
+			if element.Name.Local == "g" && len(attributes) == 0 {
+				unwrapped = append(unwrapped, true)
+				continue
+			}
+			unwrapped = append(unwrapped, false)
+			out.WriteString("<" + qualifiedName(element.Name))
+			for _, attr := range attributes {
+				fmt.Fprintf(&out, " %s=\"%s\"", qualifiedName(attr.Name), escapeSVGAttribute(attr.Value))
+			}
+			startTagOpen = true
+		case xml.EndElement:
+			if skipDepth > 0 {
+				skipDepth--
+				continue
+			}
+			if len(openElements) == 0 {
+				return nil, fmt.Errorf("unexpected end element %s: %w", qualifiedName(element.Name), ErrInvalidSVG)
+			}
+			wasUnwrapped := unwrapped[len(unwrapped)-1]
+			openElements, unwrapped = openElements[:len(openElements)-1], unwrapped[:len(unwrapped)-1]
+			if wasUnwrapped {
+				continue
+			}
+			if startTagOpen {
+				out.WriteString("/>")
+				startTagOpen = false
+				continue
+			}
+			out.WriteString("</" + qualifiedName(element.Name) + ">")
+		case xml.CharData:
+			if skipDepth > 0 || len(openElements) == 0 {
+				continue
+			}
+			if len(bytes.TrimSpace(element)) == 0 && !whitespaceSensitiveElements[openElements[len(openElements)-1]] {
+				continue
+			}
+			closeStartTag()
+			out.WriteString(svgTextEscaper.Replace(string(element)))
+		}
+		// Comments, processing instructions and doctypes are dropped
+	}
+	return out.Bytes(), nil
+}
+
+// optimizeIconfile returns the optimized content of SVG iconfiles along with their original content if optimization
+// is turned on and makes a difference. Iconfiles failing to be optimized are kept as they are.
+func (service *IconService) optimizeIconfile(format string, content []byte) ([]byte, []byte) {
+	if !service.OptimizeSVGIconfiles || format != "svg" {
+		return content, nil
+	}
+	optimized, err := optimizeSVG(content)
+	if err != nil {
+		log.WithField("prefix", "optimizeIconfile").Warnf("failed to optimize SVG, keeping it as is: %v", err)
+		return content, nil
+	}
+	if bytes.Equal(optimized, content) {
+		return content, nil
+	}
+	return optimized, content
+}
+
+// Variants of iconfiles clients can choose from
+const (
+	IconfileVariantOptimized = "optimized"
+	IconfileVariantOriginal  = "original"
+)
+
+// ErrInvalidIconfileVariant is returned for unknown iconfile variants
+var ErrInvalidIconfileVariant = errors.New("invalid iconfile variant")
+
+// GetIconfileVariant returns the iconfile as stored, optimized if optimization was turned on when it was added, or
+// as originally uploaded. Missing PNGs of the stored variant are rendered from the SVG iconfile of the icon.
+func (service *IconService) GetIconfileVariant(iconName string, descriptor domain.IconfileDescriptor, variant string, requestedBy UserInfo) (domain.StoredIconfile, error) {
+	switch variant {
+	case "", IconfileVariantOptimized:
+		return service.GetIconfileOrRasterize(iconName, descriptor, requestedBy)
+	case IconfileVariantOriginal:
+		iconfile, err := service.Repositories.DB.GetOriginalIconfile(iconName, descriptor.Format, descriptor.Size)
+		if err != nil {
+			return domain.StoredIconfile{}, fmt.Errorf("failed to retrieve original iconfile %v: %w", descriptor, err)
+		}
+		return iconfile, nil
+	}
+	return domain.StoredIconfile{}, fmt.Errorf("%s: %w", variant, ErrInvalidIconfileVariant)
+}
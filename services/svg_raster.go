@@ -0,0 +1,366 @@
+package services
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// rasterSubsamples is the number of scanlines sampled per pixel row for anti-aliasing
+const rasterSubsamples = 5
+
+// flatteningTolerance is how far in pixels the polylines approximating curves may deviate from them
+const flatteningTolerance = 0.1
+
+// polyline is a flattened subpath in pixel coordinates
+type polyline struct {
+	points []point
+	closed bool
+}
+
+func curveSteps(points ...point) int {
+	length := 0.0
+	for i := 1; i < len(points); i++ {
+		length += points[i].sub(points[i-1]).length()
+	}
+	steps := int(math.Ceil(math.Sqrt(length / flatteningTolerance / 8)))
+	if steps < 1 {
+		return 1
+	}
+	if steps > 100 {
+		return 100
+	}
+	return steps
+}
+
+// flatten approximates the subpaths with polylines
+func flatten(subpaths []subpath) []polyline {
+	polylines := []polyline{}
+	for _, sp := range subpaths {
+		current := sp.start
+		points := []point{current}
+		for _, segment := range sp.segments {
+			switch segment.kind {
+			case lineTo:
+				points = append(points, segment.points[0])
+			case quadTo:
+				control, end := segment.points[0], segment.points[1]
+				steps := curveSteps(current, control, end)
+				for i := 1; i <= steps; i++ {
+					t := float64(i) / float64(steps)
+					points = append(points, lerp(lerp(current, control, t), lerp(control, end, t), t))
+				}
+			case cubicTo:
+				c1, c2, end := segment.points[0], segment.points[1], segment.points[2]
+				steps := curveSteps(current, c1, c2, end)
+				for i := 1; i <= steps; i++ {
+					t := float64(i) / float64(steps)
+					a, b, c := lerp(current, c1, t), lerp(c1, c2, t), lerp(c2, end, t)
+					points = append(points, lerp(lerp(a, b, t), lerp(b, c, t), t))
+				}
+			default:
+				continue
+			}
+			current = segment.points[len(segment.points)-1]
+		}
+		deduplicated := []point{points[0]}
+		for _, p := range points[1:] {
+			if p.sub(deduplicated[len(deduplicated)-1]).length() > 1e-9 {
+				deduplicated = append(deduplicated, p)
+			}
+		}
+		polylines = append(polylines, polyline{points: deduplicated, closed: sp.closed})
+	}
+	return polylines
+}
+
+// polygonArea is positive for polygons going clockwise on screen
+func polygonArea(polygon []point) float64 {
+	area := 0.0
+	for i, p := range polygon {
+		q := polygon[(i+1)%len(polygon)]
+		area += p.x*q.y - q.x*p.y
+	}
+	return area / 2
+}
+
+// orientedPolygon returns the polygon going clockwise on screen so that the union of such polygons
+// is what the non-zero fill rule fills
+func orientedPolygon(polygon []point) []point {
+	if polygonArea(polygon) >= 0 {
+		return polygon
+	}
+	reversed := make([]point, len(polygon))
+	for i, p := range polygon {
+		reversed[len(polygon)-1-i] = p
+	}
+	return reversed
+}
+
+func circlePolygon(center point, radius float64) []point {
+	steps := curveSteps(point{0, 0}, point{2 * math.Pi * radius, 0})*2 + 8
+	polygon := make([]point, steps)
+	for i := range polygon {
+		angle := 2 * math.Pi * float64(i) / float64(steps)
+		polygon[i] = point{center.x + radius*math.Cos(angle), center.y + radius*math.Sin(angle)}
+	}
+	return polygon
+}
+
+func normalized(p point) point {
+	length := p.length()
+	if length == 0 {
+		return p
+	}
+	return p.scale(1 / length)
+}
+
+// strokeJoin returns the polygon filling the outer side of the join of two segments meeting at the vertex
+func strokeJoin(vertex, d1, d2 point, halfWidth float64, paint svgPaint) []point {
+	if paint.lineJoin == "round" {
+		return circlePolygon(vertex, halfWidth)
+	}
+	side := -1.0
+	if d1.x*d2.y-d1.y*d2.x < 0 {
+		side = 1
+	}
+	outer1 := point{-d1.y, d1.x}.scale(side)
+	outer2 := point{-d2.y, d2.x}.scale(side)
+	p1, p2 := vertex.add(outer1.scale(halfWidth)), vertex.add(outer2.scale(halfWidth))
+	bisector := normalized(outer1.add(outer2))
+	cosHalfAngle := bisector.x*outer1.x + bisector.y*outer1.y
+	if paint.lineJoin != "bevel" && cosHalfAngle > 0 && 1/cosHalfAngle <= paint.miterLimit {
+		return []point{vertex, p1, vertex.add(bisector.scale(halfWidth / cosHalfAngle)), p2}
+	}
+	return []point{vertex, p1, p2}
+}
+
+// strokePolygons returns polygons the non-zero union of which covers the stroke of the polylines
+func strokePolygons(polylines []polyline, width float64, paint svgPaint) [][]point {
+	halfWidth := width / 2
+	polygons := [][]point{}
+	for _, pl := range polylines {
+		points := pl.points
+		if pl.closed && len(points) > 1 && points[0].sub(points[len(points)-1]).length() < 1e-9 {
+			points = points[:len(points)-1]
+		}
+		if len(points) == 1 {
+			switch paint.lineCap {
+			case "round":
+				polygons = append(polygons, circlePolygon(points[0], halfWidth))
+			case "square":
+				p := points[0]
+				polygons = append(polygons, []point{
+					{p.x - halfWidth, p.y - halfWidth}, {p.x + halfWidth, p.y - halfWidth},
+					{p.x + halfWidth, p.y + halfWidth}, {p.x - halfWidth, p.y + halfWidth},
+				})
+			}
+			continue
+		}
+		segmentCount := len(points) - 1
+		if pl.closed {
+			segmentCount = len(points)
+		}
+		directions := make([]point, segmentCount)
+		for i := range directions {
+			directions[i] = normalized(points[(i+1)%len(points)].sub(points[i]))
+		}
+		for i, d := range directions {
+			a, b := points[i], points[(i+1)%len(points)]
+			if !pl.closed && paint.lineCap == "square" {
+				if i == 0 {
+					a = a.sub(d.scale(halfWidth))
+				}
+				if i == segmentCount-1 {
+					b = b.add(d.scale(halfWidth))
+				}
+			}
+			normal := point{-d.y, d.x}.scale(halfWidth)
+			polygons = append(polygons, []point{a.add(normal), b.add(normal), b.sub(normal), a.sub(normal)})
+		}
+		for i := 1; i < len(points); i++ {
+			if i < segmentCount {
+				polygons = append(polygons, strokeJoin(points[i], directions[i-1], directions[i], halfWidth, paint))
+			}
+		}
+		if pl.closed {
+			polygons = append(polygons, strokeJoin(points[0], directions[segmentCount-1], directions[0], halfWidth, paint))
+		} else if paint.lineCap == "round" {
+			polygons = append(polygons, circlePolygon(points[0], halfWidth), circlePolygon(points[len(points)-1], halfWidth))
+		}
+	}
+	for i, polygon := range polygons {
+		polygons[i] = orientedPolygon(polygon)
+	}
+	return polygons
+}
+
+// rasterEdge is a non-horizontal polygon edge going downwards from (x0, y0) to (x1, y1)
+type rasterEdge struct {
+	x0, y0, x1, y1 float64
+	winding        int
+}
+
+type crossing struct {
+	x       float64
+	winding int
+}
+
+// canvas accumulates the rendering of shapes as premultiplied colors
+type canvas struct {
+	width, height int
+	pixels        []rgba
+}
+
+func newCanvas(width, height int) *canvas {
+	return &canvas{width: width, height: height, pixels: make([]rgba, width*height)}
+}
+
+func addSpan(row []float64, from, to, weight float64) {
+	from = math.Max(from, 0)
+	to = math.Min(to, float64(len(row)))
+	if from >= to {
+		return
+	}
+	first, last := int(from), int(to)
+	if first == last {
+		row[first] += (to - from) * weight
+		return
+	}
+	row[first] += (float64(first+1) - from) * weight
+	for x := first + 1; x < last; x++ {
+		row[x] += weight
+	}
+	if last < len(row) {
+		row[last] += (to - float64(last)) * weight
+	}
+}
+
+// coverage computes how much of each pixel the polygons cover under the fill rule by sampling rasterSubsamples
+// scanlines per row and computing the horizontal coverage of the spans exactly
+func (c *canvas) coverage(polygons [][]point, evenOdd bool) []float64 {
+	edges := []rasterEdge{}
+	for _, polygon := range polygons {
+		for i, p := range polygon {
+			q := polygon[(i+1)%len(polygon)]
+			switch {
+			case p.y < q.y:
+				edges = append(edges, rasterEdge{p.x, p.y, q.x, q.y, 1})
+			case p.y > q.y:
+				edges = append(edges, rasterEdge{q.x, q.y, p.x, p.y, -1})
+			}
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].y0 < edges[j].y0 })
+
+	result := make([]float64, c.width*c.height)
+	active := []rasterEdge{}
+	next := 0
+	crossings := []crossing{}
+	weight := 1.0 / rasterSubsamples
+	for y := 0; y < c.height; y++ {
+		row := result[y*c.width : (y+1)*c.width]
+		for sample := 0; sample < rasterSubsamples; sample++ {
+			sampleY := float64(y) + (float64(sample)+0.5)/rasterSubsamples
+			for next < len(edges) && edges[next].y0 <= sampleY {
+				active = append(active, edges[next])
+				next++
+			}
+			crossings = crossings[:0]
+			stillActive := active[:0]
+			for _, edge := range active {
+				if edge.y1 <= sampleY {
+					continue
+				}
+				stillActive = append(stillActive, edge)
+				t := (sampleY - edge.y0) / (edge.y1 - edge.y0)
+				crossings = append(crossings, crossing{edge.x0 + (edge.x1-edge.x0)*t, edge.winding})
+			}
+			active = stillActive
+			sort.Slice(crossings, func(i, j int) bool { return crossings[i].x < crossings[j].x })
+			winding := 0
+			for i, cross := range crossings {
+				winding += cross.winding
+				inside := winding != 0
+				if evenOdd {
+					inside = winding%2 != 0
+				}
+				if inside && i+1 < len(crossings) {
+					addSpan(row, cross.x, crossings[i+1].x, weight)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// composite paints the color over the canvas where the coverage is
+func (c *canvas) composite(coverage []float64, paint rgba, opacity float64) {
+	for i, covered := range coverage {
+		alpha := math.Min(covered, 1) * paint.a * opacity
+		if alpha <= 0 {
+			continue
+		}
+		pixel := c.pixels[i]
+		c.pixels[i] = rgba{
+			paint.r*alpha + pixel.r*(1-alpha),
+			paint.g*alpha + pixel.g*(1-alpha),
+			paint.b*alpha + pixel.b*(1-alpha),
+			alpha + pixel.a*(1-alpha),
+		}
+	}
+}
+
+func (c *canvas) image() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, c.width, c.height))
+	for i, pixel := range c.pixels {
+		if pixel.a <= 0 {
+			continue
+		}
+		img.Pix[4*i] = uint8(math.Round(clamp01(pixel.r/pixel.a) * 255))
+		img.Pix[4*i+1] = uint8(math.Round(clamp01(pixel.g/pixel.a) * 255))
+		img.Pix[4*i+2] = uint8(math.Round(clamp01(pixel.b/pixel.a) * 255))
+		img.Pix[4*i+3] = uint8(math.Round(clamp01(pixel.a) * 255))
+	}
+	return img
+}
+
+// rasterize renders the SVG at the height in pixels keeping its aspect ratio. Shapes are filled and stroked
+// with solid colors, gradients are approximated by their average colors; dashes, masks, clipping,
+// filters and text are not supported.
+func (svg parsedSVG) rasterize(height int) (image.Image, error) {
+	origin, viewBoxWidth, viewBoxHeight, err := svg.viewBoxRect()
+	if err != nil {
+		return nil, err
+	}
+	shapes, err := svg.shapes()
+	if err != nil {
+		return nil, err
+	}
+	gradients := svg.gradientColors()
+
+	width, err := rasterWidth(viewBoxWidth/viewBoxHeight, height)
+	if err != nil {
+		return nil, err
+	}
+	scale := float64(height) / viewBoxHeight
+	toPixels := affine{scale, 0, 0, scale, -origin.x * scale, -origin.y * scale}
+	c := newCanvas(width, height)
+	for _, shape := range shapes {
+		polylines := flatten(toPixels.applyToSubpaths(shape.subpaths))
+		if fill, paints := resolvePaint(shape.paint.fill, shape.paint.color, gradients); paints {
+			polygons := [][]point{}
+			for _, pl := range polylines {
+				if len(pl.points) > 2 {
+					polygons = append(polygons, pl.points)
+				}
+			}
+			c.composite(c.coverage(polygons, shape.paint.fillRule == "evenodd"), fill, shape.paint.fillOpacity*shape.paint.opacity)
+		}
+		strokeWidth := shape.paint.strokeWidth * shape.scale * scale
+		if stroke, paints := resolvePaint(shape.paint.stroke, shape.paint.color, gradients); paints && strokeWidth > 0 {
+			c.composite(c.coverage(strokePolygons(polylines, strokeWidth, shape.paint), false), stroke, shape.paint.strokeOpacity*shape.paint.opacity)
+		}
+	}
+	return c.image(), nil
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/pdkovacs/igo-repo/config"
+	"github.com/pdkovacs/igo-repo/domain"
+)
+
+// ErrInvalidRecoloring is returned for recolorings with malformed colors, unknown themes or of other than SVGs
+var ErrInvalidRecoloring = errors.New("invalid recoloring")
+
+// ErrNotRecolorable is returned for SVG iconfiles which are not monochrome
+var ErrNotRecolorable = errors.New("iconfile is not monochrome")
+
+// recolorCache keeps the recently recolored SVGs keyed by the content hash of their source along with the colors
+var recolorCache = newContentCache(16 << 20)
+
+// Recoloring tells which colors to paint a monochrome SVG with. The colors of the theme, if any, are overridden by
+// those given explicitly. If only either of the fill and the stroke colors is known, it is used for both.
+type Recoloring struct {
+	Fill   string
+	Stroke string
+	Theme  string
+}
+
+// Requested tells whether any recoloring is requested at all
+func (recoloring Recoloring) Requested() bool {
+	return recoloring.Fill != "" || recoloring.Stroke != "" || recoloring.Theme != ""
+}
+
+var hexColorPattern = regexp.MustCompile(`^#?([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// normalizeHexColor returns the color in the #rrggbb notation. The # is optional as it needs escaping in URLs.
+func normalizeHexColor(value string) (string, error) {
+	match := hexColorPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return "", fmt.Errorf("%q is not a hex color: %w", value, ErrInvalidRecoloring)
+	}
+	digits := strings.ToLower(match[1])
+	if len(digits) == 3 {
+		digits = string([]byte{digits[0], digits[0], digits[1], digits[1], digits[2], digits[2]})
+	}
+	return "#" + digits, nil
+}
+
+// ValidateThemePalettes checks that the colors of the configured themes are hex colors
+func ValidateThemePalettes(themes config.ThemePalettes) error {
+	for name, palette := range themes {
+		if palette.Fill == "" && palette.Stroke == "" {
+			return fmt.Errorf("theme %s has no colors: %w", name, ErrInvalidRecoloring)
+		}
+		for _, color := range []string{palette.Fill, palette.Stroke} {
+			if _, err := normalizeHexColor(color); color != "" && err != nil {
+				return fmt.Errorf("theme %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// recoloringColors resolves the fill and stroke colors of the recoloring
+func (service *IconService) recoloringColors(recoloring Recoloring) (string, string, error) {
+	fill, stroke := recoloring.Fill, recoloring.Stroke
+	if recoloring.Theme != "" {
+		palette, ok := service.SVGThemes[recoloring.Theme]
+		if !ok {
+			return "", "", fmt.Errorf("unknown theme %s: %w", recoloring.Theme, ErrInvalidRecoloring)
+		}
+		if fill == "" {
+			fill = palette.Fill
+		}
+		if stroke == "" {
+			stroke = palette.Stroke
+		}
+	}
+	if fill == "" {
+		fill = stroke
+	}
+	if stroke == "" {
+		stroke = fill
+	}
+	var err error
+	if fill, err = normalizeHexColor(fill); err != nil {
+		return "", "", err
+	}
+	if stroke, err = normalizeHexColor(stroke); err != nil {
+		return "", "", err
+	}
+	return fill, stroke, nil
+}
+
+// paintDeclarationPattern matches the fill and stroke declarations of style attributes and style sheets
+var paintDeclarationPattern = regexp.MustCompile(`(^|[;{\s])(fill|stroke)(\s*:\s*)([^;}!]*[^;}!\s])`)
+
+// svgRecoloring rewrites the fill and stroke colors of an SVG collecting the colors it finds on the way
+type svgRecoloring struct {
+	fill   string
+	stroke string
+	// colors are the distinct colors found
+	colors map[rgba]bool
+	// references are paints referring to gradients or patterns
+	references []string
+}
+
+func (r *svgRecoloring) addColor(color rgba) {
+	if color.a > 0 {
+		r.colors[rgba{color.r, color.g, color.b, 1}] = true
+	}
+}
+
+// paint returns the new value of a fill or a stroke property. Only colors are replaced: none is kept as is.
+func (r *svgRecoloring) paint(property string, value string) string {
+	trimmed := strings.TrimSpace(value)
+	switch {
+	case trimmed == "" || trimmed == "none" || trimmed == "inherit":
+		return value
+	case urlReferencePattern.MatchString(trimmed):
+		r.references = append(r.references, trimmed)
+		return value
+	case strings.EqualFold(trimmed, "currentColor"):
+	default:
+		color, ok := parseColor(trimmed)
+		if !ok {
+			return value
+		}
+		r.addColor(color)
+	}
+	if property == "stroke" {
+		return r.stroke
+	}
+	return r.fill
+}
+
+func (r *svgRecoloring) declarations(css string) string {
+	return paintDeclarationPattern.ReplaceAllStringFunc(css, func(declaration string) string {
+		match := paintDeclarationPattern.FindStringSubmatch(declaration)
+		return match[1] + match[2] + match[3] + r.paint(match[2], match[4])
+	})
+}
+
+// shapeColors adds the colors the shapes of the SVG are painted with, the implicit black fill included
+func (r *svgRecoloring) shapeColors(svg parsedSVG) error {
+	shapes, err := svg.shapes()
+	if err != nil {
+		return err
+	}
+	for _, shape := range shapes {
+		paints := []string{shape.paint.fill}
+		if shape.paint.strokeWidth > 0 {
+			paints = append(paints, shape.paint.stroke)
+		}
+		for _, value := range paints {
+			if strings.EqualFold(strings.TrimSpace(value), "currentColor") {
+				value = shape.paint.color
+			}
+			if color, ok := parseColor(value); ok {
+				r.addColor(color)
+			}
+		}
+	}
+	return nil
+}
+
+// recolorSVG paints the SVG with the fill and stroke colors provided it is monochrome. The contents of clip paths and
+// masks are left as they are since only their geometry, or luminance, matter.
+func recolorSVG(content []byte, fill string, stroke string) ([]byte, error) {
+	svg, err := parseSVG(content)
+	if err != nil {
+		return nil, err
+	}
+	r := svgRecoloring{fill: fill, stroke: stroke, colors: map[rgba]bool{}}
+	err = r.shapeColors(svg)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	var out bytes.Buffer
+	depth := 0
+	untouchedDepth := 0
+	inStyle := false
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to recolor SVG: %v: %w", err, ErrInvalidSVG)
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			depth++
+			if untouchedDepth > 0 || element.Name.Local == "clipPath" || element.Name.Local == "mask" {
+				untouchedDepth++
+			}
+			inStyle = element.Name.Local == "style"
+			hasFill := false
+			out.WriteString("<" + qualifiedName(element.Name))
+			for _, attr := range element.Attr {
+				value := attr.Value
+				if untouchedDepth == 0 && attr.Name.Space == "" {
+					switch attr.Name.Local {
+					case "fill", "stroke":
+						value = r.paint(attr.Name.Local, value)
+						hasFill = hasFill || attr.Name.Local == "fill"
+					case "style":
+						value = r.declarations(value)
+						hasFill = hasFill || presentationAttributes(element)["fill"] != ""
+					}
+				}
+				fmt.Fprintf(&out, " %s=\"%s\"", qualifiedName(attr.Name), escapeSVGAttribute(value))
+			}
+			// The default black fill is overridden on the root element to be inherited
+			if depth == 1 && !hasFill {
+				fmt.Fprintf(&out, " fill=\"%s\"", escapeSVGAttribute(fill))
+			}
+			out.WriteString(">")
+		case xml.EndElement:
+			depth--
+			if untouchedDepth > 0 {
+				untouchedDepth--
+			}
+			inStyle = false
+			out.WriteString("</" + qualifiedName(element.Name) + ">")
+		case xml.CharData:
+			text := string(element)
+			if inStyle && untouchedDepth == 0 {
+				text = r.declarations(text)
+			}
+			out.WriteString(svgTextEscaper.Replace(text))
+		case xml.Comment:
+			out.WriteString("<!--" + string(element) + "-->")
+		case xml.ProcInst:
+			out.WriteString("<?" + element.Target + " " + string(element.Inst) + "?>")
+		case xml.Directive:
+			out.WriteString("<!" + string(element) + ">")
+		}
+	}
+
+	if len(r.references) > 0 {
+		return nil, fmt.Errorf("painted with %s: %w", r.references[0], ErrNotRecolorable)
+	}
+	if len(r.colors) > 1 {
+		return nil, fmt.Errorf("painted with %d colors: %w", len(r.colors), ErrNotRecolorable)
+	}
+	return out.Bytes(), nil
+}
+
+// RecolorIconfile paints the monochrome SVG iconfile with the colors of the recoloring
+func (service *IconService) RecolorIconfile(iconfile domain.StoredIconfile, recoloring Recoloring) (domain.StoredIconfile, error) {
+	if iconfile.Format != "svg" {
+		return domain.StoredIconfile{}, fmt.Errorf("%s iconfiles cannot be recolored: %w", iconfile.Format, ErrInvalidRecoloring)
+	}
+	fill, stroke, err := service.recoloringColors(recoloring)
+	if err != nil {
+		return domain.StoredIconfile{}, err
+	}
+
+	recolored := domain.StoredIconfile{Iconfile: domain.Iconfile{IconfileDescriptor: iconfile.IconfileDescriptor}, CreatedAt: iconfile.CreatedAt, Derived: true}
+	key := fmt.Sprintf("%s/fill=%s/stroke=%s", iconfile.ContentHash, fill, stroke)
+	if content, cached := recolorCache.get(key); cached {
+		recolored.Content = content
+		recolored.ContentHash = domain.ContentHash(content)
+		return recolored, nil
+	}
+
+	recolored.Content, err = recolorSVG(iconfile.Content, fill, stroke)
+	if err != nil {
+		return domain.StoredIconfile{}, fmt.Errorf("failed to recolor %v: %w", iconfile.IconfileDescriptor, err)
+	}
+	recolored.ContentHash = domain.ContentHash(recolored.Content)
+	recolorCache.put(key, recolored.Content)
+	return recolored, nil
+}
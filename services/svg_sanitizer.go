@@ -0,0 +1,410 @@
+package services
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrUnsafeSVG is returned in strict mode for SVG iconfiles with content the sanitizer would remove
+var ErrUnsafeSVG = errors.New("unsafe SVG")
+
+// Kinds of content removed from SVG iconfiles
+const (
+	RemovedElement               = "element"
+	RemovedAttribute             = "attribute"
+	RemovedEventHandler          = "event-handler"
+	RemovedExternalReference     = "external-reference"
+	RemovedEntityDeclaration     = "entity-declaration"
+	RemovedProcessingInstruction = "processing-instruction"
+)
+
+// SanitizationRemoval describes a piece of content removed from an SVG iconfile
+type SanitizationRemoval struct {
+	Kind      string `json:"kind"`
+	Element   string `json:"element,omitempty"`
+	Attribute string `json:"attribute,omitempty"`
+	Value     string `json:"value,omitempty"`
+}
+
+// SanitizationReport lists what has been removed from an SVG iconfile, or in strict mode, what would have been
+type SanitizationReport struct {
+	Removed []SanitizationRemoval `json:"removed"`
+}
+
+// Clean tells whether there was nothing to remove
+func (report SanitizationReport) Clean() bool {
+	return len(report.Removed) == 0
+}
+
+func (report *SanitizationReport) add(removal SanitizationRemoval) {
+	const maxValueLength = 200
+	if len(removal.Value) > maxValueLength {
+		removal.Value = removal.Value[:maxValueLength] + "..."
+	}
+	report.Removed = append(report.Removed, removal)
+}
+
+// safeSVGElements are the elements of the SVG namespace kept. All others, like those running scripts, embedding
+// arbitrary (X)HTML or loading documents, as well as the elements of any other namespace are removed along with
+// their content. Names are case-sensitive in XML, so case variants of the names are removed, too.
+var safeSVGElements = map[string]bool{
+	"a": true, "altGlyph": true, "altGlyphDef": true, "altGlyphItem": true, "animate": true, "animateColor": true,
+	"animateMotion": true, "animateTransform": true, "circle": true, "clipPath": true, "color-profile": true,
+	"cursor": true, "defs": true, "desc": true, "discard": true, "ellipse": true, "feBlend": true,
+	"feColorMatrix": true, "feComponentTransfer": true, "feComposite": true, "feConvolveMatrix": true,
+	"feDiffuseLighting": true, "feDisplacementMap": true, "feDistantLight": true, "feDropShadow": true,
+	"feFlood": true, "feFuncA": true, "feFuncB": true, "feFuncG": true, "feFuncR": true, "feGaussianBlur": true,
+	"feImage": true, "feMerge": true, "feMergeNode": true, "feMorphology": true, "feOffset": true,
+	"fePointLight": true, "feSpecularLighting": true, "feSpotLight": true, "feTile": true, "feTurbulence": true,
+	"filter": true, "font": true, "font-face": true, "font-face-format": true, "font-face-name": true,
+	"font-face-src": true, "font-face-uri": true, "g": true, "glyph": true, "glyphRef": true, "hkern": true,
+	"image": true, "line": true, "linearGradient": true, "marker": true, "mask": true, "metadata": true,
+	"missing-glyph": true, "mpath": true, "path": true, "pattern": true, "polygon": true, "polyline": true,
+	"radialGradient": true, "rect": true, "set": true, "stop": true, "style": true, "svg": true, "switch": true,
+	"symbol": true, "text": true, "textPath": true, "title": true, "tref": true, "tspan": true, "use": true,
+	"view": true, "vkern": true,
+}
+
+// safeXLinkAttributes are the attributes of the XLink namespace kept, the href among them only if it is not external
+var safeXLinkAttributes = map[string]bool{"href": true, "title": true, "show": true, "actuate": true, "type": true, "role": true, "arcrole": true}
+
+// safeXMLAttributes are the attributes of the XML namespace kept: xml:base, for one, would redirect relative links
+var safeXMLAttributes = map[string]bool{"lang": true, "space": true}
+
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// safeDataReferencePattern matches the data URLs of raster images, the only references outside the document kept
+var safeDataReferencePattern = regexp.MustCompile(`(?i)^data:image/(png|jpeg|gif|webp)[;,]`)
+
+// svgNamespaceScope maps the prefixes in scope to their namespaces, the default namespace to the empty prefix
+type svgNamespaceScope map[string]string
+
+// enter returns the scope of the element taking its namespace declarations into account
+func (scope svgNamespaceScope) enter(element xml.StartElement) svgNamespaceScope {
+	entered := svgNamespaceScope{}
+	for prefix, namespace := range scope {
+		entered[prefix] = namespace
+	}
+	for _, attr := range element.Attr {
+		switch {
+		case attr.Name.Space == "xmlns":
+			entered[attr.Name.Local] = attr.Value
+		case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+			entered[""] = attr.Value
+		}
+	}
+	return entered
+}
+
+// namespace resolves the prefix of the name. Unprefixed elements of documents without a default namespace are
+// taken to be SVG's, the way HTML parsers treat inline SVG.
+func (scope svgNamespaceScope) namespace(name xml.Name) string {
+	switch name.Space {
+	case "":
+		if namespace, declared := scope[""]; declared {
+			return namespace
+		}
+		return svgNamespace
+	case "xml":
+		return xmlNamespace
+	}
+	return scope[name.Space]
+}
+
+func (scope svgNamespaceScope) isSafeElement(name xml.Name) bool {
+	return scope.namespace(name) == svgNamespace && safeSVGElements[name.Local]
+}
+
+func isEventHandlerAttribute(name xml.Name) bool {
+	return name.Space == "" && len(name.Local) > 2 && strings.EqualFold(name.Local[:2], "on")
+}
+
+// isReferenceName tells whether the attribute of the name loads what it refers to
+func isReferenceName(name string) bool {
+	return strings.EqualFold(name, "href") || strings.EqualFold(name, "src") || strings.EqualFold(name, "data")
+}
+
+// isReferenceAttribute tells whether the attribute is a link whatever prefix the XLink namespace is bound to
+func (scope svgNamespaceScope) isReferenceAttribute(name xml.Name) bool {
+	return (name.Space == "" || scope.namespace(name) == xlinkNamespace) && isReferenceName(name.Local)
+}
+
+// isSafeAttribute tells whether the attribute is one of SVG's own, which are in no namespace, one of the XLink and
+// XML attributes kept or a namespace declaration. Attributes of any other namespace, like those of drawing
+// applications, are not interpreted by user agents, so they are kept for the optimizer to remove.
+func (scope svgNamespaceScope) isSafeAttribute(name xml.Name) bool {
+	if name.Space == "" || name.Space == "xmlns" {
+		return true
+	}
+	switch scope.namespace(name) {
+	case xlinkNamespace:
+		return safeXLinkAttributes[name.Local]
+	case xmlNamespace:
+		return safeXMLAttributes[name.Local]
+	case svgNamespace:
+		return false
+	}
+	return true
+}
+
+func isExternalReference(value string) bool {
+	value = strings.TrimSpace(value)
+	return !strings.HasPrefix(value, "#") && !safeDataReferencePattern.MatchString(value)
+}
+
+// cssImportPattern matches the @import rules of stylesheets
+var cssImportPattern = regexp.MustCompile(`(?i)@import[^;]*;?`)
+
+// cssURLPattern matches the url() references of stylesheets capturing the quoted or the bare reference
+var cssURLPattern = regexp.MustCompile(`(?i)url\(\s*(?:"([^"]*)"|'([^']*)'|([^)]*?))\s*\)`)
+
+// cssImageSetPattern matches the start of image-set() references, which take URLs as plain strings
+var cssImageSetPattern = regexp.MustCompile(`(?i)image-set\(`)
+
+// cssStringPattern matches quoted CSS strings capturing their content
+var cssStringPattern = regexp.MustCompile(`"([^"]*)"|'([^']*)'`)
+
+var cssCommentPattern = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+var cssEscapePattern = regexp.MustCompile(`\\(?:([0-9a-fA-F]{1,6})[ \t\n\r\f]?|(\r\n|[\n\r\f])|(.))`)
+
+// unescapeCSS resolves the escapes which could otherwise disguise @import rules and url() references
+func unescapeCSS(css string) string {
+	return cssEscapePattern.ReplaceAllStringFunc(css, func(escape string) string {
+		match := cssEscapePattern.FindStringSubmatch(escape)
+		switch {
+		case match[1] != "":
+			codepoint, _ := strconv.ParseInt(match[1], 16, 32)
+			if codepoint == 0 || codepoint > unicode.MaxRune {
+				return string(unicode.ReplacementChar)
+			}
+			return string(rune(codepoint))
+		case match[2] != "":
+			return ""
+		default:
+			return match[3]
+		}
+	})
+}
+
+// closingParenthesis returns the index of the parenthesis closing the one before the start of the CSS
+func closingParenthesis(css string, start int) int {
+	depth := 1
+	for i := start; i < len(css); i++ {
+		switch css[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(css) - 1
+}
+
+// sanitizeCSS removes the @import rules and replaces the url() and image-set() references to resources outside the
+// document with none. The CSS is returned as is if there is nothing to remove, otherwise with escapes resolved and
+// comments dropped as that is what the references are looked for in.
+func sanitizeCSS(css string) (string, []string) {
+	removed := []string{}
+	if !strings.ContainsAny(css, "@(") {
+		return css, removed
+	}
+	canonical := cssCommentPattern.ReplaceAllString(unescapeCSS(css), "")
+
+	canonical = cssImportPattern.ReplaceAllStringFunc(canonical, func(rule string) string {
+		removed = append(removed, rule)
+		return ""
+	})
+	canonical = cssURLPattern.ReplaceAllStringFunc(canonical, func(reference string) string {
+		match := cssURLPattern.FindStringSubmatch(reference)
+		if isExternalReference(match[1] + match[2] + match[3]) {
+			removed = append(removed, reference)
+			return "none"
+		}
+		return reference
+	})
+	var out strings.Builder
+	for {
+		location := cssImageSetPattern.FindStringIndex(canonical)
+		if location == nil {
+			out.WriteString(canonical)
+			break
+		}
+		end := closingParenthesis(canonical, location[1])
+		reference := canonical[location[0] : end+1]
+		out.WriteString(canonical[:location[0]])
+		external := false
+		for _, match := range cssStringPattern.FindAllStringSubmatch(reference, -1) {
+			external = external || isExternalReference(match[1]+match[2])
+		}
+		if external {
+			removed = append(removed, reference)
+			out.WriteString("none")
+		} else {
+			out.WriteString(reference)
+		}
+		canonical = canonical[end+1:]
+	}
+
+	if len(removed) == 0 {
+		return css, removed
+	}
+	return out.String(), removed
+}
+
+// isUnsafeAnimation tells whether the element is an animation setting event handlers or references which would
+// get around the removal of the attributes themselves
+func isUnsafeAnimation(element xml.StartElement) bool {
+	switch element.Name.Local {
+	case "animate", "set", "animateMotion", "animateTransform":
+	default:
+		return false
+	}
+	for _, attr := range element.Attr {
+		if attr.Name.Space == "" && attr.Name.Local == "attributeName" {
+			target := strings.TrimSpace(attr.Value)
+			target = target[strings.LastIndex(target, ":")+1:]
+			return isReferenceName(target) || len(target) > 2 && strings.EqualFold(target[:2], "on")
+		}
+	}
+	return false
+}
+
+// sanitizeSVG removes the elements other than those of SVG known to be safe, event handlers, references to resources
+// outside the document, be they links or in stylesheets and style attributes, entity declarations and stylesheet
+// processing instructions from the SVG. The content is returned as is if there is nothing to remove.
+func sanitizeSVG(content []byte) ([]byte, SanitizationReport, error) {
+	report := SanitizationReport{Removed: []SanitizationRemoval{}}
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	var out bytes.Buffer
+	skipDepth := 0
+	inStyle := false
+	scopes := []svgNamespaceScope{{}}
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, report, fmt.Errorf("failed to sanitize SVG: %v: %w", err, ErrInvalidSVG)
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			if skipDepth > 0 {
+				skipDepth++
+				continue
+			}
+			scope := scopes[len(scopes)-1].enter(element)
+			if !scope.isSafeElement(element.Name) || isUnsafeAnimation(element) {
+				report.add(SanitizationRemoval{Kind: RemovedElement, Element: qualifiedName(element.Name)})
+				skipDepth++
+				continue
+			}
+			scopes = append(scopes, scope)
+			out.WriteString("<" + qualifiedName(element.Name))
+			for _, attr := range element.Attr {
+				removal := SanitizationRemoval{Element: qualifiedName(element.Name), Attribute: qualifiedName(attr.Name), Value: attr.Value}
+				if isEventHandlerAttribute(attr.Name) {
+					removal.Kind = RemovedEventHandler
+					report.add(removal)
+					continue
+				}
+				if !scope.isSafeAttribute(attr.Name) {
+					removal.Kind = RemovedAttribute
+					report.add(removal)
+					continue
+				}
+				if scope.isReferenceAttribute(attr.Name) {
+					if isExternalReference(attr.Value) {
+						removal.Kind = RemovedExternalReference
+						report.add(removal)
+						continue
+					}
+				} else {
+					// Presentation attributes take url() references, too
+					var removedFromCSS []string
+					attr.Value, removedFromCSS = sanitizeCSS(attr.Value)
+					for _, reference := range removedFromCSS {
+						removal.Kind, removal.Value = RemovedExternalReference, reference
+						report.add(removal)
+					}
+				}
+				fmt.Fprintf(&out, " %s=\"%s\"", qualifiedName(attr.Name), escapeSVGAttribute(attr.Value))
+			}
+			out.WriteString(">")
+			inStyle = element.Name.Local == "style"
+		case xml.EndElement:
+			if skipDepth > 0 {
+				skipDepth--
+				continue
+			}
+			if len(scopes) > 1 {
+				scopes = scopes[:len(scopes)-1]
+			}
+			inStyle = false
+			out.WriteString("</" + qualifiedName(element.Name) + ">")
+		case xml.CharData:
+			if skipDepth == 0 {
+				text := string(element)
+				if inStyle {
+					var removedFromCSS []string
+					text, removedFromCSS = sanitizeCSS(text)
+					for _, reference := range removedFromCSS {
+						report.add(SanitizationRemoval{Kind: RemovedExternalReference, Element: "style", Value: reference})
+					}
+				}
+				out.WriteString(svgTextEscaper.Replace(text))
+			}
+		case xml.Comment:
+			if skipDepth == 0 {
+				out.WriteString("<!--" + string(element) + "-->")
+			}
+		case xml.ProcInst:
+			if element.Target == "xml-stylesheet" {
+				report.add(SanitizationRemoval{Kind: RemovedProcessingInstruction, Element: element.Target, Value: string(element.Inst)})
+				continue
+			}
+			if skipDepth == 0 {
+				out.WriteString("<?" + element.Target + " " + string(element.Inst) + "?>")
+			}
+		case xml.Directive:
+			if bytes.Contains(element, []byte("<!ENTITY")) {
+				report.add(SanitizationRemoval{Kind: RemovedEntityDeclaration, Value: string(element)})
+				continue
+			}
+			if skipDepth == 0 {
+				out.WriteString("<!" + string(element) + ">")
+			}
+		}
+	}
+	if report.Clean() {
+		return content, report, nil
+	}
+	return out.Bytes(), report, nil
+}
+
+// sanitizeIconfile sanitizes the content of SVG iconfiles. In strict mode, rather than being cleaned, iconfiles with
+// anything to remove are rejected with ErrUnsafeSVG.
+func (service *IconService) sanitizeIconfile(format string, content []byte) ([]byte, SanitizationReport, error) {
+	if format != "svg" {
+		return content, SanitizationReport{Removed: []SanitizationRemoval{}}, nil
+	}
+	sanitized, report, err := sanitizeSVG(content)
+	if err != nil {
+		return nil, report, err
+	}
+	if service.StrictSVGSanitization && !report.Clean() {
+		return nil, report, fmt.Errorf("%d unsafe item(s) found: %w", len(report.Removed), ErrUnsafeSVG)
+	}
+	return sanitized, report, nil
+}
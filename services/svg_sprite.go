@@ -0,0 +1,300 @@
+package services
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pdkovacs/igo-repo/domain"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrInvalidSVG is returned for SVG content which cannot be processed
+var ErrInvalidSVG = errors.New("invalid SVG")
+
+// spriteCache keeps the recently assembled sprites keyed by the content hashes of their iconfiles
+var spriteCache = newContentCache(16 << 20)
+
+// SpriteFilter selects the icons to assemble into a sprite. Without a size the largest SVG iconfile of each icon is used.
+type SpriteFilter struct {
+	Tags     []string
+	TagMatch string
+	Size     string
+}
+
+// Sprite is an SVG sprite along with the key identifying its content
+type Sprite struct {
+	Content []byte
+	Key     string
+}
+
+// svgRootAttributesToDrop are those attributes of the root svg element which don't apply to symbols
+var svgRootAttributesToDrop = map[string]bool{
+	"width": true, "height": true, "viewBox": true, "x": true, "y": true,
+	"id": true, "version": true, "baseProfile": true, "enable-background": true,
+}
+
+var svgTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+var urlReferencePattern = regexp.MustCompile(`url\(\s*['"]?#([^'")\s]+)['"]?\s*\)`)
+
+func escapeSVGAttribute(value string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(value))
+	return buf.String()
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// isForeignName tells whether the name belongs to some namespace other than SVG's own, XLink's or XML's
+// like the metadata of drawing applications
+func isForeignName(name xml.Name) bool {
+	return name.Space != "" && name.Space != "xlink" && name.Space != "xml" && name.Space != "xmlns"
+}
+
+func leadingNumber(value string) (float64, bool) {
+	value = strings.TrimSpace(value)
+	end := 0
+	for end < len(value) && strings.ContainsRune("+-.0123456789eE", rune(value[end])) {
+		end++
+	}
+	number, err := strconv.ParseFloat(value[:end], 64)
+	return number, err == nil
+}
+
+// parsedSVG is the root element of an SVG document along with the tokens of its content
+type parsedSVG struct {
+	root    xml.StartElement
+	content []xml.Token
+}
+
+func parseSVG(content []byte) (parsedSVG, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	parsed := parsedSVG{}
+	// RawToken leaves checking that elements are properly nested to the caller
+	openElements := []xml.Name{}
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return parsed, fmt.Errorf("%v: %w", err, ErrInvalidSVG)
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			openElements = append(openElements, element.Name)
+			if len(openElements) == 1 {
+				if element.Name.Local != "svg" {
+					return parsed, fmt.Errorf("root element is %s: %w", element.Name.Local, ErrInvalidSVG)
+				}
+				parsed.root = element.Copy()
+				continue
+			}
+		case xml.EndElement:
+			if len(openElements) == 0 || openElements[len(openElements)-1] != element.Name {
+				return parsed, fmt.Errorf("unexpected end element %s at offset %d: %w", qualifiedName(element.Name), decoder.InputOffset(), ErrInvalidSVG)
+			}
+			openElements = openElements[:len(openElements)-1]
+			if len(openElements) == 0 {
+				return parsed, nil
+			}
+		}
+		if len(openElements) > 0 {
+			parsed.content = append(parsed.content, xml.CopyToken(token))
+		}
+	}
+	if len(openElements) > 0 {
+		return parsed, fmt.Errorf("element %s is not closed: %w", qualifiedName(openElements[len(openElements)-1]), ErrInvalidSVG)
+	}
+	return parsed, fmt.Errorf("no svg element found: %w", ErrInvalidSVG)
+}
+
+func (svg parsedSVG) attribute(name string) string {
+	for _, attr := range svg.root.Attr {
+		if attr.Name.Space == "" && attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// viewBox returns the viewBox of the SVG falling back to one spanning its width and height
+func (svg parsedSVG) viewBox() (string, error) {
+	if viewBox := svg.attribute("viewBox"); viewBox != "" {
+		return viewBox, nil
+	}
+	dimensions, err := svg.dimensions()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("0 0 %s %s", strconv.FormatFloat(dimensions.width, 'f', -1, 64), strconv.FormatFloat(dimensions.height, 'f', -1, 64)), nil
+}
+
+func (svg parsedSVG) ids() map[string]bool {
+	ids := map[string]bool{}
+	for _, token := range svg.content {
+		if element, ok := token.(xml.StartElement); ok {
+			for _, attr := range element.Attr {
+				if attr.Name.Space == "" && attr.Name.Local == "id" {
+					ids[attr.Value] = true
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// namespaceReferences prefixes the references in the attribute value to the IDs defined in the icon
+func namespaceReferences(attr xml.Attr, ids map[string]bool, prefix string) string {
+	if attr.Name.Local == "href" && strings.HasPrefix(attr.Value, "#") && ids[attr.Value[1:]] {
+		return "#" + prefix + attr.Value[1:]
+	}
+	return urlReferencePattern.ReplaceAllStringFunc(attr.Value, func(reference string) string {
+		id := urlReferencePattern.FindStringSubmatch(reference)[1]
+		if !ids[id] {
+			return reference
+		}
+		return "url(#" + prefix + id + ")"
+	})
+}
+
+func writeSymbol(out *bytes.Buffer, symbolID string, svg parsedSVG) error {
+	viewBox, err := svg.viewBox()
+	if err != nil {
+		return err
+	}
+	ids := svg.ids()
+	idPrefix := symbolID + "--"
+
+	fmt.Fprintf(out, "<symbol id=\"%s\" viewBox=\"%s\"", escapeSVGAttribute(symbolID), escapeSVGAttribute(viewBox))
+	for _, attr := range svg.root.Attr {
+		if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") ||
+			isForeignName(attr.Name) || svgRootAttributesToDrop[attr.Name.Local] {
+			continue
+		}
+		fmt.Fprintf(out, " %s=\"%s\"", qualifiedName(attr.Name), escapeSVGAttribute(attr.Value))
+	}
+	out.WriteString(">")
+
+	skipDepth := 0
+	for _, token := range svg.content {
+		switch element := token.(type) {
+		case xml.StartElement:
+			if skipDepth > 0 || isForeignName(element.Name) || element.Name.Local == "metadata" {
+				skipDepth++
+				continue
+			}
+			out.WriteString("<" + qualifiedName(element.Name))
+			for _, attr := range element.Attr {
+				if isForeignName(attr.Name) || attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+					continue
+				}
+				value := attr.Value
+				if attr.Name.Space == "" && attr.Name.Local == "id" {
+					value = idPrefix + value
+				} else {
+					value = namespaceReferences(attr, ids, idPrefix)
+				}
+				fmt.Fprintf(out, " %s=\"%s\"", qualifiedName(attr.Name), escapeSVGAttribute(value))
+			}
+			out.WriteString(">")
+		case xml.EndElement:
+			if skipDepth > 0 {
+				skipDepth--
+				continue
+			}
+			out.WriteString("</" + qualifiedName(element.Name) + ">")
+		case xml.CharData:
+			if skipDepth == 0 {
+				out.WriteString(svgTextEscaper.Replace(string(element)))
+			}
+		}
+	}
+	out.WriteString("</symbol>\n")
+	return nil
+}
+
+// selectSpriteIconfile picks the SVG iconfile of the requested size or, without one, the largest SVG iconfile
+func selectSpriteIconfile(icon domain.IconDescriptor, size string) (domain.IconfileDescriptor, bool) {
+	var selected domain.IconfileDescriptor
+	found := false
+	largest := -1.0
+	for _, iconfile := range icon.Iconfiles {
+		if iconfile.Format != "svg" {
+			continue
+		}
+		if size != "" {
+			if iconfile.Size == size {
+				return iconfile, true
+			}
+			continue
+		}
+		if number, _ := leadingNumber(iconfile.Size); !found || number > largest {
+			selected, largest, found = iconfile, number, true
+		}
+	}
+	return selected, found
+}
+
+// GetSprite assembles the SVG iconfiles of the selected icons into a sprite with a symbol for each icon identified
+// by the icon's name. IDs within the icons are prefixed with the icon's name to avoid collisions.
+func (service *IconService) GetSprite(filter SpriteFilter) (Sprite, error) {
+	logger := log.WithField("prefix", "GetSprite")
+	page, err := service.QueryIcons(domain.IconQuery{Tags: filter.Tags, TagMatch: filter.TagMatch, Format: "svg", Size: filter.Size})
+	if err != nil {
+		return Sprite{}, err
+	}
+
+	iconNames := []string{}
+	iconfiles := map[string]domain.StoredIconfile{}
+	var keyBase strings.Builder
+	for _, icon := range page.Icons {
+		descriptor, found := selectSpriteIconfile(icon, filter.Size)
+		if !found {
+			continue
+		}
+		iconfile, getErr := service.GetIconfile(icon.Name, descriptor)
+		if getErr != nil {
+			return Sprite{}, fmt.Errorf("failed to assemble sprite: %w", getErr)
+		}
+		iconNames = append(iconNames, icon.Name)
+		iconfiles[icon.Name] = iconfile
+	}
+	sort.Strings(iconNames)
+	for _, iconName := range iconNames {
+		fmt.Fprintf(&keyBase, "%s:%s\n", iconName, iconfiles[iconName].ContentHash)
+	}
+	key := domain.ContentHash([]byte(keyBase.String()))
+
+	if content, cached := spriteCache.get(key); cached {
+		return Sprite{Content: content, Key: key}, nil
+	}
+
+	var out bytes.Buffer
+	out.WriteString("<svg xmlns=\"http://www.w3.org/2000/svg\" xmlns:xlink=\"http://www.w3.org/1999/xlink\">\n")
+	for _, iconName := range iconNames {
+		svg, parseErr := parseSVG(iconfiles[iconName].Content)
+		if parseErr == nil {
+			parseErr = writeSymbol(&out, iconName, svg)
+		}
+		if parseErr != nil {
+			logger.Warnf("leaving %s out of the sprite: %v", iconName, parseErr)
+		}
+	}
+	out.WriteString("</svg>\n")
+
+	spriteCache.put(key, out.Bytes())
+	return Sprite{Content: out.Bytes(), Key: key}, nil
+}
@@ -0,0 +1,505 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"unicode/utf16"
+
+	"github.com/andybalholm/brotli"
+)
+
+// ttPoint is a point of a TrueType glyph outline
+type ttPoint struct {
+	x, y    int
+	onCurve bool
+}
+
+type ttContour []ttPoint
+
+// ttGlyph is a TrueType glyph with its advance width
+type ttGlyph struct {
+	contours []ttContour
+	advance  int
+}
+
+func (g ttGlyph) bounds() (xMin, yMin, xMax, yMax int, empty bool) {
+	xMin, yMin, xMax, yMax = math.MaxInt32, math.MaxInt32, math.MinInt32, math.MinInt32
+	empty = true
+	for _, contour := range g.contours {
+		for _, p := range contour {
+			empty = false
+			if p.x < xMin {
+				xMin = p.x
+			}
+			if p.x > xMax {
+				xMax = p.x
+			}
+			if p.y < yMin {
+				yMin = p.y
+			}
+			if p.y > yMax {
+				yMax = p.y
+			}
+		}
+	}
+	if empty {
+		return 0, 0, 0, 0, true
+	}
+	return xMin, yMin, xMax, yMax, false
+}
+
+// ttFont is the data a TrueType font is built from. The first glyph is the .notdef glyph.
+type ttFont struct {
+	familyName string
+	unitsPerEm int
+	ascent     int
+	descent    int
+	glyphs     []ttGlyph
+	// glyphsByCodepoint maps Unicode codepoints to glyph indexes
+	glyphsByCodepoint map[int]int
+}
+
+// fontTable is a table of an sfnt font
+type fontTable struct {
+	tag  string
+	data []byte
+}
+
+func writeValues(buf *bytes.Buffer, values ...interface{}) {
+	for _, value := range values {
+		_ = binary.Write(buf, binary.BigEndian, value)
+	}
+}
+
+func tableChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+func padTo4(data []byte) []byte {
+	for len(data)%4 != 0 {
+		data = append(data, 0)
+	}
+	return data
+}
+
+func log2Floor(n int) int {
+	result := 0
+	for n > 1 {
+		n >>= 1
+		result++
+	}
+	return result
+}
+
+func (font ttFont) sortedCodepoints() []int {
+	codepoints := make([]int, 0, len(font.glyphsByCodepoint))
+	for codepoint := range font.glyphsByCodepoint {
+		codepoints = append(codepoints, codepoint)
+	}
+	sort.Ints(codepoints)
+	return codepoints
+}
+
+func (font ttFont) glyfAndLoca() ([]byte, []byte) {
+	var glyf, loca bytes.Buffer
+	for _, glyph := range font.glyphs {
+		writeValues(&loca, uint32(glyf.Len()))
+		xMin, yMin, xMax, yMax, empty := glyph.bounds()
+		if empty {
+			continue
+		}
+		writeValues(&glyf, int16(len(glyph.contours)), int16(xMin), int16(yMin), int16(xMax), int16(yMax))
+		endPoint := -1
+		for _, contour := range glyph.contours {
+			endPoint += len(contour)
+			writeValues(&glyf, uint16(endPoint))
+		}
+		writeValues(&glyf, uint16(0)) // no instructions
+
+		var flags, xs, ys bytes.Buffer
+		previous := ttPoint{}
+		for _, contour := range glyph.contours {
+			for _, p := range contour {
+				var flag byte
+				if p.onCurve {
+					flag |= 0x01
+				}
+				dx, dy := p.x-previous.x, p.y-previous.y
+				switch {
+				case dx == 0:
+					flag |= 0x10
+				case dx > -256 && dx < 256:
+					flag |= 0x02
+					if dx > 0 {
+						flag |= 0x10
+						xs.WriteByte(byte(dx))
+					} else {
+						xs.WriteByte(byte(-dx))
+					}
+				default:
+					writeValues(&xs, int16(dx))
+				}
+				switch {
+				case dy == 0:
+					flag |= 0x20
+				case dy > -256 && dy < 256:
+					flag |= 0x04
+					if dy > 0 {
+						flag |= 0x20
+						ys.WriteByte(byte(dy))
+					} else {
+						ys.WriteByte(byte(-dy))
+					}
+				default:
+					writeValues(&ys, int16(dy))
+				}
+				flags.WriteByte(flag)
+				previous = p
+			}
+		}
+		glyf.Write(flags.Bytes())
+		glyf.Write(xs.Bytes())
+		glyf.Write(ys.Bytes())
+		for glyf.Len()%4 != 0 {
+			glyf.WriteByte(0)
+		}
+	}
+	writeValues(&loca, uint32(glyf.Len()))
+	return glyf.Bytes(), loca.Bytes()
+}
+
+func (font ttFont) cmap() []byte {
+	codepoints := font.sortedCodepoints()
+
+	// Format 4 covers the Basic Multilingual Plane with runs of consecutive codepoints mapped to consecutive glyphs
+	type segment struct{ start, end, glyph int }
+	segments := []segment{}
+	for _, codepoint := range codepoints {
+		if codepoint > 0xFFFE {
+			break
+		}
+		glyph := font.glyphsByCodepoint[codepoint]
+		if n := len(segments); n > 0 && segments[n-1].end == codepoint-1 && segments[n-1].glyph+codepoint-segments[n-1].start == glyph {
+			segments[n-1].end = codepoint
+			continue
+		}
+		segments = append(segments, segment{codepoint, codepoint, glyph})
+	}
+	segments = append(segments, segment{0xFFFF, 0xFFFF, 0})
+	segCount := len(segments)
+	searchRange := 2 * (1 << log2Floor(segCount))
+	var format4 bytes.Buffer
+	writeValues(&format4, uint16(4), uint16(16+8*segCount), uint16(0),
+		uint16(2*segCount), uint16(searchRange), uint16(log2Floor(segCount)), uint16(2*segCount-searchRange))
+	for _, s := range segments {
+		writeValues(&format4, uint16(s.end))
+	}
+	writeValues(&format4, uint16(0))
+	for _, s := range segments {
+		writeValues(&format4, uint16(s.start))
+	}
+	for _, s := range segments {
+		if s.start == 0xFFFF {
+			writeValues(&format4, uint16(1))
+			continue
+		}
+		writeValues(&format4, uint16(s.glyph-s.start))
+	}
+	for range segments {
+		writeValues(&format4, uint16(0))
+	}
+
+	// Format 12 is needed only for codepoints beyond the Basic Multilingual Plane
+	var format12 bytes.Buffer
+	if len(codepoints) > 0 && codepoints[len(codepoints)-1] > 0xFFFF {
+		writeValues(&format12, uint16(12), uint16(0), uint32(16+12*len(codepoints)), uint32(0), uint32(len(codepoints)))
+		for _, codepoint := range codepoints {
+			writeValues(&format12, uint32(codepoint), uint32(codepoint), uint32(font.glyphsByCodepoint[codepoint]))
+		}
+	}
+
+	var cmap bytes.Buffer
+	if format12.Len() == 0 {
+		writeValues(&cmap, uint16(0), uint16(2))
+		writeValues(&cmap, uint16(0), uint16(3), uint32(20))
+		writeValues(&cmap, uint16(3), uint16(1), uint32(20))
+		cmap.Write(format4.Bytes())
+		return cmap.Bytes()
+	}
+	format4Offset := uint32(4 + 8*4)
+	format12Offset := format4Offset + uint32(format4.Len())
+	writeValues(&cmap, uint16(0), uint16(4))
+	writeValues(&cmap, uint16(0), uint16(3), format4Offset)
+	writeValues(&cmap, uint16(0), uint16(4), format12Offset)
+	writeValues(&cmap, uint16(3), uint16(1), format4Offset)
+	writeValues(&cmap, uint16(3), uint16(10), format12Offset)
+	cmap.Write(format4.Bytes())
+	cmap.Write(format12.Bytes())
+	return cmap.Bytes()
+}
+
+func (font ttFont) name() []byte {
+	postScriptName := []rune{}
+	for _, r := range font.familyName {
+		if r > 32 && r < 127 && r != '[' && r != ']' && r != '(' && r != ')' && r != '{' && r != '}' &&
+			r != '<' && r != '>' && r != '/' && r != '%' {
+			postScriptName = append(postScriptName, r)
+		}
+	}
+	names := []string{
+		1: font.familyName,
+		2: "Regular",
+		3: font.familyName + " Regular",
+		4: font.familyName,
+		5: "Version 1.0",
+		6: string(postScriptName),
+	}
+	var records, strs bytes.Buffer
+	for id := 1; id < len(names); id++ {
+		encoded := utf16.Encode([]rune(names[id]))
+		writeValues(&records, uint16(3), uint16(1), uint16(0x0409), uint16(id), uint16(2*len(encoded)), uint16(strs.Len()))
+		writeValues(&strs, encoded)
+	}
+	var name bytes.Buffer
+	writeValues(&name, uint16(0), uint16(len(names)-1), uint16(6+records.Len()))
+	name.Write(records.Bytes())
+	name.Write(strs.Bytes())
+	return name.Bytes()
+}
+
+// tables builds the tables of the font sorted by tag
+func (font ttFont) tables() []fontTable {
+	glyf, loca := font.glyfAndLoca()
+
+	xMin, yMin, xMax, yMax := 0, 0, 0, 0
+	maxPoints, maxContours, advanceMax := 0, 0, 0
+	minLSB, minRSB, maxExtent := math.MaxInt16, math.MaxInt16, 0
+	var hmtx bytes.Buffer
+	for i, glyph := range font.glyphs {
+		gxMin, gyMin, gxMax, gyMax, empty := glyph.bounds()
+		writeValues(&hmtx, uint16(glyph.advance), int16(gxMin))
+		if glyph.advance > advanceMax {
+			advanceMax = glyph.advance
+		}
+		if empty {
+			continue
+		}
+		if i == 0 || gxMin < xMin {
+			xMin = gxMin
+		}
+		if gyMin < yMin {
+			yMin = gyMin
+		}
+		if gxMax > xMax {
+			xMax = gxMax
+		}
+		if gyMax > yMax {
+			yMax = gyMax
+		}
+		points := 0
+		for _, contour := range glyph.contours {
+			points += len(contour)
+		}
+		if points > maxPoints {
+			maxPoints = points
+		}
+		if len(glyph.contours) > maxContours {
+			maxContours = len(glyph.contours)
+		}
+		if gxMin < minLSB {
+			minLSB = gxMin
+		}
+		if glyph.advance-gxMax < minRSB {
+			minRSB = glyph.advance - gxMax
+		}
+		if gxMax > maxExtent {
+			maxExtent = gxMax
+		}
+	}
+	if minLSB == math.MaxInt16 {
+		minLSB, minRSB = 0, 0
+	}
+
+	var head bytes.Buffer
+	writeValues(&head, uint32(0x00010000), uint32(0x00010000), uint32(0), uint32(0x5F0F3CF5),
+		uint16(0x000B), uint16(font.unitsPerEm), int64(0), int64(0),
+		int16(xMin), int16(yMin), int16(xMax), int16(yMax),
+		uint16(0), uint16(8), int16(2), int16(1), int16(0))
+
+	var hhea bytes.Buffer
+	writeValues(&hhea, uint32(0x00010000), int16(font.ascent), int16(-font.descent), int16(0),
+		uint16(advanceMax), int16(minLSB), int16(minRSB), int16(maxExtent),
+		int16(1), int16(0), int16(0), int16(0), int16(0), int16(0), int16(0), int16(0), uint16(len(font.glyphs)))
+
+	var maxp bytes.Buffer
+	writeValues(&maxp, uint32(0x00010000), uint16(len(font.glyphs)), uint16(maxPoints), uint16(maxContours),
+		uint16(0), uint16(0), uint16(2), uint16(0), uint16(0), uint16(0), uint16(0), uint16(0), uint16(0), uint16(0), uint16(0))
+
+	codepoints := font.sortedCodepoints()
+	firstChar, lastChar := 0xFFFF, 0
+	if len(codepoints) > 0 {
+		firstChar = codepoints[0]
+		lastChar = codepoints[len(codepoints)-1]
+	}
+	if firstChar > 0xFFFF {
+		firstChar = 0xFFFF
+	}
+	if lastChar > 0xFFFF {
+		lastChar = 0xFFFF
+	}
+	averageWidth := 0
+	if len(font.glyphs) > 0 {
+		total := 0
+		for _, glyph := range font.glyphs {
+			total += glyph.advance
+		}
+		averageWidth = total / len(font.glyphs)
+	}
+	var os2 bytes.Buffer
+	writeValues(&os2, uint16(4), int16(averageWidth), uint16(400), uint16(5), uint16(0),
+		int16(650), int16(700), int16(0), int16(140), int16(650), int16(700), int16(0), int16(480), int16(50), int16(250),
+		int16(0), [10]byte{},
+		// Private Use Area
+		uint32(0), uint32(1<<28), uint32(0), uint32(0),
+		[4]byte{'i', 'g', 'o', ' '}, uint16(0x0040), uint16(firstChar), uint16(lastChar),
+		int16(font.ascent), int16(-font.descent), int16(0), uint16(font.ascent), uint16(font.descent),
+		uint32(1), uint32(0), int16(0), int16(0), uint16(0), uint16(32), uint16(0))
+
+	var post bytes.Buffer
+	writeValues(&post, uint32(0x00030000), uint32(0), int16(-75), int16(50), uint32(0), uint32(0), uint32(0), uint32(0), uint32(0))
+
+	return []fontTable{
+		{"OS/2", os2.Bytes()},
+		{"cmap", font.cmap()},
+		{"glyf", glyf},
+		{"head", head.Bytes()},
+		{"hhea", hhea.Bytes()},
+		{"hmtx", hmtx.Bytes()},
+		{"loca", loca},
+		{"maxp", maxp.Bytes()},
+		{"name", font.name()},
+		{"post", post.Bytes()},
+	}
+}
+
+// sfnt assembles the tables into a TrueType font file setting the checksum adjustment in the head table
+func sfnt(tables []fontTable) []byte {
+	numTables := len(tables)
+	searchRange := 16 * (1 << log2Floor(numTables))
+	var out bytes.Buffer
+	writeValues(&out, uint32(0x00010000), uint16(numTables), uint16(searchRange), uint16(log2Floor(numTables)), uint16(16*numTables-searchRange))
+	offset := 12 + 16*numTables
+	headOffset := 0
+	for _, table := range tables {
+		writeValues(&out, []byte(table.tag), tableChecksum(table.data), uint32(offset), uint32(len(table.data)))
+		if table.tag == "head" {
+			headOffset = offset
+		}
+		offset += len(padTo4(append([]byte{}, table.data...)))
+	}
+	for _, table := range tables {
+		out.Write(padTo4(append([]byte{}, table.data...)))
+	}
+	font := out.Bytes()
+	checksumAdjustment := 0xB1B0AFBA - tableChecksum(font)
+	binary.BigEndian.PutUint32(font[headOffset+8:], checksumAdjustment)
+	for _, table := range tables {
+		if table.tag == "head" {
+			binary.BigEndian.PutUint32(table.data[8:], checksumAdjustment)
+		}
+	}
+	return font
+}
+
+// woff2KnownTags are the tags with an index in the WOFF2 table directory
+var woff2KnownTags = map[string]byte{
+	"cmap": 0, "head": 1, "hhea": 2, "hmtx": 3, "maxp": 4, "name": 5, "OS/2": 6, "post": 7,
+	"cvt ": 8, "fpgm": 9, "glyf": 10, "loca": 11, "prep": 12, "CFF ": 13, "VORG": 14, "EBDT": 15,
+	"EBLC": 16, "gasp": 17, "hdmx": 18, "kern": 19, "LTSH": 20, "PCLT": 21, "VDMX": 22, "vhea": 23,
+	"vmtx": 24, "BASE": 25, "GDEF": 26, "GPOS": 27, "GSUB": 28,
+}
+
+func writeUIntBase128(buf *bytes.Buffer, value uint32) {
+	var groups []byte
+	for {
+		groups = append([]byte{byte(value & 0x7F)}, groups...)
+		value >>= 7
+		if value == 0 {
+			break
+		}
+	}
+	for i := 0; i < len(groups)-1; i++ {
+		groups[i] |= 0x80
+	}
+	buf.Write(groups)
+}
+
+// woff2 compresses the tables of a TrueType font into WOFF2 format. The tables are expected to have been
+// assembled with sfnt so that they carry the checksum adjustment.
+// The glyf and loca tables are stored with the null transform which WOFF2 decoders pass through as is.
+func woff2(tables []fontTable) ([]byte, error) {
+	// loca must immediately follow glyf in the table directory
+	var loca fontTable
+	for _, table := range tables {
+		if table.tag == "loca" {
+			loca = table
+		}
+	}
+	ordered := []fontTable{}
+	for _, table := range tables {
+		if table.tag == "loca" {
+			continue
+		}
+		ordered = append(ordered, table)
+		if table.tag == "glyf" {
+			ordered = append(ordered, loca)
+		}
+	}
+
+	var directory, stream bytes.Buffer
+	totalSfntSize := 12 + 16*len(tables)
+	for _, table := range ordered {
+		flags, known := woff2KnownTags[table.tag]
+		if !known {
+			flags = 63
+		}
+		if table.tag == "glyf" || table.tag == "loca" {
+			flags |= 3 << 6
+		}
+		directory.WriteByte(flags)
+		if !known {
+			directory.WriteString(table.tag)
+		}
+		writeUIntBase128(&directory, uint32(len(table.data)))
+		stream.Write(table.data)
+		totalSfntSize += len(padTo4(append([]byte{}, table.data...)))
+	}
+
+	var compressed bytes.Buffer
+	writer := brotli.NewWriterLevel(&compressed, brotli.BestCompression)
+	_, err := writer.Write(stream.Bytes())
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress font tables: %w", err)
+	}
+
+	length := len(padTo4(make([]byte, 48+directory.Len()+compressed.Len())))
+	var out bytes.Buffer
+	writeValues(&out, []byte("wOF2"), uint32(0x00010000), uint32(length), uint16(len(tables)), uint16(0),
+		uint32(totalSfntSize), uint32(compressed.Len()), uint16(1), uint16(0),
+		uint32(0), uint32(0), uint32(0), uint32(0), uint32(0))
+	out.Write(directory.Bytes())
+	out.Write(compressed.Bytes())
+	return padTo4(out.Bytes()), nil
+}
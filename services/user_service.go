@@ -27,12 +27,25 @@ func (us *UserService) getPermissionsForUser(userId authn.UserID, memberIn []aut
 	userPermissions := []authr.PermissionID{}
 
 	for _, group := range memberIn {
-		userPermissions = append(userPermissions, authr.GetPermissionsForGroup(group)...)
+		for _, permission := range us.authorizationService.GetPermissionsForGroup(group) {
+			if !containsPermission(userPermissions, permission) {
+				userPermissions = append(userPermissions, permission)
+			}
+		}
 	}
 
 	return userPermissions
 }
 
+func containsPermission(permissions []authr.PermissionID, permission authr.PermissionID) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
 func (us *UserService) getDisplayName(userId authn.UserID) string {
 	return userId.String()
 }
@@ -46,3 +59,20 @@ func (us *UserService) GetUserInfo(userId authn.UserID) UserInfo {
 		DisplayName: us.getDisplayName(userId),
 	}
 }
+
+// GetUserInfoWithGroups is like GetUserInfo, but it also takes into account the groups some trusted party
+// (like the issuer of a token presented by the user) asserts the user is member of
+func (us *UserService) GetUserInfoWithGroups(userId authn.UserID, groupClaimValues []string) UserInfo {
+	memberIn := us.authorizationService.GetGroupsForUser(userId)
+	for _, assertedGroup := range us.authorizationService.MapClaimedGroups(groupClaimValues) {
+		if !containsGroup(memberIn, assertedGroup) {
+			memberIn = append(memberIn, assertedGroup)
+		}
+	}
+	return UserInfo{
+		UserId:      userId,
+		Groups:      memberIn,
+		Permissions: us.getPermissionsForUser(userId, memberIn),
+		DisplayName: us.getDisplayName(userId),
+	}
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"testing"
+	"time"
+
+	"github.com/pdkovacs/igo-repo/api"
+	"github.com/pdkovacs/igo-repo/domain"
+	"github.com/pdkovacs/igo-repo/security/authr"
+	"github.com/pdkovacs/igo-repo/services"
+	"github.com/pdkovacs/igo-repo/test/api/testdata"
+	"github.com/pdkovacs/igo-repo/test/common"
+	"github.com/stretchr/testify/suite"
+)
+
+type accessTokenTestSuite struct {
+	apiTestSuite
+}
+
+func TestAccessTokenTestSuite(t *testing.T) {
+	suite.Run(t, &accessTokenTestSuite{})
+}
+
+func (s *accessTokenTestSuite) BeforeTest(suiteName string, testName string) {
+	serverConfig := common.CloneConfig(s.defaultConfig)
+	serverConfig.EnableBackdoors = true
+	serverConfig.UsersByRoles = map[string][]string{
+		string(authr.ICON_EDITOR): {testdata.DefaultCredentials.Username},
+	}
+	s.startTestServer(serverConfig)
+}
+
+func (s *accessTokenTestSuite) mustCreateAccessToken(session *apiTestSession, permissions []authr.PermissionID, expiresAt time.Time) api.CreateAccessTokenResponse {
+	statusCode, token, err := session.createAccessToken(api.CreateAccessTokenRequestData{
+		Label:       "ci",
+		Permissions: permissions,
+		ExpiresAt:   expiresAt,
+	})
+	s.Require().NoError(err)
+	s.Require().Equal(201, statusCode)
+	return token
+}
+
+func (s *accessTokenTestSuite) getUserInfoWithToken(token string) testResponse {
+	credentials := makeBearerRequestCredentials(token)
+	resp, err := s.client.get(&testRequest{
+		path:          "/user",
+		credentials:   &credentials,
+		respBodyProto: &services.UserInfo{},
+	})
+	if resp.statusCode == 200 {
+		s.NoError(err)
+	}
+	return resp
+}
+
+func (s *accessTokenTestSuite) createIconWithToken(token string, iconName string) int {
+	iconfileContent := testdata.GetDemoIconfileContent(iconName, domain.IconfileDescriptor{Format: "png", Size: "36dp"})
+
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	fw, err := w.CreateFormField("iconName")
+	s.Require().NoError(err)
+	_, err = fw.Write([]byte(iconName))
+	s.Require().NoError(err)
+	fw, err = w.CreateFormFile("iconfile", iconName)
+	s.Require().NoError(err)
+	_, err = io.Copy(fw, bytes.NewReader(iconfileContent))
+	s.Require().NoError(err)
+	w.Close()
+
+	credentials := makeBearerRequestCredentials(token)
+	resp, _ := s.client.post(&testRequest{
+		path:        "/icon",
+		credentials: &credentials,
+		headers:     map[string]string{"Content-Type": w.FormDataContentType()},
+		body:        b.Bytes(),
+	})
+	return resp.statusCode
+}
+
+func (s *accessTokenTestSuite) TestShouldAuthenticateWithTokenRestrictedToItsScope() {
+	session := s.client.mustLogin(nil)
+	token := s.mustCreateAccessToken(session, []authr.PermissionID{authr.ADD_TAG}, time.Now().Add(time.Hour))
+	s.True(services.IsAccessToken(token.Token))
+	s.Equal([]authr.PermissionID{authr.ADD_TAG}, token.Permissions)
+
+	resp := s.getUserInfoWithToken(token.Token)
+	s.Equal(200, resp.statusCode)
+	userInfo := resp.body.(*services.UserInfo)
+	s.Equal(testdata.DefaultCredentials.Username, userInfo.UserId.IDInDomain)
+	s.Equal([]authr.PermissionID{authr.ADD_TAG}, userInfo.Permissions)
+	_, hasCookie := resp.headers["Set-Cookie"]
+	s.False(hasCookie)
+
+	s.Equal(403, s.createIconWithToken(token.Token, "dock"))
+}
+
+func (s *accessTokenTestSuite) TestShouldAllowIconCreationWithTokenInScope() {
+	session := s.client.mustLogin(nil)
+	token := s.mustCreateAccessToken(session, []authr.PermissionID{authr.CREATE_ICON}, time.Now().Add(time.Hour))
+	s.Equal(201, s.createIconWithToken(token.Token, "dock"))
+}
+
+func (s *accessTokenTestSuite) TestShouldNotCreateTokenWithPermissionsBeyondTheUsers() {
+	session := s.client.mustLogin(nil)
+	session.mustSetAuthorization([]authr.PermissionID{authr.ADD_TAG})
+	statusCode, _, _ := session.createAccessToken(api.CreateAccessTokenRequestData{
+		Label:       "ci",
+		Permissions: []authr.PermissionID{authr.REMOVE_ICON},
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	s.Equal(403, statusCode)
+}
+
+func (s *accessTokenTestSuite) TestShouldNotCreateTokenWithUnknownPermissionOrPastExpiry() {
+	session := s.client.mustLogin(nil)
+	statusCode, _, _ := session.createAccessToken(api.CreateAccessTokenRequestData{
+		Label:       "ci",
+		Permissions: []authr.PermissionID{"FLY_TO_THE_MOON"},
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	s.Equal(400, statusCode)
+
+	statusCode, _, _ = session.createAccessToken(api.CreateAccessTokenRequestData{
+		Label:       "ci",
+		Permissions: []authr.PermissionID{authr.ADD_TAG},
+		ExpiresAt:   time.Now().Add(-time.Hour),
+	})
+	s.Equal(400, statusCode)
+}
+
+func (s *accessTokenTestSuite) TestShouldNotCreateTokenWithToken() {
+	session := s.client.mustLogin(nil)
+	token := s.mustCreateAccessToken(session, []authr.PermissionID{authr.ADD_TAG}, time.Now().Add(time.Hour))
+
+	credentials := makeBearerRequestCredentials(token.Token)
+	resp, _ := s.client.post(&testRequest{
+		path:        "/user/tokens",
+		credentials: &credentials,
+		json:        true,
+		body: api.CreateAccessTokenRequestData{
+			Label:       "ci-2",
+			Permissions: []authr.PermissionID{authr.ADD_TAG},
+			ExpiresAt:   time.Now().Add(time.Hour),
+		},
+	})
+	s.Equal(403, resp.statusCode)
+}
+
+func (s *accessTokenTestSuite) TestShouldListTokensWithoutSecrets() {
+	session := s.client.mustLogin(nil)
+	token := s.mustCreateAccessToken(session, []authr.PermissionID{authr.ADD_TAG}, time.Now().Add(time.Hour))
+
+	s.Equal(200, s.getUserInfoWithToken(token.Token).statusCode)
+
+	tokens, err := session.listAccessTokens()
+	s.NoError(err)
+	s.Equal(1, len(tokens))
+	s.Equal(token.ID, tokens[0].ID)
+	s.Equal("ci", tokens[0].Label)
+	s.NotNil(tokens[0].LastUsedAt)
+}
+
+func (s *accessTokenTestSuite) TestShouldRejectRevokedToken() {
+	session := s.client.mustLogin(nil)
+	token := s.mustCreateAccessToken(session, []authr.PermissionID{authr.ADD_TAG}, time.Now().Add(time.Hour))
+
+	statusCode, err := session.revokeAccessToken(token.ID)
+	s.NoError(err)
+	s.Equal(204, statusCode)
+
+	resp := s.getUserInfoWithToken(token.Token)
+	s.Equal(401, resp.statusCode)
+	s.Equal(`Bearer error="invalid_token"`, resp.headers["Www-Authenticate"][0])
+
+	statusCode, err = session.revokeAccessToken(token.ID)
+	s.NoError(err)
+	s.Equal(404, statusCode)
+}
+
+func (s *accessTokenTestSuite) TestShouldRejectExpiredToken() {
+	session := s.client.mustLogin(nil)
+	token := s.mustCreateAccessToken(session, []authr.PermissionID{authr.ADD_TAG}, time.Now().Add(2*time.Second))
+	time.Sleep(3 * time.Second)
+	s.Equal(401, s.getUserInfoWithToken(token.Token).statusCode)
+}
+
+func (s *accessTokenTestSuite) TestShouldRejectUnknownToken() {
+	s.Equal(401, s.getUserInfoWithToken(services.AccessTokenPrefix+"no-such-token").statusCode)
+}
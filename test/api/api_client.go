@@ -125,6 +125,14 @@ func (c *apiTestClient) sendRequest(method string, req *testRequest) (testRespon
 		if responseReadError != nil {
 			return testResponse{}, fmt.Errorf("failed to read response body: %w", responseReadError)
 		}
+		if rawBody, isRaw := req.respBodyProto.(*[]byte); isRaw {
+			*rawBody = byteBody
+			return testResponse{
+				headers:    resp.Header,
+				statusCode: resp.StatusCode,
+				body:       rawBody,
+			}, nil
+		}
 		jsonUnmarshalError := json.Unmarshal(byteBody, req.respBodyProto)
 		// TODO: We should somehow better handle unmarshalling failed calls as well...
 		//       ... using some standard error JSON for example?
@@ -7,12 +7,14 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/pdkovacs/igo-repo/api"
 	"github.com/pdkovacs/igo-repo/config"
 	"github.com/pdkovacs/igo-repo/domain"
 	"github.com/pdkovacs/igo-repo/security/authr"
+	"github.com/pdkovacs/igo-repo/services"
 	"github.com/pdkovacs/igo-repo/test/api/testdata"
 )
 
@@ -165,6 +167,25 @@ func (session *apiTestSession) describeAllIcons() ([]api.ResponseIcon, error) {
 	return *icons, err
 }
 
+func (session *apiTestSession) queryIcons(query string) (testResponse, []api.ResponseIcon, error) {
+	resp, err := session.get(&testRequest{
+		path:          "/icon?" + query,
+		jar:           session.cjar,
+		respBodyProto: &[]api.ResponseIcon{},
+	})
+	if err != nil {
+		return resp, []api.ResponseIcon{}, fmt.Errorf("GET /icon?%s failed: %w", query, err)
+	}
+	if resp.statusCode != 200 {
+		return resp, []api.ResponseIcon{}, fmt.Errorf("%w: got %d", errUnexpecteHTTPStatus, resp.statusCode)
+	}
+	icons, ok := resp.body.(*[]api.ResponseIcon)
+	if !ok {
+		return resp, []api.ResponseIcon{}, fmt.Errorf("failed to cast %T as []api.ResponseIcon", resp.body)
+	}
+	return resp, *icons, nil
+}
+
 func (session *apiTestSession) mustDescribeAllIcons() []api.ResponseIcon {
 	respIcons, err := session.describeAllIcons()
 	if err != nil {
@@ -189,11 +210,10 @@ func (session *apiTestSession) describeIcon(iconName string) (int, api.ResponseI
 	return resp.statusCode, *icon, err
 }
 
+// iconfileUploadForm makes the multipart form icons are created and iconfiles are added with
 // https://stackoverflow.com/questions/20205796/post-data-using-the-content-type-multipart-form-data
-func (session *apiTestSession) createIcon(iconName string, initialIconfile []byte) (int, api.ResponseIcon, error) {
+func iconfileUploadForm(iconName string, content []byte) ([]byte, map[string]string) {
 	var err error
-	var resp testResponse
-
 	var b bytes.Buffer
 	w := multipart.NewWriter(&b)
 
@@ -208,7 +228,7 @@ func (session *apiTestSession) createIcon(iconName string, initialIconfile []byt
 	if fw, err = w.CreateFormFile("iconfile", iconName); err != nil {
 		panic(err)
 	}
-	if _, err = io.Copy(fw, bytes.NewReader(initialIconfile)); err != nil {
+	if _, err = io.Copy(fw, bytes.NewReader(content)); err != nil {
 		panic(err)
 	}
 	w.Close()
@@ -217,11 +237,20 @@ func (session *apiTestSession) createIcon(iconName string, initialIconfile []byt
 		"Content-Type": w.FormDataContentType(),
 	}
 
+	return b.Bytes(), headers
+}
+
+func (session *apiTestSession) createIcon(iconName string, initialIconfile []byte) (int, api.ResponseIcon, error) {
+	var err error
+	var resp testResponse
+
+	body, headers := iconfileUploadForm(iconName, initialIconfile)
+
 	resp, err = session.sendRequest("POST", &testRequest{
 		path:          "/icon",
 		jar:           session.cjar,
 		headers:       headers,
-		body:          b.Bytes(),
+		body:          body,
 		respBodyProto: &api.ResponseIcon{},
 	})
 	if err != nil {
@@ -237,6 +266,25 @@ func (session *apiTestSession) createIcon(iconName string, initialIconfile []byt
 	return statusCode, api.ResponseIcon{}, fmt.Errorf("failed to cast %T to api.ResponseIcon", resp.body)
 }
 
+// uploadIconfile creates the icon or, with addToExisting, adds the iconfile to it returning the raw response body
+// so that the sanitization reports, of both successful and rejected uploads, can be checked
+func (session *apiTestSession) uploadIconfile(iconName string, content []byte, addToExisting bool) (int, []byte, error) {
+	body, headers := iconfileUploadForm(iconName, content)
+	path := "/icon"
+	if addToExisting {
+		path = fmt.Sprintf("/icon/%s", iconName)
+	}
+	respBody := []byte{}
+	resp, err := session.sendRequest("POST", &testRequest{
+		path:          path,
+		jar:           session.cjar,
+		headers:       headers,
+		body:          body,
+		respBodyProto: &respBody,
+	})
+	return resp.statusCode, respBody, err
+}
+
 func (session *apiTestSession) deleteIcon(iconName string) (int, error) {
 	resp, deleteError := session.sendRequest(
 		"DELETE",
@@ -249,55 +297,36 @@ func (session *apiTestSession) deleteIcon(iconName string) (int, error) {
 }
 
 func (s *apiTestSession) GetIconfile(iconName string, iconfileDescriptor domain.IconfileDescriptor) (domain.Iconfile, error) {
-	iconfile := domain.Iconfile{}
+	iconfile := domain.Iconfile{
+		IconfileDescriptor: iconfileDescriptor,
+	}
+	content := []byte{}
 	resp, reqErr := s.get(&testRequest{
 		path:          getFilePath(iconName, iconfileDescriptor),
-		respBodyProto: &iconfile,
+		respBodyProto: &content,
 	})
 	if reqErr != nil {
 		return iconfile, fmt.Errorf("failed to retrieve iconfile %v of %s: %w", iconfileDescriptor, iconName, reqErr)
 	}
-
-	if respIconfile, ok := resp.body.(*domain.Iconfile); ok {
-		iconfile.Content = respIconfile.Content
-		return iconfile, nil
+	if resp.statusCode != 200 {
+		return iconfile, fmt.Errorf("failed to retrieve iconfile %v of %s with status code %d: %w", iconfileDescriptor, iconName, resp.statusCode, errUnexpecteHTTPStatus)
 	}
 
-	return iconfile, fmt.Errorf("failed to cast the reply %T to []byte while retrieving iconfile %v of %s", resp.body, iconfileDescriptor, iconName)
+	iconfile.Content = content
+	return iconfile, nil
 }
 
 func (session *apiTestSession) addIconfile(iconName string, iconfile domain.Iconfile) (int, api.IconPath, error) {
 	var err error
 	var resp testResponse
 
-	var b bytes.Buffer
-	w := multipart.NewWriter(&b)
-
-	var fw io.Writer
-	if fw, err = w.CreateFormField("iconName"); err != nil {
-		panic(err)
-	}
-	if _, err = io.Copy(fw, strings.NewReader(iconName)); err != nil {
-		panic(err)
-	}
-
-	if fw, err = w.CreateFormFile("iconfile", iconName); err != nil {
-		panic(err)
-	}
-	if _, err = io.Copy(fw, bytes.NewReader(iconfile.Content)); err != nil {
-		panic(err)
-	}
-	w.Close()
-
-	headers := map[string]string{
-		"Content-Type": w.FormDataContentType(),
-	}
+	body, headers := iconfileUploadForm(iconName, iconfile.Content)
 
 	resp, err = session.sendRequest("POST", &testRequest{
 		path:          fmt.Sprintf("/icon/%s", iconName),
 		jar:           session.cjar,
 		headers:       headers,
-		body:          b.Bytes(),
+		body:          body,
 		respBodyProto: &api.IconPath{},
 	})
 	if err != nil {
@@ -350,3 +379,96 @@ func (session *apiTestSession) removeTag(iconName string, tag string) (int, erro
 
 	return resp.statusCode, err
 }
+
+func (session *apiTestSession) updateDescription(iconName string, description string) (int, error) {
+	resp, err := session.sendRequest("PUT", &testRequest{
+		path: fmt.Sprintf("/icon/%s/description", iconName),
+		jar:  session.cjar,
+		json: true,
+		body: api.UpdateDescriptionRequestData{Description: description},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return resp.statusCode, err
+}
+
+func (session *apiTestSession) searchIcons(text string) (int, []api.SearchHit, error) {
+	resp, err := session.get(&testRequest{
+		path:          "/search?q=" + url.QueryEscape(text),
+		jar:           session.cjar,
+		respBodyProto: &[]api.SearchHit{},
+	})
+	if err != nil {
+		return resp.statusCode, nil, fmt.Errorf("GET /search?q=%s failed: %w", text, err)
+	}
+	if resp.statusCode != 200 {
+		return resp.statusCode, nil, nil
+	}
+	hits, ok := resp.body.(*[]api.SearchHit)
+	if !ok {
+		return resp.statusCode, nil, fmt.Errorf("failed to cast %T as []api.SearchHit", resp.body)
+	}
+	return resp.statusCode, *hits, nil
+}
+
+func (session *apiTestSession) importIcons(archive []byte, dryRun bool) (int, services.ImportReport, error) {
+	resp, err := session.sendRequest("POST", &testRequest{
+		path:          fmt.Sprintf("/icon/import?dryRun=%v", dryRun),
+		jar:           session.cjar,
+		headers:       map[string]string{"Content-Type": "application/zip"},
+		body:          archive,
+		respBodyProto: &services.ImportReport{},
+	})
+	if err != nil {
+		return resp.statusCode, services.ImportReport{}, err
+	}
+	report, ok := resp.body.(*services.ImportReport)
+	if !ok {
+		return resp.statusCode, services.ImportReport{}, fmt.Errorf("failed to cast %T as services.ImportReport", resp.body)
+	}
+	return resp.statusCode, *report, nil
+}
+
+func (session *apiTestSession) createAccessToken(requestData api.CreateAccessTokenRequestData) (int, api.CreateAccessTokenResponse, error) {
+	resp, err := session.sendRequest("POST", &testRequest{
+		path:          "/user/tokens",
+		jar:           session.cjar,
+		json:          true,
+		body:          requestData,
+		respBodyProto: &api.CreateAccessTokenResponse{},
+	})
+	if err != nil {
+		return resp.statusCode, api.CreateAccessTokenResponse{}, err
+	}
+	if token, ok := resp.body.(*api.CreateAccessTokenResponse); ok {
+		return resp.statusCode, *token, nil
+	}
+	return resp.statusCode, api.CreateAccessTokenResponse{}, fmt.Errorf("failed to cast %T to api.CreateAccessTokenResponse", resp.body)
+}
+
+func (session *apiTestSession) listAccessTokens() ([]domain.AccessToken, error) {
+	resp, err := session.get(&testRequest{
+		path:          "/user/tokens",
+		respBodyProto: &[]domain.AccessToken{},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GET /user/tokens failed: %w", err)
+	}
+	if resp.statusCode != 200 {
+		return nil, fmt.Errorf("%w: got %d", errUnexpecteHTTPStatus, resp.statusCode)
+	}
+	tokens, ok := resp.body.(*[]domain.AccessToken)
+	if !ok {
+		return nil, fmt.Errorf("failed to cast %T as []domain.AccessToken", resp.body)
+	}
+	return *tokens, nil
+}
+
+func (session *apiTestSession) revokeAccessToken(id int64) (int, error) {
+	resp, err := session.sendRequest("DELETE", &testRequest{
+		path: fmt.Sprintf("/user/tokens/%d", id),
+		jar:  session.cjar,
+	})
+	return resp.statusCode, err
+}
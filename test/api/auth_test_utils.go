@@ -24,3 +24,10 @@ func makeRequestCredentials(authnScheme string, usernameOrToken string, password
 		return requestCredentials{}, fmt.Errorf("Unexpected authorization scheme: %v", authnScheme)
 	}
 }
+
+func makeBearerRequestCredentials(token string) requestCredentials {
+	return requestCredentials{
+		headerName:  "Authorization",
+		headerValue: "Bearer " + token,
+	}
+}
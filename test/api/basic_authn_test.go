@@ -1,10 +1,16 @@
 package api
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/pdkovacs/igo-repo/config"
+	"github.com/pdkovacs/igo-repo/security/authn"
 	"github.com/pdkovacs/igo-repo/test/api/testdata"
+	"github.com/pdkovacs/igo-repo/test/common"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -60,3 +66,85 @@ func (s *basicAuthnTestSuite) TestShouldPasssWithCorrectCredentials() {
 	_, hasChallange := resp.headers["Www-Authenticate"]
 	s.False(hasChallange)
 }
+
+type hashedPasswordAuthnTestSuite struct {
+	apiTestSuite
+	htpasswdFile string
+}
+
+func TestHashedPasswordAuthnTestSuite(t *testing.T) {
+	suite.Run(t, &hashedPasswordAuthnTestSuite{})
+}
+
+var bcryptCredentials = config.PasswordCredentials{Username: "bcrypt-user", Password: "bcrypt-password"}
+var argon2idCredentials = config.PasswordCredentials{Username: "argon2id-user", Password: "argon2id-password"}
+var htpasswdCredentials = config.PasswordCredentials{Username: "htpasswd-user", Password: "htpasswd-password"}
+
+func (s *hashedPasswordAuthnTestSuite) mustHashPassword(password string, algorithm string) string {
+	hash, err := authn.HashPassword(password, algorithm)
+	s.Require().NoError(err)
+	return hash
+}
+
+func (s *hashedPasswordAuthnTestSuite) SetupSuite() {
+	s.apiTestSuite.SetupSuite()
+
+	// htpasswd -B creates hashes with the "$2y$" prefix
+	htpasswdHash := "$2y$" + strings.TrimPrefix(s.mustHashPassword(htpasswdCredentials.Password, authn.BcryptHashAlgorithm), "$2a$")
+	s.htpasswdFile = filepath.Join(os.TempDir(), "igo-repo-itest-htpasswd")
+	htpasswd := fmt.Sprintf("# test users\n%s:%s\n", htpasswdCredentials.Username, htpasswdHash)
+	s.Require().NoError(os.WriteFile(s.htpasswdFile, []byte(htpasswd), 0600))
+}
+
+func (s *hashedPasswordAuthnTestSuite) TearDownSuite() {
+	os.Remove(s.htpasswdFile)
+}
+
+func (s *hashedPasswordAuthnTestSuite) BeforeTest(suiteName string, testName string) {
+	serverConfig := common.CloneConfig(s.defaultConfig)
+	serverConfig.PasswordCredentials = []config.PasswordCredentials{
+		{Username: bcryptCredentials.Username, PasswordHash: s.mustHashPassword(bcryptCredentials.Password, authn.BcryptHashAlgorithm)},
+		{Username: argon2idCredentials.Username, PasswordHash: s.mustHashPassword(argon2idCredentials.Password, authn.Argon2idHashAlgorithm)},
+	}
+	serverConfig.HtpasswdFile = s.htpasswdFile
+	s.startTestServer(serverConfig)
+}
+
+func (s *hashedPasswordAuthnTestSuite) getAppInfo(username string, password string) int {
+	reqCreds, makeReqCredErr := makeRequestCredentials(config.BasicAuthentication, username, password)
+	s.Require().NoError(makeReqCredErr)
+	resp, requestError := s.client.get(&testRequest{
+		path:        "/app-info",
+		credentials: &reqCreds,
+	})
+	s.NoError(requestError)
+	return resp.statusCode
+}
+
+func (s *hashedPasswordAuthnTestSuite) TestShouldPassWithCorrectPasswords() {
+	for _, credentials := range []config.PasswordCredentials{bcryptCredentials, argon2idCredentials, htpasswdCredentials} {
+		s.Equal(200, s.getAppInfo(credentials.Username, credentials.Password), credentials.Username)
+	}
+}
+
+func (s *hashedPasswordAuthnTestSuite) TestShouldFailWith401WithWrongPasswords() {
+	for _, credentials := range []config.PasswordCredentials{bcryptCredentials, argon2idCredentials, htpasswdCredentials} {
+		s.Equal(401, s.getAppInfo(credentials.Username, credentials.Password+"x"), credentials.Username)
+	}
+}
+
+func (s *hashedPasswordAuthnTestSuite) TestShouldNotAcceptTheHashAsPassword() {
+	hash := s.mustHashPassword(bcryptCredentials.Password, authn.BcryptHashAlgorithm)
+	s.Equal(401, s.getAppInfo(bcryptCredentials.Username, hash))
+}
+
+func (s *hashedPasswordAuthnTestSuite) TestShouldRejectArgon2idHashesWithZeroParameters() {
+	hash := s.mustHashPassword(argon2idCredentials.Password, authn.Argon2idHashAlgorithm)
+	parts := strings.Split(hash, "$")
+	for _, parameters := range []string{"m=0,t=1,p=1", "m=65536,t=0,p=1", "m=65536,t=1,p=0"} {
+		parts[3] = parameters
+		zeroed := strings.Join(parts, "$")
+		s.ErrorIs(authn.ValidatePasswordHash(zeroed), authn.ErrUnsupportedPasswordHash, parameters)
+		s.False(authn.VerifyPassword(zeroed, argon2idCredentials.Password), parameters)
+	}
+}
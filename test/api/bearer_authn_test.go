@@ -0,0 +1,222 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pdkovacs/igo-repo/config"
+	"github.com/pdkovacs/igo-repo/security/authn"
+	"github.com/pdkovacs/igo-repo/security/authr"
+	"github.com/pdkovacs/igo-repo/services"
+	"github.com/pdkovacs/igo-repo/test/common"
+	"github.com/stretchr/testify/suite"
+)
+
+const bearerTestIssuer = "https://ci.example.com"
+const bearerTestECKeyID = "ci-key-1"
+
+type bearerAuthnTestSuite struct {
+	apiTestSuite
+	rsaKey   *rsa.PrivateKey
+	ecKey    *ecdsa.PrivateKey
+	jwksFile string
+}
+
+func TestBearerAuthnTestSuite(t *testing.T) {
+	suite.Run(t, &bearerAuthnTestSuite{})
+}
+
+func (s *bearerAuthnTestSuite) SetupSuite() {
+	s.apiTestSuite.SetupSuite()
+
+	var err error
+	s.rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	s.Require().NoError(err)
+	s.ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+
+	s.jwksFile = filepath.Join(os.TempDir(), "igo-repo-itest-jwks.json")
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "EC",
+				"kid": bearerTestECKeyID,
+				"use": "sig",
+				"crv": "P-256",
+				"x":   base64.RawURLEncoding.EncodeToString(paddedBytes(s.ecKey.X, 32)),
+				"y":   base64.RawURLEncoding.EncodeToString(paddedBytes(s.ecKey.Y, 32)),
+			},
+		},
+	}
+	jwksJSON, err := json.Marshal(jwks)
+	s.Require().NoError(err)
+	s.Require().NoError(os.WriteFile(s.jwksFile, jwksJSON, 0600))
+}
+
+func (s *bearerAuthnTestSuite) TearDownSuite() {
+	os.Remove(s.jwksFile)
+}
+
+func (s *bearerAuthnTestSuite) BeforeTest(suiteName string, testName string) {
+	derBytes, err := x509.MarshalPKIXPublicKey(&s.rsaKey.PublicKey)
+	s.Require().NoError(err)
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: derBytes})
+
+	serverConfig := common.CloneConfig(s.defaultConfig)
+	serverConfig.JWTBearerPublicKeyPemBase64 = base64.StdEncoding.EncodeToString(pemBytes)
+	serverConfig.JWTBearerJWKSFile = s.jwksFile
+	serverConfig.JWTBearerIssuer = bearerTestIssuer
+	if testName != "TestShouldNotTakeGroupClaimsForGroupsWithoutMapping" {
+		serverConfig.GroupClaimsByRoles = config.ClaimsByRoles{
+			string(authr.ICON_EDITOR): {"design-team"},
+		}
+	}
+	if testName == "TestShouldRejectRequestWithoutTokenWhenOnlyBearerTokensAreAccepted" {
+		serverConfig.PasswordCredentials = nil
+	}
+	s.startTestServer(serverConfig)
+}
+
+func paddedBytes(n *big.Int, size int) []byte {
+	b := n.Bytes()
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded
+}
+
+func (s *bearerAuthnTestSuite) createToken(method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	s.Require().NoError(err)
+	return signed
+}
+
+func validClaims(subject string, groups []string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":    bearerTestIssuer,
+		"sub":    subject,
+		"exp":    time.Now().Add(5 * time.Minute).Unix(),
+		"groups": groups,
+	}
+}
+
+func (s *bearerAuthnTestSuite) getUserInfo(token string) testResponse {
+	credentials := makeBearerRequestCredentials(token)
+	resp, err := s.client.get(&testRequest{
+		path:          "/user",
+		credentials:   &credentials,
+		respBodyProto: &services.UserInfo{},
+	})
+	if resp.statusCode == 200 {
+		s.NoError(err)
+	}
+	return resp
+}
+
+func (s *bearerAuthnTestSuite) TestShouldAcceptRS256TokenSignedWithConfiguredKey() {
+	userID := authn.IssuerDomain(bearerTestIssuer).CreateUserID("ci-pipeline")
+	expectedUserInfo := services.UserInfo{
+		UserId:      userID,
+		Groups:      []authr.GroupID{authr.ICON_EDITOR},
+		Permissions: authr.GetPermissionsForGroup(authr.ICON_EDITOR),
+		DisplayName: userID.String(),
+	}
+
+	token := s.createToken(jwt.SigningMethodRS256, s.rsaKey, "", validClaims("ci-pipeline", []string{"design-team"}))
+	resp := s.getUserInfo(token)
+	s.Equal(200, resp.statusCode)
+	s.Equal(&expectedUserInfo, resp.body)
+	_, hasCookie := resp.headers["Set-Cookie"]
+	s.False(hasCookie)
+}
+
+func (s *bearerAuthnTestSuite) TestShouldAcceptES256TokenSignedWithKeyFromJWKS() {
+	userID := authn.IssuerDomain(bearerTestIssuer).CreateUserID("design-plugin")
+	expectedUserInfo := services.UserInfo{
+		UserId:      userID,
+		Groups:      []authr.GroupID{},
+		Permissions: []authr.PermissionID{},
+		DisplayName: userID.String(),
+	}
+
+	token := s.createToken(jwt.SigningMethodES256, s.ecKey, bearerTestECKeyID, validClaims("design-plugin", []string{}))
+	resp := s.getUserInfo(token)
+	s.Equal(200, resp.statusCode)
+	s.Equal(&expectedUserInfo, resp.body)
+}
+
+func (s *bearerAuthnTestSuite) TestShouldMapGroupClaimToGroups() {
+	token := s.createToken(jwt.SigningMethodRS256, s.rsaKey, "", validClaims("ci-pipeline", []string{"design-team", string(authr.ADMINISTRATOR)}))
+	resp := s.getUserInfo(token)
+	s.Equal(200, resp.statusCode)
+	userInfo := resp.body.(*services.UserInfo)
+	s.Equal([]authr.GroupID{authr.ICON_EDITOR}, userInfo.Groups)
+	s.ElementsMatch(authr.GetPermissionsForGroup(authr.ICON_EDITOR), userInfo.Permissions)
+}
+
+func (s *bearerAuthnTestSuite) TestShouldNotTakeGroupClaimsForGroupsWithoutMapping() {
+	token := s.createToken(jwt.SigningMethodRS256, s.rsaKey, "", validClaims("ci-pipeline", []string{string(authr.ICON_EDITOR), string(authr.ADMINISTRATOR)}))
+	resp := s.getUserInfo(token)
+	s.Equal(200, resp.statusCode)
+	userInfo := resp.body.(*services.UserInfo)
+	s.Empty(userInfo.Groups)
+	s.Empty(userInfo.Permissions)
+}
+
+func (s *bearerAuthnTestSuite) TestShouldRejectTokenSignedWithUnknownKey() {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	s.Require().NoError(err)
+	token := s.createToken(jwt.SigningMethodRS256, otherKey, "", validClaims("ci-pipeline", []string{string(authr.ICON_EDITOR)}))
+	resp := s.getUserInfo(token)
+	s.Equal(401, resp.statusCode)
+	s.Equal(`Bearer error="invalid_token"`, resp.headers["Www-Authenticate"][0])
+}
+
+func (s *bearerAuthnTestSuite) TestShouldRejectExpiredToken() {
+	claims := validClaims("ci-pipeline", []string{string(authr.ICON_EDITOR)})
+	claims["exp"] = time.Now().Add(-time.Minute).Unix()
+	token := s.createToken(jwt.SigningMethodRS256, s.rsaKey, "", claims)
+	resp := s.getUserInfo(token)
+	s.Equal(401, resp.statusCode)
+}
+
+func (s *bearerAuthnTestSuite) TestShouldRejectTokenFromUnexpectedIssuer() {
+	claims := validClaims("ci-pipeline", []string{string(authr.ICON_EDITOR)})
+	claims["iss"] = "https://somebody.else.com"
+	token := s.createToken(jwt.SigningMethodRS256, s.rsaKey, "", claims)
+	resp := s.getUserInfo(token)
+	s.Equal(401, resp.statusCode)
+}
+
+func (s *bearerAuthnTestSuite) TestShouldStillAcceptBasicCredentials() {
+	session := s.client.mustLogin(nil)
+	resp, err := session.get(&testRequest{
+		path: "/user",
+	})
+	s.NoError(err)
+	s.Equal(200, resp.statusCode)
+}
+
+func (s *bearerAuthnTestSuite) TestShouldRejectRequestWithoutTokenWhenOnlyBearerTokensAreAccepted() {
+	resp, err := s.client.get(&testRequest{
+		path: "/user",
+	})
+	s.NoError(err)
+	s.Equal(401, resp.statusCode)
+	s.Equal("Bearer", resp.headers["Www-Authenticate"][0])
+}
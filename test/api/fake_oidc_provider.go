@@ -0,0 +1,173 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// fakeOIDCProvider is a minimal, in-process OIDC identity provider supporting the authorization code flow
+type fakeOIDCProvider struct {
+	server     *httptest.Server
+	signingKey *rsa.PrivateKey
+	clientID   string
+	// issuer is put into the ID tokens issued; tests may change it to something unexpected
+	issuer string
+	// subject is the authenticated user's identifier put into the ID tokens issued
+	subject string
+	// groups is put into the "groups" claim of the ID tokens issued
+	groups []string
+
+	mutex               sync.Mutex
+	nonceByCode         map[string]string
+	authorizationCount  int
+	logoutRequestsCount int
+}
+
+func newFakeOIDCProvider(clientID string, subject string) *fakeOIDCProvider {
+	signingKey, keyGenErr := rsa.GenerateKey(rand.Reader, 2048)
+	if keyGenErr != nil {
+		panic(keyGenErr)
+	}
+
+	idp := &fakeOIDCProvider{
+		signingKey:  signingKey,
+		clientID:    clientID,
+		subject:     subject,
+		nonceByCode: map[string]string{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", idp.handleAuthorize)
+	mux.HandleFunc("/token", idp.handleToken)
+	mux.HandleFunc("/logout", idp.handleLogout)
+	idp.server = httptest.NewServer(mux)
+	idp.issuer = idp.server.URL
+
+	return idp
+}
+
+func (idp *fakeOIDCProvider) close() {
+	idp.server.Close()
+}
+
+func (idp *fakeOIDCProvider) url(path string) string {
+	return idp.server.URL + path
+}
+
+func (idp *fakeOIDCProvider) publicKeyPEMBase64() string {
+	derBytes, marshalErr := x509.MarshalPKIXPublicKey(&idp.signingKey.PublicKey)
+	if marshalErr != nil {
+		panic(marshalErr)
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: derBytes})
+	return base64.StdEncoding.EncodeToString(pemBytes)
+}
+
+func (idp *fakeOIDCProvider) getAuthorizationCount() int {
+	idp.mutex.Lock()
+	defer idp.mutex.Unlock()
+	return idp.authorizationCount
+}
+
+func (idp *fakeOIDCProvider) getLogoutRequestsCount() int {
+	idp.mutex.Lock()
+	defer idp.mutex.Unlock()
+	return idp.logoutRequestsCount
+}
+
+// handleAuthorize authenticates the user without further ado and sends them back to the client with an authorization code
+func (idp *fakeOIDCProvider) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != idp.clientID || query.Get("response_type") != "code" {
+		w.WriteHeader(400)
+		return
+	}
+
+	redirectURI, parseErr := url.Parse(query.Get("redirect_uri"))
+	if parseErr != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	code := randomCode()
+	idp.mutex.Lock()
+	idp.nonceByCode[code] = query.Get("nonce")
+	idp.authorizationCount++
+	idp.mutex.Unlock()
+
+	redirectQuery := redirectURI.Query()
+	redirectQuery.Set("code", code)
+	redirectQuery.Set("state", query.Get("state"))
+	redirectURI.RawQuery = redirectQuery.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (idp *fakeOIDCProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	idp.mutex.Lock()
+	nonce, codeFound := idp.nonceByCode[r.PostForm.Get("code")]
+	delete(idp.nonceByCode, r.PostForm.Get("code"))
+	idp.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !codeFound || r.PostForm.Get("client_id") != idp.clientID {
+		w.WriteHeader(400)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":    idp.issuer,
+		"sub":    idp.subject,
+		"aud":    idp.clientID,
+		"iat":    now.Unix(),
+		"exp":    now.Add(5 * time.Minute).Unix(),
+		"nonce":  nonce,
+		"groups": idp.groups,
+	})
+	idToken, signErr := token.SignedString(idp.signingKey)
+	if signErr != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": randomCode(),
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func (idp *fakeOIDCProvider) handleLogout(w http.ResponseWriter, r *http.Request) {
+	idp.mutex.Lock()
+	idp.logoutRequestsCount++
+	idp.mutex.Unlock()
+	fmt.Fprint(w, "logged out")
+}
+
+func randomCode() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"image/png"
+	"io"
+	"sort"
+	"testing"
+
+	"github.com/pdkovacs/igo-repo/services"
+	"github.com/pdkovacs/igo-repo/test/api/testdata"
+	"github.com/stretchr/testify/suite"
+)
+
+type faviconBundleTestSuite struct {
+	iconTestSuite
+}
+
+func TestFaviconBundleTestSuite(t *testing.T) {
+	suite.Run(t, &faviconBundleTestSuite{})
+}
+
+func (s *faviconBundleTestSuite) getFaviconBundle(session *apiTestSession, iconName string, query string) (int, map[string][]byte) {
+	content := []byte{}
+	resp, err := session.get(&testRequest{
+		path:          "/icon/" + iconName + "/favicon?" + query,
+		respBodyProto: &content,
+	})
+	s.NoError(err)
+	if resp.statusCode != 200 {
+		return resp.statusCode, nil
+	}
+	s.Equal("application/zip", resp.headers["Content-Type"][0])
+
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	s.NoError(err)
+	files := map[string][]byte{}
+	for _, file := range archive.File {
+		reader, openErr := file.Open()
+		s.NoError(openErr)
+		files[file.Name], err = io.ReadAll(reader)
+		s.NoError(err)
+		reader.Close()
+	}
+	return resp.statusCode, files
+}
+
+// icoImageSizes returns the sizes of the PNG images in the ICO file as stored in its directory
+func (s *faviconBundleTestSuite) icoImageSizes(ico []byte) []int {
+	var header struct{ Reserved, Type, Count uint16 }
+	s.NoError(binary.Read(bytes.NewReader(ico), binary.LittleEndian, &header))
+	s.Equal(uint16(1), header.Type)
+	sizes := []int{}
+	for i := 0; i < int(header.Count); i++ {
+		entry := ico[6+16*i:]
+		length, offset := binary.LittleEndian.Uint32(entry[8:]), binary.LittleEndian.Uint32(entry[12:])
+		config, err := png.DecodeConfig(bytes.NewReader(ico[offset : offset+length]))
+		s.NoError(err)
+		s.Equal(int(entry[0]), config.Width)
+		s.Equal(int(entry[1]), config.Height)
+		sizes = append(sizes, config.Width)
+	}
+	return sizes
+}
+
+func fileNames(files map[string][]byte) []string {
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *faviconBundleTestSuite) TestBuildBundleFromSVG() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+
+	statusCode, files := s.getFaviconBundle(session, dataIn[0].Name, "appName=Money&themeColor=%23336699")
+	s.Equal(200, statusCode)
+	s.Equal([]string{
+		"android-chrome-192x192.png",
+		"android-chrome-512x512.png",
+		"apple-touch-icon-120x120.png",
+		"apple-touch-icon-152x152.png",
+		"apple-touch-icon-167x167.png",
+		"apple-touch-icon.png",
+		"favicon.ico",
+		services.FaviconManifestName,
+	}, fileNames(files))
+	s.Equal([]int{16, 32, 48}, s.icoImageSizes(files["favicon.ico"]))
+
+	appleTouchIcon, err := png.Decode(bytes.NewReader(files["apple-touch-icon.png"]))
+	s.NoError(err)
+	s.Equal(180, appleTouchIcon.Bounds().Dx())
+	s.Equal(180, appleTouchIcon.Bounds().Dy())
+	_, _, _, alpha := appleTouchIcon.At(0, 0).RGBA()
+	s.Equal(uint32(0xffff), alpha)
+
+	manifest := services.WebManifest{}
+	s.NoError(json.Unmarshal(files[services.FaviconManifestName], &manifest))
+	s.Equal("Money", manifest.Name)
+	s.Equal("#336699", manifest.ThemeColor)
+	s.Equal("#ffffff", manifest.BackgroundColor)
+	s.Equal([]services.WebManifestIcon{
+		{Src: "/android-chrome-192x192.png", Sizes: "192x192", Type: "image/png"},
+		{Src: "/android-chrome-512x512.png", Sizes: "512x512", Type: "image/png"},
+	}, manifest.Icons)
+
+	// Nothing is added to the icon
+	statusCode, icon, err := session.describeIcon(dataIn[0].Name)
+	s.NoError(err)
+	s.Equal(200, statusCode)
+	s.Equal(len(dataIn[0].Iconfiles), len(icon.Paths))
+	s.assertEndState()
+}
+
+func (s *faviconBundleTestSuite) TestLeaveOutSizesLargerThanRasterSources() {
+	session := s.client.mustLoginSetAllPerms()
+	statusCode, _, err := session.createIcon("disc", makeRasterIconfile(64, "png"))
+	s.NoError(err)
+	s.Equal(201, statusCode)
+
+	statusCode, files := s.getFaviconBundle(session, "disc", "")
+	s.Equal(200, statusCode)
+	s.Equal([]string{"favicon.ico", services.FaviconManifestName}, fileNames(files))
+	s.Equal([]int{16, 32, 48}, s.icoImageSizes(files["favicon.ico"]))
+
+	manifest := services.WebManifest{}
+	s.NoError(json.Unmarshal(files[services.FaviconManifestName], &manifest))
+	s.Equal("disc", manifest.Name)
+	s.Empty(manifest.Icons)
+}
+
+func (s *faviconBundleTestSuite) TestRejectInvalidRequests() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+
+	statusCode, _ := s.getFaviconBundle(session, "no-such-icon", "")
+	s.Equal(404, statusCode)
+
+	statusCode, _ = s.getFaviconBundle(session, dataIn[0].Name, "backgroundColor=white")
+	s.Equal(400, statusCode)
+
+	statusCode, _, err := session.createIcon("tiny", makeRasterIconfile(12, "png"))
+	s.NoError(err)
+	s.Equal(201, statusCode)
+	statusCode, _ = s.getFaviconBundle(session, "tiny", "")
+	s.Equal(404, statusCode)
+}
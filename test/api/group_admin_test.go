@@ -0,0 +1,145 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/pdkovacs/igo-repo/config"
+	"github.com/pdkovacs/igo-repo/security/authn"
+	"github.com/pdkovacs/igo-repo/security/authr"
+	"github.com/pdkovacs/igo-repo/services"
+	"github.com/pdkovacs/igo-repo/test/api/testdata"
+	"github.com/pdkovacs/igo-repo/test/common"
+	"github.com/stretchr/testify/suite"
+)
+
+var groupAdminCredentials = config.PasswordCredentials{Username: "group-admin", Password: "group-admin"}
+
+type groupAdminTestSuite struct {
+	apiTestSuite
+}
+
+func TestGroupAdminTestSuite(t *testing.T) {
+	suite.Run(t, &groupAdminTestSuite{})
+}
+
+func (s *groupAdminTestSuite) BeforeTest(suiteName string, testName string) {
+	serverConfig := common.CloneConfig(s.defaultConfig)
+	serverConfig.EnableBackdoors = true
+	serverConfig.PasswordCredentials = append(serverConfig.PasswordCredentials, groupAdminCredentials)
+	serverConfig.UsersByRoles = map[string][]string{
+		string(authr.ADMINISTRATOR): {groupAdminCredentials.Username},
+	}
+	s.startTestServer(serverConfig)
+}
+
+func (s *groupAdminTestSuite) mustLoginAsAdmin() *apiTestSession {
+	credentials := s.client.makeRequestCredentials(groupAdminCredentials)
+	return s.client.mustLogin(&credentials)
+}
+
+func (s *groupAdminTestSuite) sendMembershipRequest(session *apiTestSession, method string, group string, user string) int {
+	resp, err := session.sendRequest(method, &testRequest{
+		path: fmt.Sprintf("/admin/groups/%s/members?user=%s", group, url.QueryEscape(user)),
+		jar:  session.cjar,
+	})
+	s.Require().NoError(err)
+	return resp.statusCode
+}
+
+func (s *groupAdminTestSuite) listGroups(session *apiTestSession) (int, []services.GroupInfo) {
+	resp, err := session.get(&testRequest{
+		path:          "/admin/groups",
+		respBodyProto: &[]services.GroupInfo{},
+	})
+	if resp.statusCode != 200 {
+		return resp.statusCode, nil
+	}
+	s.Require().NoError(err)
+	return resp.statusCode, *resp.body.(*[]services.GroupInfo)
+}
+
+func (s *groupAdminTestSuite) getUserInfo(session *apiTestSession, path string) services.UserInfo {
+	resp, err := session.get(&testRequest{
+		path:          path,
+		respBodyProto: &services.UserInfo{},
+	})
+	s.Require().NoError(err)
+	s.Require().Equal(200, resp.statusCode)
+	return *resp.body.(*services.UserInfo)
+}
+
+func (s *groupAdminTestSuite) TestShouldGrantPermissionsOfGroupOnNextLogin() {
+	admin := s.mustLoginAsAdmin()
+	user := testdata.DefaultCredentials.Username
+
+	s.Equal(204, s.sendMembershipRequest(admin, "PUT", string(authr.ICON_EDITOR), user))
+	// Adding an existing member is fine
+	s.Equal(204, s.sendMembershipRequest(admin, "PUT", string(authr.ICON_EDITOR), user))
+
+	effectiveUserInfo := s.getUserInfo(admin, fmt.Sprintf("/admin/users?user=%s", url.QueryEscape(user)))
+	s.Equal([]authr.GroupID{authr.ICON_EDITOR}, effectiveUserInfo.Groups)
+	s.ElementsMatch(authr.GetPermissionsForGroup(authr.ICON_EDITOR), effectiveUserInfo.Permissions)
+
+	userInfo := s.getUserInfo(s.client.mustLogin(nil), "/user")
+	s.ElementsMatch(authr.GetPermissionsForGroup(authr.ICON_EDITOR), userInfo.Permissions)
+
+	statusCode, groups := s.listGroups(admin)
+	s.Equal(200, statusCode)
+	var iconEditors *services.GroupInfo
+	for i := range groups {
+		if groups[i].Name == authr.ICON_EDITOR {
+			iconEditors = &groups[i]
+		}
+	}
+	s.Require().NotNil(iconEditors)
+	s.Equal([]services.GroupMember{{UserID: user, Source: services.DatabaseMembership}}, iconEditors.Members)
+
+	s.Equal(204, s.sendMembershipRequest(admin, "DELETE", string(authr.ICON_EDITOR), user))
+	s.Equal(404, s.sendMembershipRequest(admin, "DELETE", string(authr.ICON_EDITOR), user))
+
+	userInfo = s.getUserInfo(s.client.mustLogin(nil), "/user")
+	s.Empty(userInfo.Permissions)
+}
+
+func (s *groupAdminTestSuite) TestShouldNotRemoveConfiguredMembership() {
+	admin := s.mustLoginAsAdmin()
+	s.Equal(409, s.sendMembershipRequest(admin, "DELETE", string(authr.ADMINISTRATOR), groupAdminCredentials.Username))
+}
+
+func (s *groupAdminTestSuite) TestShouldNotAddMemberToUndefinedGroup() {
+	admin := s.mustLoginAsAdmin()
+	s.Equal(404, s.sendMembershipRequest(admin, "PUT", "NO_SUCH_GROUP", testdata.DefaultCredentials.Username))
+}
+
+func (s *groupAdminTestSuite) TestShouldNotAllowGroupManagementWithoutPermission() {
+	userSession := s.client.mustLoginSetAllPerms()
+
+	statusCode, _ := s.listGroups(userSession)
+	s.Equal(403, statusCode)
+	s.Equal(403, s.sendMembershipRequest(userSession, "PUT", string(authr.ADMINISTRATOR), testdata.DefaultCredentials.Username))
+}
+
+func (s *groupAdminTestSuite) TestShouldManageMembershipOfUserFromIdentityProvider() {
+	admin := s.mustLoginAsAdmin()
+	userID := authn.IssuerDomain("https://idp.example.com/realms/igo").CreateUserID("9XE3-JI34-00132A")
+	user := userID.String()
+
+	s.Equal(204, s.sendMembershipRequest(admin, "PUT", string(authr.ICON_EDITOR), user))
+	effectiveUserInfo := s.getUserInfo(admin, fmt.Sprintf("/admin/users?user=%s", url.QueryEscape(user)))
+	s.Equal(user, effectiveUserInfo.UserId.String())
+	s.Equal([]authr.GroupID{authr.ICON_EDITOR}, effectiveUserInfo.Groups)
+
+	s.Equal(204, s.sendMembershipRequest(admin, "DELETE", string(authr.ICON_EDITOR), user))
+}
+
+func (s *groupAdminTestSuite) TestShouldRequireUserToManageMembership() {
+	admin := s.mustLoginAsAdmin()
+	resp, err := admin.sendRequest("PUT", &testRequest{
+		path: fmt.Sprintf("/admin/groups/%s/members", authr.ICON_EDITOR),
+		jar:  admin.cjar,
+	})
+	s.Require().NoError(err)
+	s.Equal(400, resp.statusCode)
+}
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"testing"
+
+	"github.com/pdkovacs/igo-repo/config"
+	"github.com/pdkovacs/igo-repo/domain"
+	"github.com/pdkovacs/igo-repo/security/authr"
+	"github.com/pdkovacs/igo-repo/test/api/testdata"
+	"github.com/pdkovacs/igo-repo/test/common"
+	"github.com/stretchr/testify/suite"
+)
+
+var otherEditorCredentials = config.PasswordCredentials{Username: "other-editor", Password: "other-editor"}
+
+type iconACLTestSuite struct {
+	iconTestSuite
+}
+
+func TestIconACLTestSuite(t *testing.T) {
+	suite.Run(t, &iconACLTestSuite{})
+}
+
+func (s *iconACLTestSuite) BeforeTest(suiteName string, testName string) {
+	serverConfig := common.CloneConfig(s.defaultConfig)
+	serverConfig.EnableBackdoors = true
+	serverConfig.PasswordCredentials = append(serverConfig.PasswordCredentials, otherEditorCredentials)
+	serverConfig.PersistRasterizedIconfiles = testName == "TestShouldNotPersistIconfilesRasterizedForOthers"
+	serverConfig.UsersByRoles = config.UsersByRoles{
+		string(authr.ICON_EDITOR): {testdata.DefaultCredentials.Username, otherEditorCredentials.Username},
+	}
+	s.startTestServer(serverConfig)
+}
+
+// createIconOwnedByGroup creates the icon handing it over to the owning group as well
+func (s *iconACLTestSuite) createIconOwnedByGroup(session *apiTestSession, iconName string, content []byte, owningGroup string) int {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	s.Require().NoError(w.WriteField("iconName", iconName))
+	s.Require().NoError(w.WriteField("owningGroup", owningGroup))
+	fw, err := w.CreateFormFile("iconfile", iconName)
+	s.Require().NoError(err)
+	_, err = fw.Write(content)
+	s.Require().NoError(err)
+	s.Require().NoError(w.Close())
+
+	resp, err := session.sendRequest("POST", &testRequest{
+		path:    "/icon",
+		jar:     session.cjar,
+		headers: map[string]string{"Content-Type": w.FormDataContentType()},
+		body:    b.Bytes(),
+	})
+	s.Require().NoError(err)
+	return resp.statusCode
+}
+
+func (s *iconACLTestSuite) mustLoginAsOtherEditor(permissions []authr.PermissionID) *apiTestSession {
+	credentials := s.client.makeRequestCredentials(otherEditorCredentials)
+	session := s.client.mustLogin(&credentials)
+	resp, err := session.sendRequest("PUT", &testRequest{
+		path:        authenticationBackdoorPath,
+		credentials: &credentials,
+		jar:         session.cjar,
+		json:        true,
+		body:        permissions,
+	})
+	s.Require().NoError(err)
+	s.Require().Equal(200, resp.statusCode)
+	return session
+}
+
+func (s *iconACLTestSuite) getIconACL(session *apiTestSession, iconName string) domain.IconACL {
+	resp, err := session.get(&testRequest{
+		path:          fmt.Sprintf("/icon/%s/acl", iconName),
+		respBodyProto: &domain.IconACL{},
+	})
+	s.Require().NoError(err)
+	s.Require().Equal(200, resp.statusCode)
+	return *resp.body.(*domain.IconACL)
+}
+
+func (s *iconACLTestSuite) setIconACL(session *apiTestSession, iconName string, acl domain.IconACL) int {
+	resp, err := session.put(&testRequest{
+		path: fmt.Sprintf("/icon/%s/acl", iconName),
+		json: true,
+		body: acl,
+	})
+	s.Require().NoError(err)
+	return resp.statusCode
+}
+
+func (s *iconACLTestSuite) TestCreatorShouldOwnIcon() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+
+	acl := s.getIconACL(session, dataIn[0].Name)
+	s.Equal(testdata.DefaultCredentials.Username, acl.Owner)
+	s.Empty(acl.Entries)
+}
+
+func (s *iconACLTestSuite) TestShouldNotLetOthersDeleteOwnedIcon() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+
+	otherSession := s.mustLoginAsOtherEditor(authr.GetPermissionsForGroup(authr.ICON_EDITOR))
+	statusCode, err := otherSession.deleteIcon(dataIn[0].Name)
+	s.NoError(err)
+	s.Equal(403, statusCode)
+	statusCode, err = otherSession.deleteIconfile(dataIn[0].Name, dataIn[0].Iconfiles[0].IconfileDescriptor)
+	s.NoError(err)
+	s.Equal(403, statusCode)
+
+	statusCode, err = session.deleteIcon(dataIn[0].Name)
+	s.NoError(err)
+	s.Equal(204, statusCode)
+}
+
+func (s *iconACLTestSuite) TestShouldLetOthersDeleteIconWhenGrantedInACL() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+
+	s.Equal(204, s.setIconACL(session, dataIn[0].Name, domain.IconACL{
+		Owner: testdata.DefaultCredentials.Username,
+		Entries: []domain.IconACLEntry{
+			{PrincipalType: domain.UserPrincipal, Principal: otherEditorCredentials.Username, Permission: string(authr.REMOVE_ICON)},
+		},
+	}))
+
+	otherSession := s.mustLoginAsOtherEditor(authr.GetPermissionsForGroup(authr.ICON_EDITOR))
+	statusCode, err := otherSession.deleteIconfile(dataIn[0].Name, dataIn[0].Iconfiles[0].IconfileDescriptor)
+	s.NoError(err)
+	s.Equal(403, statusCode)
+	statusCode, err = otherSession.deleteIcon(dataIn[0].Name)
+	s.NoError(err)
+	s.Equal(204, statusCode)
+}
+
+func (s *iconACLTestSuite) TestShouldOnlyLetOwnersChangeACL() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+
+	otherSession := s.mustLoginAsOtherEditor(authr.GetPermissionsForGroup(authr.ICON_EDITOR))
+	s.Equal(403, s.setIconACL(otherSession, dataIn[0].Name, domain.IconACL{Owner: otherEditorCredentials.Username}))
+
+	adminSession := s.mustLoginAsOtherEditor([]authr.PermissionID{authr.MANAGE_ICON_ACLS})
+	s.Equal(204, s.setIconACL(adminSession, dataIn[0].Name, domain.IconACL{Owner: otherEditorCredentials.Username}))
+	s.Equal(otherEditorCredentials.Username, s.getIconACL(session, dataIn[0].Name).Owner)
+}
+
+func (s *iconACLTestSuite) TestShouldRejectInvalidACL() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+
+	s.Equal(400, s.setIconACL(session, dataIn[0].Name, domain.IconACL{}))
+	s.Equal(400, s.setIconACL(session, dataIn[0].Name, domain.IconACL{
+		Owner: testdata.DefaultCredentials.Username,
+		Entries: []domain.IconACLEntry{
+			{PrincipalType: domain.GroupPrincipal, Principal: "DESIGNERS", Permission: string(authr.CREATE_ICON)},
+		},
+	}))
+	s.Equal(404, s.setIconACL(session, "no-such-icon", domain.IconACL{Owner: testdata.DefaultCredentials.Username}))
+}
+
+func (s *iconACLTestSuite) TestShouldLetMembersOfOwningGroupDeleteIcon() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	s.Equal(201, s.createIconOwnedByGroup(session, dataIn[0].Name, dataIn[0].Iconfiles[0].Content, string(authr.ICON_EDITOR)))
+
+	acl := s.getIconACL(session, dataIn[0].Name)
+	s.Equal(testdata.DefaultCredentials.Username, acl.Owner)
+	s.Equal(string(authr.ICON_EDITOR), acl.OwningGroup)
+
+	otherSession := s.mustLoginAsOtherEditor(authr.GetPermissionsForGroup(authr.ICON_EDITOR))
+	statusCode, err := otherSession.deleteIcon(dataIn[0].Name)
+	s.NoError(err)
+	s.Equal(204, statusCode)
+}
+
+func (s *iconACLTestSuite) TestShouldNotLetCreatorHandIconOverToOtherGroup() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	s.Equal(400, s.createIconOwnedByGroup(session, dataIn[0].Name, dataIn[0].Iconfiles[0].Content, "DESIGNERS"))
+}
+
+func (s *iconACLTestSuite) TestShouldNotPersistIconfilesRasterizedForOthers() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+	descriptor := domain.IconfileDescriptor{Format: "png", Size: "96px"}
+
+	otherSession := s.mustLoginAsOtherEditor(authr.GetPermissionsForGroup(authr.ICON_EDITOR))
+	_, err := otherSession.GetIconfile(dataIn[0].Name, descriptor)
+	s.NoError(err)
+	statusCode, icon, err := session.describeIcon(dataIn[0].Name)
+	s.NoError(err)
+	s.Equal(200, statusCode)
+	for _, path := range icon.Paths {
+		s.NotEqual(descriptor, path.IconfileDescriptor)
+	}
+
+	_, err = session.GetIconfile(dataIn[0].Name, descriptor)
+	s.NoError(err)
+	statusCode, icon, err = session.describeIcon(dataIn[0].Name)
+	s.NoError(err)
+	s.Equal(200, statusCode)
+	s.Equal(len(dataIn[0].Iconfiles)+1, len(icon.Paths))
+}
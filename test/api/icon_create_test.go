@@ -1,6 +1,7 @@
 package api
 
 import (
+	"encoding/json"
 	"errors"
 	"os"
 	"testing"
@@ -41,6 +42,26 @@ func (s *iconCreateTestSuite) TestFailsWith403WithoutPrivilege() {
 	s.Equal(0, len(icons))
 }
 
+func (s *iconCreateTestSuite) TestTellsWhyIconfileIsInvalid() {
+	session := s.client.mustLoginSetAllPerms()
+
+	for content, reason := range map[string]string{
+		`<svg xmlns="http://www.w3.org/2000/svg"><path d="M2 2h20v20H2z"/></svg>`:                         "neither width and height nor viewBox",
+		`<svg xmlns="http://www.w3.org/2000/svg" width="1000" height="10"><path d="M2 2h20v2H2z"/></svg>`: "more than 16 times",
+	} {
+		statusCode, body, err := session.uploadIconfile("invalid", []byte(content), false)
+		s.NoError(err)
+		s.Equal(400, statusCode, content)
+		var invalid api.InvalidIconfileResponse
+		s.NoError(json.Unmarshal(body, &invalid))
+		s.Contains(invalid.Error, reason)
+	}
+
+	icons, errDesc := session.describeAllIcons()
+	s.NoError(errDesc)
+	s.Equal(0, len(icons))
+}
+
 func (s *iconCreateTestSuite) TestCompletesWithPrivilege() {
 	const iconName = "dock"
 	var iconfileDescriptor = domain.IconfileDescriptor{
@@ -127,3 +148,38 @@ func (s *iconCreateTestSuite) TestRollbackToLastConsistentStateOnError() {
 
 	s.assertEndState()
 }
+
+func (s *iconCreateTestSuite) TestSizeSVGByViewBoxAndUnits() {
+	session := s.client.mustLoginSetAllPerms()
+
+	statusCode, resultIcon, err := session.createIcon("viewbox_only", []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 24 24"><path d="M2 2h20v20H2z"/></svg>`))
+	s.NoError(err)
+	s.Equal(201, statusCode)
+	s.Equal(domain.IconfileDescriptor{Format: "svg", Size: "24px"}, resultIcon.Paths[0].IconfileDescriptor)
+
+	statusCode, resultIcon, err = session.createIcon("em_sized", []byte(`<?xml version="1.0"?>
+<svg xmlns="http://www.w3.org/2000/svg" width="2em" height="2em" viewBox="0 0 24 24"><path d="M2 2h20v20H2z"/></svg>`))
+	s.NoError(err)
+	s.Equal(201, statusCode)
+	s.Equal(domain.IconfileDescriptor{Format: "svg", Size: "32px"}, resultIcon.Paths[0].IconfileDescriptor)
+
+	s.assertEndState()
+}
+
+func (s *iconCreateTestSuite) TestFailsWith400OnInvalidSVG() {
+	session := s.client.mustLoginSetAllPerms()
+
+	for _, content := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg"><path d="M2 2h20v20H2z"/></svg>`,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24"><path d="M2 2h20v20H2z"></svg>`,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="10vw" height="24"></svg>`,
+		`<?xml version="1.0"?><html></html>`,
+	} {
+		statusCode, _, _ := session.createIcon("invalid", []byte(content))
+		s.Equal(400, statusCode, content)
+	}
+
+	icons, errDesc := session.describeAllIcons()
+	s.NoError(errDesc)
+	s.Equal(0, len(icons))
+}
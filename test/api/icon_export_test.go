@@ -0,0 +1,97 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/pdkovacs/igo-repo/domain"
+	"github.com/pdkovacs/igo-repo/services"
+	"github.com/pdkovacs/igo-repo/test/api/testdata"
+	"github.com/stretchr/testify/suite"
+)
+
+type iconExportTestSuite struct {
+	iconTestSuite
+}
+
+func TestIconExportTestSuite(t *testing.T) {
+	suite.Run(t, &iconExportTestSuite{})
+}
+
+func (s *iconExportTestSuite) mustExport(session *apiTestSession, query string) map[string][]byte {
+	content := []byte{}
+	resp, err := session.get(&testRequest{
+		path:          "/export?" + query,
+		respBodyProto: &content,
+	})
+	s.NoError(err)
+	s.Equal(200, resp.statusCode)
+	s.Equal("application/zip", resp.headers["Content-Type"][0])
+
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	s.NoError(err)
+	files := map[string][]byte{}
+	for _, file := range archive.File {
+		reader, openErr := file.Open()
+		s.NoError(openErr)
+		files[file.Name], err = io.ReadAll(reader)
+		s.NoError(err)
+		reader.Close()
+	}
+	return files
+}
+
+func (s *iconExportTestSuite) TestExportIconsWithManifest() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+	statusCode, err := session.addTag(dataIn[0].Name, "finance")
+	s.NoError(err)
+	s.Equal(201, statusCode)
+
+	files := s.mustExport(session, "tag=finance&format=svg")
+
+	manifest := services.ExportManifest{}
+	s.NoError(json.Unmarshal(files[services.ExportManifestName], &manifest))
+	s.Equal(1, len(manifest.Icons))
+	exported := manifest.Icons[0]
+	s.Equal(dataIn[0].Name, exported.Name)
+	s.Equal([]string{"finance"}, exported.Tags)
+
+	expectedFileCount := 1
+	for _, iconfile := range dataIn[0].Iconfiles {
+		if iconfile.Format != "svg" {
+			continue
+		}
+		expectedFileCount++
+		path := s.testGitRepo.GetPathToIconfileInRepos(dataIn[0].Name, iconfile.IconfileDescriptor)
+		s.Equal(iconfile.Content, files[path])
+		s.Contains(exported.Iconfiles, services.ExportedIconfile{
+			Format:      iconfile.Format,
+			Size:        iconfile.Size,
+			Path:        path,
+			ContentHash: domain.ContentHash(iconfile.Content),
+		})
+	}
+	s.Equal(expectedFileCount, len(files))
+}
+
+func (s *iconExportTestSuite) TestExportAllIcons() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+
+	files := s.mustExport(session, "")
+
+	expectedFileCount := 1
+	for _, icon := range dataIn {
+		for _, iconfile := range icon.Iconfiles {
+			expectedFileCount++
+			s.Equal(iconfile.Content, files[s.testGitRepo.GetPathToIconfileInRepos(icon.Name, iconfile.IconfileDescriptor)])
+		}
+	}
+	s.Equal(expectedFileCount, len(files))
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/pdkovacs/igo-repo/security/authr"
+	"github.com/pdkovacs/igo-repo/services"
+	"github.com/pdkovacs/igo-repo/test/api/testdata"
+	"github.com/stretchr/testify/suite"
+)
+
+type iconFontTestSuite struct {
+	iconTestSuite
+}
+
+func TestIconFontTestSuite(t *testing.T) {
+	suite.Run(t, &iconFontTestSuite{})
+}
+
+func (s *iconFontTestSuite) mustGetFont(session *apiTestSession, query string) map[string][]byte {
+	content := []byte{}
+	resp, err := session.get(&testRequest{
+		path:          "/font?" + query,
+		respBodyProto: &content,
+	})
+	s.NoError(err)
+	s.Equal(200, resp.statusCode)
+	s.Equal("application/zip", resp.headers["Content-Type"][0])
+
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	s.NoError(err)
+	files := map[string][]byte{}
+	for _, file := range archive.File {
+		reader, openErr := file.Open()
+		s.NoError(openErr)
+		files[file.Name], err = io.ReadAll(reader)
+		s.NoError(err)
+		reader.Close()
+	}
+	return files
+}
+
+func (s *iconFontTestSuite) codepointMap(files map[string][]byte, baseName string) services.FontCodepointMap {
+	codepoints := services.FontCodepointMap{}
+	s.NoError(json.Unmarshal(files[baseName+".json"], &codepoints))
+	return codepoints
+}
+
+func (s *iconFontTestSuite) TestBuildFontWithStylesheetAndCodepointMap() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+
+	files := s.mustGetFont(session, "family=Product+Icons")
+	s.Equal(4, len(files))
+	s.Equal([]byte{0, 1, 0, 0}, files["product-icons.ttf"][:4])
+	s.Equal("wOF2", string(files["product-icons.woff2"][:4]))
+
+	codepoints := s.codepointMap(files, "product-icons")
+	s.Equal("Product Icons", codepoints.Family)
+	s.Equal(2, len(codepoints.Icons))
+	first := codepoints.Icons[dataIn[0].Name]
+	s.GreaterOrEqual(first.Codepoint, 0xE000)
+	css := string(files["product-icons.css"])
+	s.Contains(css, "font-family: \"Product Icons\"")
+	s.Contains(css, "."+first.ClassName+"::before {\n  content: \"\\"+first.Unicode+"\";")
+}
+
+func (s *iconFontTestSuite) TestCodepointsAreStable() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+	statusCode, err := session.addTag(dataIn[1].Name, "finance")
+	s.NoError(err)
+	s.Equal(201, statusCode)
+
+	tagged := s.codepointMap(s.mustGetFont(session, "tags=finance"), services.DefaultFontFamily)
+	s.Equal(1, len(tagged.Icons))
+	all := s.codepointMap(s.mustGetFont(session, ""), services.DefaultFontFamily)
+	s.Equal(2, len(all.Icons))
+	s.Equal(tagged.Icons[dataIn[1].Name], all.Icons[dataIn[1].Name])
+	s.NotEqual(all.Icons[dataIn[0].Name].Codepoint, all.Icons[dataIn[1].Name].Codepoint)
+
+	statusCode, err = session.deleteIcon(dataIn[0].Name)
+	s.NoError(err)
+	s.Equal(204, statusCode)
+	session.mustAddTestData(dataIn[:1])
+
+	again := s.codepointMap(s.mustGetFont(session, ""), services.DefaultFontFamily)
+	s.Equal(all.Icons, again.Icons)
+}
+
+func (s *iconFontTestSuite) TestServeCodepointsAssignedOnCreationToReaders() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+	session.mustSetAuthorization([]authr.PermissionID{})
+
+	codepoints := s.codepointMap(s.mustGetFont(session, ""), services.DefaultFontFamily)
+	s.Equal(2, len(codepoints.Icons))
+	s.NotEqual(codepoints.Icons[dataIn[0].Name].Codepoint, codepoints.Icons[dataIn[1].Name].Codepoint)
+}
+
+func (s *iconFontTestSuite) TestRejectInvalidFontFamily() {
+	session := s.client.mustLoginSetAllPerms()
+	resp, err := session.get(&testRequest{path: "/font?family=%22%7D%3Cscript%3E"})
+	s.NoError(err)
+	s.Equal(400, resp.statusCode)
+}
@@ -2,8 +2,11 @@ package api
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 
+	"github.com/pdkovacs/igo-repo/api"
+	"github.com/pdkovacs/igo-repo/domain"
 	"github.com/pdkovacs/igo-repo/test/api/testdata"
 	"github.com/stretchr/testify/suite"
 )
@@ -49,3 +52,125 @@ func (s *iconGetTestSuite) TestReturn404ForNonExistentIcon() {
 
 	s.assertEndState()
 }
+
+func (s *iconGetTestSuite) TestServeIconfileContentWithContentType() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+
+	for _, iconfile := range dataIn[0].Iconfiles {
+		content := []byte{}
+		resp, err := session.get(&testRequest{
+			path:          getFilePath(dataIn[0].Name, iconfile.IconfileDescriptor),
+			respBodyProto: &content,
+		})
+		s.NoError(err)
+		s.Equal(200, resp.statusCode)
+		s.Equal(iconfile.Content, content)
+		expectedContentType := "image/png"
+		if iconfile.Format == "svg" {
+			expectedContentType = "image/svg+xml"
+		}
+		s.Equal(expectedContentType, resp.headers["Content-Type"][0])
+	}
+
+	s.assertEndState()
+}
+
+func (s *iconGetTestSuite) TestServeIconfileMetadataWhenJSONAccepted() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+
+	iconfile := dataIn[0].Iconfiles[0]
+	resp, err := session.get(&testRequest{
+		path:          getFilePath(dataIn[0].Name, iconfile.IconfileDescriptor),
+		headers:       map[string]string{"Accept": "application/json"},
+		respBodyProto: &api.IconfileMetadata{},
+	})
+	s.NoError(err)
+	s.Equal(200, resp.statusCode)
+	contentHash := domain.ContentHash(iconfile.Content)
+	s.Equal(&api.IconfileMetadata{
+		IconPath:      api.CreateIconPath("/icon", dataIn[0].Name, iconfile.IconfileDescriptor),
+		ContentType:   "image/svg+xml",
+		ContentLength: len(iconfile.Content),
+		ContentHash:   contentHash,
+		ImmutablePath: "/iconfile/" + contentHash,
+	}, resp.body)
+
+	s.assertEndState()
+}
+
+func (s *iconGetTestSuite) TestReturn404ForNonExistentIconfile() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+
+	resp, err := session.get(&testRequest{
+		path: getFilePath(dataIn[0].Name, domain.IconfileDescriptor{Format: "svg", Size: "512px"}),
+	})
+	s.NoError(err)
+	s.Equal(404, resp.statusCode)
+
+	resp, err = session.get(&testRequest{
+		path: getFilePath("somenonexistentname", dataIn[0].Iconfiles[0].IconfileDescriptor),
+	})
+	s.NoError(err)
+	s.Equal(404, resp.statusCode)
+
+	s.assertEndState()
+}
+
+func (s *iconGetTestSuite) TestHonorConditionalRequestsForIconfiles() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+
+	path := getFilePath(dataIn[0].Name, dataIn[0].Iconfiles[0].IconfileDescriptor)
+	resp, err := session.get(&testRequest{path: path})
+	s.NoError(err)
+	s.Equal(200, resp.statusCode)
+	etag := resp.headers["Etag"][0]
+	s.Equal(fmt.Sprintf("\"%s\"", domain.ContentHash(dataIn[0].Iconfiles[0].Content)), etag)
+	lastModified := resp.headers["Last-Modified"][0]
+
+	resp, err = session.get(&testRequest{path: path, headers: map[string]string{"If-None-Match": etag}})
+	s.NoError(err)
+	s.Equal(304, resp.statusCode)
+
+	resp, err = session.get(&testRequest{path: path, headers: map[string]string{"If-None-Match": "\"some-other-etag\""}})
+	s.NoError(err)
+	s.Equal(200, resp.statusCode)
+
+	resp, err = session.get(&testRequest{path: path, headers: map[string]string{"If-Modified-Since": lastModified}})
+	s.NoError(err)
+	s.Equal(304, resp.statusCode)
+
+	s.assertEndState()
+}
+
+func (s *iconGetTestSuite) TestServeIconfileByContentHashAsImmutable() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+
+	iconfile := dataIn[0].Iconfiles[0]
+	contentHash := domain.ContentHash(iconfile.Content)
+	content := []byte{}
+	resp, err := session.get(&testRequest{
+		path:          "/iconfile/" + contentHash,
+		respBodyProto: &content,
+	})
+	s.NoError(err)
+	s.Equal(200, resp.statusCode)
+	s.Equal(iconfile.Content, content)
+	s.Equal("image/svg+xml", resp.headers["Content-Type"][0])
+	s.Contains(resp.headers["Cache-Control"][0], "immutable")
+
+	resp, err = session.get(&testRequest{path: "/iconfile/" + domain.ContentHash([]byte("no such content"))})
+	s.NoError(err)
+	s.Equal(404, resp.statusCode)
+
+	s.assertEndState()
+}
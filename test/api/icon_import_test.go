@@ -0,0 +1,189 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/pdkovacs/igo-repo/domain"
+	"github.com/pdkovacs/igo-repo/security/authr"
+	"github.com/pdkovacs/igo-repo/services"
+	"github.com/pdkovacs/igo-repo/test/api/testdata"
+	"github.com/stretchr/testify/suite"
+)
+
+type iconImportTestSuite struct {
+	iconTestSuite
+}
+
+func TestIconImportTestSuite(t *testing.T) {
+	suite.Run(t, &iconImportTestSuite{})
+}
+
+func demoDataPath(iconName string, iconfile domain.IconfileDescriptor) string {
+	return fmt.Sprintf("%s/%s/%s.%s", iconfile.Format, iconfile.Size, iconName, iconfile.Format)
+}
+
+func gitLayoutPath(iconName string, iconfile domain.IconfileDescriptor) string {
+	return fmt.Sprintf("%s/%s/%s@%s.%s", iconfile.Format, iconfile.Size, iconName, iconfile.Size, iconfile.Format)
+}
+
+func makeImportArchive(files map[string][]byte) []byte {
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for name, content := range files {
+		fileWriter, err := writer.Create(name)
+		if err != nil {
+			panic(err)
+		}
+		_, err = fileWriter.Write(content)
+		if err != nil {
+			panic(err)
+		}
+	}
+	err := writer.Close()
+	if err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+func importEntryStatuses(report services.ImportReport) map[string]string {
+	statuses := map[string]string{}
+	for _, entry := range report.Entries {
+		statuses[entry.Path] = entry.Status
+	}
+	return statuses
+}
+
+func (s *iconImportTestSuite) TestImportIconsInSingleCommit() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+
+	existingIconfile := dataIn[1].Iconfiles[0]
+	statusCode, _, err := session.createIcon(dataIn[1].Name, existingIconfile.Content)
+	s.NoError(err)
+	s.Equal(201, statusCode)
+	commitCountBefore, err := s.testGitRepo.GetCommitCount()
+	s.NoError(err)
+
+	files := map[string][]byte{"README.txt": []byte("not an icon")}
+	expectedStatuses := map[string]string{"README.txt": services.ImportFailed}
+	for _, iconfile := range dataIn[0].Iconfiles {
+		path := demoDataPath(dataIn[0].Name, iconfile.IconfileDescriptor)
+		files[path] = iconfile.Content
+		expectedStatuses[path] = services.ImportCreated
+	}
+	for _, iconfile := range dataIn[1].Iconfiles {
+		path := gitLayoutPath(dataIn[1].Name, iconfile.IconfileDescriptor)
+		files[path] = iconfile.Content
+		expectedStatuses[path] = services.ImportCreated
+	}
+	expectedStatuses[gitLayoutPath(dataIn[1].Name, existingIconfile.IconfileDescriptor)] = services.ImportSkipped
+
+	statusCode, report, err := session.importIcons(makeImportArchive(files), false)
+	s.NoError(err)
+	s.Equal(200, statusCode)
+	s.Equal(expectedStatuses, importEntryStatuses(report))
+	s.Equal(len(dataIn[0].Iconfiles)+len(dataIn[1].Iconfiles)-1, report.Created)
+	s.Equal(1, report.Skipped)
+	s.Equal(1, report.Failed)
+
+	commitCountAfter, err := s.testGitRepo.GetCommitCount()
+	s.NoError(err)
+	s.Equal(commitCountBefore+1, commitCountAfter)
+
+	for _, icon := range dataIn[:2] {
+		for _, iconfile := range icon.Iconfiles {
+			iconfile.Size = testdata.DP2PX[iconfile.Size]
+			s.getCheckIconfile(session, icon.Name, iconfile)
+		}
+	}
+
+	s.assertEndState()
+}
+
+func (s *iconImportTestSuite) TestDryRunLeavesRepositoriesIntact() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+
+	files := map[string][]byte{}
+	for _, iconfile := range dataIn[0].Iconfiles {
+		files[demoDataPath(dataIn[0].Name, iconfile.IconfileDescriptor)] = iconfile.Content
+	}
+
+	statusCode, report, err := session.importIcons(makeImportArchive(files), true)
+	s.NoError(err)
+	s.Equal(200, statusCode)
+	s.True(report.DryRun)
+	s.Equal(len(dataIn[0].Iconfiles), report.Created)
+
+	icons, err := session.describeAllIcons()
+	s.NoError(err)
+	s.Equal(0, len(icons))
+}
+
+func (s *iconImportTestSuite) TestRejectInvalidArchive() {
+	session := s.client.mustLoginSetAllPerms()
+	statusCode, _, _ := session.importIcons([]byte("not a zip"), false)
+	s.Equal(400, statusCode)
+}
+
+func (s *iconImportTestSuite) TestRequireCreatePermissionToImport() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustSetAllPermsExcept([]authr.PermissionID{authr.CREATE_ICON})
+
+	iconfile := dataIn[0].Iconfiles[0]
+	archive := makeImportArchive(map[string][]byte{demoDataPath(dataIn[0].Name, iconfile.IconfileDescriptor): iconfile.Content})
+	statusCode, _, _ := session.importIcons(archive, false)
+	s.Equal(403, statusCode)
+}
+
+func (s *iconImportTestSuite) TestImportIconfilesAtSizeAndInFormatOfContent() {
+	session := s.client.mustLoginSetAllPerms()
+
+	files := map[string][]byte{
+		"jpg/large/photo.jpg":    makeRasterIconfile(40, "jpeg"),
+		"png/24dp/disc.png":      makeRasterIconfile(36, "png"),
+		"png/36px/disc@36px.png": makeRasterIconfile(36, "png"),
+	}
+	statusCode, report, err := session.importIcons(makeImportArchive(files), false)
+	s.NoError(err)
+	s.Equal(200, statusCode)
+	s.Equal(2, report.Created)
+	s.Equal(1, report.Skipped)
+
+	sizes := map[string]string{}
+	for _, entry := range report.Entries {
+		sizes[entry.Path] = entry.Format + "/" + entry.Size
+	}
+	s.Equal(map[string]string{
+		"jpg/large/photo.jpg":    "jpeg/40px",
+		"png/24dp/disc.png":      "png/36px",
+		"png/36px/disc@36px.png": "png/36px",
+	}, sizes)
+
+	_, err = session.GetIconfile("photo", domain.IconfileDescriptor{Format: "jpeg", Size: "40px"})
+	s.NoError(err)
+	_, err = session.GetIconfile("disc", domain.IconfileDescriptor{Format: "png", Size: "36px"})
+	s.NoError(err)
+
+	s.assertEndState()
+}
+
+func (s *iconImportTestSuite) TestRejectArchiveWithTooMuchContentInTotal() {
+	session := s.client.mustLoginSetAllPerms()
+
+	files := map[string][]byte{}
+	for i := 0; i < 5; i++ {
+		files[fmt.Sprintf("svg/24px/filler%d.svg", i)] = make([]byte, 30<<20)
+	}
+	statusCode, _, _ := session.importIcons(makeImportArchive(files), false)
+	s.Equal(400, statusCode)
+
+	icons, err := session.describeAllIcons()
+	s.NoError(err)
+	s.Equal(0, len(icons))
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/pdkovacs/igo-repo/api"
+	"github.com/pdkovacs/igo-repo/test/api/testdata"
+	"github.com/stretchr/testify/suite"
+)
+
+type iconQueryTestSuite struct {
+	iconTestSuite
+}
+
+func TestIconQueryTestSuite(t *testing.T) {
+	suite.Run(t, &iconQueryTestSuite{})
+}
+
+func iconNames(icons []api.ResponseIcon) []string {
+	names := []string{}
+	for _, icon := range icons {
+		names = append(names, icon.Name)
+	}
+	return names
+}
+
+var nextLinkPattern = regexp.MustCompile(`<([^>]*)>; rel="next"`)
+
+func nextCursor(resp testResponse) string {
+	links := resp.headers["Link"]
+	if len(links) == 0 {
+		return ""
+	}
+	match := nextLinkPattern.FindStringSubmatch(links[0])
+	if match == nil {
+		return ""
+	}
+	nextURL, err := url.Parse(match[1])
+	if err != nil {
+		panic(err)
+	}
+	return nextURL.Query().Get("cursor")
+}
+
+func (s *iconQueryTestSuite) TestFilterIconsByNameAndFormat() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+
+	_, icons, err := session.queryIcons("name=MONEY")
+	s.NoError(err)
+	s.Equal([]string{"attach_money"}, iconNames(icons))
+
+	_, icons, err = session.queryIcons("prefix=cast")
+	s.NoError(err)
+	s.Equal([]string{"cast_connected"}, iconNames(icons))
+
+	_, icons, err = session.queryIcons("format=svg&size=48px")
+	s.NoError(err)
+	s.Equal([]string{"cast_connected"}, iconNames(icons))
+
+	s.assertEndState()
+}
+
+func (s *iconQueryTestSuite) TestFilterIconsByTags() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+
+	for _, tagging := range []struct{ icon, tag string }{
+		{"attach_money", "finance"},
+		{"attach_money", "outlined"},
+		{"cast_connected", "outlined"},
+	} {
+		statusCode, err := session.addTag(tagging.icon, tagging.tag)
+		s.NoError(err)
+		s.Equal(201, statusCode)
+	}
+
+	_, icons, err := session.queryIcons("tag=finance,outlined")
+	s.NoError(err)
+	s.Equal([]string{"attach_money", "cast_connected"}, iconNames(icons))
+
+	_, icons, err = session.queryIcons("tag=finance&tag=outlined&tagMatch=all")
+	s.NoError(err)
+	s.Equal([]string{"attach_money"}, iconNames(icons))
+}
+
+func (s *iconQueryTestSuite) TestPaginateIcons() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+
+	resp, icons, err := session.queryIcons("sort=-name&limit=1")
+	s.NoError(err)
+	s.Equal([]string{"cast_connected"}, iconNames(icons))
+	cursor := nextCursor(resp)
+	s.NotEmpty(cursor)
+
+	resp, icons, err = session.queryIcons("sort=-name&limit=1&cursor=" + url.QueryEscape(cursor))
+	s.NoError(err)
+	s.Equal([]string{"attach_money"}, iconNames(icons))
+	s.Empty(nextCursor(resp))
+
+	s.assertEndState()
+}
+
+func (s *iconQueryTestSuite) TestRejectInvalidIconQueries() {
+	session := s.client.mustLoginSetAllPerms()
+	for _, query := range []string{"limit=0", "limit=x", "limit=100000", "sort=size", "tagMatch=some", "cursor=garbage"} {
+		resp, _, _ := session.queryIcons(query)
+		s.Equal(400, resp.statusCode, query)
+	}
+}
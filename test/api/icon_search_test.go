@@ -0,0 +1,103 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/pdkovacs/igo-repo/api"
+	"github.com/pdkovacs/igo-repo/security/authr"
+	"github.com/pdkovacs/igo-repo/test/api/testdata"
+	"github.com/stretchr/testify/suite"
+)
+
+type iconSearchTestSuite struct {
+	iconTestSuite
+}
+
+func TestIconSearchTestSuite(t *testing.T) {
+	suite.Run(t, &iconSearchTestSuite{})
+}
+
+func hitNames(hits []api.SearchHit) []string {
+	names := []string{}
+	for _, hit := range hits {
+		names = append(names, hit.Name)
+	}
+	return names
+}
+
+func (s *iconSearchTestSuite) TestFindIconsByNameTagsAndDescription() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+
+	statusCode, err := session.addTag("cast_connected", "chromecast")
+	s.NoError(err)
+	s.Equal(201, statusCode)
+	statusCode, err = session.updateDescription("attach_money", "currency, dollar, payment")
+	s.NoError(err)
+	s.Equal(204, statusCode)
+
+	statusCode, hits, err := session.searchIcons("money")
+	s.NoError(err)
+	s.Equal(200, statusCode)
+	s.Equal([]string{"attach_money"}, hitNames(hits))
+	s.Equal("currency, dollar, payment", hits[0].Description)
+
+	_, hits, err = session.searchIcons("chromecast")
+	s.NoError(err)
+	s.Equal([]string{"cast_connected"}, hitNames(hits))
+
+	_, hits, err = session.searchIcons("payments")
+	s.NoError(err)
+	s.Equal([]string{"attach_money"}, hitNames(hits))
+
+	_, hits, err = session.searchIcons("payment cast")
+	s.NoError(err)
+	s.ElementsMatch([]string{"attach_money", "cast_connected"}, hitNames(hits))
+
+	s.assertEndState()
+}
+
+func (s *iconSearchTestSuite) TestFindIconsBySimilarNames() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+
+	_, hits, err := session.searchIcons("atach")
+	s.NoError(err)
+	s.Equal([]string{"attach_money"}, hitNames(hits))
+
+	s.assertEndState()
+}
+
+func (s *iconSearchTestSuite) TestRankNameMatchesFirst() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+
+	statusCode, err := session.updateDescription("attach_money", "cast a coin")
+	s.NoError(err)
+	s.Equal(204, statusCode)
+
+	_, hits, err := session.searchIcons("cast")
+	s.NoError(err)
+	s.Equal([]string{"cast_connected", "attach_money"}, hitNames(hits))
+	s.Greater(hits[0].Score, hits[1].Score)
+}
+
+func (s *iconSearchTestSuite) TestRejectEmptySearch() {
+	session := s.client.mustLoginSetAllPerms()
+	statusCode, _, _ := session.searchIcons(" ")
+	s.Equal(400, statusCode)
+}
+
+func (s *iconSearchTestSuite) TestRequireUpdatePermissionToChangeDescription() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+	session.mustSetAllPermsExcept([]authr.PermissionID{authr.UPDATE_ICON})
+
+	statusCode, err := session.updateDescription("attach_money", "currency")
+	s.NoError(err)
+	s.Equal(403, statusCode)
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pdkovacs/igo-repo/domain"
+	"github.com/pdkovacs/igo-repo/security/authr"
+	"github.com/pdkovacs/igo-repo/test/common"
+	"github.com/stretchr/testify/suite"
+)
+
+type iconfileDownscalingTestSuite struct {
+	iconTestSuite
+}
+
+func TestIconfileDownscalingTestSuite(t *testing.T) {
+	suite.Run(t, &iconfileDownscalingTestSuite{})
+}
+
+func (s *iconfileDownscalingTestSuite) BeforeTest(suiteName string, testName string) {
+	serverConfig := common.CloneConfig(s.defaultConfig)
+	serverConfig.EnableBackdoors = true
+	serverConfig.GenerateRasterSizeLadder = strings.Contains(testName, "Ladder")
+	serverConfig.PersistRasterizedIconfiles = strings.Contains(testName, "Persist")
+	s.startTestServer(serverConfig)
+}
+
+// makeRasterIconfile draws a disc of the size in the format
+func makeRasterIconfile(size int, format string) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	center := float64(size) / 2
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx, dy := float64(x)+0.5-center, float64(y)+0.5-center
+			if dx*dx+dy*dy <= center*center*0.64 {
+				img.SetNRGBA(x, y, color.NRGBA{R: 0x20, G: 0x60, B: 0xa0, A: 0xff})
+			}
+		}
+	}
+	var out bytes.Buffer
+	var err error
+	if format == "jpeg" {
+		err = jpeg.Encode(&out, img, nil)
+	} else {
+		err = png.Encode(&out, img)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return out.Bytes()
+}
+
+func (s *iconfileDownscalingTestSuite) iconfileSizes(session *apiTestSession, iconName string) []string {
+	statusCode, icon, err := session.describeIcon(iconName)
+	s.NoError(err)
+	s.Equal(200, statusCode)
+	sizes := []string{}
+	for _, path := range icon.Paths {
+		sizes = append(sizes, path.Format+"/"+path.Size)
+	}
+	sort.Strings(sizes)
+	return sizes
+}
+
+func (s *iconfileDownscalingTestSuite) TestDownscaleFromSmallestLargerIconfile() {
+	session := s.client.mustLoginSetAllPerms()
+	statusCode, _, err := session.createIcon("disc", makeRasterIconfile(128, "png"))
+	s.NoError(err)
+	s.Equal(201, statusCode)
+
+	for _, descriptor := range []domain.IconfileDescriptor{{Format: "png", Size: "48px"}, {Format: "jpeg", Size: "32px"}} {
+		iconfile, err := session.GetIconfile("disc", descriptor)
+		s.NoError(err)
+		config, format, err := image.DecodeConfig(bytes.NewReader(iconfile.Content))
+		s.NoError(err)
+		s.Equal(descriptor.Format, format)
+		s.Equal(config.Width, config.Height)
+		s.Equal(descriptor.Size, fmt.Sprintf("%dpx", config.Height))
+	}
+
+	_, err = session.GetIconfile("disc", domain.IconfileDescriptor{Format: "png", Size: "256px"})
+	s.ErrorIs(err, errUnexpecteHTTPStatus)
+
+	s.Equal([]string{"png/128px"}, s.iconfileSizes(session, "disc"))
+	s.assertEndState()
+}
+
+func (s *iconfileDownscalingTestSuite) TestGenerateSizeLadderOnCreate() {
+	session := s.client.mustLoginSetAllPerms()
+	statusCode, icon, err := session.createIcon("disc", makeRasterIconfile(48, "png"))
+	s.NoError(err)
+	s.Equal(201, statusCode)
+	s.Equal(4, len(icon.Paths))
+
+	s.Equal([]string{"png/16px", "png/24px", "png/32px", "png/48px"}, s.iconfileSizes(session, "disc"))
+	iconfile, err := session.GetIconfile("disc", domain.IconfileDescriptor{Format: "png", Size: "16px"})
+	s.NoError(err)
+	config, err := png.DecodeConfig(bytes.NewReader(iconfile.Content))
+	s.NoError(err)
+	s.Equal(16, config.Height)
+
+	s.assertEndState()
+}
+
+func (s *iconfileDownscalingTestSuite) TestGenerateSizeLadderOnAddingIconfile() {
+	session := s.client.mustLoginSetAllPerms()
+	statusCode, _, err := session.createIcon("disc", makeRasterIconfile(24, "jpeg"))
+	s.NoError(err)
+	s.Equal(201, statusCode)
+	s.Equal([]string{"jpeg/16px", "jpeg/24px"}, s.iconfileSizes(session, "disc"))
+
+	statusCode, _, err = session.addIconfile("disc", domain.Iconfile{Content: makeRasterIconfile(100, "jpeg")})
+	s.NoError(err)
+	s.Equal(200, statusCode)
+	s.Equal([]string{"jpeg/100px", "jpeg/16px", "jpeg/24px", "jpeg/32px", "jpeg/48px", "jpeg/64px"}, s.iconfileSizes(session, "disc"))
+
+	s.assertEndState()
+}
+
+func (s *iconfileDownscalingTestSuite) TestPersistDownscaledIconfileOnlyForThoseAllowedToAddIconfiles() {
+	session := s.client.mustLoginSetAllPerms()
+	statusCode, _, err := session.createIcon("disc", makeRasterIconfile(128, "png"))
+	s.NoError(err)
+	s.Equal(201, statusCode)
+
+	session.mustSetAllPermsExcept([]authr.PermissionID{authr.ADD_ICONFILE})
+	_, err = session.GetIconfile("disc", domain.IconfileDescriptor{Format: "jpeg", Size: "32px"})
+	s.NoError(err)
+	s.Equal([]string{"png/128px"}, s.iconfileSizes(session, "disc"))
+
+	session.mustSetAuthorization(authr.GetPermissionsForGroup(authr.ICON_EDITOR))
+	_, err = session.GetIconfile("disc", domain.IconfileDescriptor{Format: "png", Size: "48px"})
+	s.NoError(err)
+	s.Equal([]string{"png/128px", "png/48px"}, s.iconfileSizes(session, "disc"))
+
+	s.assertEndState()
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/pdkovacs/igo-repo/domain"
+	"github.com/pdkovacs/igo-repo/security/authr"
+	"github.com/pdkovacs/igo-repo/test/api/testdata"
+	"github.com/pdkovacs/igo-repo/test/common"
+	"github.com/stretchr/testify/suite"
+)
+
+type iconfileRasterizationTestSuite struct {
+	iconTestSuite
+}
+
+func TestIconfileRasterizationTestSuite(t *testing.T) {
+	suite.Run(t, &iconfileRasterizationTestSuite{})
+}
+
+func (s *iconfileRasterizationTestSuite) BeforeTest(suiteName string, testName string) {
+	serverConfig := common.CloneConfig(s.defaultConfig)
+	serverConfig.EnableBackdoors = true
+	serverConfig.PersistRasterizedIconfiles = strings.Contains(testName, "Persist")
+	s.startTestServer(serverConfig)
+}
+
+func (s *iconfileRasterizationTestSuite) hasIconfile(session *apiTestSession, iconName string, descriptor domain.IconfileDescriptor) bool {
+	statusCode, icon, err := session.describeIcon(iconName)
+	s.NoError(err)
+	s.Equal(200, statusCode)
+	for _, path := range icon.Paths {
+		if path.IconfileDescriptor == descriptor {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *iconfileRasterizationTestSuite) TestRasterizeMissingPNGFromSVG() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+	descriptor := domain.IconfileDescriptor{Format: "png", Size: "48px"}
+
+	iconfile, err := session.GetIconfile(dataIn[1].Name, descriptor)
+	s.NoError(err)
+	config, err := png.DecodeConfig(bytes.NewReader(iconfile.Content))
+	s.NoError(err)
+	s.Equal(48, config.Width)
+	s.Equal(48, config.Height)
+
+	again, err := session.GetIconfile(dataIn[1].Name, descriptor)
+	s.NoError(err)
+	s.Equal(iconfile.Content, again.Content)
+
+	s.False(s.hasIconfile(session, dataIn[1].Name, descriptor))
+	s.assertReposInSync()
+}
+
+func (s *iconfileRasterizationTestSuite) TestRejectRasterizingAtOversizedOrUnknownSizes() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+
+	resp, err := session.get(&testRequest{path: getFilePath(dataIn[1].Name, domain.IconfileDescriptor{Format: "png", Size: "4096px"})})
+	s.NoError(err)
+	s.Equal(400, resp.statusCode)
+
+	resp, err = session.get(&testRequest{path: getFilePath(dataIn[1].Name, domain.IconfileDescriptor{Format: "png", Size: "48dp"})})
+	s.NoError(err)
+	s.Equal(404, resp.statusCode)
+
+	resp, err = session.get(&testRequest{path: getFilePath("no_such_icon", domain.IconfileDescriptor{Format: "png", Size: "48px"})})
+	s.NoError(err)
+	s.Equal(404, resp.statusCode)
+}
+
+func (s *iconfileRasterizationTestSuite) TestRejectRasterizingWiderThanMaximumSize() {
+	session := s.client.mustLoginSetAllPerms()
+	wide := `<svg xmlns="http://www.w3.org/2000/svg" width="100" height="10" viewBox="0 0 100 10"><path d="M0 0h100v10H0z"/></svg>`
+	statusCode, _, err := session.createIcon("wide", []byte(wide))
+	s.NoError(err)
+	s.Equal(201, statusCode)
+
+	resp, err := session.get(&testRequest{path: getFilePath("wide", domain.IconfileDescriptor{Format: "png", Size: "512px"})})
+	s.NoError(err)
+	s.Equal(400, resp.statusCode)
+
+	iconfile, err := session.GetIconfile("wide", domain.IconfileDescriptor{Format: "png", Size: "100px"})
+	s.NoError(err)
+	config, err := png.DecodeConfig(bytes.NewReader(iconfile.Content))
+	s.NoError(err)
+	s.Equal(1000, config.Width)
+}
+
+func (s *iconfileRasterizationTestSuite) TestPersistRasterizedIconfile() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+	descriptor := domain.IconfileDescriptor{Format: "png", Size: "96px"}
+
+	iconfile, err := session.GetIconfile(dataIn[0].Name, descriptor)
+	s.NoError(err)
+	config, err := png.DecodeConfig(bytes.NewReader(iconfile.Content))
+	s.NoError(err)
+	s.Equal(96, config.Height)
+
+	s.True(s.hasIconfile(session, dataIn[0].Name, descriptor))
+	s.getCheckIconfile(session, dataIn[0].Name, iconfile)
+	s.assertReposInSync()
+}
+
+func (s *iconfileRasterizationTestSuite) TestShouldNotPersistRasterizedIconfileWithoutPermissionToAddIconfiles() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+	session.mustSetAllPermsExcept([]authr.PermissionID{authr.ADD_ICONFILE})
+	descriptor := domain.IconfileDescriptor{Format: "png", Size: "96px"}
+
+	iconfile, err := session.GetIconfile(dataIn[0].Name, descriptor)
+	s.NoError(err)
+	config, err := png.DecodeConfig(bytes.NewReader(iconfile.Content))
+	s.NoError(err)
+	s.Equal(96, config.Height)
+
+	s.False(s.hasIconfile(session, dataIn[0].Name, descriptor))
+	s.assertReposInSync()
+}
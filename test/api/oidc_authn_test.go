@@ -0,0 +1,201 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/pdkovacs/igo-repo/config"
+	"github.com/pdkovacs/igo-repo/security/authn"
+	"github.com/pdkovacs/igo-repo/security/authr"
+	"github.com/pdkovacs/igo-repo/services"
+	"github.com/pdkovacs/igo-repo/test/common"
+	"github.com/stretchr/testify/suite"
+)
+
+const oidcTestClientID = "igo-repo-itest"
+const oidcTestSubject = "9XE3-JI34-00132A"
+const oidcTestGroup = "design-team"
+
+type oidcAuthnTestSuite struct {
+	apiTestSuite
+	idp *fakeOIDCProvider
+}
+
+func TestOIDCAuthnTestSuite(t *testing.T) {
+	suite.Run(t, &oidcAuthnTestSuite{})
+}
+
+func (s *oidcAuthnTestSuite) BeforeTest(suiteName string, testName string) {
+	s.idp = newFakeOIDCProvider(oidcTestClientID, oidcTestSubject)
+
+	serverConfig := common.CloneConfig(s.defaultConfig)
+	serverConfig.AuthenticationType = config.OIDCAuthentication
+	serverConfig.PasswordCredentials = nil
+	serverConfig.OIDCClientID = oidcTestClientID
+	serverConfig.OIDCClientSecret = "igo-repo-itest-secret"
+	serverConfig.OIDCUserAuthorizationURL = s.idp.url("/authorize")
+	serverConfig.OIDCAccessTokenURL = s.idp.url("/token")
+	serverConfig.OIDCTokenIssuer = s.idp.issuer
+	serverConfig.OIDCIpJwtPublicKeyPemBase64 = s.idp.publicKeyPEMBase64()
+	serverConfig.OIDCIpLogoutURL = s.idp.url("/logout")
+	userID := authn.IssuerDomain(s.idp.issuer).CreateUserID(oidcTestSubject)
+	serverConfig.UsersByRoles = config.UsersByRoles{
+		string(authr.ICON_EDITOR): {userID.String()},
+	}
+	if testName == "TestShouldMapGroupClaimToGroups" {
+		s.idp.groups = []string{oidcTestGroup, "unmapped-team"}
+		serverConfig.UsersByRoles = nil
+		serverConfig.GroupClaimsByRoles = config.ClaimsByRoles{
+			string(authr.ADMINISTRATOR): {oidcTestGroup},
+		}
+	}
+	s.startTestServer(serverConfig)
+}
+
+func (s *oidcAuthnTestSuite) AfterTest(suiteName, testName string) {
+	s.idp.close()
+	s.apiTestSuite.AfterTest(suiteName, testName)
+}
+
+func (s *oidcAuthnTestSuite) TestShouldLoginThroughIdentityProvider() {
+	userID := authn.IssuerDomain(s.idp.issuer).CreateUserID(oidcTestSubject)
+	expectedUserInfo := services.UserInfo{
+		UserId:      userID,
+		Groups:      []authr.GroupID{authr.ICON_EDITOR},
+		Permissions: authr.GetPermissionsForGroup(authr.ICON_EDITOR),
+		DisplayName: userID.String(),
+	}
+
+	cjar := s.client.MustCreateCookieJar()
+	resp, err := s.client.get(&testRequest{
+		path:          "/user",
+		jar:           cjar,
+		respBodyProto: &services.UserInfo{},
+	})
+	s.NoError(err)
+	s.Equal(200, resp.statusCode)
+	s.Equal(&expectedUserInfo, resp.body)
+	s.Equal(1, s.idp.getAuthorizationCount())
+
+	resp, err = s.client.get(&testRequest{
+		path:          "/user",
+		jar:           cjar,
+		respBodyProto: &services.UserInfo{},
+	})
+	s.NoError(err)
+	s.Equal(200, resp.statusCode)
+	s.Equal(&expectedUserInfo, resp.body)
+	s.Equal(1, s.idp.getAuthorizationCount())
+}
+
+func (s *oidcAuthnTestSuite) TestShouldMapGroupClaimToGroups() {
+	resp, err := s.client.get(&testRequest{
+		path:          "/user",
+		jar:           s.client.MustCreateCookieJar(),
+		respBodyProto: &services.UserInfo{},
+	})
+	s.NoError(err)
+	s.Equal(200, resp.statusCode)
+	userInfo := resp.body.(*services.UserInfo)
+	s.Equal(s.idp.issuer, userInfo.UserId.DomainID)
+	s.Equal([]authr.GroupID{authr.ADMINISTRATOR}, userInfo.Groups)
+	s.ElementsMatch(authr.GetPermissionsForGroup(authr.ADMINISTRATOR), userInfo.Permissions)
+}
+
+func (s *oidcAuthnTestSuite) TestShouldRejectIDTokenFromUnexpectedIssuer() {
+	s.idp.issuer = "http://some.other.issuer"
+
+	resp, err := s.client.get(&testRequest{
+		path: "/user",
+		jar:  s.client.MustCreateCookieJar(),
+	})
+	s.NoError(err)
+	s.Equal(401, resp.statusCode)
+	s.Equal(1, s.idp.getAuthorizationCount())
+}
+
+func (s *oidcAuthnTestSuite) TestShouldRejectCallbackWithForgedState() {
+	resp, err := s.client.get(&testRequest{
+		path: "/login?code=some-code&state=some-state",
+		jar:  s.client.MustCreateCookieJar(),
+	})
+	s.NoError(err)
+	s.Equal(401, resp.statusCode)
+	s.Equal(0, s.idp.getAuthorizationCount())
+}
+
+func (s *oidcAuthnTestSuite) TestShouldNotRedirectNonGETRequests() {
+	resp, err := s.client.post(&testRequest{
+		path: "/icon",
+		jar:  s.client.MustCreateCookieJar(),
+	})
+	s.NoError(err)
+	s.Equal(401, resp.statusCode)
+	s.Equal(0, s.idp.getAuthorizationCount())
+}
+
+func (s *oidcAuthnTestSuite) TestLogoutShouldEndSessionAndRedirectToIdentityProvider() {
+	cjar := s.client.MustCreateCookieJar()
+	resp, err := s.client.get(&testRequest{
+		path: "/user",
+		jar:  cjar,
+	})
+	s.NoError(err)
+	s.Equal(200, resp.statusCode)
+	s.Equal(1, s.idp.getAuthorizationCount())
+
+	resp, err = s.client.post(&testRequest{
+		path: "/logout",
+		jar:  cjar,
+	})
+	s.NoError(err)
+	s.Equal(200, resp.statusCode)
+	s.Equal(1, s.idp.getLogoutRequestsCount())
+
+	resp, err = s.client.get(&testRequest{
+		path: "/user",
+		jar:  cjar,
+	})
+	s.NoError(err)
+	s.Equal(200, resp.statusCode)
+	s.Equal(2, s.idp.getAuthorizationCount())
+}
+
+// cookieRecordingJar remembers the cookies the server has set in the order it has set them
+type cookieRecordingJar struct {
+	http.CookieJar
+	set []*http.Cookie
+}
+
+func (jar *cookieRecordingJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	jar.set = append(jar.set, cookies...)
+	jar.CookieJar.SetCookies(u, cookies)
+}
+
+func (s *oidcAuthnTestSuite) TestShouldRenewSessionIDOnLogin() {
+	cjar := &cookieRecordingJar{CookieJar: s.client.MustCreateCookieJar()}
+	resp, err := s.client.get(&testRequest{
+		path: "/user",
+		jar:  cjar,
+	})
+	s.NoError(err)
+	s.Equal(200, resp.statusCode)
+	s.GreaterOrEqual(len(cjar.set), 2)
+	preAuthentication, authenticated := cjar.set[0], cjar.set[len(cjar.set)-1]
+	s.NotEqual(preAuthentication.Value, authenticated.Value)
+
+	// The session id handed out before logging in must not be usable after it
+	plantedJar := s.client.MustCreateCookieJar()
+	serverURL, err := url.Parse(fmt.Sprintf("http://localhost:%d/", s.client.serverPort))
+	s.NoError(err)
+	plantedJar.SetCookies(serverURL, []*http.Cookie{{Name: preAuthentication.Name, Value: preAuthentication.Value}})
+	resp, err = s.client.get(&testRequest{
+		path:    "/user",
+		jar:     plantedJar,
+		headers: map[string]string{"X-Requested-With": "XMLHttpRequest"},
+	})
+	s.NoError(err)
+	s.Equal(401, resp.statusCode)
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/pdkovacs/igo-repo/security/authr"
+	"github.com/pdkovacs/igo-repo/services"
+	"github.com/pdkovacs/igo-repo/test/api/testdata"
+	"github.com/pdkovacs/igo-repo/test/common"
+	"github.com/stretchr/testify/suite"
+)
+
+type rolesTestSuite struct {
+	apiTestSuite
+}
+
+func TestRolesTestSuite(t *testing.T) {
+	suite.Run(t, &rolesTestSuite{})
+}
+
+func (s *rolesTestSuite) BeforeTest(suiteName string, testName string) {
+	serverConfig := common.CloneConfig(s.defaultConfig)
+	serverConfig.PermissionsByRoles = map[string][]string{
+		"TAG_CURATOR": {string(authr.ADD_TAG), string(authr.REMOVE_TAG)},
+		"CONTRIBUTOR": {string(authr.CREATE_ICON), string(authr.ADD_ICONFILE), string(authr.ADD_TAG)},
+	}
+	serverConfig.UsersByRoles = map[string][]string{
+		"TAG_CURATOR": {testdata.DefaultCredentials.Username},
+		"CONTRIBUTOR": {testdata.DefaultCredentials.Username},
+	}
+	s.startTestServer(serverConfig)
+}
+
+func (s *rolesTestSuite) TestShouldGrantPermissionsOfConfiguredRoles() {
+	session := s.client.mustLogin(nil)
+	resp, err := session.get(&testRequest{
+		path:          "/user",
+		respBodyProto: &services.UserInfo{},
+	})
+	s.NoError(err)
+	s.Equal(200, resp.statusCode)
+	userInfo := resp.body.(*services.UserInfo)
+	s.ElementsMatch([]authr.GroupID{"TAG_CURATOR", "CONTRIBUTOR"}, userInfo.Groups)
+	s.ElementsMatch([]authr.PermissionID{
+		authr.ADD_TAG,
+		authr.REMOVE_TAG,
+		authr.CREATE_ICON,
+		authr.ADD_ICONFILE,
+	}, userInfo.Permissions)
+}
+
+func (s *rolesTestSuite) TestShouldNotGrantPermissionsOutsideConfiguredRoles() {
+	session := s.client.mustLogin(nil)
+	statusCode, err := session.deleteIcon("no-matter")
+	s.NoError(err)
+	s.Equal(403, statusCode)
+}
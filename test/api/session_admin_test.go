@@ -0,0 +1,143 @@
+package api
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pdkovacs/igo-repo/api"
+	"github.com/pdkovacs/igo-repo/config"
+	"github.com/pdkovacs/igo-repo/domain"
+	"github.com/pdkovacs/igo-repo/security/authr"
+	"github.com/pdkovacs/igo-repo/test/api/testdata"
+	"github.com/pdkovacs/igo-repo/test/common"
+	"github.com/stretchr/testify/suite"
+)
+
+var sessionAdminCredentials = config.PasswordCredentials{Username: "session-admin", Password: "session-admin"}
+
+type sessionAdminTestSuite struct {
+	apiTestSuite
+}
+
+func TestSessionAdminTestSuite(t *testing.T) {
+	suite.Run(t, &sessionAdminTestSuite{})
+}
+
+func (s *sessionAdminTestSuite) BeforeTest(suiteName string, testName string) {
+	serverConfig := common.CloneConfig(s.defaultConfig)
+	serverConfig.EnableBackdoors = true
+	serverConfig.PasswordCredentials = append(serverConfig.PasswordCredentials, sessionAdminCredentials)
+	serverConfig.SessionStore = config.DatabaseSessionStore
+	serverConfig.SessionKeys = []string{randomSessionKey()}
+	s.startTestServer(serverConfig)
+}
+
+func (s *sessionAdminTestSuite) mustLoginAsAdmin() *apiTestSession {
+	credentials := s.client.makeRequestCredentials(sessionAdminCredentials)
+	session := s.client.mustLogin(&credentials)
+	resp, err := session.sendRequest("PUT", &testRequest{
+		path:        authenticationBackdoorPath,
+		credentials: &credentials,
+		jar:         session.cjar,
+		json:        true,
+		body:        []authr.PermissionID{authr.MANAGE_SESSIONS},
+	})
+	s.Require().NoError(err)
+	s.Require().Equal(200, resp.statusCode)
+	return session
+}
+
+func (s *sessionAdminTestSuite) listSessions(session *apiTestSession, user string) (int, []domain.SessionInfo) {
+	resp, err := session.get(&testRequest{
+		path:          fmt.Sprintf("/admin/sessions?user=%s", user),
+		respBodyProto: &[]domain.SessionInfo{},
+	})
+	if resp.statusCode != 200 {
+		return resp.statusCode, nil
+	}
+	s.Require().NoError(err)
+	return resp.statusCode, *resp.body.(*[]domain.SessionInfo)
+}
+
+func (s *sessionAdminTestSuite) getUserStatus(session *apiTestSession) int {
+	resp, err := session.get(&testRequest{path: "/user"})
+	s.NoError(err)
+	return resp.statusCode
+}
+
+func (s *sessionAdminTestSuite) TestShouldListSessionsPerUser() {
+	s.client.mustLogin(nil)
+	s.client.mustLogin(nil)
+	admin := s.mustLoginAsAdmin()
+
+	statusCode, sessions := s.listSessions(admin, testdata.DefaultCredentials.Username)
+	s.Equal(200, statusCode)
+	s.Equal(2, len(sessions))
+	for _, session := range sessions {
+		s.Equal(testdata.DefaultCredentials.Username, session.Owner)
+		s.True(session.ExpiresAt.After(session.CreatedAt))
+	}
+
+	statusCode, sessions = s.listSessions(admin, "")
+	s.Equal(200, statusCode)
+	s.Equal(3, len(sessions))
+}
+
+func (s *sessionAdminTestSuite) TestShouldRevokeSingleSession() {
+	userSession1 := s.client.mustLogin(nil)
+	userSession2 := s.client.mustLogin(nil)
+	admin := s.mustLoginAsAdmin()
+
+	_, sessions := s.listSessions(admin, testdata.DefaultCredentials.Username)
+	s.Require().Equal(2, len(sessions))
+
+	resp, err := admin.sendRequest("DELETE", &testRequest{
+		path: fmt.Sprintf("/admin/sessions/%d", sessions[0].Handle),
+		jar:  admin.cjar,
+	})
+	s.NoError(err)
+	s.Equal(204, resp.statusCode)
+
+	s.Equal(401, s.getUserStatus(userSession1))
+	s.Equal(200, s.getUserStatus(userSession2))
+
+	resp, err = admin.sendRequest("DELETE", &testRequest{
+		path: fmt.Sprintf("/admin/sessions/%d", sessions[0].Handle),
+		jar:  admin.cjar,
+	})
+	s.NoError(err)
+	s.Equal(404, resp.statusCode)
+}
+
+func (s *sessionAdminTestSuite) TestShouldRevokeAllSessionsOfUser() {
+	userSession1 := s.client.mustLogin(nil)
+	userSession2 := s.client.mustLogin(nil)
+	admin := s.mustLoginAsAdmin()
+
+	resp, err := admin.sendRequest("DELETE", &testRequest{
+		path:          fmt.Sprintf("/admin/sessions?user=%s", testdata.DefaultCredentials.Username),
+		jar:           admin.cjar,
+		respBodyProto: &api.RevokedSessionsResponse{},
+	})
+	s.NoError(err)
+	s.Equal(200, resp.statusCode)
+	s.Equal(&api.RevokedSessionsResponse{Revoked: 2}, resp.body)
+
+	s.Equal(401, s.getUserStatus(userSession1))
+	s.Equal(401, s.getUserStatus(userSession2))
+	s.Equal(200, s.getUserStatus(admin))
+}
+
+func (s *sessionAdminTestSuite) TestShouldNotAllowSessionManagementWithoutPermission() {
+	userSession := s.client.mustLoginSetAllPerms()
+
+	statusCode, _ := s.listSessions(userSession, "")
+	s.Equal(403, statusCode)
+
+	resp, err := userSession.sendRequest("DELETE", &testRequest{
+		path: fmt.Sprintf("/admin/sessions?user=%s", sessionAdminCredentials.Username),
+		jar:  userSession.cjar,
+	})
+	s.NoError(err)
+	s.Equal(403, resp.statusCode)
+}
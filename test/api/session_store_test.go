@@ -0,0 +1,143 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/pdkovacs/igo-repo/config"
+	"github.com/pdkovacs/igo-repo/test/common"
+	"github.com/stretchr/testify/suite"
+)
+
+type sessionStoreTestSuite struct {
+	apiTestSuite
+	serverConfig config.Options
+}
+
+func TestSessionStoreTestSuite(t *testing.T) {
+	suite.Run(t, &sessionStoreTestSuite{})
+}
+
+func randomSessionKey() string {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func (s *sessionStoreTestSuite) BeforeTest(suiteName string, testName string) {
+	s.serverConfig = common.CloneConfig(s.defaultConfig)
+	s.serverConfig.SessionKeys = []string{randomSessionKey()}
+	s.serverConfig.SessionCookieName = "igo-itest-session"
+	s.serverConfig.SessionMaxAge = 3600
+	s.serverConfig.SessionCookieSecure = false
+	s.serverConfig.SessionCookieSameSite = "strict"
+
+	switch testName {
+	case "TestCookieSessionShouldSurviveRestart":
+		s.serverConfig.SessionStore = config.CookieSessionStore
+	case "TestMemorySessionShouldNotSurviveRestart":
+		s.serverConfig.SessionStore = config.MemorySessionStore
+	default:
+		s.serverConfig.SessionStore = config.DatabaseSessionStore
+	}
+	s.startTestServer(s.serverConfig)
+}
+
+func (s *sessionStoreTestSuite) restartTestServer(options config.Options, session *apiTestSession) {
+	s.terminateTestServer()
+	s.server.Repositories.DB.Close()
+	s.startTestServer(options)
+	session.serverPort = s.client.serverPort
+}
+
+func (s *sessionStoreTestSuite) getSessionCookie(resp testResponse) *http.Cookie {
+	header := http.Header{"Set-Cookie": resp.headers["Set-Cookie"]}
+	for _, cookie := range (&http.Response{Header: header}).Cookies() {
+		if cookie.Name == s.serverConfig.SessionCookieName {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func (s *sessionStoreTestSuite) getUserStatus(session *apiTestSession) int {
+	resp, err := session.get(&testRequest{path: "/user"})
+	s.NoError(err)
+	return resp.statusCode
+}
+
+func (s *sessionStoreTestSuite) loginWithCookieCheck() *apiTestSession {
+	credentials := s.client.makeRequestCredentials(config.PasswordCredentials{})
+	cjar := s.client.MustCreateCookieJar()
+	resp, err := s.client.post(&testRequest{
+		path:        "/login",
+		credentials: &credentials,
+		jar:         cjar,
+	})
+	s.Require().NoError(err)
+	s.Require().Equal(200, resp.statusCode)
+
+	cookie := s.getSessionCookie(resp)
+	s.Require().NotNil(cookie)
+	s.Equal(3600, cookie.MaxAge)
+	s.True(cookie.HttpOnly)
+	s.Equal(http.SameSiteStrictMode, cookie.SameSite)
+
+	return &apiTestSession{
+		apiTestClient: apiTestClient{serverPort: s.client.serverPort},
+		cjar:          cjar,
+	}
+}
+
+func (s *sessionStoreTestSuite) TestCookieSessionShouldSurviveRestart() {
+	session := s.loginWithCookieCheck()
+	s.restartTestServer(s.serverConfig, session)
+	s.Equal(200, s.getUserStatus(session))
+}
+
+func (s *sessionStoreTestSuite) TestDatabaseSessionShouldSurviveRestart() {
+	session := s.loginWithCookieCheck()
+	s.restartTestServer(s.serverConfig, session)
+	s.Equal(200, s.getUserStatus(session))
+}
+
+func (s *sessionStoreTestSuite) TestMemorySessionShouldNotSurviveRestart() {
+	session := s.loginWithCookieCheck()
+	s.restartTestServer(s.serverConfig, session)
+	s.Equal(401, s.getUserStatus(session))
+}
+
+func (s *sessionStoreTestSuite) TestDatabaseSessionShouldEndWithLogout() {
+	session := s.loginWithCookieCheck()
+
+	resp, err := session.sendRequest("POST", &testRequest{path: "/logout", jar: session.cjar})
+	s.NoError(err)
+	s.Equal(204, resp.statusCode)
+	s.Equal(401, s.getUserStatus(session))
+
+	var count int
+	err = s.server.Repositories.DB.ConnectionPool.QueryRow("SELECT count(*) FROM http_session").Scan(&count)
+	s.NoError(err)
+	s.Equal(0, count)
+}
+
+func (s *sessionStoreTestSuite) TestSessionShouldNotSurviveKeyRotationWithoutOldKey() {
+	session := s.loginWithCookieCheck()
+	rotatedConfig := common.CloneConfig(s.serverConfig)
+	rotatedConfig.SessionKeys = []string{randomSessionKey()}
+	s.restartTestServer(rotatedConfig, session)
+	s.Equal(401, s.getUserStatus(session))
+}
+
+func (s *sessionStoreTestSuite) TestSessionShouldSurviveKeyRotationWithOldKey() {
+	session := s.loginWithCookieCheck()
+	rotatedConfig := common.CloneConfig(s.serverConfig)
+	rotatedConfig.SessionKeys = append([]string{randomSessionKey()}, s.serverConfig.SessionKeys...)
+	s.restartTestServer(rotatedConfig, session)
+	s.Equal(200, s.getUserStatus(session))
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/pdkovacs/igo-repo/test/api/testdata"
+	"github.com/stretchr/testify/suite"
+)
+
+type spriteTestSuite struct {
+	iconTestSuite
+}
+
+func TestSpriteTestSuite(t *testing.T) {
+	suite.Run(t, &spriteTestSuite{})
+}
+
+func (s *spriteTestSuite) mustGetSprite(session *apiTestSession, query string, headers map[string]string) (testResponse, string) {
+	content := []byte{}
+	resp, err := session.get(&testRequest{
+		path:          "/sprite.svg?" + query,
+		headers:       headers,
+		respBodyProto: &content,
+	})
+	s.NoError(err)
+	return resp, string(content)
+}
+
+func (s *spriteTestSuite) TestAssembleSpriteOfTaggedIcons() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+	statusCode, err := session.addTag(dataIn[0].Name, "finance")
+	s.NoError(err)
+	s.Equal(201, statusCode)
+
+	resp, sprite := s.mustGetSprite(session, "tags=finance", nil)
+	s.Equal(200, resp.statusCode)
+	s.Equal("image/svg+xml", resp.headers["Content-Type"][0])
+	s.Contains(sprite, "<symbol id=\""+dataIn[0].Name+"\" viewBox=\"0 0 24 24\"")
+	s.NotContains(sprite, "<symbol id=\""+dataIn[1].Name+"\"")
+
+	resp, sprite = s.mustGetSprite(session, "", nil)
+	s.Equal(200, resp.statusCode)
+	s.Contains(sprite, "<symbol id=\""+dataIn[0].Name+"\"")
+	s.Contains(sprite, "<symbol id=\""+dataIn[1].Name+"\"")
+}
+
+func (s *spriteTestSuite) TestRevalidateSpriteByContentHashes() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+
+	resp, _ := s.mustGetSprite(session, "", nil)
+	s.Equal(200, resp.statusCode)
+	etag := resp.headers["Etag"][0]
+
+	resp, _ = s.mustGetSprite(session, "", map[string]string{"If-None-Match": etag})
+	s.Equal(304, resp.statusCode)
+
+	statusCode, err := session.deleteIcon(dataIn[1].Name)
+	s.NoError(err)
+	s.Equal(204, statusCode)
+
+	resp, _ = s.mustGetSprite(session, "", map[string]string{"If-None-Match": etag})
+	s.Equal(200, resp.statusCode)
+	s.NotEqual(etag, resp.headers["Etag"][0])
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pdkovacs/igo-repo/domain"
+	"github.com/pdkovacs/igo-repo/test/common"
+	"github.com/stretchr/testify/suite"
+)
+
+type svgOptimizationTestSuite struct {
+	iconTestSuite
+}
+
+func TestSVGOptimizationTestSuite(t *testing.T) {
+	suite.Run(t, &svgOptimizationTestSuite{})
+}
+
+func (s *svgOptimizationTestSuite) BeforeTest(suiteName string, testName string) {
+	serverConfig := common.CloneConfig(s.defaultConfig)
+	serverConfig.EnableBackdoors = true
+	serverConfig.OptimizeSVGIconfiles = testName != "TestKeepsSVGAsIsWithoutOptimization"
+	s.startTestServer(serverConfig)
+}
+
+const editorSVG = `<?xml version="1.0" encoding="UTF-8"?>
+<!-- Created with Inkscape -->
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:inkscape="http://www.inkscape.org/namespaces/inkscape" width="24" height="24" viewBox="0 0 24 24">
+  <metadata>generated</metadata>
+  <g>
+    <g inkscape:label="Layer 1">
+      <path d="M 2.0000012,2.0000012 L 22.000049,2 L 22,22 L 2,22 Z" inkscape:connector-curvature="0"/>
+    </g>
+  </g>
+</svg>
+`
+
+const optimizedEditorSVG = `<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24" viewBox="0 0 24 24"><path d="M2 2 22 2 22 22 2 22Z"/></svg>`
+
+func (s *svgOptimizationTestSuite) getIconfileVariant(session *apiTestSession, iconName string, descriptor domain.IconfileDescriptor, variant string) (int, []byte) {
+	content := []byte{}
+	resp, err := session.get(&testRequest{
+		path:          fmt.Sprintf("%s?variant=%s", getFilePath(iconName, descriptor), variant),
+		respBodyProto: &content,
+	})
+	s.NoError(err)
+	return resp.statusCode, content
+}
+
+func (s *svgOptimizationTestSuite) TestStoresOptimizedAndOriginalSVG() {
+	session := s.client.mustLoginSetAllPerms()
+	descriptor := domain.IconfileDescriptor{Format: "svg", Size: "24px"}
+
+	statusCode, _, err := session.createIcon("editor", []byte(editorSVG))
+	s.NoError(err)
+	s.Equal(201, statusCode)
+
+	iconfile, err := session.GetIconfile("editor", descriptor)
+	s.NoError(err)
+	s.Equal(optimizedEditorSVG, string(iconfile.Content))
+
+	statusCode, content := s.getIconfileVariant(session, "editor", descriptor, "optimized")
+	s.Equal(200, statusCode)
+	s.Equal(optimizedEditorSVG, string(content))
+
+	statusCode, content = s.getIconfileVariant(session, "editor", descriptor, "original")
+	s.Equal(200, statusCode)
+	s.Equal(editorSVG, string(content))
+
+	statusCode, _ = s.getIconfileVariant(session, "editor", descriptor, "minified")
+	s.Equal(400, statusCode)
+
+	s.assertEndState()
+}
+
+func (s *svgOptimizationTestSuite) TestOptimizesSVGAddedToIcon() {
+	session := s.client.mustLoginSetAllPerms()
+	descriptor := domain.IconfileDescriptor{Format: "svg", Size: "48px"}
+	largeEditorSVG := strings.Replace(editorSVG, `width="24" height="24"`, `width="48" height="48"`, 1)
+
+	statusCode, _, err := session.createIcon("editor", []byte(optimizedEditorSVG))
+	s.NoError(err)
+	s.Equal(201, statusCode)
+	statusCode, iconPath, err := session.addIconfile("editor", domain.Iconfile{Content: []byte(largeEditorSVG)})
+	s.NoError(err)
+	s.Equal(200, statusCode)
+	s.Equal(descriptor, iconPath.IconfileDescriptor)
+
+	iconfile, err := session.GetIconfile("editor", descriptor)
+	s.NoError(err)
+	s.NotContains(string(iconfile.Content), "inkscape")
+
+	statusCode, content := s.getIconfileVariant(session, "editor", descriptor, "original")
+	s.Equal(200, statusCode)
+	s.Equal(largeEditorSVG, string(content))
+
+	s.assertEndState()
+}
+
+func (s *svgOptimizationTestSuite) TestKeepsSVGAsIsWithoutOptimization() {
+	session := s.client.mustLoginSetAllPerms()
+	descriptor := domain.IconfileDescriptor{Format: "svg", Size: "24px"}
+
+	statusCode, _, err := session.createIcon("editor", []byte(editorSVG))
+	s.NoError(err)
+	s.Equal(201, statusCode)
+
+	iconfile, err := session.GetIconfile("editor", descriptor)
+	s.NoError(err)
+	s.Equal(editorSVG, string(iconfile.Content))
+
+	statusCode, content := s.getIconfileVariant(session, "editor", descriptor, "original")
+	s.Equal(200, statusCode)
+	s.Equal(editorSVG, string(content))
+
+	s.assertEndState()
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pdkovacs/igo-repo/api"
+	"github.com/pdkovacs/igo-repo/config"
+	"github.com/pdkovacs/igo-repo/domain"
+	"github.com/pdkovacs/igo-repo/test/api/testdata"
+	"github.com/pdkovacs/igo-repo/test/common"
+	"github.com/stretchr/testify/suite"
+)
+
+type svgRecoloringTestSuite struct {
+	iconTestSuite
+}
+
+func TestSVGRecoloringTestSuite(t *testing.T) {
+	suite.Run(t, &svgRecoloringTestSuite{})
+}
+
+func (s *svgRecoloringTestSuite) BeforeTest(suiteName string, testName string) {
+	serverConfig := common.CloneConfig(s.defaultConfig)
+	serverConfig.EnableBackdoors = true
+	serverConfig.SVGThemes = config.ThemePalettes{
+		"dark":  {Fill: "#eeeeee"},
+		"brand": {Fill: "#0055aa", Stroke: "#003366"},
+	}
+	s.startTestServer(serverConfig)
+}
+
+func (s *svgRecoloringTestSuite) getRecolored(session *apiTestSession, iconName string, descriptor domain.IconfileDescriptor, query string) (int, string) {
+	content := []byte{}
+	resp, err := session.get(&testRequest{
+		path:          fmt.Sprintf("%s?%s", getFilePath(iconName, descriptor), query),
+		respBodyProto: &content,
+	})
+	s.NoError(err)
+	return resp.statusCode, string(content)
+}
+
+func (s *svgRecoloringTestSuite) TestRecolorWithExplicitColors() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+	descriptor := dataIn[0].Iconfiles[0].IconfileDescriptor
+
+	statusCode, content := s.getRecolored(session, dataIn[0].Name, descriptor, "fill=%23FF0000")
+	s.Equal(200, statusCode)
+	s.Contains(content, `fill="#ff0000"`)
+
+	statusCode, again := s.getRecolored(session, dataIn[0].Name, descriptor, "fill=f00")
+	s.Equal(200, statusCode)
+	s.Equal(content, again)
+
+	original, err := session.GetIconfile(dataIn[0].Name, descriptor)
+	s.NoError(err)
+	s.Equal(dataIn[0].Iconfiles[0].Content, original.Content)
+}
+
+func (s *svgRecoloringTestSuite) TestRecolorStrokedIconWithTheme() {
+	session := s.client.mustLoginSetAllPerms()
+	stroked := `<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24" fill="none" stroke="currentColor" stroke-width="2"><circle cx="12" cy="12" r="10"/></svg>`
+	statusCode, _, err := session.createIcon("stroked", []byte(stroked))
+	s.NoError(err)
+	s.Equal(201, statusCode)
+	descriptor := domain.IconfileDescriptor{Format: "svg", Size: "24px"}
+
+	statusCode, content := s.getRecolored(session, "stroked", descriptor, "theme=brand")
+	s.Equal(200, statusCode)
+	s.Contains(content, `fill="none"`)
+	s.Contains(content, `stroke="#003366"`)
+
+	statusCode, content = s.getRecolored(session, "stroked", descriptor, "theme=brand&stroke=%23ffffff")
+	s.Equal(200, statusCode)
+	s.Contains(content, `stroke="#ffffff"`)
+
+	statusCode, content = s.getRecolored(session, "stroked", descriptor, "theme=dark")
+	s.Equal(200, statusCode)
+	s.Contains(content, `stroke="#eeeeee"`)
+}
+
+func (s *svgRecoloringTestSuite) TestRejectInvalidRecoloring() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+	svgDescriptor := dataIn[0].Iconfiles[0].IconfileDescriptor
+
+	for _, query := range []string{"fill=red", "stroke=%23ff00", "theme=no-such-theme"} {
+		statusCode, _ := s.getRecolored(session, dataIn[0].Name, svgDescriptor, query)
+		s.Equal(400, statusCode, query)
+	}
+
+	statusCode, _ := s.getRecolored(session, dataIn[0].Name, domain.IconfileDescriptor{Format: "png", Size: "24dp"}, "fill=%23ff0000")
+	s.Equal(400, statusCode)
+}
+
+func (s *svgRecoloringTestSuite) TestRejectRecoloringMulticolorIcon() {
+	session := s.client.mustLoginSetAllPerms()
+	for iconName, content := range map[string]string{
+		"two_colors": `<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24"><path fill="#ff0000" d="M0 0h8v8z"/><path d="M9 9h8v8z"/></svg>`,
+		"gradient": `<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24"><defs><linearGradient id="g"><stop stop-color="red"/>` +
+			`<stop offset="1" stop-color="blue"/></linearGradient></defs><path fill="url(#g)" d="M0 0h8v8z"/></svg>`,
+	} {
+		statusCode, _, err := session.createIcon(iconName, []byte(content))
+		s.NoError(err)
+		s.Equal(201, statusCode)
+
+		statusCode, body := s.getRecolored(session, iconName, domain.IconfileDescriptor{Format: "svg", Size: "24px"}, "fill=%23ff0000")
+		s.Equal(422, statusCode, iconName)
+		s.False(strings.Contains(body, "<svg"))
+	}
+}
+
+func (s *svgRecoloringTestSuite) TestNoImmutablePathForRecoloredIconfile() {
+	dataIn, _ := testdata.Get()
+	session := s.client.mustLoginSetAllPerms()
+	session.mustAddTestData(dataIn)
+	path := fmt.Sprintf("%s?%s", getFilePath(dataIn[0].Name, dataIn[0].Iconfiles[0].IconfileDescriptor), "fill=%23ff0000")
+
+	resp, err := session.get(&testRequest{
+		path:          path,
+		headers:       map[string]string{"Accept": "application/json"},
+		respBodyProto: &api.IconfileMetadata{},
+	})
+	s.NoError(err)
+	s.Equal(200, resp.statusCode)
+	metadata := resp.body.(*api.IconfileMetadata)
+	s.Equal(path, metadata.ImmutablePath)
+
+	resp, err = session.get(&testRequest{path: "/iconfile/" + metadata.ContentHash})
+	s.NoError(err)
+	s.Equal(404, resp.statusCode)
+}
@@ -0,0 +1,237 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/pdkovacs/igo-repo/api"
+	"github.com/pdkovacs/igo-repo/domain"
+	"github.com/pdkovacs/igo-repo/services"
+	"github.com/pdkovacs/igo-repo/test/common"
+	"github.com/stretchr/testify/suite"
+)
+
+type svgSanitizationTestSuite struct {
+	iconTestSuite
+}
+
+func TestSVGSanitizationTestSuite(t *testing.T) {
+	suite.Run(t, &svgSanitizationTestSuite{})
+}
+
+func (s *svgSanitizationTestSuite) BeforeTest(suiteName string, testName string) {
+	serverConfig := common.CloneConfig(s.defaultConfig)
+	serverConfig.EnableBackdoors = true
+	serverConfig.StrictSVGSanitization = strings.HasPrefix(testName, "TestStrict")
+	s.startTestServer(serverConfig)
+}
+
+const unsafeSVG = `<?xml version="1.0"?>
+<!DOCTYPE svg [ <!ENTITY width "24"> ]>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="24" height="24" onload="alert(1)">
+	<script>alert(2)</script>
+	<foreignObject width="24" height="24"><div xmlns="http://www.w3.org/1999/xhtml">hi</div></foreignObject>
+	<defs><path id="box" d="M2 2h20v20H2z"/></defs>
+	<a xlink:href="javascript:alert(3)"><use href="#box"/></a>
+	<set attributeName="onclick" to="alert(4)"/>
+</svg>`
+
+func removedKinds(report services.SanitizationReport) []string {
+	kinds := []string{}
+	for _, removal := range report.Removed {
+		kinds = append(kinds, removal.Kind)
+	}
+	return kinds
+}
+
+func (s *svgSanitizationTestSuite) TestCleansUnsafeSVG() {
+	session := s.client.mustLoginSetAllPerms()
+
+	statusCode, body, err := session.uploadIconfile("unsafe", []byte(unsafeSVG), false)
+	s.NoError(err)
+	s.Equal(201, statusCode)
+	var created api.CreatedIcon
+	s.NoError(json.Unmarshal(body, &created))
+	s.Equal("unsafe", created.Name)
+	s.NotNil(created.Sanitization)
+	s.Equal([]string{
+		services.RemovedEntityDeclaration,
+		services.RemovedEventHandler,
+		services.RemovedElement,
+		services.RemovedElement,
+		services.RemovedExternalReference,
+		services.RemovedElement,
+	}, removedKinds(*created.Sanitization))
+
+	iconfile, err := session.GetIconfile("unsafe", domain.IconfileDescriptor{Format: "svg", Size: "24px"})
+	s.NoError(err)
+	content := string(iconfile.Content)
+	for _, removed := range []string{"<!ENTITY", "onload", "<script", "<foreignObject", "javascript:", "<set"} {
+		s.NotContains(content, removed)
+	}
+	s.Contains(content, `<use href="#box">`)
+
+	s.assertEndState()
+}
+
+func (s *svgSanitizationTestSuite) TestKeepsSafeSVGAsIs() {
+	session := s.client.mustLoginSetAllPerms()
+	content := []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24"><path d="M2 2h20v20H2z"/></svg>`)
+
+	statusCode, body, err := session.uploadIconfile("safe", content, false)
+	s.NoError(err)
+	s.Equal(201, statusCode)
+	var created api.CreatedIcon
+	s.NoError(json.Unmarshal(body, &created))
+	s.Nil(created.Sanitization)
+
+	iconfile, err := session.GetIconfile("safe", domain.IconfileDescriptor{Format: "svg", Size: "24px"})
+	s.NoError(err)
+	s.Equal(content, iconfile.Content)
+
+	s.assertEndState()
+}
+
+func (s *svgSanitizationTestSuite) TestCleansUnsafeSVGAddedToIcon() {
+	session := s.client.mustLoginSetAllPerms()
+	statusCode, _, err := session.createIcon("unsafe", []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="18" height="18"></svg>`))
+	s.NoError(err)
+	s.Equal(201, statusCode)
+
+	statusCode, body, err := session.uploadIconfile("unsafe", []byte(unsafeSVG), true)
+	s.NoError(err)
+	s.Equal(200, statusCode)
+	var added api.AddedIconfile
+	s.NoError(json.Unmarshal(body, &added))
+	s.Equal(domain.IconfileDescriptor{Format: "svg", Size: "24px"}, added.IconfileDescriptor)
+	s.NotNil(added.Sanitization)
+	s.Equal(6, len(added.Sanitization.Removed))
+
+	s.assertEndState()
+}
+
+func (s *svgSanitizationTestSuite) TestStrictModeRejectsUnsafeSVG() {
+	session := s.client.mustLoginSetAllPerms()
+
+	statusCode, body, err := session.uploadIconfile("unsafe", []byte(unsafeSVG), false)
+	s.NoError(err)
+	s.Equal(400, statusCode)
+	var rejected api.UnsafeIconfileResponse
+	s.NoError(json.Unmarshal(body, &rejected))
+	s.Equal(6, len(rejected.Sanitization.Removed))
+	s.Contains(rejected.Error, services.ErrUnsafeSVG.Error())
+
+	icons, errDesc := session.describeAllIcons()
+	s.NoError(errDesc)
+	s.Equal(0, len(icons))
+}
+
+func (s *svgSanitizationTestSuite) TestStrictModeAcceptsSafeSVG() {
+	session := s.client.mustLoginSetAllPerms()
+
+	statusCode, body, err := session.uploadIconfile("safe", []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24"><use href="#a"/></svg>`), false)
+	s.NoError(err)
+	s.Equal(201, statusCode)
+	var created api.CreatedIcon
+	s.NoError(json.Unmarshal(body, &created))
+	s.Nil(created.Sanitization)
+
+	s.assertEndState()
+}
+
+func (s *svgSanitizationTestSuite) TestCleansReferencesWhateverPrefixXLinkIsBoundTo() {
+	session := s.client.mustLoginSetAllPerms()
+	content := `<svg xmlns="http://www.w3.org/2000/svg" xmlns:x="http://www.w3.org/1999/xlink" width="24" height="24">
+	<a x:href="javascript:alert(1)"><use x:href="#box"/></a>
+</svg>`
+
+	statusCode, body, err := session.uploadIconfile("prefixed", []byte(content), false)
+	s.NoError(err)
+	s.Equal(201, statusCode)
+	var created api.CreatedIcon
+	s.NoError(json.Unmarshal(body, &created))
+	s.NotNil(created.Sanitization)
+	s.Equal([]string{services.RemovedExternalReference}, removedKinds(*created.Sanitization))
+
+	iconfile, err := session.GetIconfile("prefixed", domain.IconfileDescriptor{Format: "svg", Size: "24px"})
+	s.NoError(err)
+	s.NotContains(string(iconfile.Content), "javascript:")
+	s.Contains(string(iconfile.Content), `<use x:href="#box">`)
+
+	s.assertEndState()
+}
+
+func (s *svgSanitizationTestSuite) TestCleansElementsOtherThanSafeSVGOnes() {
+	session := s.client.mustLoginSetAllPerms()
+	content := `<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24">
+	<html:iframe xmlns:html="http://www.w3.org/1999/xhtml" src="javascript:alert(1)"/>
+	<object data="javascript:alert(2)"/>
+	<embed src="https://evil.example.com/a.swf"/>
+	<SCRIPT>alert(3)</SCRIPT>
+	<g xmlns="http://www.w3.org/1999/xhtml"><iframe src="javascript:alert(4)"/></g>
+	<image SRC="https://evil.example.com/b.png" width="24" height="24"/>
+	<path d="M2 2h20v20H2z"/>
+</svg>`
+
+	statusCode, body, err := session.uploadIconfile("foreign", []byte(content), false)
+	s.NoError(err)
+	s.Equal(201, statusCode)
+	var created api.CreatedIcon
+	s.NoError(json.Unmarshal(body, &created))
+	s.NotNil(created.Sanitization)
+	s.Equal([]string{
+		services.RemovedElement,
+		services.RemovedElement,
+		services.RemovedElement,
+		services.RemovedElement,
+		services.RemovedElement,
+		services.RemovedExternalReference,
+	}, removedKinds(*created.Sanitization))
+
+	iconfile, err := session.GetIconfile("foreign", domain.IconfileDescriptor{Format: "svg", Size: "24px"})
+	s.NoError(err)
+	for _, removed := range []string{"iframe", "<object", "<embed", "SCRIPT", "javascript:", "evil.example.com"} {
+		s.NotContains(string(iconfile.Content), removed)
+	}
+	s.Contains(string(iconfile.Content), `<path d="M2 2h20v20H2z">`)
+
+	s.assertEndState()
+}
+
+func (s *svgSanitizationTestSuite) TestCleansExternalReferencesInStyles() {
+	session := s.client.mustLoginSetAllPerms()
+	content := `<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24">
+	<style>@\69mport "https://evil.example.com/a.css"; .a { fill: url(#gradient) } .b { fill: u\72l('https://evil.example.com/b.svg#p') }</style>
+	<rect style="fill: url(https://evil.example.com/c.svg#p)" width="24" height="24"/>
+	<circle fill="url(https://evil.example.com/d.svg#p)" r="12"/>
+</svg>`
+
+	statusCode, body, err := session.uploadIconfile("styled", []byte(content), false)
+	s.NoError(err)
+	s.Equal(201, statusCode)
+	var created api.CreatedIcon
+	s.NoError(json.Unmarshal(body, &created))
+	s.NotNil(created.Sanitization)
+	s.Equal(4, len(created.Sanitization.Removed))
+
+	iconfile, err := session.GetIconfile("styled", domain.IconfileDescriptor{Format: "svg", Size: "24px"})
+	s.NoError(err)
+	s.NotContains(string(iconfile.Content), "evil.example.com")
+	s.NotContains(string(iconfile.Content), "@import")
+	s.Contains(string(iconfile.Content), "url(#gradient)")
+
+	s.assertEndState()
+}
+
+func (s *svgSanitizationTestSuite) TestStrictModeRejectsCSSImport() {
+	session := s.client.mustLoginSetAllPerms()
+	content := `<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24"><style>@import url(https://evil.example.com/a.css);</style></svg>`
+
+	statusCode, body, err := session.uploadIconfile("styled", []byte(content), false)
+	s.NoError(err)
+	s.Equal(400, statusCode)
+	var rejected api.UnsafeIconfileResponse
+	s.NoError(json.Unmarshal(body, &rejected))
+	s.Equal(1, len(rejected.Sanitization.Removed))
+}
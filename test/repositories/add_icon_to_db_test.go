@@ -21,7 +21,7 @@ func TestAddIconToDBTestSuite(t *testing.T) {
 func (s *addIconToDBTestSuite) TestAddFirstIcon() {
 	var icon = itest_common.TestData[0]
 	fmt.Printf("Hello, First Icon %v\n", icon.Name)
-	err := s.dbRepo.CreateIcon(icon.Name, icon.Iconfiles[0], icon.ModifiedBy, nil)
+	err := s.dbRepo.CreateIcon(icon.Name, icon.Iconfiles[0], icon.ModifiedBy, "", nil)
 	s.NoError(err)
 	var iconDesc domain.IconDescriptor
 	iconDesc, err = s.dbRepo.DescribeIcon(icon.Name)
@@ -34,9 +34,9 @@ func (s *addIconToDBTestSuite) TestAddASecondIcon() {
 	var err error
 	var icon1 = itest_common.TestData[0]
 	var icon2 = itest_common.TestData[1]
-	err = s.dbRepo.CreateIcon(icon1.Name, icon1.Iconfiles[0], icon1.ModifiedBy, nil)
+	err = s.dbRepo.CreateIcon(icon1.Name, icon1.Iconfiles[0], icon1.ModifiedBy, "", nil)
 	s.NoError(err)
-	err = s.dbRepo.CreateIcon(icon2.Name, icon2.Iconfiles[1], icon2.ModifiedBy, nil)
+	err = s.dbRepo.CreateIcon(icon2.Name, icon2.Iconfiles[1], icon2.ModifiedBy, "", nil)
 	s.NoError(err)
 	var count int
 	count, err = s.getIconCount()
@@ -58,9 +58,9 @@ func (s *addIconToDBTestSuite) TestRollbackOnErrorInSideEffect() {
 
 	var icon1 = itest_common.TestData[0]
 	var icon2 = itest_common.TestData[1]
-	err = s.dbRepo.CreateIcon(icon1.Name, icon1.Iconfiles[0], icon1.ModifiedBy, nil)
+	err = s.dbRepo.CreateIcon(icon1.Name, icon1.Iconfiles[0], icon1.ModifiedBy, "", nil)
 	s.NoError(err)
-	err = s.dbRepo.CreateIcon(icon2.Name, icon2.Iconfiles[1], icon2.ModifiedBy, createSideEffect)
+	err = s.dbRepo.CreateIcon(icon2.Name, icon2.Iconfiles[1], icon2.ModifiedBy, "", createSideEffect)
 	s.True(errors.Is(err, sideEffectTestError))
 
 	count, err = s.getIconCount()
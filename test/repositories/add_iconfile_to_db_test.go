@@ -22,10 +22,10 @@ func (s *addIconfileToDBTestSuite) TestErrorOnDuplicateIconfile() {
 	var icon = itests_common.TestData[0]
 	var iconfile = icon.Iconfiles[0]
 
-	err = s.dbRepo.CreateIcon(icon.Name, iconfile, icon.ModifiedBy, nil)
+	err = s.dbRepo.CreateIcon(icon.Name, iconfile, icon.ModifiedBy, "", nil)
 	s.NoError(err)
 
-	err = s.dbRepo.AddIconfileToIcon(icon.Name, iconfile, icon.ModifiedBy, nil)
+	err = s.dbRepo.AddIconfileToIcon(icon.Name, iconfile, icon.ModifiedBy, nil, nil)
 	s.True(errors.Is(err, domain.ErrIconfileAlreadyExists))
 }
 
@@ -35,10 +35,10 @@ func (s *addIconfileToDBTestSuite) TestSecondIconfile() {
 	var iconfile1 = icon.Iconfiles[0]
 	var iconfile2 = icon.Iconfiles[1]
 
-	err = s.dbRepo.CreateIcon(icon.Name, iconfile1, icon.ModifiedBy, nil)
+	err = s.dbRepo.CreateIcon(icon.Name, iconfile1, icon.ModifiedBy, "", nil)
 	s.NoError(err)
 
-	err = s.dbRepo.AddIconfileToIcon(icon.Name, iconfile2, icon.ModifiedBy, nil)
+	err = s.dbRepo.AddIconfileToIcon(icon.Name, iconfile2, icon.ModifiedBy, nil, nil)
 	s.NoError(err)
 
 	var iconDesc domain.IconDescriptor
@@ -55,10 +55,10 @@ func (s *addIconfileToDBTestSuite) TestAddSecondIconfileBySecondUser() {
 
 	var secondUser = "sedat"
 
-	err = s.dbRepo.CreateIcon(icon.Name, iconfile1, icon.ModifiedBy, nil)
+	err = s.dbRepo.CreateIcon(icon.Name, iconfile1, icon.ModifiedBy, "", nil)
 	s.NoError(err)
 
-	err = s.dbRepo.AddIconfileToIcon(icon.Name, iconfile2, secondUser, nil)
+	err = s.dbRepo.AddIconfileToIcon(icon.Name, iconfile2, secondUser, nil, nil)
 	s.NoError(err)
 
 	var iconDesc domain.IconDescriptor
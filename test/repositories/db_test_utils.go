@@ -28,7 +28,7 @@ func DeleteDBData(db *sql.DB) error {
 	}
 	defer tx.Rollback()
 
-	tables := []string{"icon", "icon_file", "tag", "icon_to_tags"}
+	tables := []string{"icon", "icon_file", "tag", "icon_to_tags", "access_token", "http_session", "group_membership", "icon_acl", "icon_codepoint"}
 	for _, table := range tables {
 		_, err = tx.Exec("DELETE FROM " + table)
 		if err != nil {
@@ -20,12 +20,12 @@ func (s *deleteIconFromDBTestSuite) TestDeleteAllAssociatedEntries() {
 
 	icon := itests_common.TestData[0]
 
-	err = s.dbRepo.CreateIcon(icon.Name, icon.Iconfiles[0], icon.ModifiedBy, nil)
+	err = s.dbRepo.CreateIcon(icon.Name, icon.Iconfiles[0], icon.ModifiedBy, "", nil)
 	s.NoError(err)
 	err = s.dbRepo.AddTag(icon.Name, icon.Tags[0], icon.ModifiedBy)
 	s.NoError(err)
 
-	err = s.dbRepo.DeleteIcon(icon.Name, icon.ModifiedBy, nil)
+	err = s.dbRepo.DeleteIcon(icon.Name, icon.ModifiedBy, nil, nil)
 	s.NoError(err)
 
 	var rowCount int
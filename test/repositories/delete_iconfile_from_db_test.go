@@ -22,12 +22,12 @@ func (s *deleteIconfileFromDBTestSuite) TestDeleteTheOnlyIconfile() {
 	icon := itests_common.TestData[0]
 	iconfile := icon.Iconfiles[0]
 
-	err = s.dbRepo.CreateIcon(icon.Name, iconfile, icon.ModifiedBy, nil)
+	err = s.dbRepo.CreateIcon(icon.Name, iconfile, icon.ModifiedBy, "", nil)
 	s.NoError(err)
 	err = s.dbRepo.AddTag(icon.Name, icon.ModifiedBy, icon.Tags[0])
 	s.NoError(err)
 
-	err = s.dbRepo.DeleteIconfile(icon.Name, iconfile.IconfileDescriptor, icon.ModifiedBy, nil)
+	err = s.dbRepo.DeleteIconfile(icon.Name, iconfile.IconfileDescriptor, icon.ModifiedBy, nil, nil)
 	s.NoError(err)
 
 	_, err = s.dbRepo.DescribeIcon(icon.Name)
@@ -52,14 +52,14 @@ func (s *deleteIconfileFromDBTestSuite) TestDeleteNextToLastIconfile() {
 	iconfile1 := icon.Iconfiles[0]
 	iconfile2 := icon.Iconfiles[1]
 
-	err = s.dbRepo.CreateIcon(icon.Name, iconfile1, icon.ModifiedBy, nil)
+	err = s.dbRepo.CreateIcon(icon.Name, iconfile1, icon.ModifiedBy, "", nil)
 	s.NoError(err)
 	err = s.dbRepo.AddTag(icon.Name, icon.Tags[0], icon.ModifiedBy)
 	s.NoError(err)
-	err = s.dbRepo.AddIconfileToIcon(icon.Name, iconfile2, icon.ModifiedBy, nil)
+	err = s.dbRepo.AddIconfileToIcon(icon.Name, iconfile2, icon.ModifiedBy, nil, nil)
 	s.NoError(err)
 
-	err = s.dbRepo.DeleteIconfile(icon.Name, iconfile1.IconfileDescriptor, icon.ModifiedBy, nil)
+	err = s.dbRepo.DeleteIconfile(icon.Name, iconfile1.IconfileDescriptor, icon.ModifiedBy, nil, nil)
 	s.NoError(err)
 
 	var iconDesc domain.IconDescriptor
@@ -78,14 +78,14 @@ func (s *deleteIconfileFromDBTestSuite) TestDeleteNextToLastIconfileBySecondUser
 	iconfile1 := icon.Iconfiles[0]
 	iconfile2 := icon.Iconfiles[1]
 
-	err = s.dbRepo.CreateIcon(icon.Name, iconfile1, icon.ModifiedBy, nil)
+	err = s.dbRepo.CreateIcon(icon.Name, iconfile1, icon.ModifiedBy, "", nil)
 	s.NoError(err)
 	err = s.dbRepo.AddTag(icon.Name, icon.Tags[0], icon.ModifiedBy)
 	s.NoError(err)
-	err = s.dbRepo.AddIconfileToIcon(icon.Name, iconfile2, icon.ModifiedBy, nil)
+	err = s.dbRepo.AddIconfileToIcon(icon.Name, iconfile2, icon.ModifiedBy, nil, nil)
 	s.NoError(err)
 
-	err = s.dbRepo.DeleteIconfile(icon.Name, iconfile1.IconfileDescriptor, secondUser, nil)
+	err = s.dbRepo.DeleteIconfile(icon.Name, iconfile1.IconfileDescriptor, secondUser, nil, nil)
 	s.NoError(err)
 
 	clone := itests_common.CloneIcon(icon)
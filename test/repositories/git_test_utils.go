@@ -69,6 +69,14 @@ func (repo *GitTestRepo) GetCurrentCommit() (string, error) {
 	return strings.TrimSpace(out), nil
 }
 
+func (repo *GitTestRepo) GetCommitCount() (int, error) {
+	out, err := repo.ExecuteGitCommand([]string{"rev-list", "--count", "HEAD"})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count git commits: %w", err)
+	}
+	return strconv.Atoi(strings.TrimSpace(out))
+}
+
 func (repo *GitTestRepo) AssertGitCleanStatus(s *suite.Suite) {
 	status, err := repo.getGitStatus()
 	s.NoError(err)